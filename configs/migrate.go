@@ -1,19 +1,186 @@
 package configs
 
 import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/modules/account"
 	"github.com/OctavianoRyan25/be-agriculture/modules/admin"
 	"github.com/OctavianoRyan25/be-agriculture/modules/article"
+	"github.com/OctavianoRyan25/be-agriculture/modules/audit"
+	"github.com/OctavianoRyan25/be-agriculture/modules/device"
 	"github.com/OctavianoRyan25/be-agriculture/modules/fertilizer"
+	"github.com/OctavianoRyan25/be-agriculture/modules/lockout"
 	"github.com/OctavianoRyan25/be-agriculture/modules/notification"
 	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+	"github.com/OctavianoRyan25/be-agriculture/modules/session"
 	"github.com/OctavianoRyan25/be-agriculture/modules/user"
 	wateringhistory "github.com/OctavianoRyan25/be-agriculture/modules/watering_history"
+	"github.com/OctavianoRyan25/be-agriculture/utils/rrule"
 	"gorm.io/gorm"
 )
 
 func AutoMigrate(db *gorm.DB) error {
-	if err := db.AutoMigrate(&user.User{}, &admin.Admin{}, &plant.PlantCategory{}, &plant.Plant{}, &plant.PlantImage{}, &plant.PlantInstruction{}, &plant.PlantFAQ{}, &plant.PlantReminder{}, &plant.PlantCharacteristic{}, &plant.UserPlant{}, &plant.PlantInstructionCategory{}, &plant.PlantProgress{}, &notification.Notification{}, &notification.CustomizeWateringReminder{}, &wateringhistory.WateringHistory{}, &plant.UserPlantHistory{}, &fertilizer.Fertilizer{}, &plant.PlantEarliestWatering{}, &article.Article{}); err != nil {
+	if err := db.AutoMigrate(&user.User{}, &user.PasswordReset{}, &user.Identity{}, &user.OAuthState{}, &user.OTPSend{}, &session.Session{}, &device.Device{}, &lockout.LoginFailure{}, &account.DataExport{}, &account.DeletionRequest{}, &admin.Admin{}, &admin.AdminInvitation{}, &audit.AuditLog{}, &plant.PlantCategory{}, &plant.Plant{}, &plant.PlantImage{}, &plant.PlantInstruction{}, &plant.PlantFAQ{}, &plant.PlantReminder{}, &plant.PlantReminderTime{}, &plant.PlantCharacteristic{}, &plant.UserPlant{}, &plant.PlantInstructionCategory{}, &plant.PlantProgress{}, &notification.Notification{}, &notification.CustomizeWateringReminder{}, &notification.ReminderJob{}, &notification.ReminderPlan{}, &notification.NotificationDelivery{}, &notification.DeadLetter{}, &notification.NotificationPreference{}, &notification.QuietHours{}, &notification.ReminderSkip{}, &wateringhistory.WateringHistory{}, &wateringhistory.WateringSkip{}, &plant.UserPlantHistory{}, &fertilizer.Fertilizer{}, &plant.PlantEarliestWatering{}, &article.Article{}); err != nil {
+		return err
+	}
+	if err := migrateWateringTimes(db); err != nil {
 		return err
 	}
+	if err := migrateReminderRules(db); err != nil {
+		return err
+	}
+	if err := migrateFCMTokens(db); err != nil {
+		return err
+	}
+	return migrateWateringLog(db)
+}
+
+// migrateWateringTimes splits the free-text watering_time of every plant
+// reminder, like "07:00, 17:00", into plant_reminder_times rows, normalizes
+// the period in each and drops the old column. Text with times that cannot
+// be read is kept in legacy_watering_time, so the schedule can be fixed by
+// hand instead of being lost.
+func migrateWateringTimes(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&plant.PlantReminder{}, "watering_time") {
+		return nil
+	}
+
+	var reminders []struct {
+		ID           int
+		Each         string
+		WateringTime string
+	}
+	err := db.Table("plant_reminders").Select("id, `each`, watering_time").Find(&reminders).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, reminder := range reminders {
+			wateringTimes, invalid := plant.ParseWateringTimes([]string{reminder.WateringTime})
+			legacy := ""
+			if len(invalid) > 0 {
+				log.Printf("plant reminder %d: keeping invalid watering times %s in legacy_watering_time\n", reminder.ID, strings.Join(invalid, ", "))
+				legacy = reminder.WateringTime
+			}
+
+			err := tx.Where("plant_reminder_id = ?", reminder.ID).Delete(&plant.PlantReminderTime{}).Error
+			if err != nil {
+				return err
+			}
+			for _, wateringTime := range wateringTimes {
+				err := tx.Create(&plant.PlantReminderTime{PlantReminderID: reminder.ID, Time: wateringTime}).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			err = tx.Model(&plant.PlantReminder{}).Where("id = ?", reminder.ID).Updates(map[string]interface{}{
+				"each":                 plant.ParseWateringPeriod(reminder.Each),
+				"watering_frequency":   len(wateringTimes),
+				"legacy_watering_time": legacy,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return db.Migrator().DropColumn(&plant.PlantReminder{}, "watering_time")
+}
+
+// migrateReminderRules turns the time, type and recurring flag of customized
+// watering reminders into a recurrence rule starting on the day the reminder
+// was created, then drops the old columns.
+func migrateReminderRules(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&notification.CustomizeWateringReminder{}, "type") {
+		return nil
+	}
+
+	var reminders []struct {
+		ID        int
+		Time      string
+		Recurring bool
+		Type      string
+		CreatedAt time.Time
+	}
+	err := db.Table("customize_watering_reminders").Select("id, `time`, recurring, `type`, created_at").Find(&reminders).Error
+	if err != nil {
+		return err
+	}
+
+	location, _ := time.LoadLocation("Asia/Jakarta")
+	now := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, reminder := range reminders {
+			created := reminder.CreatedAt.In(location)
+			startAt, err := time.ParseInLocation("2006-01-02 15:04", created.Format("2006-01-02")+" "+strings.TrimSpace(reminder.Time), location)
+			if err != nil {
+				log.Printf("customized reminder %d: invalid time %q, disabling it\n", reminder.ID, reminder.Time)
+				startAt = created
+			}
+			if startAt.Before(created) {
+				// Reminders used to fire at the next matching time.
+				startAt = startAt.AddDate(0, 0, 1)
+			}
+
+			ruleText := notification.LegacyRRule(reminder.Type, reminder.Recurring)
+			rule, _ := rrule.Parse(ruleText)
+			var nextRunAt *time.Time
+			if next, ok := rule.Next(startAt, now); ok && err == nil {
+				nextRunAt = &next
+			}
+
+			err = tx.Model(&notification.CustomizeWateringReminder{}).Where("id = ?", reminder.ID).Updates(map[string]interface{}{
+				"rrule":       ruleText,
+				"start_at":    startAt,
+				"next_run_at": nextRunAt,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, column := range []string{"time", "recurring", "type"} {
+		if err := db.Migrator().DropColumn(&notification.CustomizeWateringReminder{}, column); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// migrateFCMTokens moves the FCM token users had before the device registry
+// into the devices table and drops the old column.
+func migrateFCMTokens(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&user.User{}, "fcm_token") {
+		return nil
+	}
+
+	err := db.Exec(`INSERT IGNORE INTO devices (user_id, token, platform, app_version, last_seen_at, created_at, updated_at)
+		SELECT id, fcm_token, '', '', updated_at, NOW(), NOW() FROM users WHERE fcm_token <> ''`).Error
+	if err != nil {
+		return err
+	}
+	return db.Migrator().DropColumn(&user.User{}, "fcm_token")
+}
+
+// migrateWateringLog fills in the watering time and the plant of the user of
+// waterings logged before the log had them: the time the entry was created
+// and the first plant the user added of the kind. Only those entries have no
+// watering time.
+func migrateWateringLog(db *gorm.DB) error {
+	return db.Exec(`UPDATE watering_histories SET watered_at = created_at, user_plant_id = (
+			SELECT MIN(user_plants.id) FROM user_plants
+			WHERE user_plants.user_id = watering_histories.user_id AND user_plants.plant_id = watering_histories.plant_id)
+		WHERE watered_at IS NULL`).Error
+}
@@ -7,4 +7,57 @@ var (
 	ErrEmailAlreadyExist    = "Email already exist"
 	ErrEmailNotValidatedYet = "Email not validated yet"
 	ErrInvalidOTP           = "Invalid OTP"
+	ErrInvalidResetToken    = "Invalid or expired reset token"
+	ErrTokenRevoked         = "Token revoked"
+	ErrInvalidRefreshToken  = "Invalid or expired refresh token"
+	ErrForbidden            = "Forbidden"
+	ErrInvalidOAuthState    = "Invalid or expired OAuth state"
+	ErrEmailNotVerified     = "Email is not verified by the provider"
+	ErrIdentityLinked       = "Account is already linked to another user"
+	ErrIdentityNotFound     = "Account is not linked"
+	ErrLastLoginMethod      = "Set a password before unlinking the last login method"
+	ErrDeviceNotFound       = "Device not found"
+	ErrOTPExpired           = "OTP expired"
+	ErrOTPLocked            = "Too many wrong OTP attempts, request a new OTP"
+	ErrOTPResendCooldown    = "Please wait before requesting another OTP"
+	ErrOTPResendLimit       = "Too many OTP requests, try again later"
+	ErrEmailAlreadyVerified = "Email already verified"
+	ErrInvalidInvitation    = "Invalid or expired invitation"
+	ErrInvalidRole          = "Invalid role"
+	ErrAccountDeactivated   = "Account is deactivated"
+	ErrAdminNotFound        = "Admin not found"
+	ErrCannotDeactivateSelf = "You cannot deactivate your own account"
+	ErrInvalidCredentials   = "Invalid email or password"
+	ErrTooManyLoginAttempts = "Too many login attempts, try again later"
+	ErrAccountLocked        = "Account temporarily locked after too many failed logins"
+	ErrInvalidPassword      = "Current password is incorrect"
+	ErrNoPendingEmail       = "No email change is pending"
+	ErrInvalidImage         = "Image must be a JPEG, PNG or WebP file of at most 2MB"
+
+	ErrExportInProgress         = "A data export is already in progress"
+	ErrExportCooldown           = "Only one data export can be requested per day"
+	ErrExportNotFound           = "Data export not found"
+	ErrExportNotReady           = "Data export is not ready yet"
+	ErrDeletionAlreadyRequested = "Account deletion is already scheduled"
+	ErrDeletionNotFound         = "No account deletion is scheduled"
+
+	ErrReminderNotFound  = "Reminder not found"
+	ErrInvalidRecurrence = "Invalid recurrence rule"
+	ErrInvalidReminder   = "End date must not be before the start date"
+	ErrReminderEnded     = "Reminder has no upcoming occurrence"
+	ErrPlantNotFound     = "Plant not found"
+
+	ErrInvalidTimezone = "Unknown time zone"
+	ErrUnknownLocation = "Time zone cannot be determined for this location"
+
+	ErrInvalidQuietHours = "Quiet hours must not start and end at the same time or cover the whole day"
+
+	ErrNotificationNotFound = "Notification not found"
+	ErrInvalidAction        = "Unknown notification action"
+	ErrNotActionable        = "This notification has no actions"
+
+	ErrWateringNotFound  = "Watering history not found"
+	ErrWateredInFuture   = "Watering time must not be in the future"
+	ErrInvalidDateRange  = "End date must not be before the start date"
+	ErrPhotoUploadFailed = "Failed to upload the photo"
 )
@@ -8,4 +8,57 @@ var (
 	ErrCodeEmailAlreadyExist    = 409
 	ErrCodeEmailNotValidatedYet = 400
 	ErrCodeInvalidOTP           = 400
+	ErrCodeInvalidResetToken    = 400
+	ErrCodeTokenRevoked         = 401
+	ErrCodeInvalidRefreshToken  = 401
+	ErrCodeForbidden            = 403
+	ErrCodeInvalidOAuthState    = 400
+	ErrCodeEmailNotVerified     = 400
+	ErrCodeIdentityLinked       = 409
+	ErrCodeIdentityNotFound     = 404
+	ErrCodeLastLoginMethod      = 400
+	ErrCodeDeviceNotFound       = 404
+	ErrCodeOTPExpired           = 410
+	ErrCodeOTPLocked            = 423
+	ErrCodeOTPResendCooldown    = 429
+	ErrCodeOTPResendLimit       = 429
+	ErrCodeEmailAlreadyVerified = 409
+	ErrCodeInvalidInvitation    = 400
+	ErrCodeInvalidRole          = 400
+	ErrCodeAccountDeactivated   = 403
+	ErrCodeAdminNotFound        = 404
+	ErrCodeCannotDeactivateSelf = 400
+	ErrCodeInvalidCredentials   = 400
+	ErrCodeTooManyLoginAttempts = 429
+	ErrCodeAccountLocked        = 423
+	ErrCodeInvalidPassword      = 400
+	ErrCodeNoPendingEmail       = 400
+	ErrCodeInvalidImage         = 400
+
+	ErrCodeExportInProgress         = 409
+	ErrCodeExportCooldown           = 429
+	ErrCodeExportNotFound           = 404
+	ErrCodeExportNotReady           = 409
+	ErrCodeDeletionAlreadyRequested = 409
+	ErrCodeDeletionNotFound         = 404
+
+	ErrCodeReminderNotFound  = 404
+	ErrCodeInvalidRecurrence = 400
+	ErrCodeInvalidReminder   = 400
+	ErrCodeReminderEnded     = 409
+	ErrCodePlantNotFound     = 404
+
+	ErrCodeInvalidTimezone = 400
+	ErrCodeUnknownLocation = 422
+
+	ErrCodeInvalidQuietHours = 400
+
+	ErrCodeNotificationNotFound = 404
+	ErrCodeInvalidAction        = 400
+	ErrCodeNotActionable        = 409
+
+	ErrCodeWateringNotFound  = 404
+	ErrCodeWateredInFuture   = 400
+	ErrCodeInvalidDateRange  = 400
+	ErrCodePhotoUploadFailed = 502
 )
@@ -5,6 +5,7 @@ import (
 
 	"strconv"
 
+	"github.com/OctavianoRyan25/be-agriculture/modules/audit"
 	"github.com/OctavianoRyan25/be-agriculture/modules/fertilizer"
 	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
 	"github.com/cloudinary/cloudinary-go/v2"
@@ -15,10 +16,11 @@ import (
 type FertilizerHandler struct {
 	service    fertilizer.FertilizerService
 	cloudinary *cloudinary.Cloudinary
+	audit      audit.UseCase
 }
 
-func NewFertilizerHandler(service fertilizer.FertilizerService, cloudinary *cloudinary.Cloudinary) *FertilizerHandler {
-	return &FertilizerHandler{service, cloudinary}
+func NewFertilizerHandler(service fertilizer.FertilizerService, cloudinary *cloudinary.Cloudinary, audit audit.UseCase) *FertilizerHandler {
+	return &FertilizerHandler{service, cloudinary, audit}
 }
 
 func (h *FertilizerHandler) GetFertilizer(c echo.Context) error {
@@ -60,12 +62,6 @@ func (h *FertilizerHandler) CreateFertilizer(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can create", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
-	}
-
 	validate := validator.New()
 	if err := validate.Struct(input); err != nil {
 		errors := helper.FormatValidationError(err)
@@ -73,13 +69,14 @@ func (h *FertilizerHandler) CreateFertilizer(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	//  fertilizer, err = h.service.CreateFertilizer(input)
-	// if err != nil {
-	// 	response := helper.APIResponse("Failed to create fertilizer", http.StatusInternalServerError, "error", nil)
-	// 	return c.JSON(http.StatusInternalServerError, response)
-	// }
+	created, err := h.service.CreateFertilizer(input)
+	if err != nil {
+		response := helper.APIResponse("Failed to create fertilizer", http.StatusInternalServerError, "error", nil)
+		return c.JSON(http.StatusInternalServerError, response)
+	}
+	audit.Record(c, h.audit, audit.ActionCreate, "fertilizer", created.Id, nil, created)
 
-	response := helper.APIResponse("Fertilizer created successfully", http.StatusCreated, "success", nil)
+	response := helper.APIResponse("Fertilizer created successfully", http.StatusCreated, "success", created)
 	return c.JSON(http.StatusCreated, response)
 }
 
@@ -92,10 +89,10 @@ func (h *FertilizerHandler) UpdateFertilizer(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can update", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
+	before, err := h.service.GetFertilizerByID(id)
+	if err != nil {
+		response := helper.APIResponse("Fertilizer not found", http.StatusNotFound, "error", nil)
+		return c.JSON(http.StatusNotFound, response)
 	}
 
 	var input fertilizer.FertilizerInput
@@ -117,6 +114,7 @@ func (h *FertilizerHandler) UpdateFertilizer(c echo.Context) error {
 		response := helper.APIResponse("Failed to update fertilizer", http.StatusInternalServerError, "error", nil)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
+	audit.Record(c, h.audit, audit.ActionUpdate, "fertilizer", id, before, category)
 
 	response := helper.APIResponse("Fertilizer updated successfully", http.StatusOK, "success", category)
 	return c.JSON(http.StatusOK, response)
@@ -131,12 +129,6 @@ func (h *FertilizerHandler) DeleteFertilizer(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can delete", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
-	}
-
 	category, err := h.service.GetFertilizerByID(id)
 	if err != nil {
 		response := helper.APIResponse("Fertilizer not found", http.StatusNotFound, "error", nil)
@@ -148,6 +140,7 @@ func (h *FertilizerHandler) DeleteFertilizer(c echo.Context) error {
 		response := helper.APIResponse("Failed to delete fertilizer", http.StatusInternalServerError, "error", nil)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
+	audit.Record(c, h.audit, audit.ActionDelete, "fertilizer", id, category, nil)
 
 	response := helper.APIResponse("Fertilizer deleted successfully", http.StatusOK, "success", category)
 	return c.JSON(http.StatusOK, response)
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/OctavianoRyan25/be-agriculture/modules/audit"
 	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
 	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
 	"github.com/cloudinary/cloudinary-go/v2"
@@ -16,10 +17,11 @@ import (
 type PlantInstructionCategoryHandler struct {
 	service plant.PlantInstructionCategoryService
 	cloudinary  *cloudinary.Cloudinary
+	audit   audit.UseCase
 }
 
-func NewPlantInstructionCategoryHandler(service plant.PlantInstructionCategoryService, cloudinary  *cloudinary.Cloudinary) *PlantInstructionCategoryHandler {
-	return &PlantInstructionCategoryHandler{service , cloudinary}
+func NewPlantInstructionCategoryHandler(service plant.PlantInstructionCategoryService, cloudinary  *cloudinary.Cloudinary, audit audit.UseCase) *PlantInstructionCategoryHandler {
+	return &PlantInstructionCategoryHandler{service , cloudinary, audit}
 }
 
 func (h *PlantInstructionCategoryHandler) GetAll(c echo.Context) error {
@@ -75,12 +77,6 @@ func (h *PlantInstructionCategoryHandler) Create(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can create", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
-	}
-
 	validate := validator.New()
 	if err := validate.Struct(input); err != nil {
 		errors := helper.FormatValidationError(err)
@@ -120,11 +116,12 @@ func (h *PlantInstructionCategoryHandler) Create(c echo.Context) error {
 
 	imageURL := uploadResult.SecureURL
 
-	_, err = h.service.Create(input, imageURL)
+	category, err := h.service.Create(input, imageURL)
 	if err != nil {
 		response := helper.APIResponse("Failed to create instruction category", http.StatusInternalServerError, "error", nil)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
+	audit.Record(c, h.audit, audit.ActionCreate, "instruction_category", category.ID, nil, category)
 
 	response := helper.APIResponse("Instruction category created successfully", http.StatusCreated, "success", nil)
 	return c.JSON(http.StatusCreated, response)
@@ -138,10 +135,10 @@ func (h *PlantInstructionCategoryHandler) Update(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can update", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
+	before, err := h.service.FindByID(id)
+	if err != nil {
+		response := helper.APIResponse("Instruction category not found", http.StatusNotFound, "error", nil)
+		return c.JSON(http.StatusNotFound, response)
 	}
 
 	var input plant.PlantInstructionCategoryInput
@@ -199,6 +196,7 @@ func (h *PlantInstructionCategoryHandler) Update(c echo.Context) error {
 		response := helper.APIResponse("Failed to update plant category", http.StatusInternalServerError, "error", nil)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
+	audit.Record(c, h.audit, audit.ActionUpdate, "instruction_category", id, before, category)
 
 	response := helper.APIResponse("Instruction category updated successfully", http.StatusOK, "success", category)
 	return c.JSON(http.StatusOK, response)
@@ -213,12 +211,6 @@ func (h *PlantInstructionCategoryHandler) Delete(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can delete", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
-	}
-
 	category, err := h.service.FindByID(id)
 	if err != nil {
 		response := helper.APIResponse("Instruction category not found", http.StatusNotFound, "error", nil)
@@ -230,6 +222,7 @@ func (h *PlantInstructionCategoryHandler) Delete(c echo.Context) error {
 		response := helper.APIResponse("Failed to delete instruction category", http.StatusInternalServerError, "error", nil)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
+	audit.Record(c, h.audit, audit.ActionDelete, "instruction_category", id, category, nil)
 
 	response := helper.APIResponse("Instruction category deleted successfully", http.StatusOK, "success", category)
 	return c.JSON(http.StatusOK, response)
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"github.com/labstack/echo/v4"
+)
+
+type JWKSHandler struct {
+	keys *helper.KeyRing
+}
+
+func NewJWKSHandler(keys *helper.KeyRing) *JWKSHandler {
+	return &JWKSHandler{keys}
+}
+
+// GetJWKS serves the public signing keys in the standard JWK Set format, so
+// it is not wrapped in helper.APIResponse.
+func (h *JWKSHandler) GetJWKS(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/OctavianoRyan25/be-agriculture/modules/audit"
 	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
 	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
 	"github.com/cloudinary/cloudinary-go/v2"
@@ -19,10 +20,11 @@ import (
 type PlantHandler struct {
 	service    plant.PlantService
 	cloudinary *cloudinary.Cloudinary
+	audit      audit.UseCase
 }
 
-func NewPlantHandler(service plant.PlantService, cloudinary *cloudinary.Cloudinary) *PlantHandler {
-	return &PlantHandler{service, cloudinary}
+func NewPlantHandler(service plant.PlantService, cloudinary *cloudinary.Cloudinary, audit audit.UseCase) *PlantHandler {
+	return &PlantHandler{service, cloudinary, audit}
 }
 
 func (h *PlantHandler) GetRecommendations(c echo.Context) error {
@@ -152,12 +154,6 @@ func (h *PlantHandler) Create(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can create plant", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
-	}
-
 	var input plant.CreatePlantInput
 
 	input.Name = form.Value["name"][0]
@@ -180,14 +176,10 @@ func (h *PlantHandler) Create(c echo.Context) error {
 	}
 
 	// Parsing watering schedule
-	input.WateringSchedule = plant.CreateWateringScheduleInput{
-		WateringFrequency:    atoi(form.Value["watering_schedule.watering_frequency"][0]),
-		Each:                 form.Value["watering_schedule.each"][0],
-		WateringAmount:       atoi(form.Value["watering_schedule.watering_amount"][0]),
-		Unit:                 form.Value["watering_schedule.unit"][0],
-		WateringTime:         form.Value["watering_schedule.watering_time"][0],
-		WeatherCondition:     form.Value["watering_schedule.weather_condition"][0],
-		ConditionDescription: form.Value["watering_schedule.condition_description"][0],
+	input.WateringSchedule, err = CreateWateringScheduleInput(form.Value)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "error", nil)
+		return c.JSON(http.StatusBadRequest, response)
 	}
 
 	// Parsing plant instructions with image upload
@@ -264,6 +256,7 @@ func (h *PlantHandler) Create(c echo.Context) error {
 		response := helper.APIResponse("Failed to create plant", http.StatusInternalServerError, "error", nil)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
+	audit.Record(c, h.audit, audit.ActionCreate, "plant", createdPlant.ID, nil, createdPlant)
 
 	response := helper.APIResponse("Plant created successfully", http.StatusCreated, "success", createdPlant)
 	return c.JSON(http.StatusCreated, response)
@@ -281,10 +274,10 @@ func (h *PlantHandler) Update(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can update plant", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
+	before, err := h.service.FindByID(id)
+	if err != nil {
+		response := helper.APIResponse("Plant not found", http.StatusNotFound, "error", nil)
+		return c.JSON(http.StatusNotFound, response)
 	}
 
 	form, err := c.MultipartForm()
@@ -315,14 +308,10 @@ func (h *PlantHandler) Update(c echo.Context) error {
 	}
 
 	// Parsing watering schedule
-	input.WateringSchedule = plant.CreateWateringScheduleInput{
-		WateringFrequency:    atoi(form.Value["watering_schedule.watering_frequency"][0]),
-		Each:                 form.Value["watering_schedule.each"][0],
-		WateringAmount:       atoi(form.Value["watering_schedule.watering_amount"][0]),
-		Unit:                 form.Value["watering_schedule.unit"][0],
-		WateringTime:         form.Value["watering_schedule.watering_time"][0],
-		WeatherCondition:     form.Value["watering_schedule.weather_condition"][0],
-		ConditionDescription: form.Value["watering_schedule.condition_description"][0],
+	input.WateringSchedule, err = CreateWateringScheduleInput(form.Value)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "error", nil)
+		return c.JSON(http.StatusBadRequest, response)
 	}
 
 	// Parsing plant instructions with image upload
@@ -400,6 +389,7 @@ func (h *PlantHandler) Update(c echo.Context) error {
 		response := helper.APIResponse("Failed to update plant", http.StatusInternalServerError, "error", nil)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
+	audit.Record(c, h.audit, audit.ActionUpdate, "plant", id, before, updatedPlant)
 
 	response := helper.APIResponse("Plant updated successfully", http.StatusOK, "success", updatedPlant)
 	return c.JSON(http.StatusOK, response)
@@ -412,17 +402,12 @@ func (h *PlantHandler) Delete(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can update plant", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
-	}
-
 	deletedPlant, err := h.service.DeletePlant(id)
 	if err != nil {
 		response := helper.APIResponse("Failed to delete plant", http.StatusInternalServerError, "error", nil)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
+	audit.Record(c, h.audit, audit.ActionDelete, "plant", id, deletedPlant, nil)
 
 	response := helper.APIResponse("Plant deleted successfully", http.StatusOK, "success", deletedPlant)
 	return c.JSON(http.StatusOK, response)
@@ -474,16 +459,33 @@ func (h *PlantHandler) SearchPlantsByName(c echo.Context) error {
 	return c.JSON(http.StatusOK, response)
 }
 
-func CreateWateringScheduleInput(form map[string][]string) plant.CreateWateringScheduleInput {
+// CreateWateringScheduleInput reads the watering schedule fields of a plant
+// form. watering_schedule.watering_time and watering_schedule.weekdays may be
+// sent several times or as comma separated lists.
+func CreateWateringScheduleInput(form map[string][]string) (plant.CreateWateringScheduleInput, error) {
+	times, invalid := plant.ParseWateringTimes(form["watering_schedule.watering_time"])
+	if len(invalid) > 0 {
+		return plant.CreateWateringScheduleInput{}, fmt.Errorf("Invalid watering time: %s", strings.Join(invalid, ", "))
+	}
+
 	return plant.CreateWateringScheduleInput{
-		WateringFrequency:    atoi(form["watering_schedule.watering_frequency"][0]),
-		Each:                 form["watering_schedule.each"][0],
-		WateringAmount:       atoi(form["watering_schedule.watering_amount"][0]),
-		Unit:                 form["watering_schedule.unit"][0],
-		WateringTime:         form["watering_schedule.watering_time"][0],
-		WeatherCondition:     form["watering_schedule.weather_condition"][0],
-		ConditionDescription: form["watering_schedule.condition_description"][0],
+		Each:                 plant.ParseWateringPeriod(formValue(form, "watering_schedule.each")),
+		Interval:             atoi(formValue(form, "watering_schedule.interval")),
+		Weekdays:             plant.ParseWeekdays(form["watering_schedule.weekdays"]),
+		WateringAmount:       atoi(formValue(form, "watering_schedule.watering_amount")),
+		Unit:                 formValue(form, "watering_schedule.unit"),
+		WateringTimes:        times,
+		WeatherCondition:     formValue(form, "watering_schedule.weather_condition"),
+		ConditionDescription: formValue(form, "watering_schedule.condition_description"),
+	}, nil
+}
+
+// formValue returns the first value of key, or "" when the form lacks it.
+func formValue(form map[string][]string, key string) string {
+	if len(form[key]) == 0 {
+		return ""
 	}
+	return form[key][0]
 }
 
 func ConvertToTime(timeStr string) (time.Time, error) {
@@ -492,26 +494,25 @@ func ConvertToTime(timeStr string) (time.Time, error) {
 }
 
 func (h *PlantHandler) FindEarliestWateringTime(c echo.Context, schedules []plant.CreateWateringScheduleInput) (plant.CreateWateringScheduleInput, error) {
-	if len(schedules) == 0 {
-		return plant.CreateWateringScheduleInput{}, fmt.Errorf("no schedules provided")
-	}
-
-	earliestSchedule := schedules[0]
-	earliestTime, err := ConvertToTime(earliestSchedule.WateringTime)
-	if err != nil {
-		return plant.CreateWateringScheduleInput{}, err
-	}
+	var earliestSchedule *plant.CreateWateringScheduleInput
+	var earliestTime time.Time
+	for i, schedule := range schedules {
+		if len(schedule.WateringTimes) == 0 {
+			continue
+		}
 
-	for _, schedule := range schedules[1:] {
-		currentTime, err := ConvertToTime(schedule.WateringTime)
+		currentTime, err := ConvertToTime(schedule.WateringTimes[0])
 		if err != nil {
 			return plant.CreateWateringScheduleInput{}, err
 		}
 
-		if currentTime.Before(earliestTime) {
+		if earliestSchedule == nil || currentTime.Before(earliestTime) {
 			earliestTime = currentTime
-			earliestSchedule = schedule
+			earliestSchedule = &schedules[i]
 		}
 	}
-	return earliestSchedule, nil
+	if earliestSchedule == nil {
+		return plant.CreateWateringScheduleInput{}, fmt.Errorf("no schedules provided")
+	}
+	return *earliestSchedule, nil
 }
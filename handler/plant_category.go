@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/OctavianoRyan25/be-agriculture/modules/audit"
 	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
 	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
 	"github.com/cloudinary/cloudinary-go/v2"
@@ -16,10 +17,11 @@ import (
 type PlantCategoryHandler struct {
 	service plant.PlantCategoryService
 	cloudinary  *cloudinary.Cloudinary
+	audit   audit.UseCase
 }
 
-func NewPlantCategoryHandler(service plant.PlantCategoryService, cloudinary  *cloudinary.Cloudinary) *PlantCategoryHandler {
-	return &PlantCategoryHandler{service , cloudinary}
+func NewPlantCategoryHandler(service plant.PlantCategoryService, cloudinary  *cloudinary.Cloudinary, audit audit.UseCase) *PlantCategoryHandler {
+	return &PlantCategoryHandler{service , cloudinary, audit}
 }
 
 func (h *PlantCategoryHandler) GetAll(c echo.Context) error {
@@ -60,12 +62,6 @@ func (h *PlantCategoryHandler) Create(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can create", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
-	}
-
 	validate := validator.New()
 	if err := validate.Struct(input); err != nil {
 		errors := helper.FormatValidationError(err)
@@ -105,11 +101,12 @@ func (h *PlantCategoryHandler) Create(c echo.Context) error {
 
 	imageURL := uploadResult.SecureURL
 
-	_, err = h.service.Create(input, imageURL)
+	category, err := h.service.Create(input, imageURL)
 	if err != nil {
 		response := helper.APIResponse("Failed to create plant category", http.StatusInternalServerError, "error", nil)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
+	audit.Record(c, h.audit, audit.ActionCreate, "plant_category", category.ID, nil, category)
 
 	response := helper.APIResponse("Plant category created successfully", http.StatusCreated, "success", nil)
 	return c.JSON(http.StatusCreated, response)
@@ -123,10 +120,10 @@ func (h *PlantCategoryHandler) Update(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can update", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
+	before, err := h.service.FindByID(id)
+	if err != nil {
+		response := helper.APIResponse("Plant category not found", http.StatusNotFound, "error", nil)
+		return c.JSON(http.StatusNotFound, response)
 	}
 
 	var input plant.PlantCategoryClimateInput
@@ -184,6 +181,7 @@ func (h *PlantCategoryHandler) Update(c echo.Context) error {
 		response := helper.APIResponse("Failed to update plant category", http.StatusInternalServerError, "error", nil)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
+	audit.Record(c, h.audit, audit.ActionUpdate, "plant_category", id, before, category)
 
 	response := helper.APIResponse("Plant category updated successfully", http.StatusOK, "success", category)
 	return c.JSON(http.StatusOK, response)
@@ -198,12 +196,6 @@ func (h *PlantCategoryHandler) Delete(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	role := c.Get("role").(string)
-	if role != "admin" {
-		response := helper.APIResponse("Only admin can delete", http.StatusUnauthorized, "error", nil)
-		return c.JSON(http.StatusUnauthorized, response)
-	}
-
 	category, err := h.service.FindByID(id)
 	if err != nil {
 		response := helper.APIResponse("Plant category not found", http.StatusNotFound, "error", nil)
@@ -215,6 +207,7 @@ func (h *PlantCategoryHandler) Delete(c echo.Context) error {
 		response := helper.APIResponse("Failed to delete plant category", http.StatusInternalServerError, "error", nil)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
+	audit.Record(c, h.audit, audit.ActionDelete, "plant_category", id, category, nil)
 
 	response := helper.APIResponse("Plant category deleted successfully", http.StatusOK, "success", category)
 	return c.JSON(http.StatusOK, response)
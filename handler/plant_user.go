@@ -310,3 +310,50 @@ func (h *UserPlantHandler) UpdateCustomizeName(c echo.Context) error {
 	response := helper.APIResponse("Customize name updated successfully", http.StatusOK, "success", updatedPlant)
 	return c.JSON(http.StatusOK, response)
 }
+
+// UpdateOutdoor marks a plant of the user as growing outdoors or indoors.
+// Reminders of outdoor plants follow the rain forecast.
+func (h *UserPlantHandler) UpdateOutdoor(c echo.Context) error {
+	var input struct {
+		Outdoor *bool `json:"outdoor" form:"outdoor" validate:"required"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		response := helper.APIResponse("Invalid request", http.StatusBadRequest, "error", nil)
+		return c.JSON(http.StatusBadRequest, response)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(input); err != nil {
+		errors := helper.FormatValidationError(err)
+		response := helper.APIResponse(errors[0], http.StatusBadRequest, "error", nil)
+		return c.JSON(http.StatusBadRequest, response)
+	}
+
+	userPlantID, err := strconv.Atoi(c.Param("userPlantID"))
+	if err != nil {
+		response := helper.APIResponse("Invalid user plant ID", http.StatusBadRequest, "error", nil)
+		return c.JSON(http.StatusBadRequest, response)
+	}
+
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		response := helper.APIResponse("Unauthorized", http.StatusUnauthorized, "error", nil)
+		return c.JSON(http.StatusUnauthorized, response)
+	}
+
+	userPlant, err := h.service.GetUserPlantByID(userPlantID)
+	if err != nil || userPlant.UserID != int(userID) {
+		response := helper.APIResponse("User plant ID not found", http.StatusNotFound, "error", nil)
+		return c.JSON(http.StatusNotFound, response)
+	}
+
+	updatedPlant, err := h.service.UpdateOutdoor(userPlantID, *input.Outdoor)
+	if err != nil {
+		response := helper.APIResponse("Failed to update outdoor", http.StatusInternalServerError, "error", nil)
+		return c.JSON(http.StatusInternalServerError, response)
+	}
+
+	response := helper.APIResponse("Outdoor updated successfully", http.StatusOK, "success", updatedPlant)
+	return c.JSON(http.StatusOK, response)
+}
@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/middlewares"
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
 	"github.com/OctavianoRyan25/be-agriculture/modules/weather"
 	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
 	"github.com/labstack/echo/v4"
@@ -11,10 +15,26 @@ import (
 
 type WeatherHandler struct {
 	Service weather.WeatherService
+	users   user.UserUseCase
 }
 
-func NewWeatherHandler(service weather.WeatherService) *WeatherHandler {
-	return &WeatherHandler{Service: service}
+func NewWeatherHandler(service weather.WeatherService, users user.UserUseCase) *WeatherHandler {
+	return &WeatherHandler{Service: service, users: users}
+}
+
+// detectTimezone sets the time zone of users who have not chosen one from
+// the coordinates they ask the weather for.
+func (h *WeatherHandler) detectTimezone(c echo.Context, lat, lon float64) {
+	role, _ := c.Get("role").(string)
+	userID, _ := c.Get("user_id").(uint)
+	if role != middlewares.RoleUser || userID == 0 {
+		return
+	}
+
+	code, err := h.users.DetectTimezone(userID, lat, lon)
+	if err != nil && code != constants.ErrCodeUnknownLocation {
+		log.Println("Failed to detect time zone:", err)
+	}
 }
 
 func (h *WeatherHandler) GetCurrentWeather(c echo.Context) error {
@@ -33,13 +53,16 @@ func (h *WeatherHandler) GetCurrentWeather(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	weather, err := h.Service.GetCurrentWeatherByCoordinates(lat, lon)
+	h.detectTimezone(c, lat, lon)
+
+	currentWeather, err := h.Service.GetCurrentWeatherByCoordinates(lat, lon)
 	if err != nil {
-		response := helper.APIResponse("Failed to get current weather", http.StatusInternalServerError, "error", nil)
-		return c.JSON(http.StatusInternalServerError, response)
+		code := weather.ErrorCode(err)
+		response := helper.APIResponse("Failed to get current weather", code, "error", nil)
+		return c.JSON(code, response)
 	}
 
-	response := helper.APIResponse("Current weather data", http.StatusOK, "success", weather)
+	response := helper.APIResponse("Current weather data", http.StatusOK, "success", currentWeather)
 	return c.JSON(http.StatusOK, response)
 }
 
@@ -59,10 +82,13 @@ func (h *WeatherHandler) GetHourlyWeather(c echo.Context) error {
 			return c.JSON(http.StatusBadRequest, response)
 	}
 
+	h.detectTimezone(c, lat, lon)
+
 	hourlyWeather, err := h.Service.GetHourlyWeatherByCoordinates(lat, lon)
 	if err != nil {
-			response := helper.APIResponse("Failed to get hourly weather", http.StatusInternalServerError, "error", nil)
-			return c.JSON(http.StatusInternalServerError, response)
+			code := weather.ErrorCode(err)
+			response := helper.APIResponse("Failed to get hourly weather", code, "error", nil)
+			return c.JSON(code, response)
 	}
 
 	response := helper.APIResponse("Hourly weather data", http.StatusOK, "success", hourlyWeather)
@@ -85,10 +111,13 @@ func (h *WeatherHandler) GetDailyWeather(c echo.Context) error {
 			return c.JSON(http.StatusBadRequest, response)
 	}
 
+	h.detectTimezone(c, lat, lon)
+
 	dailyWeather, err := h.Service.GetDailyWeatherByCoordinates(lat, lon)
 	if err != nil {
-			response := helper.APIResponse("Failed to get daily weather", http.StatusInternalServerError, "error", nil)
-			return c.JSON(http.StatusInternalServerError, response)
+			code := weather.ErrorCode(err)
+			response := helper.APIResponse("Failed to get daily weather", code, "error", nil)
+			return c.JSON(code, response)
 	}
 
 	response := helper.APIResponse("Daily weather data", http.StatusOK, "success", dailyWeather)
@@ -2,21 +2,30 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"strings"
 
 	"github.com/OctavianoRyan25/be-agriculture/configs"
 	"github.com/OctavianoRyan25/be-agriculture/handler"
+	"github.com/OctavianoRyan25/be-agriculture/middlewares"
+	"github.com/OctavianoRyan25/be-agriculture/modules/account"
 	"github.com/OctavianoRyan25/be-agriculture/modules/admin"
 	"github.com/OctavianoRyan25/be-agriculture/modules/ai"
 	"github.com/OctavianoRyan25/be-agriculture/modules/article"
+	"github.com/OctavianoRyan25/be-agriculture/modules/audit"
+	"github.com/OctavianoRyan25/be-agriculture/modules/device"
 	"github.com/OctavianoRyan25/be-agriculture/modules/fertilizer"
+	"github.com/OctavianoRyan25/be-agriculture/modules/lockout"
 	"github.com/OctavianoRyan25/be-agriculture/modules/notification"
 	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
 	"github.com/OctavianoRyan25/be-agriculture/modules/search"
+	"github.com/OctavianoRyan25/be-agriculture/modules/session"
 	"github.com/OctavianoRyan25/be-agriculture/modules/user"
 	wateringhistory "github.com/OctavianoRyan25/be-agriculture/modules/watering_history"
 	"github.com/OctavianoRyan25/be-agriculture/modules/weather"
 	"github.com/OctavianoRyan25/be-agriculture/router"
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -26,6 +35,7 @@ func main() {
 	fmt.Println("Ini Branch Development!")
 
 	e := echo.New()
+	e.IPExtractor = ipExtractor()
 
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
@@ -41,23 +51,65 @@ func main() {
 		panic("Failed to migrate database")
 	}
 
+	keyRing, err := helper.LoadKeyRing()
+	if err != nil {
+		panic("Failed to load JWT keys: " + err.Error())
+	}
+	helper.SetKeyRing(keyRing)
+	jwksHandler := handler.NewJWKSHandler(keyRing)
+
 	cloudinary, err := initCloudinary()
 	if err != nil {
 		fmt.Println("Failed to initialize Cloudinary:", err)
 		return
 	}
 
+	sessionRepo := session.NewRepository(db)
+	sessionUseCase := session.NewUseCase(sessionRepo)
+	sessionController := session.NewSessionController(sessionUseCase)
+	middlewares.SetSessionChecker(sessionUseCase)
+
+	deviceRepo := device.NewRepository(db)
+	deviceUseCase := device.NewUseCase(deviceRepo)
+	deviceController := device.NewDeviceController(deviceUseCase)
+
+	lockoutRepo := lockout.NewRepository(db)
+	lockoutUseCase := lockout.NewUseCase(lockoutRepo)
+
 	repo := user.NewRepository(db)
-	useCase := user.NewUseCase(repo)
-	controller := user.NewUserController(useCase)
+	useCase := user.NewUseCase(repo, sessionUseCase, lockoutUseCase)
+	controller := user.NewUserController(useCase, sessionUseCase, deviceUseCase, cloudinary)
+
+	accountRepo := account.NewRepository(db)
+	accountUseCase := account.NewUseCase(accountRepo, account.NewCloudinaryImages(cloudinary), lockoutUseCase)
+	accountController := account.NewAccountController(accountUseCase)
+	account.StartScheduler(accountUseCase)
+
+	auditRepo := audit.NewRepository(db)
+	auditUseCase := audit.NewUseCase(auditRepo)
+	auditController := audit.NewAuditController(auditUseCase)
 
 	repoAdmin := admin.NewRepository(db)
-	useCaseAdmin := admin.NewUseCase(repoAdmin)
-	controllerAdmin := admin.NewUserController(*useCaseAdmin)
+	useCaseAdmin := admin.NewUseCase(repoAdmin, sessionUseCase, lockoutUseCase)
+	if email := os.Getenv("ADMIN_BOOTSTRAP_EMAIL"); email != "" {
+		password := os.Getenv("ADMIN_BOOTSTRAP_PASSWORD")
+		if password == "" {
+			panic("ADMIN_BOOTSTRAP_PASSWORD environment variable is not set")
+		}
+		created, err := useCaseAdmin.BootstrapAdmin(os.Getenv("ADMIN_BOOTSTRAP_NAME"), email, admin.HashPass(password))
+		if err != nil {
+			panic(err)
+		}
+		if created {
+			fmt.Println("Bootstrap super admin set up:", email)
+		}
+	}
+	middlewares.SetAdminRoleResolver(repoAdmin)
+	controllerAdmin := admin.NewUserController(*useCaseAdmin, sessionUseCase, auditUseCase)
 
 	plantCategoryRepository := plant.NewPlantCategoryRepository(db)
 	plantCategoryService := plant.NewPlantCategoryService(plantCategoryRepository)
-	plantCategoryHandler := handler.NewPlantCategoryHandler(plantCategoryService, cloudinary)
+	plantCategoryHandler := handler.NewPlantCategoryHandler(plantCategoryService, cloudinary, auditUseCase)
 
 	plantProgressRepository := plant.NewPlantProgressRepository(db)
 	plantProgressService := plant.NewPlantProgressService(plantProgressRepository)
@@ -65,11 +117,11 @@ func main() {
 
 	plantInstructionCategoryRepository := plant.NewPlantInstructionCategoryRepository(db)
 	plantInstructionCategoryService := plant.NewPlantInstructionCategoryService(plantInstructionCategoryRepository)
-	plantInstructionCategoryHandler := handler.NewPlantInstructionCategoryHandler(plantInstructionCategoryService, cloudinary)
+	plantInstructionCategoryHandler := handler.NewPlantInstructionCategoryHandler(plantInstructionCategoryService, cloudinary, auditUseCase)
 
 	plantRepository := plant.NewPlantRepository(db)
 	plantService := plant.NewPlantService(plantRepository, plantCategoryRepository)
-	plantHandler := handler.NewPlantHandler(plantService, cloudinary)
+	plantHandler := handler.NewPlantHandler(plantService, cloudinary, auditUseCase)
 
 	plantUserRepository := plant.NewUserPlantRepository(db)
 	plantUserService := plant.NewUserPlantService(plantUserRepository)
@@ -79,8 +131,12 @@ func main() {
 	plantEarliestWateringService := plant.NewPlantEarliestWateringService(plantEarliestWateringRepository)
 	plantEarliestWateringHandler := handler.NewPlantEarliestWateringHandler(plantEarliestWateringService, cloudinary)
 
-	weatherService := weather.NewWeatherService()
-	weatherHandler := handler.NewWeatherHandler(weatherService)
+	weatherProvider := weather.ProviderFromEnv()
+	weatherService := weather.NewWeatherService(weatherProvider)
+	if weatherCache := weather.CacheFromEnv(); weatherCache != nil {
+		weatherService = weather.NewCachedWeatherService(weatherService, weatherCache, weatherProvider.Name())
+	}
+	weatherHandler := handler.NewWeatherHandler(weatherService, useCase)
 
 	searchRepository := search.NewRepository(db)
 	searchUsecase := search.NewUsecase(searchRepository)
@@ -88,28 +144,31 @@ func main() {
 
 	// Initialize the notification repository and use case
 	notificationRepo := notification.NewRepository(db)
-	notificationUseCase := notification.NewUseCase(notificationRepo)
+	notifiers := notification.NotifiersFromEnv(deviceUseCase)
+	notificationUseCase := notification.NewUseCase(notificationRepo, notifiers)
+	useCase.SetTimezoneListener(notificationUseCase)
+	notificationUseCase.SetForecaster(weatherService)
 	notificationController := notification.NewNotificationController(notificationUseCase)
 
-	// Initialize Firebase
-	//firebaseApp := notification.InitFirebase()
-
 	// Schedule watering reminders
-	notification.StartScheduler(db, notificationUseCase)
-	notification.StartSchedulerForCustomizeWateringReminder(db, notificationUseCase)
+	notification.StartReminderWorker(notificationUseCase)
 
 	// Initialize the watering history repository and use case
 	wateringHistoryRepo := wateringhistory.NewRepository(db)
-	wateringHistoryUseCase := wateringhistory.NewUseCase(wateringHistoryRepo)
+	wateringHistoryUseCase := wateringhistory.NewUseCase(wateringHistoryRepo, wateringhistory.NewCloudinaryImages(cloudinary))
 	wateringHistoryController := wateringhistory.NeWateringHistoryController(wateringHistoryUseCase)
 
+	// Escalate plants that stay overdue for watering
+	wateringHistoryUseCase.SetEscalator(notificationUseCase, wateringhistory.EscalateAfterFromEnv())
+	wateringhistory.StartComplianceWorker(wateringHistoryUseCase)
+
 	fertilizerRepo := fertilizer.NewFertilizerRepository(db)
 	fertilizerUseCase := fertilizer.NewFertilizerService(fertilizerRepo)
-	fertilizerHandler := handler.NewFertilizerHandler(fertilizerUseCase, cloudinary)
+	fertilizerHandler := handler.NewFertilizerHandler(fertilizerUseCase, cloudinary, auditUseCase)
 
 	articleRepo := article.NewRepository(db)
 	articleUseCase := article.NewUseCase(articleRepo)
-	articleController := article.NewArticleController(articleUseCase)
+	articleController := article.NewArticleController(articleUseCase, auditUseCase)
 
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey == "" {
@@ -119,11 +178,32 @@ func main() {
 	aiFertilizerRecommendationService := ai.NewPlantService(apiKey)
 	aiFertilizerRecommendationHandler := handler.NewAIFertilizerRecommendationHandler(aiFertilizerRecommendationService)
 
-	router.InitRoutes(e, controller, controllerAdmin, plantCategoryHandler, plantHandler, plantUserHandler, weatherHandler, plantInstructionCategoryHandler, plantProgressHandler, searchController, notificationController, wateringHistoryController, fertilizerHandler, aiFertilizerRecommendationHandler, plantEarliestWateringHandler, articleController)
+	router.InitRoutes(e, controller, controllerAdmin, sessionController, deviceController, accountController, plantCategoryHandler, plantHandler, plantUserHandler, weatherHandler, plantInstructionCategoryHandler, plantProgressHandler, searchController, notificationController, wateringHistoryController, fertilizerHandler, aiFertilizerRecommendationHandler, plantEarliestWateringHandler, articleController, auditController, jwksHandler)
 
 	e.Logger.Fatal(e.Start(":8080"))
 }
 
+// ipExtractor returns how the client IP, which logins are locked out by, is
+// read. By default it is the address of the connection, headers can be set
+// by any client. Behind a proxy, TRUSTED_PROXIES lists its ranges in CIDR
+// notation, separated by commas, and the IP is read from X-Forwarded-For.
+func ipExtractor() echo.IPExtractor {
+	proxies := os.Getenv("TRUSTED_PROXIES")
+	if proxies == "" {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, cidr := range strings.Split(proxies, ",") {
+		_, ipRange, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			panic("Invalid TRUSTED_PROXIES range: " + cidr)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
 func initCloudinary() (*cloudinary.Cloudinary, error) {
 	//Production
 	cloudinaryURL := os.Getenv("CLOUDINARY_URL")
@@ -8,6 +8,19 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// SessionChecker reports whether the session an access token belongs to is
+// still active, so logged out or revoked sessions are rejected before their
+// access tokens expire.
+type SessionChecker interface {
+	IsSessionActive(sessionID string) (bool, error)
+}
+
+var sessionChecker SessionChecker
+
+func SetSessionChecker(checker SessionChecker) {
+	sessionChecker = checker
+}
+
 func Authentication() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -43,6 +56,22 @@ func Authentication() echo.MiddlewareFunc {
 				})
 			}
 
+			// Tolak token dari sesi yang sudah logout atau dicabut
+			sessionID, _ := claims["sid"].(string)
+			if sessionChecker != nil {
+				active, err := sessionChecker.IsSessionActive(sessionID)
+				if err != nil || !active {
+					return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+						"error":      constants.ErrTokenRevoked,
+						"error_code": constants.ErrCodeTokenRevoked,
+						"message":    "Session has been revoked",
+					})
+				}
+			}
+
+			// Setel sessionID dalam konteks Echo
+			c.Set("session_id", sessionID)
+
 			// Setel Role dalam konteks Echo sesuai dengan peran yang diminta
 			c.Set("role", role)
 
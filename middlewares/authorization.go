@@ -0,0 +1,132 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/labstack/echo/v4"
+)
+
+// Account types carried in the "role" claim of the access token.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Admin roles, stored on the admin account and resolved on every request so a
+// role change takes effect without waiting for the token to expire.
+const (
+	RoleSuperAdmin    = "super-admin"
+	RoleContentEditor = "content-editor"
+	RoleCatalogEditor = "catalog-editor"
+)
+
+// Permissions checked by RequirePermission.
+const (
+	PermManageCatalog  = "catalog:write"
+	PermManageArticles = "articles:write"
+	PermManageAdmins   = "admins:write"
+	PermViewAuditLog   = "audit:read"
+)
+
+// rolePermissions is the permission table of every admin role. Super admins
+// are granted every permission.
+var rolePermissions = map[string][]string{
+	RoleSuperAdmin:    {PermManageCatalog, PermManageArticles, PermManageAdmins, PermViewAuditLog},
+	RoleCatalogEditor: {PermManageCatalog},
+	RoleContentEditor: {PermManageArticles},
+}
+
+// AdminRoleResolver returns the current role of an admin account.
+type AdminRoleResolver interface {
+	GetAdminRole(id uint) (string, error)
+}
+
+var adminRoleResolver AdminRoleResolver
+
+func SetAdminRoleResolver(resolver AdminRoleResolver) {
+	adminRoleResolver = resolver
+}
+
+// IsAdminRole reports whether role is one of the known admin roles.
+func IsAdminRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether the admin role grants permission.
+func HasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRole allows the request when the account type ("user", "admin") or
+// the admin role of the caller is one of roles. It must be registered after
+// Authentication.
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, adminRole, err := resolveRoles(c)
+			if err != nil {
+				return forbidden(c)
+			}
+			for _, r := range roles {
+				if r == role || (adminRole != "" && r == adminRole) {
+					return next(c)
+				}
+			}
+			return forbidden(c)
+		}
+	}
+}
+
+// RequirePermission allows the request when the admin role of the caller
+// grants permission. It must be registered after Authentication.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			_, adminRole, err := resolveRoles(c)
+			if err != nil || !HasPermission(adminRole, permission) {
+				return forbidden(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// resolveRoles returns the account type set by Authentication and, for admins,
+// their admin role. The admin role is cached in the context as "admin_role".
+func resolveRoles(c echo.Context) (string, string, error) {
+	role, _ := c.Get("role").(string)
+	if role != RoleAdmin {
+		return role, "", nil
+	}
+
+	if adminRole, ok := c.Get("admin_role").(string); ok {
+		return role, adminRole, nil
+	}
+
+	if adminRoleResolver == nil {
+		return role, "", nil
+	}
+	userID, _ := c.Get("user_id").(uint)
+	adminRole, err := adminRoleResolver.GetAdminRole(userID)
+	if err != nil {
+		return role, "", err
+	}
+	c.Set("admin_role", adminRole)
+
+	return role, adminRole, nil
+}
+
+func forbidden(c echo.Context) error {
+	return c.JSON(http.StatusForbidden, map[string]interface{}{
+		"error":      constants.ErrForbidden,
+		"error_code": constants.ErrCodeForbidden,
+		"message":    "Forbidden access",
+	})
+}
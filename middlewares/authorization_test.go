@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRoleResolver map[uint]string
+
+func (f fakeRoleResolver) GetAdminRole(id uint) (string, error) {
+	role, ok := f[id]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return role, nil
+}
+
+func serve(mw echo.MiddlewareFunc, role string, userID uint) int {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+	c.Set("role", role)
+	c.Set("user_id", userID)
+
+	handler := mw(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	_ = handler(c)
+	return rec.Code
+}
+
+func TestRequirePermission(t *testing.T) {
+	SetAdminRoleResolver(fakeRoleResolver{1: RoleSuperAdmin, 2: RoleContentEditor})
+	defer SetAdminRoleResolver(nil)
+
+	catalog := RequirePermission(PermManageCatalog)
+	articles := RequirePermission(PermManageArticles)
+
+	assert.Equal(t, http.StatusOK, serve(catalog, RoleAdmin, 1))
+	assert.Equal(t, http.StatusForbidden, serve(catalog, RoleAdmin, 2))
+	assert.Equal(t, http.StatusOK, serve(articles, RoleAdmin, 2))
+	assert.Equal(t, http.StatusForbidden, serve(catalog, RoleAdmin, 3))
+	// user ids can overlap with admin ids, the account type must be checked
+	assert.Equal(t, http.StatusForbidden, serve(catalog, RoleUser, 1))
+}
+
+func TestRequireRole(t *testing.T) {
+	SetAdminRoleResolver(fakeRoleResolver{1: RoleContentEditor})
+	defer SetAdminRoleResolver(nil)
+
+	assert.Equal(t, http.StatusOK, serve(RequireRole(RoleUser), RoleUser, 1))
+	assert.Equal(t, http.StatusForbidden, serve(RequireRole(RoleUser), RoleAdmin, 1))
+	assert.Equal(t, http.StatusOK, serve(RequireRole(RoleAdmin), RoleAdmin, 1))
+	assert.Equal(t, http.StatusOK, serve(RequireRole(RoleContentEditor), RoleAdmin, 1))
+	assert.Equal(t, http.StatusForbidden, serve(RequireRole(RoleSuperAdmin), RoleAdmin, 1))
+}
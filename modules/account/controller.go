@@ -0,0 +1,166 @@
+package account
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/base"
+	"github.com/labstack/echo/v4"
+)
+
+type AccountController struct {
+	useCase UseCase
+}
+
+func NewAccountController(useCase UseCase) *AccountController {
+	return &AccountController{
+		useCase: useCase,
+	}
+}
+
+// RequestExport queues a "download my data" export. The user is emailed
+// when the archive is ready.
+func (c *AccountController) RequestExport(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+
+	export, code, err := c.useCase.RequestExport(userID)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Data export requested",
+		Data:    MapExportToResponse(export),
+	}
+	return ctx.JSON(http.StatusAccepted, res)
+}
+
+func (c *AccountController) GetExports(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+
+	exports, code, err := c.useCase.GetExports(userID)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Data exports",
+		Data:    MapExportsToResponse(exports),
+	}
+	return ctx.JSON(code, res)
+}
+
+func (c *AccountController) DownloadExport(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: "Invalid export id",
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	path, code, err := c.useCase.GetExportFile(userID, id)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	return ctx.Attachment(path, fmt.Sprintf("plantopia-data-%s.zip", time.Now().Format("2006-01-02")))
+}
+
+// RequestDeletion schedules the deletion of the account. The user can still
+// log in and cancel it during the grace period.
+func (c *AccountController) RequestDeletion(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+
+	req := new(DeletionRequestRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+
+	request, code, err := c.useCase.RequestDeletion(userID, req.Password)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Account deletion scheduled",
+		Data:    MapDeletionToResponse(request),
+	}
+	return ctx.JSON(http.StatusAccepted, res)
+}
+
+func (c *AccountController) GetDeletion(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+
+	request, code, err := c.useCase.GetDeletion(userID)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Account deletion",
+		Data:    MapDeletionToResponse(request),
+	}
+	return ctx.JSON(code, res)
+}
+
+func (c *AccountController) CancelDeletion(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+
+	code, err := c.useCase.CancelDeletion(userID)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Account deletion canceled",
+	}
+	return ctx.JSON(code, res)
+}
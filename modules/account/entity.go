@@ -0,0 +1,36 @@
+package account
+
+import (
+	"time"
+)
+
+// Status of a data export.
+const (
+	ExportPending    = "pending"
+	ExportProcessing = "processing"
+	ExportReady      = "ready"
+	ExportFailed     = "failed"
+)
+
+// DataExport is a "download my data" job. The scheduler builds the ZIP
+// archive in the background and stores it at FilePath until ExpiresAt.
+type DataExport struct {
+	ID          int    `gorm:"primaryKey"`
+	UserID      int    `gorm:"index"`
+	Status      string `gorm:"size:16;index"`
+	FilePath    string
+	Error       string
+	ExpiresAt   *time.Time
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// DeletionRequest schedules the deletion of an account. The account and all
+// its data are removed at ScheduledAt unless the user cancels before.
+type DeletionRequest struct {
+	ID          int       `gorm:"primaryKey"`
+	UserID      int       `gorm:"uniqueIndex"`
+	ScheduledAt time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
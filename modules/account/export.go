@@ -0,0 +1,182 @@
+package account
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/utils/timezone"
+)
+
+// profileExport is the user.json file of an export. Password hashes and
+// OTP codes are left out.
+type profileExport struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	IsActive  bool      `json:"is_active"`
+	ImageURL  string    `json:"url_image"`
+	Timezone  string    `json:"timezone"`
+	Latitude  *float64  `json:"lat"`
+	Longitude *float64  `json:"lon"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// fetchImage downloads a plant progress image for the archive.
+type fetchImage func(url string) ([]byte, error)
+
+var imageClient = &http.Client{Timeout: 30 * time.Second}
+
+func httpFetchImage(url string) ([]byte, error) {
+	resp, err := imageClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// writeArchive writes the ZIP archive of data to w: user.json, one CSV file
+// per table and the plant progress images in progress/. An image that cannot
+// be downloaded is left out, its URL is still in plant_progress.csv.
+func writeArchive(w io.Writer, data *UserData, fetch fetchImage) error {
+	zw := zip.NewWriter(w)
+
+	profile := profileExport{
+		ID:        data.User.ID,
+		Name:      data.User.Name,
+		Email:     data.User.Email,
+		IsActive:  data.User.Is_Active,
+		ImageURL:  data.User.Url_Image,
+		Timezone:  data.User.Location().String(),
+		Latitude:  data.User.Latitude,
+		Longitude: data.User.Longitude,
+		CreatedAt: data.User.Created_at,
+		UpdatedAt: data.User.Updated_at,
+	}
+	f, err := zw.Create("user.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(profile)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{{"id", "plant_id", "plant_name", "customize_name", "created_at", "updated_at"}}
+	for _, p := range data.UserPlants {
+		rows = append(rows, []string{itoa(p.ID), itoa(p.PlantID), p.Plant.Name, p.CustomizeName, formatTime(p.CreatedAt), formatTime(p.UpdatedAt)})
+	}
+	err = writeCSV(zw, "user_plants.csv", rows)
+	if err != nil {
+		return err
+	}
+
+	rows = [][]string{{"id", "plant_id", "image_url", "file", "created_at"}}
+	for _, p := range data.PlantProgress {
+		file := ""
+		image, err := fetch(p.ImageURL)
+		if err == nil {
+			file = fmt.Sprintf("progress/%d%s", p.ID, path.Ext(p.ImageURL))
+			f, err := zw.Create(file)
+			if err != nil {
+				return err
+			}
+			_, err = f.Write(image)
+			if err != nil {
+				return err
+			}
+		}
+		rows = append(rows, []string{itoa(p.ID), itoa(p.PlantID), p.ImageURL, file, formatTime(p.CreatedAt)})
+	}
+	err = writeCSV(zw, "plant_progress.csv", rows)
+	if err != nil {
+		return err
+	}
+
+	rows = [][]string{{"id", "user_plant_id", "plant_id", "plant_name", "watered_at", "amount", "unit", "method", "note", "photo_url", "created_at"}}
+	for _, h := range data.WateringHistories {
+		rows = append(rows, []string{itoa(h.ID), itoaPtr(h.UserPlantID), itoa(h.PlantID), h.Plant.Name, formatTime(h.WateredAt), itoa(h.Amount), h.Unit, h.Method, h.Note, h.PhotoURL, formatTime(h.CreatedAt)})
+	}
+	err = writeCSV(zw, "watering_history.csv", rows)
+	if err != nil {
+		return err
+	}
+
+	rows = [][]string{{"id", "plant_id", "title", "body", "is_read", "created_at"}}
+	for _, n := range data.Notifications {
+		rows = append(rows, []string{itoa(n.Id), itoa(n.PlantId), n.Title, n.Body, strconv.FormatBool(n.IsRead), formatTime(n.CreatedAt)})
+	}
+	err = writeCSV(zw, "notifications.csv", rows)
+	if err != nil {
+		return err
+	}
+
+	rows = [][]string{{"id", "plant_id", "rrule", "timezone", "start_at", "end_at", "paused", "created_at"}}
+	for _, r := range data.CustomizeWateringReminders {
+		rows = append(rows, []string{itoa(r.Id), itoa(r.PlantId), r.RRule, timezone.Location(r.Timezone).String(), formatTime(r.StartAt), formatTimePtr(r.EndAt), strconv.FormatBool(r.PausedAt != nil), formatTime(r.CreatedAt)})
+	}
+	err = writeCSV(zw, "watering_reminders.csv", rows)
+	if err != nil {
+		return err
+	}
+
+	rows = [][]string{{"id", "plant_id", "plant_name", "plant_category", "plant_image_url", "created_at"}}
+	for _, h := range data.UserPlantHistories {
+		rows = append(rows, []string{itoa(h.ID), itoa(h.PlantID), h.PlantName, h.PlantCategory, h.PlantImageURL, formatTime(h.CreatedAt)})
+	}
+	err = writeCSV(zw, "plant_history.csv", rows)
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeCSV(zw *zip.Writer, name string, rows [][]string) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	err = w.WriteAll(rows)
+	if err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
+
+func itoaPtr(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return itoa(*i)
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
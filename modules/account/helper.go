@@ -0,0 +1,26 @@
+package account
+
+func MapExportToResponse(export *DataExport) *DataExportResponse {
+	return &DataExportResponse{
+		ID:          export.ID,
+		Status:      export.Status,
+		ExpiresAt:   export.ExpiresAt,
+		CompletedAt: export.CompletedAt,
+		CreatedAt:   export.CreatedAt,
+	}
+}
+
+func MapExportsToResponse(exports []DataExport) []DataExportResponse {
+	res := make([]DataExportResponse, 0, len(exports))
+	for i := range exports {
+		res = append(res, *MapExportToResponse(&exports[i]))
+	}
+	return res
+}
+
+func MapDeletionToResponse(request *DeletionRequest) *DeletionResponse {
+	return &DeletionResponse{
+		ScheduledAt: request.ScheduledAt,
+		CreatedAt:   request.CreatedAt,
+	}
+}
@@ -0,0 +1,37 @@
+package account
+
+import (
+	"context"
+
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+)
+
+// ImageStore removes uploaded images of a deleted account.
+type ImageStore interface {
+	RemoveImage(string) error
+}
+
+type cloudinaryImages struct {
+	cloudinary *cloudinary.Cloudinary
+}
+
+func NewCloudinaryImages(cloudinary *cloudinary.Cloudinary) *cloudinaryImages {
+	return &cloudinaryImages{
+		cloudinary: cloudinary,
+	}
+}
+
+// RemoveImage deletes the image at imageURL from Cloudinary. Images hosted
+// elsewhere, such as Google profile pictures, are ignored.
+func (s *cloudinaryImages) RemoveImage(imageURL string) error {
+	publicID, ok := helper.CloudinaryPublicID(imageURL)
+	if !ok {
+		return nil
+	}
+	_, err := s.cloudinary.Upload.Destroy(context.Background(), uploader.DestroyParams{
+		PublicID: publicID,
+	})
+	return err
+}
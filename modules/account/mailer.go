@@ -0,0 +1,6 @@
+package account
+
+import "github.com/OctavianoRyan25/be-agriculture/utils/helper"
+
+// templateDir holds the html templates of the emails about account data.
+var templateDir = helper.TemplateDir("account")
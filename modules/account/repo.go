@@ -0,0 +1,227 @@
+package account
+
+import (
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/modules/device"
+	"github.com/OctavianoRyan25/be-agriculture/modules/notification"
+	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+	"github.com/OctavianoRyan25/be-agriculture/modules/session"
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+	wateringhistory "github.com/OctavianoRyan25/be-agriculture/modules/watering_history"
+	"gorm.io/gorm"
+)
+
+// UserData holds every record of a user that goes into a data export.
+type UserData struct {
+	User                       user.User
+	UserPlants                 []plant.UserPlant
+	PlantProgress              []plant.PlantProgress
+	WateringHistories          []wateringhistory.WateringHistory
+	Notifications              []notification.Notification
+	CustomizeWateringReminders []notification.CustomizeWateringReminder
+	UserPlantHistories         []plant.UserPlantHistory
+}
+
+type Repository interface {
+	GetUser(int) (*user.User, error)
+	GetUserData(int) (*UserData, error)
+	CreateExport(*DataExport) error
+	GetExport(int) (*DataExport, error)
+	GetExports(int) ([]DataExport, error)
+	GetLatestExport(int) (*DataExport, error)
+	GetPendingExports(time.Time) ([]DataExport, error)
+	ClaimExport(int, time.Time) (bool, error)
+	UpdateExport(*DataExport) error
+	GetExpiredExports(time.Time) ([]DataExport, error)
+	DeleteExport(int) error
+	CreateDeletionRequest(*DeletionRequest) error
+	GetDeletionRequest(int) (*DeletionRequest, error)
+	DeleteDeletionRequest(int) (bool, error)
+	GetDueDeletionRequests(time.Time) ([]DeletionRequest, error)
+	PurgeUser(*user.User) error
+}
+
+type accountRepository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *accountRepository {
+	return &accountRepository{
+		db: db,
+	}
+}
+
+func (r *accountRepository) GetUser(id int) (*user.User, error) {
+	var u user.User
+	err := r.db.Where("id = ?", id).First(&u).Error
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *accountRepository) GetUserData(id int) (*UserData, error) {
+	u, err := r.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	data := &UserData{User: *u}
+
+	err = r.db.Preload("Plant").Where("user_id = ?", id).Order("id").Find(&data.UserPlants).Error
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.Where("user_id = ?", id).Order("id").Find(&data.PlantProgress).Error
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.Preload("Plant").Where("user_id = ?", id).Order("id").Find(&data.WateringHistories).Error
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.Where("user_id = ?", id).Order("id").Find(&data.Notifications).Error
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.Where("user_id = ?", id).Order("id").Find(&data.CustomizeWateringReminders).Error
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.Where("user_id = ?", id).Order("id").Find(&data.UserPlantHistories).Error
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (r *accountRepository) CreateExport(export *DataExport) error {
+	return r.db.Create(export).Error
+}
+
+func (r *accountRepository) GetExport(id int) (*DataExport, error) {
+	var export DataExport
+	err := r.db.Where("id = ?", id).First(&export).Error
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+func (r *accountRepository) GetExports(userID int) ([]DataExport, error) {
+	var exports []DataExport
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&exports).Error
+	return exports, err
+}
+
+func (r *accountRepository) GetLatestExport(userID int) (*DataExport, error) {
+	var export DataExport
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").First(&export).Error
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// GetPendingExports returns the exports waiting to be built, including the
+// ones stuck in processing since before staleBefore.
+func (r *accountRepository) GetPendingExports(staleBefore time.Time) ([]DataExport, error) {
+	var exports []DataExport
+	err := r.db.Where("status = ? OR (status = ? AND updated_at < ?)", ExportPending, ExportProcessing, staleBefore).
+		Order("id").Find(&exports).Error
+	return exports, err
+}
+
+// ClaimExport marks a pending or stale export as processing. It reports false
+// when another instance claimed it first.
+func (r *accountRepository) ClaimExport(id int, staleBefore time.Time) (bool, error) {
+	result := r.db.Model(&DataExport{}).
+		Where("id = ? AND (status = ? OR (status = ? AND updated_at < ?))", id, ExportPending, ExportProcessing, staleBefore).
+		Updates(map[string]interface{}{
+			"status":     ExportProcessing,
+			"updated_at": time.Now(),
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *accountRepository) UpdateExport(export *DataExport) error {
+	return r.db.Save(export).Error
+}
+
+func (r *accountRepository) GetExpiredExports(now time.Time) ([]DataExport, error) {
+	var exports []DataExport
+	err := r.db.Where("expires_at < ?", now).Find(&exports).Error
+	return exports, err
+}
+
+func (r *accountRepository) DeleteExport(id int) error {
+	return r.db.Where("id = ?", id).Delete(&DataExport{}).Error
+}
+
+func (r *accountRepository) CreateDeletionRequest(request *DeletionRequest) error {
+	return r.db.Create(request).Error
+}
+
+func (r *accountRepository) GetDeletionRequest(userID int) (*DeletionRequest, error) {
+	var request DeletionRequest
+	err := r.db.Where("user_id = ?", userID).First(&request).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *accountRepository) DeleteDeletionRequest(userID int) (bool, error) {
+	result := r.db.Where("user_id = ?", userID).Delete(&DeletionRequest{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *accountRepository) GetDueDeletionRequests(now time.Time) ([]DeletionRequest, error) {
+	var requests []DeletionRequest
+	err := r.db.Where("scheduled_at <= ?", now).Find(&requests).Error
+	return requests, err
+}
+
+// PurgeUser deletes the user and every record that belongs to them in one
+// transaction.
+func (r *accountRepository) PurgeUser(u *user.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		byUser := []interface{}{
+			&notification.Notification{},
+			&notification.CustomizeWateringReminder{},
+			&notification.ReminderJob{},
+			&notification.NotificationDelivery{},
+			&notification.DeadLetter{},
+			&notification.NotificationPreference{},
+			&notification.QuietHours{},
+			&notification.ReminderSkip{},
+			&wateringhistory.WateringHistory{},
+			&wateringhistory.WateringSkip{},
+			&plant.UserPlantHistory{},
+			&plant.PlantProgress{},
+			&plant.UserPlant{},
+			&device.Device{},
+			&user.Identity{},
+			&user.PasswordReset{},
+			&user.OAuthState{},
+			&DataExport{},
+			&DeletionRequest{},
+		}
+		for _, model := range byUser {
+			err := tx.Unscoped().Where("user_id = ?", u.ID).Delete(model).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		err := tx.Where("account_id = ? AND role = ?", u.ID, "user").Delete(&session.Session{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("email = ?", u.Email).Delete(&user.OTPSend{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("id = ?", u.ID).Delete(&user.User{}).Error
+	})
+}
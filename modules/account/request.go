@@ -0,0 +1,5 @@
+package account
+
+type DeletionRequestRequest struct {
+	Password string `json:"password"`
+}
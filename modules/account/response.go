@@ -0,0 +1,18 @@
+package account
+
+import (
+	"time"
+)
+
+type DataExportResponse struct {
+	ID          int        `json:"id"`
+	Status      string     `json:"status"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type DeletionResponse struct {
+	ScheduledAt time.Time `json:"scheduled_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package account
+
+import (
+	"github.com/robfig/cron/v3"
+)
+
+// StartScheduler builds the queued data exports every minute and, every
+// hour, deletes expired exports and the accounts whose grace period ended.
+func StartScheduler(useCase UseCase) {
+	c := cron.New()
+	c.AddFunc("@every 1m", useCase.ProcessExports)
+	c.AddFunc("0 * * * *", func() {
+		useCase.RemoveExpiredExports()
+		useCase.PurgeDueAccounts()
+	})
+	c.Start()
+}
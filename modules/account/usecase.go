@@ -0,0 +1,367 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/modules/lockout"
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"gorm.io/gorm"
+)
+
+const (
+	exportTTL           = 7 * 24 * time.Hour
+	exportCooldown      = 24 * time.Hour
+	exportStaleAfter    = time.Hour
+	deletionGracePeriod = 14 * 24 * time.Hour
+)
+
+// EXPORT_DIR is where the export archives are written. It must be shared by
+// all instances of the app.
+var EXPORT_DIR = os.Getenv("EXPORT_DIR")
+
+type UseCase interface {
+	RequestExport(uint) (*DataExport, int, error)
+	GetExports(uint) ([]DataExport, int, error)
+	GetExportFile(uint, int) (string, int, error)
+	ProcessExports()
+	RemoveExpiredExports()
+	RequestDeletion(uint, string) (*DeletionRequest, int, error)
+	GetDeletion(uint) (*DeletionRequest, int, error)
+	CancelDeletion(uint) (int, error)
+	PurgeDueAccounts()
+}
+
+type accountUseCase struct {
+	repo    Repository
+	images  ImageStore
+	lockout lockout.UseCase
+	fetch   fetchImage
+	dir     string
+}
+
+func NewUseCase(repo Repository, images ImageStore, lockout lockout.UseCase) *accountUseCase {
+	dir := EXPORT_DIR
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "plantopia-exports")
+	}
+	return &accountUseCase{
+		repo:    repo,
+		images:  images,
+		lockout: lockout,
+		fetch:   httpFetchImage,
+		dir:     dir,
+	}
+}
+
+// RequestExport queues a data export. Only one export can be requested per
+// day.
+func (uc *accountUseCase) RequestExport(userID uint) (*DataExport, int, error) {
+	latest, err := uc.repo.GetLatestExport(int(userID))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if err == nil {
+		if latest.Status == ExportPending || latest.Status == ExportProcessing {
+			return nil, constants.ErrCodeExportInProgress, errors.New(constants.ErrExportInProgress)
+		}
+		if latest.Status == ExportReady && time.Since(latest.CreatedAt) < exportCooldown {
+			return nil, constants.ErrCodeExportCooldown, errors.New(constants.ErrExportCooldown)
+		}
+	}
+
+	export := &DataExport{
+		UserID:    int(userID),
+		Status:    ExportPending,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	err = uc.repo.CreateExport(export)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return export, constants.CodeSuccess, nil
+}
+
+func (uc *accountUseCase) GetExports(userID uint) ([]DataExport, int, error) {
+	exports, err := uc.repo.GetExports(int(userID))
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return exports, constants.CodeSuccess, nil
+}
+
+// GetExportFile returns the path of a ready export archive of the user.
+func (uc *accountUseCase) GetExportFile(userID uint, id int) (string, int, error) {
+	export, err := uc.repo.GetExport(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", constants.ErrCodeExportNotFound, errors.New(constants.ErrExportNotFound)
+		}
+		return "", constants.ErrCodeBadRequest, err
+	}
+	if export.UserID != int(userID) {
+		return "", constants.ErrCodeExportNotFound, errors.New(constants.ErrExportNotFound)
+	}
+	if export.Status != ExportReady {
+		return "", constants.ErrCodeExportNotReady, errors.New(constants.ErrExportNotReady)
+	}
+	return export.FilePath, constants.CodeSuccess, nil
+}
+
+// ProcessExports builds the archives of the queued exports. It is run by the
+// scheduler.
+func (uc *accountUseCase) ProcessExports() {
+	staleBefore := time.Now().Add(-exportStaleAfter)
+	exports, err := uc.repo.GetPendingExports(staleBefore)
+	if err != nil {
+		log.Println("Failed to get pending exports:", err)
+		return
+	}
+
+	for _, export := range exports {
+		claimed, err := uc.repo.ClaimExport(export.ID, staleBefore)
+		if err != nil {
+			log.Println("Failed to claim export:", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		uc.processExport(&export)
+	}
+}
+
+func (uc *accountUseCase) processExport(export *DataExport) {
+	data, err := uc.repo.GetUserData(export.UserID)
+	if err == nil {
+		export.FilePath, err = uc.buildArchive(export, data)
+	}
+
+	now := time.Now()
+	export.CompletedAt = &now
+	export.UpdatedAt = now
+	if err != nil {
+		log.Printf("Failed to build export %d: %v\n", export.ID, err)
+		export.Status = ExportFailed
+		export.Error = err.Error()
+	} else {
+		expiresAt := now.Add(exportTTL)
+		export.Status = ExportReady
+		export.ExpiresAt = &expiresAt
+	}
+
+	err = uc.repo.UpdateExport(export)
+	if err != nil {
+		log.Println("Failed to update export:", err)
+		return
+	}
+
+	if export.Status == ExportReady {
+		mailData := struct {
+			Username string
+			Days     int
+		}{
+			Username: data.User.Name,
+			Days:     int(exportTTL.Hours() / 24),
+		}
+		err = helper.SendTemplateEmail(templateDir, data.User.Email, "Your Data Export Is Ready", "export_ready.html", mailData)
+		if err != nil {
+			log.Println("Failed to send export email:", err)
+		}
+	}
+}
+
+func (uc *accountUseCase) buildArchive(export *DataExport, data *UserData) (string, error) {
+	err := os.MkdirAll(uc.dir, 0o700)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(uc.dir, fmt.Sprintf("export-%d-%s.zip", export.ID, helper.RandomToken()[:16]))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	err = writeArchive(f, data, uc.fetch)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// RemoveExpiredExports deletes the archives whose download period ended.
+func (uc *accountUseCase) RemoveExpiredExports() {
+	exports, err := uc.repo.GetExpiredExports(time.Now())
+	if err != nil {
+		log.Println("Failed to get expired exports:", err)
+		return
+	}
+
+	for _, export := range exports {
+		uc.removeExport(export)
+	}
+}
+
+func (uc *accountUseCase) removeExport(export DataExport) {
+	if export.FilePath != "" {
+		err := os.Remove(export.FilePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Println("Failed to remove export file:", err)
+			return
+		}
+	}
+	err := uc.repo.DeleteExport(export.ID)
+	if err != nil {
+		log.Println("Failed to delete export:", err)
+	}
+}
+
+// RequestDeletion schedules the deletion of the account after the grace
+// period. Accounts with a password must confirm it, accounts that only sign
+// in with Google are confirmed by their session.
+func (uc *accountUseCase) RequestDeletion(userID uint, password string) (*DeletionRequest, int, error) {
+	u, err := uc.repo.GetUser(int(userID))
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if u.Password != "" && !user.ComparePass([]byte(u.Password), []byte(password)) {
+		return nil, constants.ErrCodeInvalidPassword, errors.New(constants.ErrInvalidPassword)
+	}
+
+	_, err = uc.repo.GetDeletionRequest(u.ID)
+	if err == nil {
+		return nil, constants.ErrCodeDeletionAlreadyRequested, errors.New(constants.ErrDeletionAlreadyRequested)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	request := &DeletionRequest{
+		UserID:      u.ID,
+		ScheduledAt: time.Now().Add(deletionGracePeriod),
+		CreatedAt:   time.Now(),
+	}
+	err = uc.repo.CreateDeletionRequest(request)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	location := u.Location()
+	mailData := struct {
+		Username string
+		Date     string
+	}{
+		Username: u.Name,
+		Date:     request.ScheduledAt.In(location).Format("02 January 2006 15:04 MST"),
+	}
+	err = helper.SendTemplateEmail(templateDir, u.Email, "Account Deletion Scheduled", "deletion_scheduled.html", mailData)
+	if err != nil {
+		log.Println("Failed to send deletion email:", err)
+	}
+	return request, constants.CodeSuccess, nil
+}
+
+func (uc *accountUseCase) GetDeletion(userID uint) (*DeletionRequest, int, error) {
+	request, err := uc.repo.GetDeletionRequest(int(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constants.ErrCodeDeletionNotFound, errors.New(constants.ErrDeletionNotFound)
+		}
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return request, constants.CodeSuccess, nil
+}
+
+func (uc *accountUseCase) CancelDeletion(userID uint) (int, error) {
+	deleted, err := uc.repo.DeleteDeletionRequest(int(userID))
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	if !deleted {
+		return constants.ErrCodeDeletionNotFound, errors.New(constants.ErrDeletionNotFound)
+	}
+	return constants.CodeSuccess, nil
+}
+
+// PurgeDueAccounts deletes the accounts whose grace period ended. It is run
+// by the scheduler.
+func (uc *accountUseCase) PurgeDueAccounts() {
+	requests, err := uc.repo.GetDueDeletionRequests(time.Now())
+	if err != nil {
+		log.Println("Failed to get due deletion requests:", err)
+		return
+	}
+
+	for _, request := range requests {
+		err := uc.purgeAccount(request.UserID)
+		if err != nil {
+			log.Printf("Failed to delete account %d: %v\n", request.UserID, err)
+		}
+	}
+}
+
+// purgeAccount removes the uploaded images and export archives of the user
+// first, so a failure leaves the account in place to be retried on the next
+// run.
+func (uc *accountUseCase) purgeAccount(userID int) error {
+	data, err := uc.repo.GetUserData(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, progress := range data.PlantProgress {
+		err = uc.images.RemoveImage(progress.ImageURL)
+		if err != nil {
+			return err
+		}
+	}
+	for _, watering := range data.WateringHistories {
+		if watering.PhotoURL == "" {
+			continue
+		}
+		err = uc.images.RemoveImage(watering.PhotoURL)
+		if err != nil {
+			return err
+		}
+	}
+	if data.User.Url_Image != "" {
+		err = uc.images.RemoveImage(data.User.Url_Image)
+		if err != nil {
+			return err
+		}
+	}
+
+	exports, err := uc.repo.GetExports(userID)
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		if export.FilePath == "" {
+			continue
+		}
+		err = os.Remove(export.FilePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	err = uc.repo.PurgeUser(&data.User)
+	if err != nil {
+		return err
+	}
+
+	// Drop the failed login counter keyed by the email
+	return uc.lockout.Succeed("user", data.User.Email)
+}
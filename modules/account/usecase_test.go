@@ -0,0 +1,202 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+	wateringhistory "github.com/OctavianoRyan25/be-agriculture/modules/watering_history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository implements the methods used by the tests, the embedded
+// Repository panics on anything else.
+type MockRepository struct {
+	mock.Mock
+	Repository
+}
+
+func (m *MockRepository) GetLatestExport(userID int) (*DataExport, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*DataExport), args.Error(1)
+}
+
+func (m *MockRepository) CreateExport(export *DataExport) error {
+	args := m.Called(export)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetExport(id int) (*DataExport, error) {
+	args := m.Called(id)
+	return args.Get(0).(*DataExport), args.Error(1)
+}
+
+func (m *MockRepository) GetUserData(id int) (*UserData, error) {
+	args := m.Called(id)
+	return args.Get(0).(*UserData), args.Error(1)
+}
+
+func (m *MockRepository) DeleteDeletionRequest(userID int) (bool, error) {
+	args := m.Called(userID)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockImages struct {
+	mock.Mock
+}
+
+func (m *MockImages) RemoveImage(url string) error {
+	args := m.Called(url)
+	return args.Error(0)
+}
+
+func TestRequestExportRejectsPendingExport(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetLatestExport", 1).Return(&DataExport{ID: 1, Status: ExportPending}, nil)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	_, code, err := service.RequestExport(1)
+
+	assert.EqualError(t, err, constants.ErrExportInProgress)
+	assert.Equal(t, constants.ErrCodeExportInProgress, code)
+}
+
+func TestRequestExportAllowsOnePerDay(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetLatestExport", 1).Return(&DataExport{ID: 1, Status: ExportReady, CreatedAt: time.Now().Add(-time.Hour)}, nil)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	_, code, err := service.RequestExport(1)
+
+	assert.EqualError(t, err, constants.ErrExportCooldown)
+	assert.Equal(t, constants.ErrCodeExportCooldown, code)
+}
+
+func TestRequestExportRetriesFailedExport(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetLatestExport", 1).Return(&DataExport{ID: 1, Status: ExportFailed, CreatedAt: time.Now()}, nil)
+	mockRepo.On("CreateExport", mock.Anything).Return(nil)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	export, _, err := service.RequestExport(1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ExportPending, export.Status)
+}
+
+func TestGetExportFileChecksOwner(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetExport", 5).Return(&DataExport{ID: 5, UserID: 2, Status: ExportReady, FilePath: "/tmp/x.zip"}, nil)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	_, code, err := service.GetExportFile(1, 5)
+
+	assert.EqualError(t, err, constants.ErrExportNotFound)
+	assert.Equal(t, constants.ErrCodeExportNotFound, code)
+}
+
+func TestCancelDeletionWithoutRequest(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("DeleteDeletionRequest", 1).Return(false, nil)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	code, err := service.CancelDeletion(1)
+
+	assert.EqualError(t, err, constants.ErrDeletionNotFound)
+	assert.Equal(t, constants.ErrCodeDeletionNotFound, code)
+}
+
+func TestPurgeAccountKeepsDataWhenImageRemovalFails(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockImages := new(MockImages)
+	data := &UserData{
+		User:          user.User{ID: 1, Email: "farmer@example.com"},
+		PlantProgress: []plant.PlantProgress{{ID: 1, ImageURL: "https://res.cloudinary.com/demo/image/upload/v1/be-agriculture/a.jpg"}},
+	}
+	mockRepo.On("GetUserData", 1).Return(data, nil)
+	mockImages.On("RemoveImage", data.PlantProgress[0].ImageURL).Return(errors.New("unavailable"))
+	service := NewUseCase(mockRepo, mockImages, nil)
+
+	err := service.purgeAccount(1)
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "PurgeUser", mock.Anything)
+}
+
+func TestPurgeAccountRemovesWateringPhotos(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockImages := new(MockImages)
+	photo := "https://res.cloudinary.com/demo/image/upload/v1/be-agriculture/watering/w.jpg"
+	data := &UserData{
+		User:              user.User{ID: 1, Email: "farmer@example.com"},
+		WateringHistories: []wateringhistory.WateringHistory{{ID: 1, PhotoURL: photo}, {ID: 2}},
+	}
+	mockRepo.On("GetUserData", 1).Return(data, nil)
+	mockImages.On("RemoveImage", photo).Return(errors.New("unavailable"))
+	service := NewUseCase(mockRepo, mockImages, nil)
+
+	err := service.purgeAccount(1)
+
+	assert.Error(t, err)
+	mockImages.AssertNumberOfCalls(t, "RemoveImage", 1)
+	mockRepo.AssertNotCalled(t, "PurgeUser", mock.Anything)
+}
+
+func TestWriteArchive(t *testing.T) {
+	userPlantID := 3
+	data := &UserData{
+		User: user.User{ID: 1, Name: "Farmer", Email: "farmer@example.com", Password: "hash", OTP: "123456"},
+		PlantProgress: []plant.PlantProgress{
+			{ID: 1, PlantID: 2, ImageURL: "https://res.cloudinary.com/demo/image/upload/v1/be-agriculture/a.jpg"},
+			{ID: 2, PlantID: 2, ImageURL: "https://example.com/missing.png"},
+		},
+		UserPlantHistories: []plant.UserPlantHistory{{ID: 1, PlantID: 2, PlantName: "Tomat"}},
+		WateringHistories: []wateringhistory.WateringHistory{
+			{ID: 4, UserPlantID: &userPlantID, PlantID: 2, Amount: 250, Unit: "ml", Method: "can", Note: "morning", WateredAt: time.Date(2024, time.January, 4, 7, 0, 0, 0, time.UTC), PhotoURL: "https://res.cloudinary.com/demo/image/upload/v1/be-agriculture/watering/w.jpg"},
+		},
+	}
+	fetch := func(url string) ([]byte, error) {
+		if url == data.PlantProgress[0].ImageURL {
+			return []byte("jpeg"), nil
+		}
+		return nil, errors.New("not found")
+	}
+
+	var buf bytes.Buffer
+	err := writeArchive(&buf, data, fetch)
+	assert.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+
+	assert.Contains(t, files["user.json"], "farmer@example.com")
+	assert.NotContains(t, files["user.json"], "hash")
+	assert.NotContains(t, files["user.json"], "123456")
+	assert.Equal(t, "jpeg", files["progress/1.jpg"])
+	assert.Contains(t, files["plant_progress.csv"], "progress/1.jpg")
+	assert.Contains(t, files["plant_progress.csv"], "https://example.com/missing.png")
+	assert.Contains(t, files["plant_history.csv"], "Tomat")
+	assert.Contains(t, files["watering_history.csv"], "user_plant_id,plant_id,plant_name,watered_at,amount,unit,method,note,photo_url")
+	assert.Contains(t, files["watering_history.csv"], "4,3,2,,2024-01-04T07:00:00Z,250,ml,can,morning,https://res.cloudinary.com/demo/image/upload/v1/be-agriculture/watering/w.jpg")
+	for _, name := range []string{"user_plants.csv", "watering_history.csv", "notifications.csv", "watering_reminders.csv"} {
+		assert.Contains(t, files, name)
+	}
+}
@@ -1,27 +1,39 @@
 package admin
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/OctavianoRyan25/be-agriculture/base"
-	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"github.com/OctavianoRyan25/be-agriculture/modules/audit"
+	"github.com/OctavianoRyan25/be-agriculture/modules/lockout"
+	"github.com/OctavianoRyan25/be-agriculture/modules/session"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
 type AdminController struct {
-	adminUseCase adminUseCase
+	adminUseCase   adminUseCase
+	sessionUseCase session.UseCase
+	auditUseCase   audit.UseCase
 }
 
-func NewUserController(adminUseCase adminUseCase) *AdminController {
+func NewUserController(adminUseCase adminUseCase, sessionUseCase session.UseCase, auditUseCase audit.UseCase) *AdminController {
 	return &AdminController{
-		adminUseCase: adminUseCase,
+		adminUseCase:   adminUseCase,
+		sessionUseCase: sessionUseCase,
+		auditUseCase:   auditUseCase,
 	}
 }
 
-func (c *AdminController) RegisterUser(ctx echo.Context) error {
-	req := new(AdminRequest)
-	//Mapping UserRequest to User
+// InviteAdmin emails an invitation to create an admin account. Admins can
+// no longer register themselves.
+func (c *AdminController) InviteAdmin(ctx echo.Context) error {
+	adminID := ctx.Get("user_id").(uint)
+
+	req := new(InviteAdminRequest)
 	err := ctx.Bind(&req)
 	if err != nil {
 		errRes := base.ErrorResponse{
@@ -44,10 +56,51 @@ func (c *AdminController) RegisterUser(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, errRes)
 	}
 
-	mapped := MapUserRequestToUser(req)
-	mapped.Password = HashPass(req.Password)
+	invitation, code, err := c.adminUseCase.InviteAdmin(adminID, req.Email, req.Role)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	audit.Record(ctx, c.auditUseCase, audit.ActionInvite, "admin_invitation", invitation.ID, nil, MapInvitationToResponse(invitation))
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Invitation sent",
+		Data:    MapInvitationToResponse(invitation),
+	}
 
-	registeredUser, code, err := c.adminUseCase.RegisterUser(mapped)
+	return ctx.JSON(http.StatusCreated, res)
+}
+
+func (c *AdminController) VerifyInvitation(ctx echo.Context) error {
+	req := new(VerifyInvitationRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	invitation, code, err := c.adminUseCase.VerifyInvitation(req.Token)
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
@@ -57,12 +110,133 @@ func (c *AdminController) RegisterUser(ctx echo.Context) error {
 		return ctx.JSON(code, errRes)
 	}
 
-	mappedRes := MapUserToResponse(registeredUser)
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Invitation is valid",
+		Data:    MapInvitationToResponse(invitation),
+	}
+
+	return ctx.JSON(code, res)
+}
+
+func (c *AdminController) AcceptInvitation(ctx echo.Context) error {
+	req := new(AcceptInvitationRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	mapped := &Admin{
+		Name:      req.Name,
+		Password:  HashPass(req.Password),
+		Url_Image: req.Url_Image,
+	}
+
+	admin, code, err := c.adminUseCase.AcceptInvitation(req.Token, mapped)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
 
 	res := base.SuccessResponse{
 		Status:  "success",
 		Message: "User registered",
-		Data:    mappedRes,
+		Data:    MapUserToResponse(admin),
+	}
+
+	return ctx.JSON(http.StatusCreated, res)
+}
+
+func (c *AdminController) GetAdmins(ctx echo.Context) error {
+	admins, code, err := c.adminUseCase.GetAdmins()
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	mapped := []AdminResponse{}
+	for i := range admins {
+		mapped = append(mapped, *MapUserToResponse(&admins[i]))
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Admins fetched",
+		Data:    mapped,
+	}
+
+	return ctx.JSON(code, res)
+}
+
+func (c *AdminController) DeactivateAdmin(ctx echo.Context) error {
+	return c.setAdminActive(ctx, false)
+}
+
+func (c *AdminController) ActivateAdmin(ctx echo.Context) error {
+	return c.setAdminActive(ctx, true)
+}
+
+func (c *AdminController) setAdminActive(ctx echo.Context, active bool) error {
+	adminID := ctx.Get("user_id").(uint)
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || id <= 0 {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: "Invalid admin id",
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	before, _, _ := c.adminUseCase.GetUserProfile(uint(id))
+
+	code, err := c.adminUseCase.SetAdminActive(adminID, uint(id), active)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	action, message := audit.ActionDeactivate, "Admin deactivated"
+	if active {
+		action, message = audit.ActionActivate, "Admin activated"
+	}
+	if before != nil {
+		after := *before
+		after.Is_Active = active
+		audit.Record(ctx, c.auditUseCase, action, "admin", id, MapUserToResponse(before), MapUserToResponse(&after))
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: message,
 	}
 
 	return ctx.JSON(code, res)
@@ -123,8 +297,12 @@ func (c *AdminController) Login(ctx echo.Context) error {
 	}
 
 	mapped := MapLoginRequestToUser(req)
-	user, code, err := c.adminUseCase.Login(mapped)
+	user, code, err := c.adminUseCase.Login(mapped, ctx.RealIP())
 	if err != nil {
+		var blocked *lockout.BlockedError
+		if errors.As(err, &blocked) {
+			ctx.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+		}
 		errRes := base.ErrorResponse{
 			Status:  "error",
 			Message: err.Error(),
@@ -133,17 +311,7 @@ func (c *AdminController) Login(ctx echo.Context) error {
 		return ctx.JSON(code, errRes)
 	}
 
-	comparePass := ComparePass([]byte(user.Password), []byte(req.Password))
-	if !comparePass {
-		errRes := base.ErrorResponse{
-			Status:  "error",
-			Message: "Invalid email or password",
-			Code:    400,
-		}
-		return ctx.JSON(http.StatusBadRequest, errRes)
-	}
-
-	token, err := helper.GenerateToken(uint(user.ID), user.Email, "admin")
+	pair, _, err := c.sessionUseCase.CreateSession(uint(user.ID), user.Email, "admin", ctx.Request().UserAgent())
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
@@ -153,7 +321,9 @@ func (c *AdminController) Login(ctx echo.Context) error {
 		return ctx.JSON(http.StatusInternalServerError, errRes)
 	}
 	resToken := LoginResponse{
-		Token: token,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    pair.ExpiresAt,
 	}
 	res := base.SuccessResponse{
 		Status:  "success",
@@ -174,17 +344,6 @@ func (c *AdminController) GetUserProfile(ctx echo.Context) error {
 		}
 		return ctx.JSON(http.StatusBadRequest, errRes)
 	}
-	role := ctx.Get("role").(string)
-	if role != "admin" {
-		errRes := base.ErrorResponse{
-			Status:  "error",
-			Message: "Forbidden access",
-			Code:    http.StatusForbidden,
-		}
-		return ctx.JSON(http.StatusForbidden, errRes)
-
-	}
-
 	user, code, err := c.adminUseCase.GetUserProfile(userId)
 	if err != nil {
 		errRes := base.ErrorResponse{
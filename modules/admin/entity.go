@@ -4,12 +4,30 @@ import (
 	"time"
 )
 
+// Admin is an account of the admin panel. Role is empty, with no
+// permissions, until the bootstrap or an accepted invitation grants one, so
+// accounts made through the old public registration get no access.
 type Admin struct {
 	ID         int `gorm:"primaryKey"`
 	Name       string
 	Email      string
 	Password   string
 	Url_Image  string
+	Role       string `gorm:"size:32;default:''"`
+	Is_Active  bool   `gorm:"default:true"`
 	Created_at time.Time
 	Updated_at time.Time
 }
+
+// AdminInvitation lets the invited email create an admin account with the
+// given role. Only the SHA-256 hash of the token sent by email is stored.
+type AdminInvitation struct {
+	ID          int    `gorm:"primaryKey"`
+	Email       string `gorm:"size:255;index"`
+	Role        string `gorm:"size:32"`
+	Token       string `gorm:"size:64;uniqueIndex"`
+	Invited_by  int
+	Expires_at  time.Time
+	Accepted_at *time.Time
+	Created_at  time.Time
+}
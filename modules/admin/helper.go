@@ -1,15 +1,14 @@
 package admin
 
-import (
-	"math/rand"
-)
+import "math/rand"
 
-func MapUserRequestToUser(userRequest *AdminRequest) *Admin {
-	return &Admin{
-		Name:      userRequest.Name,
-		Email:     userRequest.Email,
-		Password:  userRequest.Password,
-		Url_Image: userRequest.Url_Image,
+func MapInvitationToResponse(invitation *AdminInvitation) *InvitationResponse {
+	return &InvitationResponse{
+		ID:         invitation.ID,
+		Email:      invitation.Email,
+		Role:       invitation.Role,
+		Expires_at: invitation.Expires_at,
+		Created_at: invitation.Created_at,
 	}
 }
 
@@ -19,6 +18,8 @@ func MapUserToResponse(user *Admin) *AdminResponse {
 		Name:       user.Name,
 		Email:      user.Email,
 		Url_Image:  user.Url_Image,
+		Role:       user.Role,
+		Is_Active:  user.Is_Active,
 		Created_at: user.Created_at,
 	}
 }
@@ -0,0 +1,12 @@
+package admin
+
+import (
+	"os"
+
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+)
+
+var ADMIN_INVITE_URL = os.Getenv("ADMIN_INVITE_URL")
+
+// templateDir holds the html templates of the emails sent to admins.
+var templateDir = helper.TemplateDir("admin")
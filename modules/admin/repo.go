@@ -3,7 +3,9 @@ package admin
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/OctavianoRyan25/be-agriculture/middlewares"
 	"gorm.io/gorm"
 )
 
@@ -12,6 +14,15 @@ type Repository interface {
 	IsDuplicateEmail(string) (bool, error)
 	Login(*Admin) (*Admin, error)
 	GetUserProfile(uint) (*Admin, error)
+	GetAdminRole(uint) (string, error)
+	CountSuperAdmins() (int64, error)
+	GetAdminByEmail(string) (*Admin, error)
+	UpdateAdmin(*Admin) error
+	GetAdmins() ([]Admin, error)
+	SetActive(uint, bool) error
+	CreateInvitation(*AdminInvitation) error
+	GetInvitation(string) (*AdminInvitation, error)
+	AcceptInvitation(*AdminInvitation, *Admin) error
 }
 
 type adminRespository struct {
@@ -62,3 +73,84 @@ func (r *adminRespository) GetUserProfile(id uint) (*Admin, error) {
 	}
 	return &admin, nil
 }
+
+// GetAdminRole returns the role of an active admin. Deactivated admins have
+// no role, so they lose every permission immediately.
+func (r *adminRespository) GetAdminRole(id uint) (string, error) {
+	var admin Admin
+	err := r.db.Select("role", "is_active").Where("id = ?", id).First(&admin).Error
+	if err != nil {
+		return "", err
+	}
+	if !admin.Is_Active {
+		return "", nil
+	}
+	return admin.Role, nil
+}
+
+// CountSuperAdmins counts the active super admins.
+func (r *adminRespository) CountSuperAdmins() (int64, error) {
+	var count int64
+	err := r.db.Model(&Admin{}).Where("role = ? AND is_active = ?", middlewares.RoleSuperAdmin, true).Count(&count).Error
+	return count, err
+}
+
+func (r *adminRespository) GetAdminByEmail(email string) (*Admin, error) {
+	var admin Admin
+	err := r.db.Where("email = ?", email).First(&admin).Error
+	if err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+func (r *adminRespository) UpdateAdmin(admin *Admin) error {
+	return r.db.Save(admin).Error
+}
+
+func (r *adminRespository) GetAdmins() ([]Admin, error) {
+	var admins []Admin
+	err := r.db.Order("id").Find(&admins).Error
+	if err != nil {
+		return nil, err
+	}
+	return admins, nil
+}
+
+func (r *adminRespository) SetActive(id uint, active bool) error {
+	return r.db.Model(&Admin{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"is_active":  active,
+		"updated_at": time.Now(),
+	}).Error
+}
+
+func (r *adminRespository) CreateInvitation(invitation *AdminInvitation) error {
+	return r.db.Create(invitation).Error
+}
+
+func (r *adminRespository) GetInvitation(token string) (*AdminInvitation, error) {
+	var invitation AdminInvitation
+	err := r.db.Where("token = ?", token).First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// AcceptInvitation consumes the invitation and creates the admin in one
+// transaction, so an invitation cannot create two accounts.
+func (r *adminRespository) AcceptInvitation(invitation *AdminInvitation, admin *Admin) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&AdminInvitation{}).
+			Where("id = ? AND accepted_at IS NULL", invitation.ID).
+			Update("accepted_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Create(admin).Error
+	})
+}
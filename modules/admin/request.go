@@ -1,16 +1,19 @@
 package admin
 
-import (
-	"time"
-)
+type InviteAdminRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required"`
+}
+
+type VerifyInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
 
-type AdminRequest struct {
-	Name       string    `json:"name" validate:"required"`
-	Email      string    `json:"email" validate:"required,email"`
-	Password   string    `json:"password" validate:"required"`
-	Url_Image  string    `json:"url_image"`
-	Created_at time.Time `json:"created_at"`
-	Updated_at time.Time `json:"updated_at"`
+type AcceptInvitationRequest struct {
+	Token     string `json:"token" validate:"required"`
+	Name      string `json:"name" validate:"required"`
+	Password  string `json:"password" validate:"required"`
+	Url_Image string `json:"url_image"`
 }
 
 type CheckEmailRequest struct {
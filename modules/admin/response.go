@@ -9,9 +9,21 @@ type AdminResponse struct {
 	Name       string    `json:"name"`
 	Email      string    `json:"email"`
 	Url_Image  string    `json:"url_image"`
+	Role       string    `json:"role"`
+	Is_Active  bool      `json:"is_active"`
+	Created_at time.Time `json:"created_at"`
+}
+
+type InvitationResponse struct {
+	ID         int       `json:"id"`
+	Email      string    `json:"email"`
+	Role       string    `json:"role"`
+	Expires_at time.Time `json:"expires_at"`
 	Created_at time.Time `json:"created_at"`
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
@@ -2,41 +2,43 @@ package admin
 
 import (
 	"errors"
+	"log"
 	"time"
 
 	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/middlewares"
+	"github.com/OctavianoRyan25/be-agriculture/modules/lockout"
+	"github.com/OctavianoRyan25/be-agriculture/modules/session"
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"gorm.io/gorm"
 )
 
+const invitationTTL = 72 * time.Hour
+
 type AdminUseCase interface {
-	RegisterUser(*Admin) (*Admin, int, error)
 	CheckEmail(string) (int, error)
-	Login(*Admin) (*Admin, int, error)
+	Login(*Admin, string) (*Admin, int, error)
 	GetUserProfile(uint) (*Admin, int, error)
+	BootstrapAdmin(string, string, string) (bool, error)
+	InviteAdmin(uint, string, string) (*AdminInvitation, int, error)
+	VerifyInvitation(string) (*AdminInvitation, int, error)
+	AcceptInvitation(string, *Admin) (*Admin, int, error)
+	GetAdmins() ([]Admin, int, error)
+	SetAdminActive(uint, uint, bool) (int, error)
 }
 
 type adminUseCase struct {
-	repo Repository
+	repo     Repository
+	sessions session.UseCase
+	lockout  lockout.UseCase
 }
 
-func NewUseCase(repo Repository) *adminUseCase {
+func NewUseCase(repo Repository, sessions session.UseCase, lockout lockout.UseCase) *adminUseCase {
 	return &adminUseCase{
-		repo: repo,
-	}
-}
-
-func (uc *adminUseCase) RegisterUser(user *Admin) (*Admin, int, error) {
-	duplicate, err := uc.repo.IsDuplicateEmail(user.Email)
-	if err != nil {
-		return nil, constants.ErrCodeBadRequest, err
-	}
-	if duplicate {
-		return nil, constants.ErrCodeEmailAlreadyExist, errors.New(constants.ErrEmailAlreadyExist)
+		repo:     repo,
+		sessions: sessions,
+		lockout:  lockout,
 	}
-	user.Created_at = time.Now()
-	user.Updated_at = time.Now()
-
-	registeredUser, err := uc.repo.RegisterUser(user)
-	return registeredUser, constants.CodeSuccess, err
 }
 
 func (uc *adminUseCase) CheckEmail(email string) (int, error) {
@@ -50,14 +52,65 @@ func (uc *adminUseCase) CheckEmail(email string) (int, error) {
 	return constants.CodeSuccess, nil
 }
 
-func (uc *adminUseCase) Login(user *Admin) (*Admin, int, error) {
-	user, err := uc.repo.Login(user)
+// Login checks the email and password of an admin. Admin logins use the
+// stricter "admin" lockout policy.
+func (uc *adminUseCase) Login(user *Admin, ip string) (*Admin, int, error) {
+	email, password := user.Email, user.Password
+
+	err := uc.lockout.Check("admin", email, ip)
+	if err != nil {
+		var blocked *lockout.BlockedError
+		if errors.As(err, &blocked) {
+			return nil, blocked.Code(), blocked
+		}
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	user, err = uc.repo.Login(user)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			code, err := uc.loginFailed(nil, email, ip)
+			return nil, code, err
+		}
 		return nil, constants.ErrCodeBadRequest, err
 	}
+	if !ComparePass([]byte(user.Password), []byte(password)) {
+		code, err := uc.loginFailed(user, email, ip)
+		return nil, code, err
+	}
+
+	err = uc.lockout.Succeed("admin", email)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if !user.Is_Active {
+		return nil, constants.ErrCodeAccountDeactivated, errors.New(constants.ErrAccountDeactivated)
+	}
 	return user, constants.CodeSuccess, nil
 }
 
+// loginFailed counts a failed login and tells the admin when it locked the
+// account. user is nil when no admin has the email.
+func (uc *adminUseCase) loginFailed(user *Admin, email, ip string) (int, error) {
+	locked, err := uc.lockout.Fail("admin", email, ip)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	if locked && user != nil {
+		data := struct {
+			Name string
+		}{
+			Name: user.Name,
+		}
+		err = helper.SendTemplateEmail(templateDir, user.Email, "Admin Account Locked", "account_locked.html", data)
+		if err != nil {
+			log.Println("Failed to send lockout email:", err)
+		}
+	}
+	return constants.ErrCodeInvalidCredentials, errors.New(constants.ErrInvalidCredentials)
+}
+
 func (uc *adminUseCase) GetUserProfile(id uint) (*Admin, int, error) {
 	user, err := uc.repo.GetUserProfile(id)
 	if err != nil {
@@ -65,3 +118,187 @@ func (uc *adminUseCase) GetUserProfile(id uint) (*Admin, int, error) {
 	}
 	return user, constants.CodeSuccess, nil
 }
+
+// BootstrapAdmin makes the given account a super admin while there is no
+// active super admin, so it is safe to run on every start. An admin that
+// already has the email is promoted and gets the bootstrap password, so
+// whoever registered it before cannot sign in with it any more.
+func (uc *adminUseCase) BootstrapAdmin(name, email, password string) (bool, error) {
+	count, err := uc.repo.CountSuperAdmins()
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	existing, err := uc.repo.GetAdminByEmail(email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if existing != nil {
+		existing.Password = password
+		existing.Role = middlewares.RoleSuperAdmin
+		existing.Is_Active = true
+		existing.Updated_at = time.Now()
+		return true, uc.repo.UpdateAdmin(existing)
+	}
+
+	_, err = uc.repo.RegisterUser(&Admin{
+		Name:       name,
+		Email:      email,
+		Password:   password,
+		Role:       middlewares.RoleSuperAdmin,
+		Is_Active:  true,
+		Created_at: time.Now(),
+		Updated_at: time.Now(),
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// InviteAdmin emails a single-use link that lets the address create an admin
+// account with the given role.
+func (uc *adminUseCase) InviteAdmin(inviterID uint, email, role string) (*AdminInvitation, int, error) {
+	if !middlewares.IsAdminRole(role) {
+		return nil, constants.ErrCodeInvalidRole, errors.New(constants.ErrInvalidRole)
+	}
+
+	duplicate, err := uc.repo.IsDuplicateEmail(email)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if duplicate {
+		return nil, constants.ErrCodeEmailAlreadyExist, errors.New(constants.ErrEmailAlreadyExist)
+	}
+
+	inviter, err := uc.repo.GetUserProfile(inviterID)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	token := helper.RandomToken()
+	invitation := &AdminInvitation{
+		Email:      email,
+		Role:       role,
+		Token:      helper.HashToken(token),
+		Invited_by: inviter.ID,
+		Expires_at: time.Now().Add(invitationTTL),
+		Created_at: time.Now(),
+	}
+	err = uc.repo.CreateInvitation(invitation)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	data := struct {
+		Inviter string
+		Role    string
+		Link    string
+		Days    int
+	}{
+		Inviter: inviter.Name,
+		Role:    role,
+		Link:    ADMIN_INVITE_URL + "?token=" + token,
+		Days:    int(invitationTTL.Hours() / 24),
+	}
+	err = helper.SendTemplateEmail(templateDir, email, "Undangan Admin", "invite_admin.html", data)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	return invitation, constants.CodeSuccess, nil
+}
+
+func (uc *adminUseCase) VerifyInvitation(token string) (*AdminInvitation, int, error) {
+	invitation, err := uc.getValidInvitation(token)
+	if err != nil {
+		return nil, constants.ErrCodeInvalidInvitation, err
+	}
+	return invitation, constants.CodeSuccess, nil
+}
+
+// AcceptInvitation creates the invited admin. The email and role come from
+// the invitation, not from the request.
+func (uc *adminUseCase) AcceptInvitation(token string, admin *Admin) (*Admin, int, error) {
+	invitation, err := uc.getValidInvitation(token)
+	if err != nil {
+		return nil, constants.ErrCodeInvalidInvitation, err
+	}
+
+	duplicate, err := uc.repo.IsDuplicateEmail(invitation.Email)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if duplicate {
+		return nil, constants.ErrCodeEmailAlreadyExist, errors.New(constants.ErrEmailAlreadyExist)
+	}
+
+	admin.Email = invitation.Email
+	admin.Role = invitation.Role
+	admin.Is_Active = true
+	admin.Created_at = time.Now()
+	admin.Updated_at = time.Now()
+
+	err = uc.repo.AcceptInvitation(invitation, admin)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constants.ErrCodeInvalidInvitation, errors.New(constants.ErrInvalidInvitation)
+		}
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return admin, constants.CodeSuccess, nil
+}
+
+func (uc *adminUseCase) GetAdmins() ([]Admin, int, error) {
+	admins, err := uc.repo.GetAdmins()
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return admins, constants.CodeSuccess, nil
+}
+
+// SetAdminActive activates or deactivates another admin. Deactivated admins
+// are logged out of every session.
+func (uc *adminUseCase) SetAdminActive(actorID, id uint, active bool) (int, error) {
+	if actorID == id {
+		return constants.ErrCodeCannotDeactivateSelf, errors.New(constants.ErrCannotDeactivateSelf)
+	}
+
+	_, err := uc.repo.GetUserProfile(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return constants.ErrCodeAdminNotFound, errors.New(constants.ErrAdminNotFound)
+		}
+		return constants.ErrCodeBadRequest, err
+	}
+
+	err = uc.repo.SetActive(id, active)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	if !active {
+		err = uc.sessions.RevokeAllSessions(id, "admin")
+		if err != nil {
+			return constants.ErrCodeBadRequest, err
+		}
+	}
+	return constants.CodeSuccess, nil
+}
+
+func (uc *adminUseCase) getValidInvitation(token string) (*AdminInvitation, error) {
+	invitation, err := uc.repo.GetInvitation(helper.HashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(constants.ErrInvalidInvitation)
+		}
+		return nil, err
+	}
+	if invitation.Accepted_at != nil || time.Now().After(invitation.Expires_at) {
+		return nil, errors.New(constants.ErrInvalidInvitation)
+	}
+	return invitation, nil
+}
@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// MockRepository implements the methods used by the tests, the embedded
+// Repository panics on anything else.
+type MockRepository struct {
+	mock.Mock
+	Repository
+}
+
+func (m *MockRepository) IsDuplicateEmail(email string) (bool, error) {
+	args := m.Called(email)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetInvitation(token string) (*AdminInvitation, error) {
+	args := m.Called(token)
+	return args.Get(0).(*AdminInvitation), args.Error(1)
+}
+
+func (m *MockRepository) AcceptInvitation(invitation *AdminInvitation, admin *Admin) error {
+	args := m.Called(invitation, admin)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CountSuperAdmins() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetAdminByEmail(email string) (*Admin, error) {
+	args := m.Called(email)
+	admin, _ := args.Get(0).(*Admin)
+	return admin, args.Error(1)
+}
+
+func (m *MockRepository) UpdateAdmin(admin *Admin) error {
+	args := m.Called(admin)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RegisterUser(admin *Admin) (*Admin, error) {
+	args := m.Called(admin)
+	return admin, args.Error(0)
+}
+
+func TestAcceptInvitationUsesInvitedEmailAndRole(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	invitation := &AdminInvitation{ID: 1, Email: "editor@example.com", Role: "content-editor", Expires_at: time.Now().Add(time.Hour)}
+	mockRepo.On("GetInvitation", helper.HashToken("token")).Return(invitation, nil)
+	mockRepo.On("IsDuplicateEmail", "editor@example.com").Return(false, nil)
+	mockRepo.On("AcceptInvitation", invitation, mock.AnythingOfType("*admin.Admin")).Return(nil)
+
+	admin, _, err := service.AcceptInvitation("token", &Admin{Name: "Editor", Email: "other@example.com", Role: "super-admin"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "editor@example.com", admin.Email)
+	assert.Equal(t, "content-editor", admin.Role)
+	assert.True(t, admin.Is_Active)
+}
+
+func TestAcceptInvitationRejectsExpiredInvitation(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	invitation := &AdminInvitation{ID: 1, Email: "editor@example.com", Role: "content-editor", Expires_at: time.Now().Add(-time.Hour)}
+	mockRepo.On("GetInvitation", helper.HashToken("token")).Return(invitation, nil)
+
+	_, code, err := service.AcceptInvitation("token", &Admin{Name: "Editor"})
+
+	assert.Error(t, err)
+	assert.Equal(t, 400, code)
+	mockRepo.AssertNotCalled(t, "AcceptInvitation", mock.Anything, mock.Anything)
+}
+
+func TestSetAdminActiveRejectsSelf(t *testing.T) {
+	service := NewUseCase(new(MockRepository), nil, nil)
+
+	_, err := service.SetAdminActive(3, 3, false)
+
+	assert.Error(t, err)
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+	mockRepo.On("CountSuperAdmins").Return(int64(0), nil)
+	mockRepo.On("GetAdminByEmail", "root@example.com").Return(nil, gorm.ErrRecordNotFound)
+	mockRepo.On("RegisterUser", mock.AnythingOfType("*admin.Admin")).Return(nil)
+
+	created, err := service.BootstrapAdmin("Root", "root@example.com", "hash")
+
+	assert.NoError(t, err)
+	assert.True(t, created)
+	admin := mockRepo.Calls[2].Arguments.Get(0).(*Admin)
+	assert.Equal(t, "super-admin", admin.Role)
+	assert.True(t, admin.Is_Active)
+}
+
+func TestBootstrapAdminPromotesExistingAccount(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+	existing := &Admin{ID: 4, Email: "root@example.com", Password: "old", Role: ""}
+	mockRepo.On("CountSuperAdmins").Return(int64(0), nil)
+	mockRepo.On("GetAdminByEmail", "root@example.com").Return(existing, nil)
+	mockRepo.On("UpdateAdmin", existing).Return(nil)
+
+	created, err := service.BootstrapAdmin("Root", "root@example.com", "hash")
+
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "super-admin", existing.Role)
+	assert.Equal(t, "hash", existing.Password)
+	mockRepo.AssertNotCalled(t, "RegisterUser", mock.Anything)
+}
+
+func TestBootstrapAdminSkipsWhenSuperAdminExists(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+	mockRepo.On("CountSuperAdmins").Return(int64(1), nil)
+
+	created, err := service.BootstrapAdmin("Root", "root@example.com", "hash")
+
+	assert.NoError(t, err)
+	assert.False(t, created)
+	mockRepo.AssertNotCalled(t, "GetAdminByEmail", mock.Anything)
+}
@@ -7,30 +7,23 @@ import (
 
 	"github.com/OctavianoRyan25/be-agriculture/base"
 	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/modules/audit"
 	"github.com/labstack/echo/v4"
 )
 
 type ArticleController struct {
 	useCase UseCase
+	audit   audit.UseCase
 }
 
-func NewArticleController(useCase UseCase) *ArticleController {
+func NewArticleController(useCase UseCase, audit audit.UseCase) *ArticleController {
 	return &ArticleController{
 		useCase: useCase,
+		audit:   audit,
 	}
 }
 
 func (c *ArticleController) StoreArticle(e echo.Context) error {
-	role := e.Get("role").(string)
-	if role != "admin" {
-		errRes := base.ErrorResponse{
-			Status:  "error",
-			Message: "Forbidden access",
-			Code:    http.StatusForbidden,
-		}
-		return e.JSON(http.StatusForbidden, errRes)
-	}
-
 	title := e.FormValue("title")
 	content := e.FormValue("content")
 	image, err := e.FormFile("image")
@@ -80,6 +73,7 @@ func (c *ArticleController) StoreArticle(e echo.Context) error {
 		}
 		return e.JSON(constants.ErrCodeBadRequest, errResponse)
 	}
+	audit.Record(e, c.audit, audit.ActionCreate, "article", article.ID, nil, ArticleTOResponse(article))
 	res := &base.SuccessResponse{
 		Status:  "success",
 		Message: "Article created",
@@ -130,16 +124,6 @@ func (c *ArticleController) GetAllArticles(e echo.Context) error {
 }
 
 func (c *ArticleController) UpdateArticle(e echo.Context) error {
-	role := e.Get("role").(string)
-	if role != "admin" {
-		errRes := base.ErrorResponse{
-			Status:  "error",
-			Message: "Forbidden access",
-			Code:    http.StatusForbidden,
-		}
-		return e.JSON(http.StatusForbidden, errRes)
-	}
-
 	id, _ := strconv.Atoi(e.Param("id"))
 	getArticle, err := c.useCase.GetArticle(id)
 	if err != nil {
@@ -209,6 +193,7 @@ func (c *ArticleController) UpdateArticle(e echo.Context) error {
 		}
 		return e.JSON(constants.ErrCodeBadRequest, errResponse)
 	}
+	audit.Record(e, c.audit, audit.ActionUpdate, "article", id, ArticleTOResponse(getArticle), ArticleTOResponse(article))
 	res := &base.SuccessResponse{
 		Status:  "success",
 		Message: "Article updated",
@@ -218,16 +203,6 @@ func (c *ArticleController) UpdateArticle(e echo.Context) error {
 }
 
 func (c *ArticleController) DeleteArticle(e echo.Context) error {
-	role := e.Get("role").(string)
-	if role != "admin" {
-		errRes := base.ErrorResponse{
-			Status:  "error",
-			Message: "Forbidden access",
-			Code:    http.StatusForbidden,
-		}
-		return e.JSON(http.StatusForbidden, errRes)
-	}
-
 	id, _ := strconv.Atoi(e.Param("id"))
 	getArticle, err := c.useCase.GetArticle(id)
 	if err != nil {
@@ -257,6 +232,7 @@ func (c *ArticleController) DeleteArticle(e echo.Context) error {
 		}
 		return e.JSON(constants.ErrCodeBadRequest, errResponse)
 	}
+	audit.Record(e, c.audit, audit.ActionDelete, "article", id, ArticleTOResponse(getArticle), nil)
 	res := &base.SuccessResponse{
 		Status:  "success",
 		Message: "Article deleted",
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/base"
+	"github.com/labstack/echo/v4"
+)
+
+type AuditController struct {
+	useCase UseCase
+}
+
+func NewAuditController(useCase UseCase) *AuditController {
+	return &AuditController{
+		useCase: useCase,
+	}
+}
+
+// GetLogs lists the audit log, newest first. It is filtered by the query
+// parameters actor_id, action, entity_type, entity_id, from and to (a date
+// like 2024-06-01 or an RFC 3339 time; to is exclusive) and paginated with
+// page and limit.
+func (c *AuditController) GetLogs(ctx echo.Context) error {
+	var query AuditLogQuery
+	err := (&echo.DefaultBinder{}).BindQueryParams(ctx, &query)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	filter := &Filter{
+		ActorID:    query.ActorID,
+		Action:     query.Action,
+		EntityType: query.EntityType,
+		EntityID:   query.EntityID,
+		Page:       query.Page,
+		Limit:      query.Limit,
+	}
+	filter.From, err = parseTime(query.From)
+	if err == nil {
+		filter.To, err = parseTime(query.To)
+	}
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: "Invalid from or to, use 2006-01-02 or RFC 3339",
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	logs, total, code, err := c.useCase.GetLogs(filter)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Audit logs",
+		Data: AuditLogPageResponse{
+			Logs:       MapLogsToResponse(logs),
+			TotalCount: total,
+			Limit:      filter.Limit,
+			Page:       filter.Page,
+			TotalPages: int((total + int64(filter.Limit) - 1) / int64(filter.Limit)),
+		},
+	}
+	return ctx.JSON(code, res)
+}
+
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		location, _ := time.LoadLocation("Asia/Jakarta")
+		t, err = time.ParseInLocation("2006-01-02", value, location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionCreate     = "create"
+	ActionUpdate     = "update"
+	ActionDelete     = "delete"
+	ActionInvite     = "invite"
+	ActionActivate   = "activate"
+	ActionDeactivate = "deactivate"
+)
+
+// AuditLog records one write made by an admin. Changes is a JSON object
+// mapping every changed field to its value before and after the write.
+type AuditLog struct {
+	ID         int       `gorm:"primaryKey"`
+	ActorID    uint      `gorm:"index"`
+	ActorRole  string    `gorm:"size:32"`
+	IP         string    `gorm:"size:45"`
+	Action     string    `gorm:"size:32;index"`
+	EntityType string    `gorm:"size:64;index:idx_audit_entity"`
+	EntityID   string    `gorm:"size:64;index:idx_audit_entity"`
+	Changes    string    `gorm:"type:text"`
+	CreatedAt  time.Time `gorm:"index"`
+}
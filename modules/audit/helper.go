@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Record stores what the admin making request c did. The write itself has
+// already happened, so a failure to record it is logged instead of failing
+// the request.
+func Record(c echo.Context, useCase UseCase, action, entityType string, entityID interface{}, before, after interface{}) {
+	actorID, _ := c.Get("user_id").(uint)
+	actorRole, _ := c.Get("admin_role").(string)
+
+	err := useCase.Record(&Entry{
+		ActorID:    actorID,
+		ActorRole:  actorRole,
+		IP:         c.RealIP(),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		Before:     before,
+		After:      after,
+	})
+	if err != nil {
+		log.Printf("Failed to record audit log %s %s %v: %v\n", action, entityType, entityID, err)
+	}
+}
+
+func MapLogToResponse(auditLog *AuditLog) *AuditLogResponse {
+	return &AuditLogResponse{
+		ID:         auditLog.ID,
+		ActorID:    auditLog.ActorID,
+		ActorRole:  auditLog.ActorRole,
+		IP:         auditLog.IP,
+		Action:     auditLog.Action,
+		EntityType: auditLog.EntityType,
+		EntityID:   auditLog.EntityID,
+		Changes:    json.RawMessage(auditLog.Changes),
+		CreatedAt:  auditLog.CreatedAt,
+	}
+}
+
+func MapLogsToResponse(logs []AuditLog) []AuditLogResponse {
+	res := make([]AuditLogResponse, 0, len(logs))
+	for i := range logs {
+		res = append(res, *MapLogToResponse(&logs[i]))
+	}
+	return res
+}
@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	CreateLog(*AuditLog) error
+	GetLogs(*Filter) ([]AuditLog, int64, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *auditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+func (r *auditRepository) CreateLog(auditLog *AuditLog) error {
+	return r.db.Create(auditLog).Error
+}
+
+// GetLogs returns one page of the logs matching filter, newest first, and
+// the number of matching logs.
+func (r *auditRepository) GetLogs(filter *Filter) ([]AuditLog, int64, error) {
+	query := r.db.Model(&AuditLog{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	err := query.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var logs []AuditLog
+	err = query.Order("created_at DESC, id DESC").
+		Offset((filter.Page - 1) * filter.Limit).
+		Limit(filter.Limit).
+		Find(&logs).Error
+	return logs, total, err
+}
@@ -0,0 +1,12 @@
+package audit
+
+type AuditLogQuery struct {
+	ActorID    uint   `query:"actor_id"`
+	Action     string `query:"action"`
+	EntityType string `query:"entity_type"`
+	EntityID   string `query:"entity_id"`
+	From       string `query:"from"`
+	To         string `query:"to"`
+	Page       int    `query:"page"`
+	Limit      int    `query:"limit"`
+}
@@ -0,0 +1,26 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditLogResponse struct {
+	ID         int             `json:"id"`
+	ActorID    uint            `json:"actor_id"`
+	ActorRole  string          `json:"actor_role"`
+	IP         string          `json:"ip"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Changes    json.RawMessage `json:"changes"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditLogPageResponse struct {
+	Logs       []AuditLogResponse `json:"logs"`
+	TotalCount int64              `json:"total_count"`
+	Limit      int                `json:"limit"`
+	Page       int                `json:"page"`
+	TotalPages int                `json:"total_pages"`
+}
@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Entry describes a write to record. Before is nil for a create and After is
+// nil for a delete.
+type Entry struct {
+	ActorID    uint
+	ActorRole  string
+	IP         string
+	Action     string
+	EntityType string
+	EntityID   string
+	Before     interface{}
+	After      interface{}
+}
+
+// Filter selects the logs returned by GetLogs. Zero fields match anything.
+type Filter struct {
+	ActorID    uint
+	Action     string
+	EntityType string
+	EntityID   string
+	From       *time.Time
+	To         *time.Time
+	Page       int
+	Limit      int
+}
+
+// Change is the value of one field before and after a write.
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type UseCase interface {
+	Record(*Entry) error
+	GetLogs(*Filter) ([]AuditLog, int64, int, error)
+}
+
+type auditUseCase struct {
+	repo Repository
+}
+
+func NewUseCase(repo Repository) *auditUseCase {
+	return &auditUseCase{
+		repo: repo,
+	}
+}
+
+func (uc *auditUseCase) Record(entry *Entry) error {
+	changes, err := diff(entry.Before, entry.After)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	return uc.repo.CreateLog(&AuditLog{
+		ActorID:    entry.ActorID,
+		ActorRole:  entry.ActorRole,
+		IP:         entry.IP,
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Changes:    string(encoded),
+		CreatedAt:  time.Now(),
+	})
+}
+
+func (uc *auditUseCase) GetLogs(filter *Filter) ([]AuditLog, int64, int, error) {
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPageSize
+	}
+	if filter.Limit > maxPageSize {
+		filter.Limit = maxPageSize
+	}
+
+	logs, total, err := uc.repo.GetLogs(filter)
+	if err != nil {
+		return nil, 0, constants.ErrCodeBadRequest, err
+	}
+	return logs, total, constants.CodeSuccess, nil
+}
+
+// ignoredFields change on every write and would only add noise to the diff.
+var ignoredFields = map[string]bool{
+	"updated_at": true,
+	"UpdatedAt":  true,
+	"Updated_at": true,
+}
+
+// diff compares the JSON encoding of before and after field by field and
+// returns the fields whose value changed.
+func diff(before, after interface{}) (map[string]Change, error) {
+	oldFields, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]Change{}
+	for name, value := range oldFields {
+		if ignoredFields[name] {
+			continue
+		}
+		if newValue, ok := newFields[name]; !ok || !reflect.DeepEqual(value, newValue) {
+			changes[name] = Change{Before: value, After: newFields[name]}
+		}
+	}
+	for name, value := range newFields {
+		if _, ok := oldFields[name]; !ok && !ignoredFields[name] {
+			changes[name] = Change{After: value}
+		}
+	}
+	return changes, nil
+}
+
+// fields decodes the JSON object of v into a map. A nil v has no fields.
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return map[string]interface{}{}, nil
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	err = json.Unmarshal(encoded, &m)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %T is not a JSON object", v)
+	}
+	return m, nil
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+	Repository
+}
+
+func (m *MockRepository) CreateLog(auditLog *AuditLog) error {
+	args := m.Called(auditLog)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetLogs(filter *Filter) ([]AuditLog, int64, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]AuditLog), args.Get(1).(int64), args.Error(2)
+}
+
+type testEntity struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Image     string `json:"image"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func TestRecordUpdateStoresChangedFieldsOnly(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewUseCase(repo)
+
+	var stored *AuditLog
+	repo.On("CreateLog", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*AuditLog)
+	}).Return(nil)
+
+	err := uc.Record(&Entry{
+		ActorID:    7,
+		ActorRole:  "catalog-editor",
+		IP:         "10.0.0.1",
+		Action:     ActionUpdate,
+		EntityType: "plant",
+		EntityID:   "3",
+		Before:     &testEntity{ID: 3, Name: "Tomat", Image: "a.png", UpdatedAt: "yesterday"},
+		After:      testEntity{ID: 3, Name: "Tomat Cherry", Image: "a.png", UpdatedAt: "today"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), stored.ActorID)
+	assert.Equal(t, "plant", stored.EntityType)
+	assert.False(t, stored.CreatedAt.IsZero())
+
+	var changes map[string]Change
+	assert.NoError(t, json.Unmarshal([]byte(stored.Changes), &changes))
+	assert.Equal(t, map[string]Change{"name": {Before: "Tomat", After: "Tomat Cherry"}}, changes)
+}
+
+func TestRecordCreateAndDelete(t *testing.T) {
+	entity := &testEntity{ID: 1, Name: "Sayur"}
+
+	created, err := diff(nil, entity)
+	assert.NoError(t, err)
+	assert.Equal(t, Change{After: "Sayur"}, created["name"])
+	assert.NotContains(t, created, "updated_at")
+
+	var nilEntity *testEntity
+	deleted, err := diff(entity, nilEntity)
+	assert.NoError(t, err)
+	assert.Equal(t, Change{Before: "Sayur"}, deleted["name"])
+	assert.Equal(t, Change{Before: float64(1)}, deleted["id"])
+}
+
+func TestRecordRejectsNonObject(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewUseCase(repo)
+
+	err := uc.Record(&Entry{Action: ActionDelete, Before: "plain string"})
+
+	assert.Error(t, err)
+	repo.AssertNotCalled(t, "CreateLog", mock.Anything)
+}
+
+func TestGetLogsClampsPaging(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewUseCase(repo)
+
+	repo.On("GetLogs", mock.Anything).Return([]AuditLog{{ID: 1}}, int64(1), nil)
+
+	filter := &Filter{Page: -2, Limit: 1000}
+	logs, total, code, err := uc.GetLogs(filter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, constants.CodeSuccess, code)
+	assert.Len(t, logs, 1)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, 1, filter.Page)
+	assert.Equal(t, maxPageSize, filter.Limit)
+
+	filter = &Filter{}
+	_, _, _, _ = uc.GetLogs(filter)
+	assert.Equal(t, defaultPageSize, filter.Limit)
+}
+
+func TestGetLogsRepositoryError(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewUseCase(repo)
+
+	repo.On("GetLogs", mock.Anything).Return([]AuditLog(nil), int64(0), errors.New("db down"))
+
+	logs, _, code, err := uc.GetLogs(&Filter{})
+
+	assert.Error(t, err)
+	assert.Nil(t, logs)
+	assert.Equal(t, constants.ErrCodeBadRequest, code)
+}
@@ -0,0 +1,132 @@
+package device
+
+import (
+	"net/http"
+
+	"github.com/OctavianoRyan25/be-agriculture/base"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type DeviceController struct {
+	useCase UseCase
+}
+
+func NewDeviceController(useCase UseCase) *DeviceController {
+	return &DeviceController{
+		useCase: useCase,
+	}
+}
+
+func (c *DeviceController) RegisterDevice(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+
+	req := new(RegisterDeviceRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	device, code, err := c.useCase.RegisterDevice(userID, MapRequestToDevice(req))
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Device registered",
+		Data:    MapDeviceToResponse(device),
+	}
+	return ctx.JSON(http.StatusCreated, res)
+}
+
+func (c *DeviceController) UnregisterDevice(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+
+	req := new(UnregisterDeviceRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	code, err := c.useCase.UnregisterDevice(userID, req.Token)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Device unregistered",
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *DeviceController) GetDevices(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+
+	devices, code, err := c.useCase.GetDevices(userID)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	mapped := []DeviceResponse{}
+	for i := range devices {
+		mapped = append(mapped, *MapDeviceToResponse(&devices[i]))
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Devices fetched",
+		Data:    mapped,
+	}
+	return ctx.JSON(code, res)
+}
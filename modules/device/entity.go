@@ -0,0 +1,18 @@
+package device
+
+import (
+	"time"
+)
+
+// Device is an app install that receives push notifications. A token
+// belongs to one install, so it moves to whoever logs in on that install.
+type Device struct {
+	ID         int    `gorm:"primaryKey"`
+	UserID     int    `gorm:"index"`
+	Token      string `gorm:"size:255;uniqueIndex"`
+	Platform   string `gorm:"size:16"`
+	AppVersion string `gorm:"size:32"`
+	LastSeenAt time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
@@ -0,0 +1,19 @@
+package device
+
+func MapRequestToDevice(req *RegisterDeviceRequest) *Device {
+	return &Device{
+		Token:      req.Token,
+		Platform:   req.Platform,
+		AppVersion: req.AppVersion,
+	}
+}
+
+func MapDeviceToResponse(device *Device) *DeviceResponse {
+	return &DeviceResponse{
+		ID:         device.ID,
+		Platform:   device.Platform,
+		AppVersion: device.AppVersion,
+		LastSeenAt: device.LastSeenAt,
+		CreatedAt:  device.CreatedAt,
+	}
+}
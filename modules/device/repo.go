@@ -0,0 +1,56 @@
+package device
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository interface {
+	UpsertDevice(*Device) error
+	GetDevicesByUser(uint) ([]Device, error)
+	DeleteDevice(uint, string) (bool, error)
+	DeleteDevicesByToken([]string) error
+}
+
+type deviceRepository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *deviceRepository {
+	return &deviceRepository{
+		db: db,
+	}
+}
+
+// UpsertDevice creates the device or, when the token is already registered,
+// moves it to the user and refreshes its details.
+func (r *deviceRepository) UpsertDevice(device *Device) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "platform", "app_version", "last_seen_at", "updated_at"}),
+	}).Create(device).Error
+}
+
+func (r *deviceRepository) GetDevicesByUser(userID uint) ([]Device, error) {
+	var devices []Device
+	err := r.db.Where("user_id = ?", userID).Order("last_seen_at DESC").Find(&devices).Error
+	if err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (r *deviceRepository) DeleteDevice(userID uint, token string) (bool, error) {
+	res := r.db.Where("user_id = ? AND token = ?", userID, token).Delete(&Device{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *deviceRepository) DeleteDevicesByToken(tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	return r.db.Where("token IN ?", tokens).Delete(&Device{}).Error
+}
@@ -0,0 +1,11 @@
+package device
+
+type RegisterDeviceRequest struct {
+	Token      string `json:"token" validate:"required"`
+	Platform   string `json:"platform" validate:"required,oneof=android ios web"`
+	AppVersion string `json:"app_version"`
+}
+
+type UnregisterDeviceRequest struct {
+	Token string `json:"token" validate:"required"`
+}
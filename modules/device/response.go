@@ -0,0 +1,13 @@
+package device
+
+import (
+	"time"
+)
+
+type DeviceResponse struct {
+	ID         int       `json:"id"`
+	Platform   string    `json:"platform"`
+	AppVersion string    `json:"app_version"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,64 @@
+package device
+
+import (
+	"errors"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+)
+
+type UseCase interface {
+	RegisterDevice(uint, *Device) (*Device, int, error)
+	UnregisterDevice(uint, string) (int, error)
+	GetDevices(uint) ([]Device, int, error)
+	PruneTokens([]string) error
+}
+
+type deviceUseCase struct {
+	repo Repository
+}
+
+func NewUseCase(repo Repository) *deviceUseCase {
+	return &deviceUseCase{
+		repo: repo,
+	}
+}
+
+func (uc *deviceUseCase) RegisterDevice(userID uint, device *Device) (*Device, int, error) {
+	now := time.Now()
+
+	device.UserID = int(userID)
+	device.LastSeenAt = now
+	device.CreatedAt = now
+	device.UpdatedAt = now
+
+	err := uc.repo.UpsertDevice(device)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return device, constants.CodeSuccess, nil
+}
+
+func (uc *deviceUseCase) UnregisterDevice(userID uint, token string) (int, error) {
+	deleted, err := uc.repo.DeleteDevice(userID, token)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	if !deleted {
+		return constants.ErrCodeDeviceNotFound, errors.New(constants.ErrDeviceNotFound)
+	}
+	return constants.CodeSuccess, nil
+}
+
+func (uc *deviceUseCase) GetDevices(userID uint) ([]Device, int, error) {
+	devices, err := uc.repo.GetDevicesByUser(userID)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return devices, constants.CodeSuccess, nil
+}
+
+// PruneTokens removes tokens that FCM reported as no longer valid.
+func (uc *deviceUseCase) PruneTokens(tokens []string) error {
+	return uc.repo.DeleteDevicesByToken(tokens)
+}
@@ -0,0 +1,69 @@
+package device
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository implements the methods used by the tests, the embedded
+// Repository panics on anything else.
+type MockRepository struct {
+	mock.Mock
+	Repository
+}
+
+func (m *MockRepository) UpsertDevice(device *Device) error {
+	args := m.Called(device)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteDevicesByToken(tokens []string) error {
+	args := m.Called(tokens)
+	return args.Error(0)
+}
+
+func TestRegisterDevice(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("UpsertDevice", mock.Anything).Return(nil)
+	uc := NewUseCase(mockRepo)
+
+	device, code, err := uc.RegisterDevice(7, &Device{Token: "fcm-token", Platform: "android", AppVersion: "1.2.0"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, constants.CodeSuccess, code)
+	assert.Equal(t, 7, device.UserID)
+	assert.WithinDuration(t, time.Now(), device.LastSeenAt, time.Minute)
+	assert.Equal(t, device.LastSeenAt, device.UpdatedAt)
+	upserted := mockRepo.Calls[0].Arguments.Get(0).(*Device)
+	assert.Equal(t, "fcm-token", upserted.Token)
+	assert.Equal(t, "1.2.0", upserted.AppVersion)
+}
+
+func TestRegisterDeviceFails(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("UpsertDevice", mock.Anything).Return(errors.New("db down"))
+	uc := NewUseCase(mockRepo)
+
+	device, code, err := uc.RegisterDevice(7, &Device{Token: "fcm-token"})
+
+	assert.Error(t, err)
+	assert.Equal(t, constants.ErrCodeBadRequest, code)
+	assert.Nil(t, device)
+}
+
+func TestPruneTokens(t *testing.T) {
+	mockRepo := new(MockRepository)
+	tokens := []string{"expired-1", "expired-2"}
+	mockRepo.On("DeleteDevicesByToken", tokens).Return(nil).Once()
+	mockRepo.On("DeleteDevicesByToken", []string{"other"}).Return(errors.New("db down")).Once()
+	uc := NewUseCase(mockRepo)
+
+	assert.NoError(t, uc.PruneTokens(tokens))
+	assert.Error(t, uc.PruneTokens([]string{"other"}))
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,15 @@
+package lockout
+
+import (
+	"time"
+)
+
+// LoginFailure counts the recent failed logins of one account or one IP
+// address. Subject is built by subject(), e.g. "admin:account:a@b.c".
+type LoginFailure struct {
+	Subject      string `gorm:"primaryKey;size:255"`
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+	UpdatedAt    time.Time
+}
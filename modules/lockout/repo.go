@@ -0,0 +1,66 @@
+package lockout
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository interface {
+	GetFailure(string) (*LoginFailure, error)
+	UpdateFailure(string, func(*LoginFailure)) (*LoginFailure, error)
+	DeleteFailure(string) error
+}
+
+type lockoutRepository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *lockoutRepository {
+	return &lockoutRepository{
+		db: db,
+	}
+}
+
+// GetFailure returns the failures of the subject, or an empty record when it
+// has none.
+func (r *lockoutRepository) GetFailure(subject string) (*LoginFailure, error) {
+	var failure LoginFailure
+	err := r.db.Where("subject = ?", subject).First(&failure).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &LoginFailure{Subject: subject}, nil
+		}
+		return nil, err
+	}
+	return &failure, nil
+}
+
+// UpdateFailure applies update to the subject's record while holding a row
+// lock, so concurrent failed logins are all counted.
+func (r *lockoutRepository) UpdateFailure(subject string, update func(*LoginFailure)) (*LoginFailure, error) {
+	var failure LoginFailure
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&LoginFailure{Subject: subject}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("subject = ?", subject).First(&failure).Error
+		if err != nil {
+			return err
+		}
+
+		update(&failure)
+		return tx.Save(&failure).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &failure, nil
+}
+
+func (r *lockoutRepository) DeleteFailure(subject string) error {
+	return r.db.Where("subject = ?", subject).Delete(&LoginFailure{}).Error
+}
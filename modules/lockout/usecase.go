@@ -0,0 +1,185 @@
+package lockout
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+)
+
+// Policy sets how failed logins of one scope are throttled.
+type Policy struct {
+	// FreeAttempts failures are allowed before the backoff starts.
+	FreeAttempts int
+	// BaseDelay doubles with every failure after FreeAttempts, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockAfter failures lock the account for LockFor.
+	LockAfter int
+	LockFor   time.Duration
+	// IPLockAfter failures from one IP address lock that address for LockFor.
+	IPLockAfter int
+	// Failures older than ResetAfter are forgotten.
+	ResetAfter time.Duration
+}
+
+// Scopes with their policies. Admin accounts are locked sooner and longer.
+var policies = map[string]Policy{
+	"user": {
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Minute,
+		LockAfter:    10,
+		LockFor:      15 * time.Minute,
+		IPLockAfter:  50,
+		ResetAfter:   time.Hour,
+	},
+	"admin": {
+		FreeAttempts: 1,
+		BaseDelay:    2 * time.Second,
+		MaxDelay:     15 * time.Minute,
+		LockAfter:    5,
+		LockFor:      time.Hour,
+		IPLockAfter:  20,
+		ResetAfter:   24 * time.Hour,
+	},
+}
+
+// BlockedError is returned while a login must wait for its backoff or
+// lockout to end.
+type BlockedError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *BlockedError) Error() string {
+	if e.Locked {
+		return constants.ErrAccountLocked
+	}
+	return constants.ErrTooManyLoginAttempts
+}
+
+// Code returns the error code of the response.
+func (e *BlockedError) Code() int {
+	if e.Locked {
+		return constants.ErrCodeAccountLocked
+	}
+	return constants.ErrCodeTooManyLoginAttempts
+}
+
+type UseCase interface {
+	Check(string, string, string) error
+	Fail(string, string, string) (bool, error)
+	Succeed(string, string) error
+}
+
+type lockoutUseCase struct {
+	repo Repository
+	now  func() time.Time
+}
+
+func NewUseCase(repo Repository) *lockoutUseCase {
+	return &lockoutUseCase{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// Check returns a *BlockedError when a login to the account, or from the IP
+// address, is not allowed yet.
+func (uc *lockoutUseCase) Check(scope, email, ip string) error {
+	policy := policies[scope]
+	now := uc.now()
+
+	account, err := uc.repo.GetFailure(subject(scope, "account", email))
+	if err != nil {
+		return err
+	}
+	if blocked := policy.blocked(account, now); blocked != nil {
+		return blocked
+	}
+
+	address, err := uc.repo.GetFailure(subject(scope, "ip", ip))
+	if err != nil {
+		return err
+	}
+	if address.LockedUntil != nil && now.Before(*address.LockedUntil) {
+		return &BlockedError{RetryAfter: address.LockedUntil.Sub(now)}
+	}
+	return nil
+}
+
+// Fail counts a failed login. It reports true when this failure locked the
+// account, so the owner can be told about it.
+func (uc *lockoutUseCase) Fail(scope, email, ip string) (bool, error) {
+	policy := policies[scope]
+	now := uc.now()
+
+	_, err := uc.repo.UpdateFailure(subject(scope, "ip", ip), func(f *LoginFailure) {
+		policy.count(f, now, policy.IPLockAfter)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var locked bool
+	_, err = uc.repo.UpdateFailure(subject(scope, "account", email), func(f *LoginFailure) {
+		locked = policy.count(f, now, policy.LockAfter)
+	})
+	if err != nil {
+		return false, err
+	}
+	return locked, nil
+}
+
+// Succeed forgets the failures of the account. Failures of the IP address
+// are kept so one valid account cannot be used to reset them.
+func (uc *lockoutUseCase) Succeed(scope, email string) error {
+	return uc.repo.DeleteFailure(subject(scope, "account", email))
+}
+
+func (p Policy) blocked(f *LoginFailure, now time.Time) *BlockedError {
+	if f.LockedUntil != nil && now.Before(*f.LockedUntil) {
+		return &BlockedError{RetryAfter: f.LockedUntil.Sub(now), Locked: true}
+	}
+	if f.LockedUntil != nil || now.Sub(f.LastFailedAt) > p.ResetAfter {
+		return nil
+	}
+
+	extra := f.Failures - p.FreeAttempts
+	if extra <= 0 {
+		return nil
+	}
+	delay := p.MaxDelay
+	if extra <= 30 {
+		delay = min(p.BaseDelay<<(extra-1), p.MaxDelay)
+	}
+	if next := f.LastFailedAt.Add(delay); now.Before(next) {
+		return &BlockedError{RetryAfter: next.Sub(now)}
+	}
+	return nil
+}
+
+// count adds a failure to f and locks it when it reaches limit. It reports
+// whether f got locked.
+func (p Policy) count(f *LoginFailure, now time.Time, limit int) bool {
+	// Start over once the old failures expired or the last lockout ended
+	if now.Sub(f.LastFailedAt) > p.ResetAfter || (f.LockedUntil != nil && !now.Before(*f.LockedUntil)) {
+		f.Failures = 0
+		f.LockedUntil = nil
+	}
+
+	f.Failures++
+	f.LastFailedAt = now
+	if f.Failures >= limit && f.LockedUntil == nil {
+		until := now.Add(p.LockFor)
+		f.LockedUntil = &until
+		return true
+	}
+	return false
+}
+
+func subject(scope, kind, value string) string {
+	return fmt.Sprintf("%s:%s:%s", scope, kind, strings.ToLower(value))
+}
@@ -0,0 +1,148 @@
+package lockout
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRepository struct {
+	failures map[string]LoginFailure
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{failures: map[string]LoginFailure{}}
+}
+
+func (r *fakeRepository) GetFailure(subject string) (*LoginFailure, error) {
+	failure, ok := r.failures[subject]
+	if !ok {
+		failure = LoginFailure{Subject: subject}
+	}
+	return &failure, nil
+}
+
+func (r *fakeRepository) UpdateFailure(subject string, update func(*LoginFailure)) (*LoginFailure, error) {
+	failure, _ := r.GetFailure(subject)
+	update(failure)
+	r.failures[subject] = *failure
+	return failure, nil
+}
+
+func (r *fakeRepository) DeleteFailure(subject string) error {
+	delete(r.failures, subject)
+	return nil
+}
+
+func newTestUseCase(now *time.Time) *lockoutUseCase {
+	uc := NewUseCase(newFakeRepository())
+	uc.now = func() time.Time { return *now }
+	return uc
+}
+
+func TestCheckAllowsFreeAttempts(t *testing.T) {
+	now := time.Now()
+	uc := newTestUseCase(&now)
+
+	for i := 0; i < policies["user"].FreeAttempts; i++ {
+		_, err := uc.Fail("user", "a@b.c", "10.0.0.1")
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, uc.Check("user", "a@b.c", "10.0.0.1"))
+}
+
+func TestCheckBacksOffExponentially(t *testing.T) {
+	now := time.Now()
+	uc := newTestUseCase(&now)
+	policy := policies["user"]
+
+	for i := 0; i < policy.FreeAttempts+3; i++ {
+		uc.Fail("user", "a@b.c", "10.0.0.1")
+	}
+
+	var blocked *BlockedError
+	err := uc.Check("user", "A@b.c", "10.0.0.2")
+	assert.True(t, errors.As(err, &blocked))
+	assert.False(t, blocked.Locked)
+	assert.Equal(t, 4*policy.BaseDelay, blocked.RetryAfter)
+
+	now = now.Add(4 * policy.BaseDelay)
+	assert.NoError(t, uc.Check("user", "a@b.c", "10.0.0.2"))
+}
+
+func TestFailLocksAccount(t *testing.T) {
+	now := time.Now()
+	uc := newTestUseCase(&now)
+	policy := policies["admin"]
+
+	var locked bool
+	for i := 0; i < policy.LockAfter; i++ {
+		var err error
+		locked, err = uc.Fail("admin", "a@b.c", "10.0.0.1")
+		assert.NoError(t, err)
+	}
+	assert.True(t, locked)
+
+	var blocked *BlockedError
+	err := uc.Check("admin", "a@b.c", "10.0.0.2")
+	assert.True(t, errors.As(err, &blocked))
+	assert.True(t, blocked.Locked)
+	assert.Equal(t, policy.LockFor, blocked.RetryAfter)
+
+	// Further failures do not notify again
+	locked, _ = uc.Fail("admin", "a@b.c", "10.0.0.1")
+	assert.False(t, locked)
+
+	// The user scope is tracked separately
+	assert.NoError(t, uc.Check("user", "a@b.c", "10.0.0.3"))
+}
+
+func TestFailLocksIPAddress(t *testing.T) {
+	now := time.Now()
+	uc := newTestUseCase(&now)
+	policy := policies["admin"]
+
+	for i := 0; i < policy.IPLockAfter; i++ {
+		uc.Fail("admin", "user"+string(rune('a'+i))+"@b.c", "10.0.0.1")
+	}
+
+	var blocked *BlockedError
+	err := uc.Check("admin", "new@b.c", "10.0.0.1")
+	assert.True(t, errors.As(err, &blocked))
+	assert.False(t, blocked.Locked)
+
+	assert.NoError(t, uc.Check("admin", "new@b.c", "10.0.0.2"))
+}
+
+func TestLockExpires(t *testing.T) {
+	now := time.Now()
+	uc := newTestUseCase(&now)
+	policy := policies["user"]
+
+	for i := 0; i < policy.LockAfter; i++ {
+		uc.Fail("user", "a@b.c", "10.0.0.1")
+	}
+
+	now = now.Add(policy.LockFor)
+	assert.NoError(t, uc.Check("user", "a@b.c", "10.0.0.2"))
+
+	locked, _ := uc.Fail("user", "a@b.c", "10.0.0.2")
+	assert.False(t, locked)
+	assert.NoError(t, uc.Check("user", "a@b.c", "10.0.0.2"))
+}
+
+func TestSucceedResetsAccount(t *testing.T) {
+	now := time.Now()
+	uc := newTestUseCase(&now)
+
+	for i := 0; i < policies["user"].FreeAttempts+2; i++ {
+		uc.Fail("user", "a@b.c", "10.0.0.1")
+	}
+	assert.Error(t, uc.Check("user", "a@b.c", "10.0.0.1"))
+
+	assert.NoError(t, uc.Succeed("user", "a@b.c"))
+	assert.NoError(t, uc.Check("user", "a@b.c", "10.0.0.1"))
+}
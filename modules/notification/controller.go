@@ -3,8 +3,11 @@ package notification
 import (
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/OctavianoRyan25/be-agriculture/base"
+	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
@@ -18,94 +21,224 @@ func NewNotificationController(useCase UseCase) *NotificationController {
 	}
 }
 
-func (c *NotificationController) ReadNotification(ctx echo.Context) error {
-	userId := ctx.Get("user_id").(uint)
-	params := ctx.Param("id")
-	convId, _ := strconv.Atoi(params)
-	// if userId == 0 {
-	// 	errRes := base.ErrorResponse{
-	// 		Status:  "error",
-	// 		Message: "Id cannot be empty",
-	// 		Code:    http.StatusBadRequest,
-	// 	}
-	// 	return ctx.JSON(http.StatusBadRequest, errRes)
-	// }
-	notification, err := c.UseCase.ReadNotification(convId)
+func (c *NotificationController) GetNotification(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	notification, code, err := c.UseCase.GetNotification(userID, id)
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
 			Message: err.Error(),
-			Code:    http.StatusInternalServerError,
+			Code:    code,
 		}
-		return ctx.JSON(http.StatusInternalServerError, errRes)
+		return ctx.JSON(code, errRes)
 	}
+	return c.notificationResponse(ctx, userID, notification, "Notification fetched")
+}
 
-	if userId != uint(notification.UserId) {
+// GetNotifications lists the inbox, newest first. It is filtered by the
+// query parameters status (read or unread) and type (a notification
+// category), and paginated with limit and the next_cursor of the previous
+// page as cursor.
+func (c *NotificationController) GetNotifications(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	var query NotificationQuery
+	err := (&echo.DefaultBinder{}).BindQueryParams(ctx, &query)
+	if err == nil {
+		err = validator.New().Struct(&query)
+	}
+	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
-			Message: "You are not authorized to read this notification",
-			Code:    http.StatusUnauthorized,
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
 		}
-		return ctx.JSON(http.StatusUnauthorized, errRes)
+		return ctx.JSON(http.StatusBadRequest, errRes)
 	}
 
-	mapped := &NotificationResponse{
-		Id:        notification.Id,
-		Title:     notification.Title,
-		Body:      notification.Body,
-		UserId:    notification.UserId,
-		IsRead:    notification.IsRead,
-		PlantId:   notification.PlantId,
-		CreatedAt: notification.CreatedAt,
+	filter := &NotificationFilter{
+		UserID:   userID,
+		Category: query.Type,
+		Limit:    query.Limit,
+	}
+	if query.Status != "" {
+		isRead := query.Status == "read"
+		filter.IsRead = &isRead
+	}
+	if query.Cursor != "" {
+		filter.Before, err = strconv.Atoi(query.Cursor)
+		if err != nil || filter.Before <= 0 {
+			errRes := base.ErrorResponse{
+				Status:  "error",
+				Message: "Invalid cursor",
+				Code:    http.StatusBadRequest,
+			}
+			return ctx.JSON(http.StatusBadRequest, errRes)
+		}
 	}
 
+	notifications, next, code, err := c.UseCase.GetNotifications(filter)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	location, errRes := c.userLocation(userID)
+	if errRes != nil {
+		return ctx.JSON(errRes.Code, errRes)
+	}
+
+	page := NotificationPageResponse{
+		Notifications: make([]NotificationResponse, 0, len(notifications)),
+		Limit:         filter.Limit,
+	}
+	for i := range notifications {
+		page.Notifications = append(page.Notifications, *MapNotificationToResponse(&notifications[i], location))
+	}
+	if next != 0 {
+		page.NextCursor = strconv.Itoa(next)
+	}
 	res := base.SuccessResponse{
 		Status:  "success",
-		Message: "Notification stored",
-		Data:    mapped,
+		Message: "Notifications fetched",
+		Data:    page,
 	}
 	return ctx.JSON(http.StatusOK, res)
 }
 
-func (c *NotificationController) GetAllNotifications(ctx echo.Context) error {
-	userId := ctx.Get("user_id").(uint)
-	if userId == 0 {
+func (c *NotificationController) CountUnreadNotifications(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	count, code, err := c.UseCase.CountUnreadNotifications(userID)
+	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
-			Message: "Id cannot be empty",
-			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Code:    code,
 		}
-		return ctx.JSON(http.StatusBadRequest, errRes)
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Unread notifications counted",
+		Data:    UnreadCountResponse{UnreadCount: count},
 	}
-	notifications, err := c.UseCase.GetAllNotifications(userId)
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *NotificationController) MarkNotificationRead(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	notification, code, err := c.UseCase.MarkNotificationRead(userID, id)
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
 			Message: err.Error(),
-			Code:    http.StatusInternalServerError,
+			Code:    code,
 		}
-		return ctx.JSON(http.StatusInternalServerError, errRes)
+		return ctx.JSON(code, errRes)
 	}
-	var mapped []NotificationResponse
-	for _, v := range notifications {
-		mapped = append(mapped, NotificationResponse{
-			Id:        v.Id,
-			Title:     v.Title,
-			Body:      v.Body,
-			UserId:    v.UserId,
-			IsRead:    v.IsRead,
-			PlantId:   v.PlantId,
-			CreatedAt: v.CreatedAt,
-		})
+	return c.notificationResponse(ctx, userID, notification, "Notification marked as read")
+}
+
+func (c *NotificationController) MarkAllNotificationsRead(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	count, code, err := c.UseCase.MarkAllNotificationsRead(userID)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
 	}
 	res := base.SuccessResponse{
 		Status:  "success",
-		Message: "Notifications fetched",
-		Data:    mapped,
+		Message: "Notifications marked as read",
+		Data:    MarkAllReadResponse{Marked: count},
 	}
 	return ctx.JSON(http.StatusOK, res)
 }
 
+func (c *NotificationController) DeleteNotification(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	code, err := c.UseCase.DeleteNotification(userID, id)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Notification deleted",
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *NotificationController) RestoreNotification(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	notification, code, err := c.UseCase.RestoreNotification(userID, id)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	return c.notificationResponse(ctx, userID, notification, "Notification restored")
+}
+
+// ActOnNotification takes one of the actions of a watering reminder:
+// watered, snooze_1h, snooze_3h or skip_today.
+func (c *NotificationController) ActOnNotification(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	notification, code, err := c.UseCase.ActOnNotification(userID, id, ctx.Param("action"))
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	return c.notificationResponse(ctx, userID, notification, "Notification action taken")
+}
+
+func (c *NotificationController) notificationResponse(ctx echo.Context, userID uint, notification *Notification, message string) error {
+	location, errRes := c.userLocation(userID)
+	if errRes != nil {
+		return ctx.JSON(errRes.Code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: message,
+		Data:    MapNotificationToResponse(notification, location),
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// userLocation returns the time zone dates are shown to the user in.
+func (c *NotificationController) userLocation(userID uint) (*time.Location, *base.ErrorResponse) {
+	location, err := c.UseCase.GetUserLocation(userID)
+	if err != nil {
+		return nil, &base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+	}
+	return location, nil
+}
+
 func (c *NotificationController) DeleteAllNotifications(ctx echo.Context) error {
 	userId := ctx.Get("user_id").(uint)
 	if userId == 0 {
@@ -134,8 +267,170 @@ func (c *NotificationController) DeleteAllNotifications(ctx echo.Context) error
 
 func (c *NotificationController) CreateCustomizeWateringReminder(ctx echo.Context) error {
 	UserID := ctx.Get("user_id").(uint)
-	reminder := new(CustomizeWateringReminderRequest)
-	if err := ctx.Bind(reminder); err != nil {
+	reminderModel, errRes := c.bindReminder(ctx, UserID)
+	if errRes != nil {
+		return ctx.JSON(errRes.Code, errRes)
+	}
+	reminderModel.UserId = int(UserID)
+
+	reminderModel, code, err := c.UseCase.CreateCustomizeWateringReminder(reminderModel)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Customize watering reminder created",
+		Data:    MapReminderToResponse(reminderModel),
+	}
+	return ctx.JSON(http.StatusCreated, res)
+}
+
+func (c *NotificationController) GetCustomizeWateringReminders(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	reminders, code, err := c.UseCase.GetCustomizeWateringReminders(userID)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Customize watering reminders fetched",
+		Data:    MapRemindersToResponse(reminders),
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *NotificationController) GetCustomizeWateringReminder(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	reminder, code, err := c.UseCase.GetCustomizeWateringReminder(userID, id)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Customize watering reminder fetched",
+		Data:    MapReminderToResponse(reminder),
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *NotificationController) UpdateCustomizeWateringReminder(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	changes, errRes := c.bindReminder(ctx, userID)
+	if errRes != nil {
+		return ctx.JSON(errRes.Code, errRes)
+	}
+
+	reminder, code, err := c.UseCase.UpdateCustomizeWateringReminder(userID, id, changes)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Customize watering reminder updated",
+		Data:    MapReminderToResponse(reminder),
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *NotificationController) DeleteCustomizeWateringReminder(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	code, err := c.UseCase.DeleteCustomizeWateringReminder(userID, id)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Customize watering reminder deleted",
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *NotificationController) PauseCustomizeWateringReminder(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	reminder, code, err := c.UseCase.PauseCustomizeWateringReminder(userID, id)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Customize watering reminder paused",
+		Data:    MapReminderToResponse(reminder),
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *NotificationController) ResumeCustomizeWateringReminder(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	reminder, code, err := c.UseCase.ResumeCustomizeWateringReminder(userID, id)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Customize watering reminder resumed",
+		Data:    MapReminderToResponse(reminder),
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *NotificationController) GetNotificationSettings(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	settings, code, err := c.UseCase.GetNotificationSettings(userID)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	return c.settingsResponse(ctx, userID, settings, "Notification settings fetched")
+}
+
+func (c *NotificationController) UpdateNotificationSettings(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	req := new(NotificationSettingsRequest)
+	if err := ctx.Bind(req); err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
 			Message: err.Error(),
@@ -143,37 +438,98 @@ func (c *NotificationController) CreateCustomizeWateringReminder(ctx echo.Contex
 		}
 		return ctx.JSON(http.StatusBadRequest, errRes)
 	}
-	reminderModel := &CustomizeWateringReminder{
-		PlantId:   reminder.PlantID,
-		UserId:    int(UserID),
-		Time:      reminder.Time,
-		Recurring: reminder.Recurring,
-		Type:      reminder.Type,
+	if err := validator.New().Struct(req); err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	settings := &NotificationSettings{}
+	for _, p := range req.Categories {
+		settings.Preferences = append(settings.Preferences, NotificationPreference{
+			Category: p.Category,
+			Enabled:  p.Enabled,
+			Channels: strings.Join(p.Channels, ","),
+		})
 	}
-	_, err := c.UseCase.CreateCustomizeWateringReminder(reminderModel)
+	for _, q := range req.QuietHours {
+		settings.QuietHours = append(settings.QuietHours, QuietHours{Start: q.Start, End: q.End})
+	}
+
+	settings, code, err := c.UseCase.UpdateNotificationSettings(userID, settings)
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
 			Message: err.Error(),
-			Code:    http.StatusInternalServerError,
+			Code:    code,
 		}
-		return ctx.JSON(http.StatusInternalServerError, errRes)
+		return ctx.JSON(code, errRes)
 	}
-	mapped := &CustomizeWateringReminderResponse{
-		Id:        reminderModel.Id,
-		PlantID:   reminderModel.PlantId,
-		Plant:     *MapPlantToPlantResponse(&reminderModel.Plant),
-		UserID:    reminderModel.UserId,
-		User:      reminderModel.User,
-		Time:      reminderModel.Time,
-		Recurring: reminderModel.Recurring,
-		Type:      reminderModel.Type,
-		CreatedAt: reminderModel.CreatedAt,
+	return c.settingsResponse(ctx, userID, settings, "Notification settings updated")
+}
+
+func (c *NotificationController) settingsResponse(ctx echo.Context, userID uint, settings *NotificationSettings, message string) error {
+	location, errRes := c.userLocation(userID)
+	if errRes != nil {
+		return ctx.JSON(errRes.Code, errRes)
 	}
 	res := base.SuccessResponse{
 		Status:  "success",
-		Message: "Customize watering reminder created",
-		Data:    mapped,
+		Message: message,
+		Data:    MapSettingsToResponse(settings, location, c.UseCase.GetChannels()),
 	}
-	return ctx.JSON(http.StatusCreated, res)
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// bindReminder reads and validates a reminder request. Dates and the time of
+// day are in the time zone of the user; the start date defaults to today.
+func (c *NotificationController) bindReminder(ctx echo.Context, userID uint) (*CustomizeWateringReminder, *base.ErrorResponse) {
+	req := new(CustomizeWateringReminderRequest)
+	if err := ctx.Bind(req); err != nil {
+		return nil, &base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return nil, &base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	location, err := c.UseCase.GetUserLocation(userID)
+	if err != nil {
+		return nil, &base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+	}
+	startDate := time.Now().In(location).Format("2006-01-02")
+	if req.StartDate != "" {
+		startDate = req.StartDate
+	}
+	startAt, _ := time.ParseInLocation("2006-01-02 15:04", startDate+" "+req.Time, location)
+
+	reminder := &CustomizeWateringReminder{
+		PlantId:  req.PlantID,
+		RRule:    req.RRule,
+		StartAt:  startAt,
+		Timezone: location.String(),
+	}
+	if reminder.RRule == "" {
+		reminder.RRule = LegacyRRule(req.Type, req.Recurring)
+	}
+	if req.EndDate != "" {
+		endDate, _ := time.ParseInLocation("2006-01-02", req.EndDate, location)
+		endAt := endDate.Add(24*time.Hour - time.Second)
+		reminder.EndAt = &endAt
+	}
+	return reminder, nil
 }
@@ -0,0 +1,115 @@
+package notification
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+
+	"gopkg.in/gomail.v2"
+)
+
+// smtpsPort is the port of SMTP over TLS, other ports start in plain text
+// and switch to TLS with STARTTLS when the server offers it.
+const smtpsPort = 465
+
+// EmailNotifier sends messages to the email address of a user.
+type EmailNotifier struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+func NewEmailNotifier(host string, port int, username, password, from string) *EmailNotifier {
+	return &EmailNotifier{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (n *EmailNotifier) Channel() string {
+	return ChannelEmail
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.User.Email == "" {
+		return ErrNoRecipient
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", n.from)
+	m.SetHeader("To", msg.User.Email)
+	m.SetHeader("Subject", msg.Title)
+	m.SetBody("text/plain", msg.Body)
+
+	err := gomail.Send(gomail.SendFunc(func(from string, to []string, m io.WriterTo) error {
+		return n.send(ctx, from, to, m)
+	}), m)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// send delivers one message. The connection is closed when ctx ends, so a
+// server that stops answering cannot hold up the delivery worker.
+func (n *EmailNotifier) send(ctx context.Context, from string, to []string, m io.WriterTo) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(n.host, strconv.Itoa(n.port)))
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	tlsConfig := &tls.Config{ServerName: n.host}
+	client := conn
+	if n.port == smtpsPort {
+		client = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(client, n.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && n.port != smtpsPort {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if ok, _ := c.Extension("AUTH"); ok && n.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.username, n.password, n.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := m.WriteTo(w); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
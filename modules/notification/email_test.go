@@ -0,0 +1,110 @@
+package notification
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveSMTP runs a server that accepts one message per connection and
+// sends what it received to mails. When hang is set, it accepts
+// connections but never answers.
+func serveSMTP(t *testing.T, hang bool, mails chan<- string) (string, int) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			if hang {
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				rd := bufio.NewReader(conn)
+				var mail strings.Builder
+				io.WriteString(conn, "220 localhost ESMTP\r\n")
+				for {
+					line, err := rd.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+					case "EHLO", "HELO":
+						io.WriteString(conn, "250 localhost\r\n")
+					case "MAIL", "RCPT":
+						mail.WriteString(line)
+						io.WriteString(conn, "250 OK\r\n")
+					case "DATA":
+						io.WriteString(conn, "354 Go ahead\r\n")
+						for {
+							line, err := rd.ReadString('\n')
+							if err != nil || line == ".\r\n" {
+								break
+							}
+							mail.WriteString(line)
+						}
+						io.WriteString(conn, "250 OK\r\n")
+						mails <- mail.String()
+					case "QUIT":
+						io.WriteString(conn, "221 Bye\r\n")
+						return
+					default:
+						io.WriteString(conn, "502 Not implemented\r\n")
+					}
+				}
+			}()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return host, portNumber
+}
+
+func TestEmailNotifier(t *testing.T) {
+	mails := make(chan string, 1)
+	host, port := serveSMTP(t, false, mails)
+	n := NewEmailNotifier(host, port, "", "", "noreply@example.com")
+	msg := Message{User: user.User{ID: 3, Email: "budi@example.com"}, Title: "Watering Reminder", Body: "Time to water"}
+
+	err := n.Notify(context.Background(), msg)
+
+	assert.NoError(t, err)
+	mail := <-mails
+	assert.Contains(t, mail, "MAIL FROM:<noreply@example.com>")
+	assert.Contains(t, mail, "RCPT TO:<budi@example.com>")
+	assert.Contains(t, mail, "Subject: Watering Reminder")
+	assert.Contains(t, mail, "Time to water")
+
+	err = n.Notify(context.Background(), Message{Title: "Watering Reminder"})
+	assert.ErrorIs(t, err, ErrNoRecipient)
+}
+
+func TestEmailNotifierStopsWithContext(t *testing.T) {
+	host, port := serveSMTP(t, true, nil)
+	n := NewEmailNotifier(host, port, "", "", "noreply@example.com")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := n.Notify(ctx, Message{User: user.User{Email: "budi@example.com"}, Title: "Watering Reminder"})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -5,28 +5,217 @@ import (
 
 	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
 	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+	"gorm.io/gorm"
 )
 
+// Notification is an entry in the inbox of a user. Deleted notifications
+// can be restored until they are purged.
 type Notification struct {
-	Id        int `gorm:"primaryKey"`
-	Title     string
-	Body      string
-	UserId    int `gorm:"foreignKey:UserID;references:Id"`
-	PlantId   int `gorm:"foreignKey:PlantId;references:Id"`
-	IsRead    bool
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	Id      int `gorm:"primaryKey"`
+	Title   string
+	Body    string
+	UserId  int `gorm:"foreignKey:UserID;references:Id;index"`
+	PlantId int `gorm:"foreignKey:PlantId;references:Id"`
+	// Category decides the channels the notification is delivered over.
+	Category string `gorm:"size:20;not null;default:''"`
+	IsRead   bool
+	// Action is the last action the user took on a reminder, and
+	// SnoozedUntil when a snoozed reminder comes back.
+	Action       string `gorm:"size:20;not null;default:''"`
+	ActedAt      *time.Time
+	SnoozedUntil *time.Time
+	// JobID is the reminder job that sent the notification, so a job that
+	// is delivered again after a crash does not show up twice.
+	JobID      *int                   `gorm:"uniqueIndex"`
+	Deliveries []NotificationDelivery `gorm:"foreignKey:NotificationID"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
 }
 
+// CustomizeWateringReminder is a reminder a user sets for a plant. It
+// repeats by the iCalendar RRule from StartAt, at the time of day StartAt has
+// in Timezone, until EndAt. Timezone follows the time zone of the user.
+// NextRunAt is nil once the series is over.
 type CustomizeWateringReminder struct {
 	Id        int `gorm:"primaryKey"`
 	UserId    int `gorm:"foreignKey:UserID;references:ID"`
 	User      user.User
 	PlantId   int `gorm:"foreignKey:PlantId;references:ID"`
 	Plant     plant.Plant
-	Time      string
-	Recurring bool
-	Type      string // "daily", "weekly", "monthly", "yearly
+	RRule     string `gorm:"column:rrule;size:255"`
+	StartAt   time.Time
+	Timezone  string `gorm:"size:64;not null;default:''"`
+	EndAt     *time.Time
+	NextRunAt *time.Time `gorm:"index"`
+	PausedAt  *time.Time
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// Kinds of reminder jobs. A snooze job repeats the notification SourceID
+// refers to. An escalation job is due at the first watering time a plant
+// missed, so a plant that stays overdue is escalated once.
+const (
+	JobPlantSchedule  = "plant_schedule"
+	JobCustomReminder = "custom_reminder"
+	JobSnooze         = "snooze"
+	JobEscalation     = "escalation"
+)
+
+// Status of a reminder job.
+const (
+	JobPending    = "pending"
+	JobProcessing = "processing"
+	JobSent       = "sent"
+	JobFailed     = "failed"
+	JobSkipped    = "skipped"
+)
+
+// ReminderJob is one occurrence of a reminder, planned ahead and stored so a
+// restart or a missed tick cannot lose it. SourceID is the plant reminder or
+// customized reminder the occurrence belongs to. The unique index makes
+// planning an occurrence twice harmless, and Status records whether it was
+// delivered. A job stays processing until LockedUntil while a worker sends
+// it; after that another worker may take it over. A job due in the quiet
+// hours of the user is held back until NotBefore, and so is a watering
+// reminder postponed until the rain stops, which sets RainDelayed.
+type ReminderJob struct {
+	ID          int       `gorm:"primaryKey"`
+	Kind        string    `gorm:"size:20;uniqueIndex:idx_reminder_job_occurrence"`
+	SourceID    int       `gorm:"uniqueIndex:idx_reminder_job_occurrence"`
+	UserID      int       `gorm:"uniqueIndex:idx_reminder_job_occurrence"`
+	User        user.User `gorm:"foreignKey:UserID;references:ID"`
+	PlantID     int
+	Plant       plant.Plant `gorm:"foreignKey:PlantID;references:ID"`
+	DueAt       time.Time   `gorm:"uniqueIndex:idx_reminder_job_occurrence;index:idx_reminder_job_due,priority:2"`
+	Status      string      `gorm:"size:16;index:idx_reminder_job_due,priority:1"`
+	Attempts    int
+	LockedUntil *time.Time
+	NotBefore   *time.Time
+	RainDelayed bool   `gorm:"not null;default:false"`
+	LastError   string `gorm:"size:500"`
+	SentAt      *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	// advice is how the weather changes the reminder, decided when the
+	// job is claimed.
+	advice *wateringAdvice
+}
+
+// ReminderPlan records up to when a kind of reminder has been planned as
+// jobs.
+type ReminderPlan struct {
+	Kind         string `gorm:"primaryKey;size:20"`
+	PlannedUntil time.Time
+}
+
+// PlantOwner is a user who has a plant, with the time zone the plant's
+// watering times are read in.
+type PlantOwner struct {
+	UserID   int
+	PlantID  int
+	Timezone string
+}
+
+// Status of the delivery of a notification over a channel.
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliverySkipped = "skipped"
+	DeliveryDead    = "dead"
+)
+
+// NotificationDelivery is the delivery of a notification over one channel.
+// A pending delivery is sent at NextAttemptAt; a failed attempt moves it
+// further back until it runs out of attempts and is dead. Skipped means the
+// user cannot be reached over the channel.
+type NotificationDelivery struct {
+	ID             int          `gorm:"primaryKey"`
+	NotificationID int          `gorm:"uniqueIndex:idx_delivery_channel"`
+	Notification   Notification `gorm:"foreignKey:NotificationID"`
+	UserID         int          `gorm:"index"`
+	User           user.User    `gorm:"foreignKey:UserID;references:ID"`
+	Channel        string       `gorm:"size:16;uniqueIndex:idx_delivery_channel"`
+	Status         string       `gorm:"size:16;index:idx_delivery_due,priority:1"`
+	Attempts       int
+	NextAttemptAt  *time.Time `gorm:"index:idx_delivery_due,priority:2"`
+	LastError      string     `gorm:"size:500"`
+	SentAt         *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// DeadLetter keeps a notification that could not be delivered over a
+// channel, with the last error, so it can be looked into and sent again.
+type DeadLetter struct {
+	ID             int `gorm:"primaryKey"`
+	DeliveryID     int `gorm:"uniqueIndex"`
+	NotificationID int
+	UserID         int    `gorm:"index"`
+	Channel        string `gorm:"size:16"`
+	Title          string
+	Body           string
+	Attempts       int
+	Error          string `gorm:"size:500"`
+	CreatedAt      time.Time
+}
+
+// Categories of notifications. Users choose the channels of each category.
+const (
+	CategoryWatering       = "watering"
+	CategoryCustomReminder = "custom_reminder"
+	CategoryWeatherAlert   = "weather_alert"
+	CategoryArticle        = "article"
+)
+
+var Categories = []string{CategoryWatering, CategoryCustomReminder, CategoryWeatherAlert, CategoryArticle}
+
+// NotificationPreference is how a user wants the notifications of a
+// category. Channels lists the channels separated by commas; without any,
+// the notifications only go to the inbox. Notifications of a disabled
+// category are not sent at all. A category without a preference is sent over
+// every channel.
+type NotificationPreference struct {
+	UserID    int    `gorm:"primaryKey;autoIncrement:false"`
+	Category  string `gorm:"primaryKey;size:20"`
+	Enabled   bool
+	Channels  string `gorm:"size:64"`
+	UpdatedAt time.Time
+}
+
+// QuietHours is a daily window in the time zone of the user. Reminders due
+// in it are held back until it ends. A window that ends before it starts
+// runs past midnight, like 22:00 to 06:00.
+type QuietHours struct {
+	ID     int    `gorm:"primaryKey"`
+	UserID int    `gorm:"index"`
+	Start  string `gorm:"size:5"`
+	End    string `gorm:"size:5"`
+}
+
+// NotificationSettings are the preferences of a user for every category,
+// and their quiet hours.
+type NotificationSettings struct {
+	Preferences []NotificationPreference
+	QuietHours  []QuietHours
+}
+
+// Actions a user can take on a watering reminder.
+const (
+	ActionWatered   = "watered"
+	ActionSnooze1h  = "snooze_1h"
+	ActionSnooze3h  = "snooze_3h"
+	ActionSkipToday = "skip_today"
+)
+
+var ReminderActions = []string{ActionWatered, ActionSnooze1h, ActionSnooze3h, ActionSkipToday}
+
+// ReminderSkip holds back the reminders of a plant for a user until Until,
+// after the user chose to skip watering it today.
+type ReminderSkip struct {
+	UserID  int `gorm:"primaryKey;autoIncrement:false"`
+	PlantID int `gorm:"primaryKey;autoIncrement:false"`
+	Until   time.Time
+}
@@ -0,0 +1,47 @@
+package notification
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeNotifier keeps messages in memory instead of delivering them, for
+// tests. Each call to Notify first takes the next of Errors; a nil or
+// missing error means the message is delivered.
+type FakeNotifier struct {
+	channel string
+	mu      sync.Mutex
+	errors  []error
+	sent    []Message
+}
+
+func NewFakeNotifier(channel string, errors ...error) *FakeNotifier {
+	return &FakeNotifier{channel: channel, errors: errors}
+}
+
+func (n *FakeNotifier) Channel() string {
+	return n.channel
+}
+
+func (n *FakeNotifier) Notify(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.errors) > 0 {
+		err := n.errors[0]
+		n.errors = n.errors[1:]
+		if err != nil {
+			return err
+		}
+	}
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+// Sent returns the delivered messages.
+func (n *FakeNotifier) Sent() []Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]Message(nil), n.sent...)
+}
@@ -0,0 +1,115 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/messaging"
+	"github.com/OctavianoRyan25/be-agriculture/modules/device"
+	"google.golang.org/api/option"
+)
+
+// FCM accepts at most 500 tokens in one multicast message
+const maxMulticastTokens = 500
+
+// FCMNotifier pushes messages to the registered devices of a user with
+// Firebase Cloud Messaging.
+type FCMNotifier struct {
+	client  *messaging.Client
+	devices device.UseCase
+}
+
+// NewFCMNotifier creates the Firebase client once for all messages.
+func NewFCMNotifier(ctx context.Context, credential []byte, devices device.UseCase) (*FCMNotifier, error) {
+	if len(credential) == 0 {
+		return nil, fmt.Errorf("no firebase credential")
+	}
+	app, err := firebase.NewApp(ctx, nil, option.WithCredentialsJSON(credential))
+	if err != nil {
+		return nil, fmt.Errorf("error initializing firebase app: %w", err)
+	}
+	client, err := app.Messaging(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing firebase messaging: %w", err)
+	}
+	return &FCMNotifier{client: client, devices: devices}, nil
+}
+
+func (n *FCMNotifier) Channel() string {
+	return ChannelPush
+}
+
+// Notify sends the message to every registered device of the user and
+// removes the tokens FCM reports as invalid. The data payload carries the
+// notification id, its category and the actions the app can offer, as a
+// comma separated list for POST /notifications/:id/actions/:action.
+func (n *FCMNotifier) Notify(ctx context.Context, msg Message) error {
+	devices, _, err := n.devices.GetDevices(uint(msg.User.ID))
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return ErrNoRecipient
+	}
+
+	tokens := make([]string, 0, len(devices))
+	for _, d := range devices {
+		tokens = append(tokens, d.Token)
+	}
+
+	var invalid []string
+	var delivered int
+	for start := 0; start < len(tokens); start += maxMulticastTokens {
+		end := min(start+maxMulticastTokens, len(tokens))
+		batch := tokens[start:end]
+
+		res, err := n.client.SendEachForMulticast(ctx, &messaging.MulticastMessage{
+			Tokens: batch,
+			Notification: &messaging.Notification{
+				Title: msg.Title,
+				Body:  msg.Body,
+			},
+			Data: map[string]string{
+				"notification_id": strconv.Itoa(msg.NotificationID),
+				"plant_id":        strconv.Itoa(msg.PlantID),
+				"category":        msg.Category,
+				"actions":         strings.Join(msg.Actions, ","),
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		delivered += res.SuccessCount
+		for i, r := range res.Responses {
+			if r.Error != nil && isInvalidToken(r.Error) {
+				invalid = append(invalid, batch[i])
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		err = n.devices.PruneTokens(invalid)
+		if err != nil {
+			log.Printf("Error pruning %d invalid FCM tokens: %v", len(invalid), err)
+		}
+	}
+
+	if len(invalid) == len(tokens) {
+		return ErrNoRecipient
+	}
+	if delivered == 0 {
+		return fmt.Errorf("no device of user %s accepted the message", msg.User.Email)
+	}
+	return nil
+}
+
+// isInvalidToken reports whether FCM rejected the token itself, so retrying
+// with the same token can never succeed.
+func isInvalidToken(err error) bool {
+	return messaging.IsUnregistered(err) || messaging.IsSenderIDMismatch(err) || messaging.IsInvalidArgument(err)
+}
@@ -1,310 +1,287 @@
 package notification
 
 import (
-	"context"
 	"fmt"
-	"log"
-	"os"
 	"strings"
 	"time"
 
-	firebase "firebase.google.com/go/v4"
-	"firebase.google.com/go/v4/messaging"
 	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
-	"github.com/OctavianoRyan25/be-agriculture/modules/user" // Updated import
+	"github.com/OctavianoRyan25/be-agriculture/utils/timezone"
 	"github.com/robfig/cron/v3"
-	"google.golang.org/api/option"
-	"gorm.io/gorm"
 )
 
-// Initialize Firebase app
-func InitFirebase() *firebase.App {
-	// Production
-	credential := os.Getenv("FIREBASE_CREDENTIAL")
-	opt := option.WithCredentialsJSON([]byte(credential))
-	//Development
-	// opt := option.WithCredentialsFile("agriculture-af713-c7c8068614c1.json")
-	app, err := firebase.NewApp(context.Background(), nil, opt)
-	if err != nil {
-		log.Fatalf("error initializing app: %v", err)
+// truncateError shortens an error to fit the 500 characters of the
+// last_error columns.
+func truncateError(err error) string {
+	msg := err.Error()
+	if len(msg) > 500 {
+		msg = msg[:500]
 	}
-	return app
+	return msg
 }
 
-// Send reminder notification and store it in the database
-func SendReminder(user user.User, plant plant.Plant, useCase UseCase) error {
-	// Simulating FCM messaging part is commented out
-	app := InitFirebase()
-	client, err := app.Messaging(context.Background())
+// StartReminderWorker plans reminder jobs every minute and sends the due
+// ones every 15 seconds, together with the deliveries that are due for
+// another attempt. Every instance of the app runs it, the job and delivery
+// tables keep them from sending a reminder twice. The first plan right after
+// startup catches up on the reminders missed while no instance was running.
+// Every hour it also purges the deleted notifications that can no longer be
+// restored.
+func StartReminderWorker(useCase UseCase) {
+	go planReminderJobs(useCase)
+
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+	c.AddFunc("@every 1m", func() {
+		planReminderJobs(useCase)
+	})
+	c.AddFunc("@every 15s", func() {
+		sendReminderJobs(useCase)
+	})
+	c.AddFunc("@every 15s", func() {
+		retryDeliveries(useCase)
+	})
+	c.AddFunc("@every 1h", func() {
+		err := useCase.PurgeDeletedNotifications()
+		if err != nil {
+			fmt.Printf("Failed to purge deleted notifications: %v\n", err)
+		}
+	})
+	c.Start()
+}
+
+func planReminderJobs(useCase UseCase) {
+	err := useCase.PlanReminderJobs()
 	if err != nil {
-		log.Fatalf("error getting FCM client: %v", err)
+		fmt.Printf("Failed to plan reminder jobs: %v\n", err)
 	}
+}
 
-	message := &messaging.Message{
-		Token: user.FCMToken,
-		Notification: &messaging.Notification{
-			Title: "Watering Reminder",
-			Body:  fmt.Sprintf("Hiii %s, It's time to water your plant: %s", user.Name, plant.Name),
-		},
-	}
+func sendReminderJobs(useCase UseCase) {
+	for {
+		jobs, err := useCase.ClaimReminderJobs()
+		if err != nil {
+			fmt.Printf("Failed to claim reminder jobs: %v\n", err)
+			return
+		}
+		if len(jobs) == 0 {
+			return
+		}
 
-	_, err = client.Send(context.Background(), message)
-	if err != nil {
-		log.Printf("Error sending FCM message: %v", err)
-	} else {
-		fmt.Printf("Reminder have pushed to %s for watering plant %s\n", user.Email, plant.Name)
+		for i := range jobs {
+			sendErr := useCase.DeliverReminderJob(&jobs[i])
+			if sendErr != nil {
+				fmt.Printf("Error sending reminder job %d: %v\n", jobs[i].ID, sendErr)
+			}
+			err := useCase.FinishReminderJob(&jobs[i], sendErr)
+			if err != nil {
+				fmt.Printf("Failed to record reminder job %d: %v\n", jobs[i].ID, err)
+			}
+		}
 	}
+}
 
-	// Store the notification in the database
-	notification := &Notification{
-		Title:     "Watering Reminder",
-		Body:      fmt.Sprintf("Hiii %s, It's time to water your plant: %s", user.Name, plant.Name),
-		UserId:    user.ID,
-		PlantId:   plant.ID,
-		IsRead:    false,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+func retryDeliveries(useCase UseCase) {
+	for {
+		n, err := useCase.RetryDeliveries()
+		if err != nil {
+			fmt.Printf("Failed to retry deliveries: %v\n", err)
+			return
+		}
+		if n < deliveryBatch {
+			return
+		}
 	}
+}
 
-	_, err = useCase.StoreNotification(notification)
-	if err != nil {
-		log.Printf("Error storing notification: %v", err)
-		return err
-	}
-	fmt.Printf("Notification stored for user %s\n", user.Email)
-	return nil
+// IsActionable reports whether the user can take the reminder actions on a
+// notification.
+func IsActionable(notification *Notification) bool {
+	return notification.PlantId != 0 &&
+		(notification.Category == CategoryWatering || notification.Category == CategoryCustomReminder)
 }
 
-func SendCustomReminder(reminder CustomizeWateringReminder, useCase UseCase) error {
-	// Simulating FCM messaging part is commented out
-	app := InitFirebase()
-	client, err := app.Messaging(context.Background())
-	if err != nil {
-		log.Fatalf("error getting FCM client: %v", err)
+// Preference returns the preference of a category.
+func (s *NotificationSettings) Preference(category string) NotificationPreference {
+	for _, p := range s.Preferences {
+		if p.Category == category {
+			return p
+		}
 	}
+	return NotificationPreference{Category: category, Enabled: true}
+}
 
-	user := reminder.User
-	plant := reminder.Plant
-
-	message := &messaging.Message{
-		Token: user.FCMToken,
-		Notification: &messaging.Notification{
-			Title: "Watering Reminder",
-			Body:  fmt.Sprintf("It's time to water your plant: %s", plant.Name),
-		},
+// ChannelList returns the channels of a preference.
+func (p NotificationPreference) ChannelList() []string {
+	var channels []string
+	for _, channel := range strings.Split(p.Channels, ",") {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			channels = append(channels, channel)
+		}
 	}
+	return channels
+}
 
-	_, err = client.Send(context.Background(), message)
-	if err != nil {
-		log.Printf("Error sending FCM message: %v", err)
-	} else {
-		fmt.Printf("Reminder have pushed to %s for watering plant %s\n", user.Email, plant.Name)
+// quietUntil reports whether t is in quiet hours, and when they end. Windows
+// that overlap or follow each other count as one.
+func quietUntil(windows []QuietHours, t time.Time, location *time.Location) (time.Time, bool) {
+	until, quiet := t, false
+	for range len(windows) + 1 {
+		end, ok := windowEnd(windows, until, location)
+		if !ok {
+			break
+		}
+		until, quiet = end, true
 	}
+	return until, quiet
+}
 
-	// Store the notification in the database
-	notification := &Notification{
-		Title:     "Customize Watering Reminder",
-		Body:      fmt.Sprintf("Hiii %s, It's time to water your plant: %s", user.Name, reminder.Plant.Name),
-		UserId:    user.ID,
-		PlantId:   reminder.Plant.ID,
-		IsRead:    false,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+// windowEnd returns the end of the window t is in.
+func windowEnd(windows []QuietHours, t time.Time, location *time.Location) (time.Time, bool) {
+	local := t.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	at := func(days, minutes int) time.Time {
+		return time.Date(local.Year(), local.Month(), local.Day()+days, minutes/60, minutes%60, 0, 0, location)
 	}
 
-	_, err = useCase.StoreNotification(notification)
-	if err != nil {
-		log.Printf("Error storing notification: %v", err)
-		return err
+	for _, w := range windows {
+		start, err := time.Parse("15:04", w.Start)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse("15:04", w.End)
+		if err != nil {
+			continue
+		}
+		s := start.Hour()*60 + start.Minute()
+		e := end.Hour()*60 + end.Minute()
+
+		switch {
+		case s < e && minute >= s && minute < e:
+			return at(0, e), true
+		case s > e && minute >= s:
+			return at(1, e), true
+		case s > e && minute < e:
+			return at(0, e), true
+		}
 	}
-	fmt.Printf("Custom notification stored for user %s\n", user.Email)
-	return nil
+	return time.Time{}, false
 }
 
-// Schedule watering reminders based on PlantReminder.WateringTime
-func StartScheduler(db *gorm.DB, useCase UseCase) {
-	// Define desired location for time zone (Asia/Jakarta)
-	location, err := time.LoadLocation("Asia/Jakarta")
-	if err != nil {
-		fmt.Printf("Error loading location: %v\n", err)
-		return
+// LegacyRRule converts the type and recurring flag reminders were created
+// with before recurrence rules into a rule.
+func LegacyRRule(reminderType string, recurring bool) string {
+	rule := "FREQ=DAILY"
+	switch strings.ToLower(reminderType) {
+	case "week", "weekly":
+		rule = "FREQ=WEEKLY"
+	case "month", "monthly":
+		rule = "FREQ=MONTHLY"
 	}
-
-	c := cron.New()
-	// c.AddFunc("@every 1m", func() {
-	// 	handlerRegularReminder(db, useCase, location, "daily")
-	// })
-	c.AddFunc("0 * * * *", func() {
-		handlerRegularReminder(db, useCase, location, "Day")
-	})
-	c.AddFunc("0 0 * * 0", func() {
-		handlerRegularReminder(db, useCase, location, "Week")
-	})
-	c.AddFunc("0 0 1 * *", func() {
-		handlerRegularReminder(db, useCase, location, "Month")
-	})
-	c.Start()
+	if !recurring {
+		rule += ";COUNT=1"
+	}
+	return rule
 }
 
-// func handlerRegularReminder(db *gorm.DB, useCase UseCase, location *time.Location, reminderType string) {
-// 	fmt.Printf("Checking %s for plants to water...", reminderType)
-// 	fmt.Printf("now is %v\n", time.Now().In(location))
-// 	var plantsToWater []plant.Plant
-// 	currentTime := time.Now().In(location)
-// 	formattedTime := currentTime.Format("15:04")
-
-// 	// Fetch all plants that need watering at the current time
-// 	err := db.
-// 		Preload("WateringSchedule").
-// 		Joins("JOIN plant_reminders ON plant_reminders.plant_id = plants.id").
-// 		Where("plant_reminders.watering_time = ? AND plant_reminders.each = ?", formattedTime, reminderType).
-// 		Find(&plantsToWater).Error
-// 	if err != nil {
-// 		fmt.Printf("Failed to fetch plants to water: %v\n", err)
-// 		return
-// 	}
-
-// 	// Check if any plants need watering
-// 	if len(plantsToWater) == 0 {
-// 		fmt.Println("No plants found for watering at this time.")
-// 		return
-// 	}
-
-// 	// Iterate over each plant to water
-// 	for _, plantToWater := range plantsToWater {
-// 		var usersWithPlant []user.User
-
-// 		// Find users who have this plant
-// 		err := db.Model(&user.User{}).
-// 			Joins("JOIN user_plants ON users.id = user_plants.user_id").
-// 			Where("user_plants.plant_id = ?", plantToWater.ID).
-// 			Find(&usersWithPlant).Error
-// 		if err != nil {
-// 			fmt.Printf("Failed to fetch users with plant %s: %v\n", plantToWater.Name, err)
-// 			continue
-// 		}
-
-// 		// Notify each user
-// 		for _, user := range usersWithPlant {
-// 			err := SendReminder(user, plantToWater, useCase)
-// 			if err != nil {
-// 				fmt.Printf("Error sending reminder to user %s: %v\n", user.Email, err)
-// 			}
-// 		}
-// 	}
-// }
-
-func handlerRegularReminder(db *gorm.DB, useCase UseCase, location *time.Location, reminderType string) {
-	fmt.Printf("Checking %s for plants to water...\n", reminderType)
-
-	currentTime := time.Now().In(location)
-	formattedTime := currentTime.Format("15:04")
-
-	// Fetch all plants that need watering at the current time
-	var plantsToWater []plant.Plant
-	err := db.
-		Preload("WateringSchedule").
-		Joins("JOIN plant_reminders ON plant_reminders.plant_id = plants.id").
-		Where("plant_reminders.each = ?", reminderType).
-		Find(&plantsToWater).Error
-	if err != nil {
-		fmt.Printf("Failed to fetch plants to water: %v\n", err)
-		return
+// MapReminderToResponse renders the times of a reminder in its time zone.
+func MapReminderToResponse(reminder *CustomizeWateringReminder) *CustomizeWateringReminderResponse {
+	location := timezone.Location(reminder.Timezone)
+	start := reminder.StartAt.In(location)
+
+	res := &CustomizeWateringReminderResponse{
+		Id:        reminder.Id,
+		PlantID:   reminder.PlantId,
+		Plant:     *MapPlantToPlantResponse(&reminder.Plant),
+		UserID:    reminder.UserId,
+		RRule:     reminder.RRule,
+		Time:      start.Format("15:04"),
+		StartDate: start.Format("2006-01-02"),
+		Timezone:  location.String(),
+		Paused:    reminder.PausedAt != nil,
+		CreatedAt: reminder.CreatedAt.In(location),
 	}
-
-	// Check if any plants need watering
-	if len(plantsToWater) == 0 {
-		fmt.Println("No plants found for watering at this time.")
-		return
+	if reminder.NextRunAt != nil {
+		next := reminder.NextRunAt.In(location)
+		res.NextRunAt = &next
 	}
-
-	// Iterate over each plant to water
-	for _, plantToWater := range plantsToWater {
-		wateringTimes := strings.Split(plantToWater.WateringSchedule.WateringTime, ", ")
-		for _, wateringTime := range wateringTimes {
-			if wateringTime == formattedTime {
-				var usersWithPlant []user.User
-
-				// Find users who have this plant
-				err := db.Model(&user.User{}).
-					Joins("JOIN user_plants ON users.id = user_plants.user_id").
-					Where("user_plants.plant_id = ?", plantToWater.ID).
-					Find(&usersWithPlant).Error
-				if err != nil {
-					fmt.Printf("Failed to fetch users with plant %s: %v\n", plantToWater.Name, err)
-					continue
-				}
-
-				// Notify each user
-				for _, user := range usersWithPlant {
-					err := SendReminder(user, plantToWater, useCase)
-					if err != nil {
-						fmt.Printf("Error sending reminder to user %s: %v\n", user.Email, err)
-					}
-				}
-			}
-		}
+	if reminder.EndAt != nil {
+		res.EndDate = reminder.EndAt.In(location).Format("2006-01-02")
 	}
+	return res
 }
 
-func StartSchedulerForCustomizeWateringReminder(db *gorm.DB, useCase UseCase) {
-	// Define desired location for time zone (Asia/Jakarta)
-	location, err := time.LoadLocation("Asia/Jakarta")
-	if err != nil {
-		fmt.Printf("Error loading location: %v\n", err)
-		return
+func MapRemindersToResponse(reminders []CustomizeWateringReminder) []CustomizeWateringReminderResponse {
+	res := make([]CustomizeWateringReminderResponse, 0, len(reminders))
+	for i := range reminders {
+		res = append(res, *MapReminderToResponse(&reminders[i]))
 	}
-	c := cron.New()
-	// c.AddFunc("@every 1m", func() {
-	// 	handleCustomizedReminders(db, useCase, location, "daily")
-	// })
-	c.AddFunc("0 * * * *", func() {
-		handleCustomizedReminders(db, useCase, location, "Day")
-	})
-
-	c.AddFunc("0 0 * * 0", func() {
-		handleCustomizedReminders(db, useCase, location, "Week")
-	})
-
-	c.AddFunc("0 0 1 * *", func() {
-		handleCustomizedReminders(db, useCase, location, "Month")
-	})
-
-	c.Start()
+	return res
 }
 
-func handleCustomizedReminders(db *gorm.DB, useCase UseCase, location *time.Location, reminderType string) {
-	fmt.Printf("Checking for %s customized watering reminders...\n", reminderType)
-	var reminders []CustomizeWateringReminder
-	currentTime := time.Now().In(location)
-	formattedTime := currentTime.Format("15:04")
-
-	err := db.Preload("User").Preload("Plant").
-		Where("type = ? AND time = ?", reminderType, formattedTime).
-		Find(&reminders).Error
-	if err != nil {
-		fmt.Printf("Failed to fetch %s customized watering reminders: %v\n", reminderType, err)
-		return
+func MapNotificationToResponse(notification *Notification, location *time.Location) *NotificationResponse {
+	res := &NotificationResponse{
+		Id:         notification.Id,
+		Title:      notification.Title,
+		Body:       notification.Body,
+		UserId:     notification.UserId,
+		PlantId:    notification.PlantId,
+		Type:       notification.Category,
+		IsRead:     notification.IsRead,
+		Actions:    []string{},
+		Action:     notification.Action,
+		Deliveries: MapDeliveriesToResponse(notification.Deliveries, location),
+		CreatedAt:  notification.CreatedAt.In(location),
 	}
-
-	if len(reminders) == 0 {
-		fmt.Printf("No %s customized watering reminders found for watering at this time.\n", reminderType)
-		return
+	if IsActionable(notification) {
+		res.Actions = ReminderActions
+	}
+	if notification.SnoozedUntil != nil {
+		snoozedUntil := notification.SnoozedUntil.In(location)
+		res.SnoozedUntil = &snoozedUntil
 	}
+	return res
+}
 
-	for _, reminder := range reminders {
-		err := SendCustomReminder(reminder, useCase)
-		if err != nil {
-			fmt.Printf("Error sending %s customized watering reminder: %v\n", reminderType, err)
-			continue
+func MapSettingsToResponse(settings *NotificationSettings, location *time.Location, channels []string) *NotificationSettingsResponse {
+	res := &NotificationSettingsResponse{
+		Timezone:          location.String(),
+		AvailableChannels: channels,
+		Categories:        make([]CategoryPreferenceResponse, 0, len(settings.Preferences)),
+		QuietHours:        make([]QuietHoursResponse, 0, len(settings.QuietHours)),
+	}
+	for _, p := range settings.Preferences {
+		category := CategoryPreferenceResponse{
+			Category: p.Category,
+			Enabled:  p.Enabled,
+			Channels: p.ChannelList(),
+		}
+		if category.Channels == nil {
+			category.Channels = []string{}
 		}
+		res.Categories = append(res.Categories, category)
+	}
+	for _, q := range settings.QuietHours {
+		res.QuietHours = append(res.QuietHours, QuietHoursResponse{Start: q.Start, End: q.End})
+	}
+	return res
+}
 
-		if !reminder.Recurring {
-			if err := db.Delete(&reminder).Error; err != nil {
-				fmt.Printf("Failed to delete one-time %s reminder: %v\n", reminderType, err)
-			}
+// MapDeliveriesToResponse shows how far a notification got on each channel.
+func MapDeliveriesToResponse(deliveries []NotificationDelivery, location *time.Location) []DeliveryResponse {
+	res := make([]DeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		delivery := DeliveryResponse{
+			Channel:  d.Channel,
+			Status:   d.Status,
+			Attempts: d.Attempts,
+		}
+		if d.SentAt != nil {
+			sentAt := d.SentAt.In(location)
+			delivery.SentAt = &sentAt
 		}
+		res = append(res, delivery)
 	}
+	return res
 }
 
 func MapPlantToPlantResponse(plant *plant.Plant) *PlantResponse {
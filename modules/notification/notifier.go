@@ -0,0 +1,116 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/OctavianoRyan25/be-agriculture/modules/device"
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+)
+
+// Channels a notification is delivered over.
+const (
+	ChannelPush    = "push"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Message is a notification from the inbox as a channel delivers it.
+type Message struct {
+	NotificationID int
+	User           user.User
+	PlantID        int
+	Category       string
+	Title          string
+	Body           string
+	// Actions the user can take on the message, see ReminderActions.
+	Actions []string
+}
+
+// Notifier delivers messages over one channel. Notify returns
+// ErrNoRecipient when the user cannot be reached over the channel, and an
+// error wrapped with Permanent when sending the message again cannot help.
+// Other errors are retried.
+type Notifier interface {
+	Channel() string
+	Notify(ctx context.Context, msg Message) error
+}
+
+// ErrNoRecipient means the user has no address on a channel, like a user
+// without registered devices.
+var ErrNoRecipient = errors.New("no recipient on this channel")
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error of a notifier that retrying cannot fix, like a
+// rejected request.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// NotifiersFromEnv creates the notifiers of the channels listed in
+// NOTIFICATION_CHANNELS, "push" when it is not set. A channel that is not
+// configured is left out with a log line instead of stopping the server.
+func NotifiersFromEnv(devices device.UseCase) []Notifier {
+	channels := os.Getenv("NOTIFICATION_CHANNELS")
+	if channels == "" {
+		channels = ChannelPush
+	}
+
+	var notifiers []Notifier
+	for _, channel := range strings.Split(channels, ",") {
+		switch channel = strings.TrimSpace(channel); channel {
+		case ChannelPush:
+			// Production
+			credential := os.Getenv("FIREBASE_CREDENTIAL")
+			//Development
+			// credential, _ := os.ReadFile("agriculture-af713-c7c8068614c1.json")
+			n, err := NewFCMNotifier(context.Background(), []byte(credential), devices)
+			if err != nil {
+				log.Printf("Push notifications disabled: %v", err)
+				continue
+			}
+			notifiers = append(notifiers, n)
+		case ChannelEmail:
+			host := os.Getenv("SMTP_HOST")
+			if host == "" {
+				log.Printf("Email notifications disabled: SMTP_HOST is not set")
+				continue
+			}
+			port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+			if err != nil {
+				port = 587
+			}
+			notifiers = append(notifiers, NewEmailNotifier(host, port, os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASS"), os.Getenv("EMAIL_FROM")))
+		case ChannelWebhook:
+			url := os.Getenv("NOTIFICATION_WEBHOOK_URL")
+			if url == "" {
+				log.Printf("Webhook notifications disabled: NOTIFICATION_WEBHOOK_URL is not set")
+				continue
+			}
+			notifiers = append(notifiers, NewWebhookNotifier(url, os.Getenv("NOTIFICATION_WEBHOOK_SECRET")))
+		case "":
+		default:
+			log.Printf("Unknown notification channel %q", channel)
+		}
+	}
+	return notifiers
+}
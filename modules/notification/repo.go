@@ -1,15 +1,58 @@
 package notification
 
 import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+	wateringhistory "github.com/OctavianoRyan25/be-agriculture/modules/watering_history"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repository interface {
 	StoreNotification(*Notification) (*Notification, error)
-	ReadNotification(int) (*Notification, error)
-	GetAllNotifications(uint) ([]Notification, error)
+	GetNotification(userID uint, id int) (*Notification, error)
+	GetNotifications(*NotificationFilter) ([]Notification, error)
+	CountUnreadNotifications(userID uint) (int64, error)
+	MarkNotificationsRead(userID uint, ids []int) (int64, error)
+	DeleteNotification(userID uint, id int) (bool, error)
+	RestoreNotification(userID uint, id int, deletedAfter time.Time) (bool, error)
 	DeleteAllNotifications(uint) error
+	PurgeDeletedNotifications(before time.Time) error
 	CreateCustomizeWateringReminder(*CustomizeWateringReminder) (*CustomizeWateringReminder, error)
+	GetCustomizeWateringReminders(uint) ([]CustomizeWateringReminder, error)
+	GetCustomizeWateringReminder(int) (*CustomizeWateringReminder, error)
+	UpdateCustomizeWateringReminder(*CustomizeWateringReminder) error
+	DeleteCustomizeWateringReminder(int) error
+	GetDueCustomizeWateringReminders(time.Time) ([]CustomizeWateringReminder, error)
+	AdvanceCustomizeWateringReminder(id int, from time.Time, next *time.Time) (bool, error)
+	PlantExists(int) (bool, error)
+	GetUser(uint) (*user.User, error)
+	GetPlantSchedules() ([]plant.Plant, error)
+	GetPlantOwners() ([]PlantOwner, error)
+	UserHasPlant(userID, plantID int) (bool, error)
+	IsOutdoorPlant(userID, plantID int) (bool, error)
+	GetReminderPlan(kind string) (*ReminderPlan, error)
+	SaveReminderPlan(*ReminderPlan) error
+	CreateReminderJobs([]ReminderJob) error
+	ClaimReminderJobs(now time.Time, limit int, lockedUntil time.Time) ([]ReminderJob, error)
+	UpdateReminderJob(*ReminderJob) error
+	DeletePendingReminderJobs(kind string, sourceID int) error
+	CreateDeliveries([]NotificationDelivery) error
+	ClaimDeliveries(now time.Time, limit int, lockedUntil time.Time, notificationID int) ([]NotificationDelivery, error)
+	UpdateDelivery(*NotificationDelivery) error
+	DeadLetterDelivery(*NotificationDelivery, *DeadLetter) error
+	GetNotificationPreferences(userID uint) ([]NotificationPreference, error)
+	GetQuietHours(userID uint) ([]QuietHours, error)
+	SaveNotificationSettings(userID uint, settings *NotificationSettings) error
+	UpdateNotificationAction(*Notification) error
+	CreateWateringHistory(*wateringhistory.WateringHistory) error
+	GetReminderSkip(userID, plantID int) (*ReminderSkip, error)
+	SaveReminderSkip(*ReminderSkip) error
+	CreateWateringSkip(*wateringhistory.WateringSkip) error
 }
 
 type notificationRepository struct {
@@ -22,23 +65,27 @@ func NewRepository(db *gorm.DB) *notificationRepository {
 	}
 }
 
+// StoreNotification adds a notification to the inbox. A notification of a
+// reminder job that is already in the inbox is not added again.
 func (r *notificationRepository) StoreNotification(notification *Notification) (*Notification, error) {
-	err := r.db.Create(notification).Error
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(notification).Error
 	if err != nil {
 		return nil, err
 	}
+	if notification.Id == 0 && notification.JobID != nil {
+		err = r.db.Unscoped().Where("job_id = ?", *notification.JobID).First(notification).Error
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return notification, nil
 }
 
-func (r *notificationRepository) ReadNotification(id int) (*Notification, error) {
+// GetNotification returns a notification of the inbox of a user.
+func (r *notificationRepository) GetNotification(userID uint, id int) (*Notification, error) {
 	var notification Notification
-	err := r.db.Where("id = ?", id).First(&notification).Error
-	if err != nil {
-		return nil, err
-	}
-	notification.IsRead = true
-	err = r.db.Save(&notification).Error
+	err := r.db.Preload("Deliveries").Where("id = ? AND user_id = ?", id, userID).First(&notification).Error
 	if err != nil {
 		return nil, err
 	}
@@ -46,9 +93,21 @@ func (r *notificationRepository) ReadNotification(id int) (*Notification, error)
 	return &notification, nil
 }
 
-func (r *notificationRepository) GetAllNotifications(userID uint) ([]Notification, error) {
+// GetNotifications returns the notifications matching filter, newest first.
+func (r *notificationRepository) GetNotifications(filter *NotificationFilter) ([]Notification, error) {
+	query := r.db.Preload("Deliveries").Where("user_id = ?", filter.UserID)
+	if filter.IsRead != nil {
+		query = query.Where("is_read = ?", *filter.IsRead)
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.Before != 0 {
+		query = query.Where("id < ?", filter.Before)
+	}
+
 	var notifications []Notification
-	err := r.db.Where("user_id = ?", userID).Find(&notifications).Error
+	err := query.Order("id DESC").Limit(filter.Limit).Find(&notifications).Error
 	if err != nil {
 		return nil, err
 	}
@@ -56,13 +115,55 @@ func (r *notificationRepository) GetAllNotifications(userID uint) ([]Notificatio
 	return notifications, nil
 }
 
-func (r *notificationRepository) DeleteAllNotifications(userID uint) error {
-	err := r.db.Where("user_id = ?", userID).Delete(&Notification{}).Error
-	if err != nil {
-		return err
+func (r *notificationRepository) CountUnreadNotifications(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&count).Error
+	return count, err
+}
+
+// MarkNotificationsRead marks the given unread notifications of a user as
+// read, or all of them when ids is nil, and returns how many it marked.
+func (r *notificationRepository) MarkNotificationsRead(userID uint, ids []int) (int64, error) {
+	query := r.db.Model(&Notification{}).Where("user_id = ? AND is_read = ?", userID, false)
+	if ids != nil {
+		query = query.Where("id IN ?", ids)
 	}
+	res := query.Updates(map[string]interface{}{"is_read": true, "updated_at": time.Now()})
+	return res.RowsAffected, res.Error
+}
+
+// DeleteNotification moves a notification of a user out of the inbox. It
+// reports false when the user has no such notification.
+func (r *notificationRepository) DeleteNotification(userID uint, id int) (bool, error) {
+	res := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&Notification{})
+	return res.RowsAffected == 1, res.Error
+}
+
+// RestoreNotification puts back a notification of a user that was deleted
+// after deletedAfter. It reports false when there is no such notification.
+func (r *notificationRepository) RestoreNotification(userID uint, id int, deletedAfter time.Time) (bool, error) {
+	res := r.db.Unscoped().Model(&Notification{}).
+		Where("id = ? AND user_id = ? AND deleted_at > ?", id, userID, deletedAfter).
+		Update("deleted_at", nil)
+	return res.RowsAffected == 1, res.Error
+}
 
-	return nil
+// DeleteAllNotifications empties the inbox of a user.
+func (r *notificationRepository) DeleteAllNotifications(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&Notification{}).Error
+}
+
+// PurgeDeletedNotifications removes the notifications deleted before before
+// for good, together with their deliveries.
+func (r *notificationRepository) PurgeDeletedNotifications(before time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		deleted := tx.Unscoped().Model(&Notification{}).Select("id").Where("deleted_at < ?", before)
+		err := tx.Where("notification_id IN (?)", deleted).Delete(&NotificationDelivery{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("deleted_at < ?", before).Delete(&Notification{}).Error
+	})
 }
 
 func (r *notificationRepository) CreateCustomizeWateringReminder(reminder *CustomizeWateringReminder) (*CustomizeWateringReminder, error) {
@@ -77,3 +178,356 @@ func (r *notificationRepository) CreateCustomizeWateringReminder(reminder *Custo
 
 	return reminder, nil
 }
+
+func (r *notificationRepository) GetCustomizeWateringReminders(userID uint) ([]CustomizeWateringReminder, error) {
+	var reminders []CustomizeWateringReminder
+	err := r.db.Preload("Plant.PlantImages").Where("user_id = ?", userID).Order("id").Find(&reminders).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return reminders, nil
+}
+
+func (r *notificationRepository) GetCustomizeWateringReminder(id int) (*CustomizeWateringReminder, error) {
+	var reminder CustomizeWateringReminder
+	err := r.db.Preload("Plant.PlantImages").Where("id = ?", id).First(&reminder).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &reminder, nil
+}
+
+func (r *notificationRepository) UpdateCustomizeWateringReminder(reminder *CustomizeWateringReminder) error {
+	return r.db.Omit(clause.Associations).Save(reminder).Error
+}
+
+func (r *notificationRepository) DeleteCustomizeWateringReminder(id int) error {
+	return r.db.Delete(&CustomizeWateringReminder{}, id).Error
+}
+
+// GetDueCustomizeWateringReminders returns the active reminders with an
+// occurrence that is not planned yet, up to until.
+func (r *notificationRepository) GetDueCustomizeWateringReminders(until time.Time) ([]CustomizeWateringReminder, error) {
+	var reminders []CustomizeWateringReminder
+	err := r.db.
+		Where("paused_at IS NULL AND next_run_at <= ?", until).
+		Find(&reminders).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return reminders, nil
+}
+
+// AdvanceCustomizeWateringReminder moves the next run of a reminder from
+// from to next. It reports false when another scheduler already moved it, so
+// each occurrence is sent once.
+func (r *notificationRepository) AdvanceCustomizeWateringReminder(id int, from time.Time, next *time.Time) (bool, error) {
+	res := r.db.Model(&CustomizeWateringReminder{}).
+		Where("id = ? AND next_run_at = ?", id, from).
+		Updates(map[string]interface{}{"next_run_at": next, "updated_at": time.Now()})
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+func (r *notificationRepository) PlantExists(id int) (bool, error) {
+	var count int64
+	err := r.db.Model(&plant.Plant{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *notificationRepository) GetUser(id uint) (*user.User, error) {
+	var u user.User
+	err := r.db.Where("id = ?", id).First(&u).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// GetPlantSchedules returns the plants that have a watering schedule.
+func (r *notificationRepository) GetPlantSchedules() ([]plant.Plant, error) {
+	var plants []plant.Plant
+	err := r.db.Preload("WateringSchedule.Times").
+		Where("id IN (?)", r.db.Model(&plant.PlantReminder{}).Select("plant_id")).
+		Find(&plants).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return plants, nil
+}
+
+func (r *notificationRepository) GetPlantOwners() ([]PlantOwner, error) {
+	var owners []PlantOwner
+	err := r.db.Model(&plant.UserPlant{}).
+		Select("DISTINCT user_plants.user_id, user_plants.plant_id, users.timezone").
+		Joins("JOIN users ON users.id = user_plants.user_id").
+		Scan(&owners).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return owners, nil
+}
+
+func (r *notificationRepository) UserHasPlant(userID, plantID int) (bool, error) {
+	var count int64
+	err := r.db.Model(&plant.UserPlant{}).Where("user_id = ? AND plant_id = ?", userID, plantID).Count(&count).Error
+	return count > 0, err
+}
+
+// IsOutdoorPlant reports whether every plant of the user with plantID grows
+// outdoors. A plant the user keeps both indoors and outdoors needs watering
+// whatever the rain.
+func (r *notificationRepository) IsOutdoorPlant(userID, plantID int) (bool, error) {
+	var outdoor []bool
+	err := r.db.Model(&plant.UserPlant{}).Where("user_id = ? AND plant_id = ?", userID, plantID).Pluck("outdoor", &outdoor).Error
+	if err != nil || len(outdoor) == 0 {
+		return false, err
+	}
+	return !slices.Contains(outdoor, false), nil
+}
+
+func (r *notificationRepository) GetReminderPlan(kind string) (*ReminderPlan, error) {
+	var plan ReminderPlan
+	err := r.db.Where("kind = ?", kind).First(&plan).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+// SaveReminderPlan moves the plan forward. Instances planning at the same
+// time cannot move it back.
+func (r *notificationRepository) SaveReminderPlan(plan *ReminderPlan) error {
+	return r.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"planned_until": gorm.Expr("GREATEST(planned_until, VALUES(planned_until))"),
+		}),
+	}).Create(plan).Error
+}
+
+// CreateReminderJobs stores planned jobs, leaving out the occurrences that
+// are already planned.
+func (r *notificationRepository) CreateReminderJobs(jobs []ReminderJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	return r.db.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(jobs, 500).Error
+}
+
+// ClaimReminderJobs marks up to limit due jobs as processing until
+// lockedUntil and returns them. Jobs held back for quiet hours are due at
+// NotBefore, jobs whose worker stopped before finishing are due again once
+// their lock expires. The rows are locked while they are
+// claimed, so two workers never get the same job.
+func (r *notificationRepository) ClaimReminderJobs(now time.Time, limit int, lockedUntil time.Time) ([]ReminderJob, error) {
+	var ids []int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&ReminderJob{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND due_at <= ? AND (not_before IS NULL OR not_before <= ?)) OR (status = ? AND locked_until < ?)", JobPending, now, now, JobProcessing, now).
+			Order("due_at").
+			Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		return tx.Model(&ReminderJob{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":       JobProcessing,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"locked_until": lockedUntil,
+			"updated_at":   now,
+		}).Error
+	})
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var jobs []ReminderJob
+	err = r.db.Preload("User").Preload("Plant.WateringSchedule.Times").
+		Where("id IN ?", ids).
+		Order("due_at").
+		Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (r *notificationRepository) UpdateReminderJob(job *ReminderJob) error {
+	return r.db.Omit(clause.Associations).Save(job).Error
+}
+
+// DeletePendingReminderJobs removes the planned occurrences of a reminder
+// that changed.
+func (r *notificationRepository) DeletePendingReminderJobs(kind string, sourceID int) error {
+	return r.db.Where("kind = ? AND source_id = ? AND status = ?", kind, sourceID, JobPending).Delete(&ReminderJob{}).Error
+}
+
+// CreateDeliveries stores the deliveries of a notification, leaving out the
+// channels it already has a delivery for.
+func (r *notificationRepository) CreateDeliveries(deliveries []NotificationDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+// ClaimDeliveries moves up to limit due deliveries to lockedUntil, counts an
+// attempt and returns them with their notification and user. A delivery
+// whose worker stopped is due again at lockedUntil. With a notificationID,
+// only the deliveries of that notification are claimed.
+func (r *notificationRepository) ClaimDeliveries(now time.Time, limit int, lockedUntil time.Time, notificationID int) ([]NotificationDelivery, error) {
+	var ids []int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&NotificationDelivery{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", DeliveryPending, now)
+		if notificationID != 0 {
+			query = query.Where("notification_id = ?", notificationID)
+		}
+		err := query.Order("next_attempt_at").Limit(limit).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		return tx.Model(&NotificationDelivery{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": lockedUntil,
+			"updated_at":      now,
+		}).Error
+	})
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var deliveries []NotificationDelivery
+	err = r.db.Preload("Notification").Preload("User").
+		Where("id IN ?", ids).
+		Order("next_attempt_at").
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *notificationRepository) UpdateDelivery(delivery *NotificationDelivery) error {
+	return r.db.Omit(clause.Associations).Save(delivery).Error
+}
+
+// DeadLetterDelivery gives up on a delivery and keeps it as a dead letter.
+func (r *notificationRepository) DeadLetterDelivery(delivery *NotificationDelivery, letter *DeadLetter) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Omit(clause.Associations).Save(delivery).Error
+		if err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(letter).Error
+	})
+}
+
+func (r *notificationRepository) GetNotificationPreferences(userID uint) ([]NotificationPreference, error) {
+	var preferences []NotificationPreference
+	err := r.db.Where("user_id = ?", userID).Find(&preferences).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+func (r *notificationRepository) GetQuietHours(userID uint) ([]QuietHours, error) {
+	var quietHours []QuietHours
+	err := r.db.Where("user_id = ?", userID).Order("start").Find(&quietHours).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return quietHours, nil
+}
+
+// SaveNotificationSettings stores the given preferences and replaces the
+// quiet hours of a user.
+func (r *notificationRepository) SaveNotificationSettings(userID uint, settings *NotificationSettings) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(settings.Preferences) > 0 {
+			err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&settings.Preferences).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		err := tx.Where("user_id = ?", userID).Delete(&QuietHours{}).Error
+		if err != nil {
+			return err
+		}
+		if len(settings.QuietHours) == 0 {
+			return nil
+		}
+		return tx.Create(&settings.QuietHours).Error
+	})
+}
+
+// UpdateNotificationAction records the action a user took on a
+// notification, which also marks it as read.
+func (r *notificationRepository) UpdateNotificationAction(notification *Notification) error {
+	return r.db.Model(&Notification{}).
+		Where("id = ? AND user_id = ?", notification.Id, notification.UserId).
+		Updates(map[string]interface{}{
+			"action":        notification.Action,
+			"acted_at":      notification.ActedAt,
+			"snoozed_until": notification.SnoozedUntil,
+			"is_read":       true,
+			"updated_at":    time.Now(),
+		}).Error
+}
+
+// CreateWateringHistory records a watering from a reminder, for the first
+// plant the user added of the kind. A reminder that was already marked as
+// watered is not recorded again.
+func (r *notificationRepository) CreateWateringHistory(history *wateringhistory.WateringHistory) error {
+	if history.UserPlantID == nil {
+		var userPlant plant.UserPlant
+		err := r.db.Where("user_id = ? AND plant_id = ?", history.UserID, history.PlantID).Order("id").First(&userPlant).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			history.UserPlantID = &userPlant.ID
+		}
+	}
+	return r.db.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(history).Error
+}
+
+func (r *notificationRepository) GetReminderSkip(userID, plantID int) (*ReminderSkip, error) {
+	var skip ReminderSkip
+	err := r.db.Where("user_id = ? AND plant_id = ?", userID, plantID).First(&skip).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &skip, nil
+}
+
+func (r *notificationRepository) SaveReminderSkip(skip *ReminderSkip) error {
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(skip).Error
+}
+
+// CreateWateringSkip records watering times the user does not have to keep,
+// so the watering compliance does not count them as missed.
+func (r *notificationRepository) CreateWateringSkip(skip *wateringhistory.WateringSkip) error {
+	return r.db.Create(skip).Error
+}
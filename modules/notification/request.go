@@ -1,8 +1,44 @@
 package notification
 
 type CustomizeWateringReminderRequest struct {
-	PlantID   int    `json:"plant_id" validate:"required"`
-	Time      string `json:"time" validate:"required"`
-	Recurring bool   `json:"recurring" validate:"required"`
-	Type      string `json:"type" validate:"required"`
+	PlantID int `json:"plant_id" validate:"required"`
+	// Time is the time of day of every occurrence, in the time zone of the
+	// user.
+	Time string `json:"time" validate:"required,datetime=15:04"`
+	// RRule is an iCalendar recurrence rule like "FREQ=WEEKLY;BYDAY=MO,TH".
+	RRule     string `json:"rrule" validate:"required_without=Type,max=255"`
+	StartDate string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	// Type and Recurring are the older way to repeat a reminder. They are
+	// only used when RRule is empty.
+	Type      string `json:"type"`
+	Recurring bool   `json:"recurring"`
+}
+
+// NotificationQuery filters and paginates the inbox.
+type NotificationQuery struct {
+	Status string `query:"status" validate:"omitempty,oneof=read unread"`
+	Type   string `query:"type" validate:"omitempty,oneof=watering custom_reminder weather_alert article"`
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit"`
+}
+
+// NotificationSettingsRequest sets the preferences of the listed categories
+// and replaces the quiet hours. Categories that are not listed keep their
+// preference.
+type NotificationSettingsRequest struct {
+	Categories []CategoryPreferenceRequest `json:"categories" validate:"dive"`
+	QuietHours []QuietHoursRequest         `json:"quiet_hours" validate:"max=5,dive"`
+}
+
+type CategoryPreferenceRequest struct {
+	Category string   `json:"category" validate:"required,oneof=watering custom_reminder weather_alert article"`
+	Enabled  bool     `json:"enabled"`
+	Channels []string `json:"channels" validate:"dive,oneof=push email webhook"`
+}
+
+// QuietHoursRequest is a daily window in the time zone of the user.
+type QuietHoursRequest struct {
+	Start string `json:"start" validate:"required,datetime=15:04"`
+	End   string `json:"end" validate:"required,datetime=15:04"`
 }
@@ -2,18 +2,43 @@ package notification
 
 import (
 	"time"
-
-	"github.com/OctavianoRyan25/be-agriculture/modules/user"
 )
 
 type NotificationResponse struct {
-	Id        int       `json:"id"`
-	Title     string    `json:"title"`
-	Body      string    `json:"body"`
-	UserId    int       `json:"user_id"`
-	PlantId   int       `json:"plant_id"`
-	IsRead    bool      `json:"is_read"`
-	CreatedAt time.Time `json:"created_at"`
+	Id      int    `json:"id"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	UserId  int    `json:"user_id"`
+	PlantId int    `json:"plant_id"`
+	Type    string `json:"type"`
+	IsRead  bool   `json:"is_read"`
+	// Actions are the actions the user can take, Action the last one taken.
+	Actions      []string           `json:"actions"`
+	Action       string             `json:"action,omitempty"`
+	SnoozedUntil *time.Time         `json:"snoozed_until,omitempty"`
+	Deliveries   []DeliveryResponse `json:"deliveries"`
+	CreatedAt    time.Time          `json:"created_at"`
+}
+
+type NotificationPageResponse struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	NextCursor    string                 `json:"next_cursor,omitempty"`
+	Limit         int                    `json:"limit"`
+}
+
+type UnreadCountResponse struct {
+	UnreadCount int64 `json:"unread_count"`
+}
+
+type MarkAllReadResponse struct {
+	Marked int64 `json:"marked"`
+}
+
+type DeliveryResponse struct {
+	Channel  string     `json:"channel"`
+	Status   string     `json:"status"`
+	Attempts int        `json:"attempts"`
+	SentAt   *time.Time `json:"sent_at"`
 }
 
 type CustomizeWateringReminderResponse struct {
@@ -21,10 +46,13 @@ type CustomizeWateringReminderResponse struct {
 	PlantID   int           `json:"plant_id"`
 	Plant     PlantResponse `json:"plant"`
 	UserID    int           `json:"user_id"`
-	User      user.User     `json:"user"`
+	RRule     string        `json:"rrule"`
 	Time      string        `json:"time"`
-	Recurring bool          `json:"recurring"`
-	Type      string        `json:"type"`
+	StartDate string        `json:"start_date"`
+	Timezone  string        `json:"timezone"`
+	EndDate   string        `json:"end_date,omitempty"`
+	NextRunAt *time.Time    `json:"next_run_at"`
+	Paused    bool          `json:"paused"`
 	CreatedAt time.Time     `json:"created_at"`
 }
 
@@ -45,3 +73,21 @@ type PlantImageResponse struct {
 	ID       int    `json:"id"`
 	FileName string `json:"file_name"`
 }
+
+type NotificationSettingsResponse struct {
+	Timezone          string                       `json:"timezone"`
+	AvailableChannels []string                     `json:"available_channels"`
+	Categories        []CategoryPreferenceResponse `json:"categories"`
+	QuietHours        []QuietHoursResponse         `json:"quiet_hours"`
+}
+
+type CategoryPreferenceResponse struct {
+	Category string   `json:"category"`
+	Enabled  bool     `json:"enabled"`
+	Channels []string `json:"channels"`
+}
+
+type QuietHoursResponse struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
@@ -1,48 +1,1131 @@
 package notification
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+	wateringhistory "github.com/OctavianoRyan25/be-agriculture/modules/watering_history"
+	"github.com/OctavianoRyan25/be-agriculture/utils/rrule"
+	"github.com/OctavianoRyan25/be-agriculture/utils/timezone"
+	"gorm.io/gorm"
+)
 
 type UseCase interface {
 	StoreNotification(*Notification) (*Notification, error)
-	ReadNotification(int) (*Notification, error)
-	GetAllNotifications(uint) ([]Notification, error)
+	GetNotification(userID uint, id int) (*Notification, int, error)
+	GetNotifications(*NotificationFilter) ([]Notification, int, int, error)
+	CountUnreadNotifications(userID uint) (int64, int, error)
+	MarkNotificationRead(userID uint, id int) (*Notification, int, error)
+	MarkAllNotificationsRead(userID uint) (int64, int, error)
+	DeleteNotification(userID uint, id int) (int, error)
+	RestoreNotification(userID uint, id int) (*Notification, int, error)
+	ActOnNotification(userID uint, id int, action string) (*Notification, int, error)
 	DeleteAllNotifications(uint) error
-	CreateCustomizeWateringReminder(*CustomizeWateringReminder) (*CustomizeWateringReminder, error)
+	PurgeDeletedNotifications() error
+	CreateCustomizeWateringReminder(*CustomizeWateringReminder) (*CustomizeWateringReminder, int, error)
+	GetCustomizeWateringReminders(uint) ([]CustomizeWateringReminder, int, error)
+	GetCustomizeWateringReminder(userID uint, id int) (*CustomizeWateringReminder, int, error)
+	UpdateCustomizeWateringReminder(userID uint, id int, changes *CustomizeWateringReminder) (*CustomizeWateringReminder, int, error)
+	DeleteCustomizeWateringReminder(userID uint, id int) (int, error)
+	PauseCustomizeWateringReminder(userID uint, id int) (*CustomizeWateringReminder, int, error)
+	ResumeCustomizeWateringReminder(userID uint, id int) (*CustomizeWateringReminder, int, error)
+	PlanReminderJobs() error
+	ClaimReminderJobs() ([]ReminderJob, error)
+	FinishReminderJob(*ReminderJob, error) error
+	DeliverReminderJob(*ReminderJob) error
+	RetryDeliveries() (int, error)
+	TimezoneChanged(userID uint, timezone string) error
+	GetUserLocation(uint) (*time.Location, error)
+	GetNotificationSettings(userID uint) (*NotificationSettings, int, error)
+	UpdateNotificationSettings(userID uint, settings *NotificationSettings) (*NotificationSettings, int, error)
+	GetChannels() []string
+	EscalateOverdueWatering(userID, plantID int, overdueSince time.Time) error
+}
+
+const (
+	// planAhead is how far ahead reminder occurrences are stored as jobs.
+	planAhead = 15 * time.Minute
+	// maxLateness is how late a reminder may still be sent after downtime.
+	// Older occurrences are skipped instead of arriving all at once.
+	maxLateness = 6 * time.Hour
+	// jobLease is how long a worker may take to send a job before another
+	// worker takes it over.
+	jobLease = 5 * time.Minute
+	jobBatch = 100
+
+	// maxDeliveryAttempts is how often a notification is sent over a
+	// channel before it becomes a dead letter.
+	maxDeliveryAttempts = 5
+	// deliveryBackoff is the wait after the first failed attempt. It
+	// doubles with every further attempt, up to maxDeliveryBackoff.
+	deliveryBackoff    = 30 * time.Second
+	maxDeliveryBackoff = time.Hour
+	// deliveryTimeout bounds one attempt, deliveryLease is how long a
+	// worker may take for it before another worker tries again.
+	deliveryTimeout = 30 * time.Second
+	deliveryLease   = 2 * time.Minute
+	deliveryBatch   = 100
+
+	defaultPageSize = 20
+	maxPageSize     = 100
+	// restoreWindow is how long a deleted notification can be restored
+	// before it is purged.
+	restoreWindow = 7 * 24 * time.Hour
+)
+
+// NotificationFilter selects a page of the inbox of a user, newest first.
+// Zero fields match anything. Before is the cursor: the id of the last
+// notification of the previous page.
+type NotificationFilter struct {
+	UserID   uint
+	IsRead   *bool
+	Category string
+	Before   int
+	Limit    int
 }
 
 type notificationUseCase struct {
 	notificationRepo Repository
+	notifiers        []Notifier
+	forecaster       Forecaster
+	now              func() time.Time
 }
 
-func NewUseCase(notificationRepo Repository) *notificationUseCase {
+func NewUseCase(notificationRepo Repository, notifiers []Notifier) *notificationUseCase {
 	return &notificationUseCase{
 		notificationRepo: notificationRepo,
+		notifiers:        notifiers,
+		now:              time.Now,
 	}
 }
 
 func (u *notificationUseCase) StoreNotification(notification *Notification) (*Notification, error) {
 	notification.IsRead = false
-	location, _ := time.LoadLocation("Asia/Jakarta")
-	notification.CreatedAt = time.Now().In(location)
-	notification.UpdatedAt = time.Now().In(location)
+	notification.CreatedAt = u.now()
+	notification.UpdatedAt = u.now()
 	return u.notificationRepo.StoreNotification(notification)
 }
 
-func (u *notificationUseCase) ReadNotification(id int) (*Notification, error) {
-	return u.notificationRepo.ReadNotification(id)
+func (u *notificationUseCase) GetNotification(userID uint, id int) (*Notification, int, error) {
+	notification, err := u.notificationRepo.GetNotification(userID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, constants.ErrCodeNotificationNotFound, errors.New(constants.ErrNotificationNotFound)
+	}
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return notification, constants.CodeSuccess, nil
+}
+
+// GetNotifications returns a page of the inbox and the cursor of the next
+// page, which is 0 on the last page.
+func (u *notificationUseCase) GetNotifications(filter *NotificationFilter) ([]Notification, int, int, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPageSize
+	}
+	if filter.Limit > maxPageSize {
+		filter.Limit = maxPageSize
+	}
+
+	page := *filter
+	page.Limit++
+	notifications, err := u.notificationRepo.GetNotifications(&page)
+	if err != nil {
+		return nil, 0, constants.ErrCodeBadRequest, err
+	}
+
+	next := 0
+	if len(notifications) > filter.Limit {
+		notifications = notifications[:filter.Limit]
+		next = notifications[filter.Limit-1].Id
+	}
+	return notifications, next, constants.CodeSuccess, nil
+}
+
+func (u *notificationUseCase) CountUnreadNotifications(userID uint) (int64, int, error) {
+	count, err := u.notificationRepo.CountUnreadNotifications(userID)
+	if err != nil {
+		return 0, constants.ErrCodeBadRequest, err
+	}
+	return count, constants.CodeSuccess, nil
+}
+
+// MarkNotificationRead marks a notification as read once it is known to
+// belong to the user.
+func (u *notificationUseCase) MarkNotificationRead(userID uint, id int) (*Notification, int, error) {
+	notification, code, err := u.GetNotification(userID, id)
+	if err != nil {
+		return nil, code, err
+	}
+	if notification.IsRead {
+		return notification, constants.CodeSuccess, nil
+	}
+
+	_, err = u.notificationRepo.MarkNotificationsRead(userID, []int{id})
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	notification.IsRead = true
+	return notification, constants.CodeSuccess, nil
 }
 
-func (u *notificationUseCase) GetAllNotifications(userID uint) ([]Notification, error) {
-	return u.notificationRepo.GetAllNotifications(userID)
+// MarkAllNotificationsRead marks the whole inbox as read and returns how
+// many notifications were unread.
+func (u *notificationUseCase) MarkAllNotificationsRead(userID uint) (int64, int, error) {
+	count, err := u.notificationRepo.MarkNotificationsRead(userID, nil)
+	if err != nil {
+		return 0, constants.ErrCodeBadRequest, err
+	}
+	return count, constants.CodeSuccess, nil
+}
+
+// DeleteNotification removes a notification from the inbox. It can be
+// restored for restoreWindow.
+func (u *notificationUseCase) DeleteNotification(userID uint, id int) (int, error) {
+	deleted, err := u.notificationRepo.DeleteNotification(userID, id)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	if !deleted {
+		return constants.ErrCodeNotificationNotFound, errors.New(constants.ErrNotificationNotFound)
+	}
+	return constants.CodeSuccess, nil
+}
+
+// RestoreNotification undoes the deletion of a notification.
+func (u *notificationUseCase) RestoreNotification(userID uint, id int) (*Notification, int, error) {
+	restored, err := u.notificationRepo.RestoreNotification(userID, id, u.now().Add(-restoreWindow))
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if !restored {
+		return nil, constants.ErrCodeNotificationNotFound, errors.New(constants.ErrNotificationNotFound)
+	}
+	return u.GetNotification(userID, id)
 }
 
 func (u *notificationUseCase) DeleteAllNotifications(userID uint) error {
 	return u.notificationRepo.DeleteAllNotifications(userID)
 }
 
-func (u *notificationUseCase) CreateCustomizeWateringReminder(reminder *CustomizeWateringReminder) (*CustomizeWateringReminder, error) {
-	location, _ := time.LoadLocation("Asia/Jakarta")
-	reminder.CreatedAt = time.Now().In(location)
-	reminder.UpdatedAt = time.Now().In(location)
-	return u.notificationRepo.CreateCustomizeWateringReminder(reminder)
+// PurgeDeletedNotifications removes the notifications that can no longer be
+// restored.
+func (u *notificationUseCase) PurgeDeletedNotifications() error {
+	return u.notificationRepo.PurgeDeletedNotifications(u.now().Add(-restoreWindow))
+}
+
+func (u *notificationUseCase) CreateCustomizeWateringReminder(reminder *CustomizeWateringReminder) (*CustomizeWateringReminder, int, error) {
+	code, err := u.checkReminder(reminder)
+	if err != nil {
+		return nil, code, err
+	}
+
+	reminder.CreatedAt = u.now()
+	reminder.UpdatedAt = u.now()
+	reminder.NextRunAt = u.nextRun(reminder, u.now())
+
+	reminder, err = u.notificationRepo.CreateCustomizeWateringReminder(reminder)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return reminder, constants.Created, nil
+}
+
+func (u *notificationUseCase) GetCustomizeWateringReminders(userID uint) ([]CustomizeWateringReminder, int, error) {
+	reminders, err := u.notificationRepo.GetCustomizeWateringReminders(userID)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return reminders, constants.CodeSuccess, nil
+}
+
+func (u *notificationUseCase) GetCustomizeWateringReminder(userID uint, id int) (*CustomizeWateringReminder, int, error) {
+	reminder, err := u.notificationRepo.GetCustomizeWateringReminder(id)
+	if err != nil || reminder.UserId != int(userID) {
+		return nil, constants.ErrCodeReminderNotFound, errors.New(constants.ErrReminderNotFound)
+	}
+	return reminder, constants.CodeSuccess, nil
+}
+
+// UpdateCustomizeWateringReminder replaces the plant and schedule of a
+// reminder. The series continues from now, so occurrences missed while the
+// schedule was different are not sent.
+func (u *notificationUseCase) UpdateCustomizeWateringReminder(userID uint, id int, changes *CustomizeWateringReminder) (*CustomizeWateringReminder, int, error) {
+	reminder, code, err := u.GetCustomizeWateringReminder(userID, id)
+	if err != nil {
+		return nil, code, err
+	}
+
+	code, err = u.checkReminder(changes)
+	if err != nil {
+		return nil, code, err
+	}
+
+	if reminder.PlantId != changes.PlantId {
+		reminder.Plant = changes.Plant
+	}
+	reminder.PlantId = changes.PlantId
+	reminder.RRule = changes.RRule
+	reminder.StartAt = changes.StartAt
+	reminder.EndAt = changes.EndAt
+	reminder.Timezone = changes.Timezone
+	reminder.NextRunAt = u.nextRun(reminder, u.now())
+	reminder.UpdatedAt = u.now()
+
+	err = u.notificationRepo.UpdateCustomizeWateringReminder(reminder)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	err = u.notificationRepo.DeletePendingReminderJobs(JobCustomReminder, reminder.Id)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return u.GetCustomizeWateringReminder(userID, id)
+}
+
+func (u *notificationUseCase) DeleteCustomizeWateringReminder(userID uint, id int) (int, error) {
+	_, code, err := u.GetCustomizeWateringReminder(userID, id)
+	if err != nil {
+		return code, err
+	}
+
+	err = u.notificationRepo.DeleteCustomizeWateringReminder(id)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	err = u.notificationRepo.DeletePendingReminderJobs(JobCustomReminder, id)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	return constants.CodeSuccess, nil
+}
+
+func (u *notificationUseCase) PauseCustomizeWateringReminder(userID uint, id int) (*CustomizeWateringReminder, int, error) {
+	reminder, code, err := u.GetCustomizeWateringReminder(userID, id)
+	if err != nil {
+		return nil, code, err
+	}
+	if reminder.PausedAt != nil {
+		return reminder, constants.CodeSuccess, nil
+	}
+
+	now := u.now()
+	reminder.PausedAt = &now
+	reminder.UpdatedAt = now
+	err = u.notificationRepo.UpdateCustomizeWateringReminder(reminder)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	err = u.notificationRepo.DeletePendingReminderJobs(JobCustomReminder, reminder.Id)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return reminder, constants.CodeSuccess, nil
+}
+
+// ResumeCustomizeWateringReminder continues a paused reminder with its next
+// occurrence from now. Occurrences that fell in the pause are skipped.
+func (u *notificationUseCase) ResumeCustomizeWateringReminder(userID uint, id int) (*CustomizeWateringReminder, int, error) {
+	reminder, code, err := u.GetCustomizeWateringReminder(userID, id)
+	if err != nil {
+		return nil, code, err
+	}
+	if reminder.PausedAt == nil {
+		return reminder, constants.CodeSuccess, nil
+	}
+
+	reminder.NextRunAt = u.nextRun(reminder, u.now())
+	if reminder.NextRunAt == nil {
+		return nil, constants.ErrCodeReminderEnded, errors.New(constants.ErrReminderEnded)
+	}
+	reminder.PausedAt = nil
+	reminder.UpdatedAt = u.now()
+	err = u.notificationRepo.UpdateCustomizeWateringReminder(reminder)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return reminder, constants.CodeSuccess, nil
+}
+
+// PlanReminderJobs stores the reminder occurrences up to planAhead from now
+// as jobs. Occurrences missed while no instance was running are planned too,
+// back to maxLateness ago.
+func (u *notificationUseCase) PlanReminderJobs() error {
+	now := u.now()
+	until := now.Add(planAhead)
+
+	err := u.planCustomReminders(now, until)
+	if err != nil {
+		return err
+	}
+	return u.planPlantSchedules(now, until)
+}
+
+// planCustomReminders plans the occurrences of the customized reminders
+// and moves each reminder's next run past them.
+func (u *notificationUseCase) planCustomReminders(now, until time.Time) error {
+	reminders, err := u.notificationRepo.GetDueCustomizeWateringReminders(until)
+	if err != nil {
+		return err
+	}
+
+	oldest := now.Add(-maxLateness)
+	for i := range reminders {
+		reminder := &reminders[i]
+		for reminder.NextRunAt != nil && !reminder.NextRunAt.After(until) {
+			due := *reminder.NextRunAt
+			after := due
+			if due.Before(oldest) {
+				after = oldest
+			} else {
+				err = u.notificationRepo.CreateReminderJobs([]ReminderJob{{
+					Kind:      JobCustomReminder,
+					SourceID:  reminder.Id,
+					UserID:    reminder.UserId,
+					PlantID:   reminder.PlantId,
+					DueAt:     due,
+					Status:    JobPending,
+					CreatedAt: now,
+					UpdatedAt: now,
+				}})
+				if err != nil {
+					return err
+				}
+			}
+
+			next := u.nextRun(reminder, after)
+			ok, err := u.notificationRepo.AdvanceCustomizeWateringReminder(reminder.Id, due, next)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				// Another instance is planning this reminder.
+				break
+			}
+			reminder.NextRunAt = next
+		}
+	}
+	return nil
+}
+
+// planPlantSchedules plans the watering times of the plant schedules for
+// every user who has the plant, in the user's time zone.
+func (u *notificationUseCase) planPlantSchedules(now, until time.Time) error {
+	from := now
+	plan, err := u.notificationRepo.GetReminderPlan(JobPlantSchedule)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil {
+		from = plan.PlannedUntil
+		if oldest := now.Add(-maxLateness); from.Before(oldest) {
+			from = oldest
+		}
+	}
+	if !from.Before(until) {
+		return nil
+	}
+
+	plants, err := u.notificationRepo.GetPlantSchedules()
+	if err != nil {
+		return err
+	}
+	schedules := make(map[int]plant.PlantReminder, len(plants))
+	for _, p := range plants {
+		schedules[p.ID] = p.WateringSchedule
+	}
+
+	owners, err := u.notificationRepo.GetPlantOwners()
+	if err != nil {
+		return err
+	}
+
+	var jobs []ReminderJob
+	for _, owner := range owners {
+		schedule, ok := schedules[owner.PlantID]
+		if !ok {
+			continue
+		}
+		for _, due := range schedule.Occurrences(from, until, timezone.Location(owner.Timezone)) {
+			jobs = append(jobs, ReminderJob{
+				Kind:      JobPlantSchedule,
+				SourceID:  schedule.ID,
+				UserID:    owner.UserID,
+				PlantID:   owner.PlantID,
+				DueAt:     due,
+				Status:    JobPending,
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+		}
+	}
+
+	err = u.notificationRepo.CreateReminderJobs(jobs)
+	if err != nil {
+		return err
+	}
+	return u.notificationRepo.SaveReminderPlan(&ReminderPlan{Kind: JobPlantSchedule, PlannedUntil: until})
+}
+
+// ClaimReminderJobs returns the due jobs this instance should send. Jobs
+// that are too late, whose reminder was removed since they were planned, or
+// whose category the user turned off are skipped. Jobs due in the quiet
+// hours of the user are held back until the quiet hours end, and watering
+// reminders of outdoor plants until the rain falling now stops.
+func (u *notificationUseCase) ClaimReminderJobs() ([]ReminderJob, error) {
+	now := u.now()
+	jobs, err := u.notificationRepo.ClaimReminderJobs(now, jobBatch, now.Add(jobLease))
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]ReminderJob, 0, len(jobs))
+	settings := map[int]*NotificationSettings{}
+	for i := range jobs {
+		job := &jobs[i]
+		sendAt := job.DueAt
+		if job.NotBefore != nil && job.NotBefore.After(sendAt) {
+			sendAt = *job.NotBefore
+		}
+
+		reason := ""
+		if sendAt.Before(now.Add(-maxLateness)) {
+			reason = "missed by more than " + maxLateness.String()
+		} else {
+			applies, err := u.jobApplies(job)
+			if err != nil {
+				return claimed, err
+			}
+			if !applies {
+				reason = "reminder no longer applies"
+			}
+		}
+
+		if reason == "" {
+			s, ok := settings[job.UserID]
+			if !ok {
+				s, err = u.notificationSettings(uint(job.UserID))
+				if err != nil {
+					return claimed, err
+				}
+				settings[job.UserID] = s
+			}
+
+			category, err := u.jobCategory(job)
+			if err != nil {
+				return claimed, err
+			}
+			if !s.Preference(category).Enabled {
+				reason = "turned off by the user"
+			} else if until, quiet := quietUntil(s.QuietHours, now, job.User.Location()); quiet {
+				job.Status = JobPending
+				job.NotBefore = &until
+				job.LockedUntil = nil
+				job.UpdatedAt = now
+				err := u.notificationRepo.UpdateReminderJob(job)
+				if err != nil {
+					return claimed, err
+				}
+				continue
+			}
+		}
+
+		if reason == "" {
+			advice, err := u.adviseJob(job, now)
+			if err != nil {
+				return claimed, err
+			}
+			if advice != nil && advice.PostponeUntil != nil {
+				job.Status = JobPending
+				job.NotBefore = advice.PostponeUntil
+				job.RainDelayed = true
+				job.LockedUntil = nil
+				job.UpdatedAt = now
+				err := u.notificationRepo.UpdateReminderJob(job)
+				if err != nil {
+					return claimed, err
+				}
+				continue
+			}
+			job.advice = advice
+			claimed = append(claimed, *job)
+			continue
+		}
+		job.Status = JobSkipped
+		job.LastError = reason
+		job.LockedUntil = nil
+		job.UpdatedAt = now
+		err := u.notificationRepo.UpdateReminderJob(job)
+		if err != nil {
+			return claimed, err
+		}
+	}
+	return claimed, nil
+}
+
+// jobApplies reports whether the reminder of a job still asks for it, and
+// the user did not skip the plant for today.
+func (u *notificationUseCase) jobApplies(job *ReminderJob) (bool, error) {
+	applies, err := u.reminderApplies(job)
+	if err != nil || !applies {
+		return false, err
+	}
+
+	skip, err := u.notificationRepo.GetReminderSkip(job.UserID, job.PlantID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !skip.Until.After(job.DueAt), nil
+}
+
+func (u *notificationUseCase) reminderApplies(job *ReminderJob) (bool, error) {
+	switch job.Kind {
+	case JobCustomReminder:
+		reminder, err := u.notificationRepo.GetCustomizeWateringReminder(job.SourceID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return reminder.PausedAt == nil, nil
+	case JobSnooze:
+		// Only the last snooze counts, and not after the plant was
+		// watered or skipped.
+		snoozed, err := u.notificationRepo.GetNotification(uint(job.UserID), job.SourceID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return snoozed.SnoozedUntil != nil && snoozed.SnoozedUntil.Equal(job.DueAt), nil
+	case JobEscalation:
+		return u.notificationRepo.UserHasPlant(job.UserID, job.PlantID)
+	}
+
+	schedule := job.Plant.WateringSchedule
+	if schedule.ID != job.SourceID || !schedule.IsDue(job.DueAt.In(job.User.Location())) {
+		return false, nil
+	}
+	return u.notificationRepo.UserHasPlant(job.UserID, job.PlantID)
+}
+
+// FinishReminderJob records the outcome of sending a job.
+func (u *notificationUseCase) FinishReminderJob(job *ReminderJob, sendErr error) error {
+	now := u.now()
+	job.LockedUntil = nil
+	job.UpdatedAt = now
+	if sendErr != nil {
+		job.Status = JobFailed
+		job.LastError = truncateError(sendErr)
+	} else {
+		job.Status = JobSent
+		job.SentAt = &now
+		job.LastError = ""
+	}
+	return u.notificationRepo.UpdateReminderJob(job)
+}
+
+// DeliverReminderJob adds the reminder of a job to the inbox of the user and
+// delivers it over the channels the user chose for its category. The inbox
+// keeps one notification per job, so a job sent again after a crash only
+// retries the channels that were not delivered yet. Channels that fail are
+// retried by RetryDeliveries.
+func (u *notificationUseCase) DeliverReminderJob(job *ReminderJob) error {
+	notification, err := u.reminderNotification(job)
+	if err != nil {
+		return err
+	}
+
+	settings, err := u.notificationSettings(uint(job.UserID))
+	if err != nil {
+		return err
+	}
+	channels := settings.Preference(notification.Category).ChannelList()
+
+	notification, err = u.StoreNotification(notification)
+	if err != nil {
+		return err
+	}
+
+	now := u.now()
+	if job.advice != nil && job.advice.Skip {
+		// The rain waters the plant in place of the user.
+		err = u.notificationRepo.CreateWateringSkip(&wateringhistory.WateringSkip{
+			UserID:    job.UserID,
+			PlantID:   job.PlantID,
+			Reason:    wateringhistory.SkipReasonRain,
+			StartsAt:  job.DueAt,
+			EndsAt:    job.advice.RainUntil,
+			CreatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	deliveries := make([]NotificationDelivery, 0, len(u.notifiers))
+	for _, notifier := range u.notifiers {
+		if !slices.Contains(channels, notifier.Channel()) {
+			continue
+		}
+		deliveries = append(deliveries, NotificationDelivery{
+			NotificationID: notification.Id,
+			UserID:         notification.UserId,
+			Channel:        notifier.Channel(),
+			Status:         DeliveryPending,
+			NextAttemptAt:  &now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	err = u.notificationRepo.CreateDeliveries(deliveries)
+	if err != nil {
+		return err
+	}
+
+	claimed, err := u.notificationRepo.ClaimDeliveries(now, len(deliveries), now.Add(deliveryLease), notification.Id)
+	if err != nil {
+		return err
+	}
+	for i := range claimed {
+		err = u.attemptDelivery(&claimed[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reminderNotification returns the inbox notification of a job. A snoozed
+// reminder comes back as the notification it was snoozed from. Watering
+// reminders tell the user how the weather changed them.
+func (u *notificationUseCase) reminderNotification(job *ReminderJob) (*Notification, error) {
+	notification := &Notification{
+		Title:    "Watering Reminder",
+		Body:     fmt.Sprintf("Hiii %s, It's time to water your plant: %s", job.User.Name, job.Plant.Name),
+		UserId:   job.User.ID,
+		PlantId:  job.Plant.ID,
+		Category: CategoryWatering,
+		JobID:    &job.ID,
+	}
+	switch job.Kind {
+	case JobCustomReminder:
+		notification.Title = "Customize Watering Reminder"
+		notification.Category = CategoryCustomReminder
+	case JobSnooze:
+		snoozed, err := u.notificationRepo.GetNotification(uint(job.UserID), job.SourceID)
+		if err != nil {
+			return nil, err
+		}
+		notification.Title = snoozed.Title
+		notification.Body = snoozed.Body
+		notification.Category = snoozed.Category
+	case JobEscalation:
+		notification.Title = "Overdue Watering"
+		notification.Body = fmt.Sprintf("Hiii %s, your plant %s has not been watered since %s. Water it soon to keep it healthy",
+			job.User.Name, job.Plant.Name, job.DueAt.In(job.User.Location()).Format("Mon 2 Jan 15:04"))
+	}
+	adviseNotification(notification, job)
+	return notification, nil
+}
+
+// jobCategory returns the notification category of a job. A snoozed
+// reminder keeps the category of the notification it was snoozed from.
+func (u *notificationUseCase) jobCategory(job *ReminderJob) (string, error) {
+	switch job.Kind {
+	case JobCustomReminder:
+		return CategoryCustomReminder, nil
+	case JobSnooze:
+		snoozed, err := u.notificationRepo.GetNotification(uint(job.UserID), job.SourceID)
+		if err != nil {
+			return "", err
+		}
+		return snoozed.Category, nil
+	}
+	return CategoryWatering, nil
+}
+
+// ActOnNotification takes an action of a watering reminder for the user:
+// watered records the watering, the snoozes send the reminder again later
+// and skip_today holds back the reminders of the plant until the end of the
+// day. The notification is marked as read.
+func (u *notificationUseCase) ActOnNotification(userID uint, id int, action string) (*Notification, int, error) {
+	notification, code, err := u.GetNotification(userID, id)
+	if err != nil {
+		return nil, code, err
+	}
+	if !slices.Contains(ReminderActions, action) {
+		return nil, constants.ErrCodeInvalidAction, errors.New(constants.ErrInvalidAction)
+	}
+	if !IsActionable(notification) {
+		return nil, constants.ErrCodeNotActionable, errors.New(constants.ErrNotActionable)
+	}
+
+	now := u.now()
+	notification.SnoozedUntil = nil
+	switch action {
+	case ActionWatered:
+		err = u.notificationRepo.CreateWateringHistory(&wateringhistory.WateringHistory{
+			PlantID:        notification.PlantId,
+			UserID:         notification.UserId,
+			NotificationID: &notification.Id,
+			WateredAt:      now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+	case ActionSnooze1h, ActionSnooze3h:
+		delay := time.Hour
+		if action == ActionSnooze3h {
+			delay = 3 * time.Hour
+		}
+		// The database keeps whole seconds, the snooze job is matched
+		// against SnoozedUntil.
+		until := now.Add(delay).Truncate(time.Second)
+		notification.SnoozedUntil = &until
+		err = u.notificationRepo.CreateReminderJobs([]ReminderJob{{
+			Kind:      JobSnooze,
+			SourceID:  notification.Id,
+			UserID:    notification.UserId,
+			PlantID:   notification.PlantId,
+			DueAt:     until,
+			Status:    JobPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}})
+	case ActionSkipToday:
+		var location *time.Location
+		location, err = u.GetUserLocation(userID)
+		if err != nil {
+			break
+		}
+		today := now.In(location)
+		midnight := time.Date(today.Year(), today.Month(), today.Day()+1, 0, 0, 0, 0, location)
+		err = u.notificationRepo.SaveReminderSkip(&ReminderSkip{
+			UserID:  notification.UserId,
+			PlantID: notification.PlantId,
+			Until:   midnight,
+		})
+		if err != nil {
+			break
+		}
+		err = u.notificationRepo.CreateWateringSkip(&wateringhistory.WateringSkip{
+			UserID:    notification.UserId,
+			PlantID:   notification.PlantId,
+			Reason:    wateringhistory.SkipReasonSkipToday,
+			StartsAt:  time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, location),
+			EndsAt:    midnight,
+			CreatedAt: now,
+		})
+	}
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	notification.Action = action
+	notification.ActedAt = &now
+	notification.IsRead = true
+	err = u.notificationRepo.UpdateNotificationAction(notification)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return notification, constants.CodeSuccess, nil
+}
+
+// EscalateOverdueWatering queues an escalation for a plant that has been
+// overdue since overdueSince. It is sent right away, but held back in quiet
+// hours like any reminder; escalating the same overdueSince again does
+// nothing.
+func (u *notificationUseCase) EscalateOverdueWatering(userID, plantID int, overdueSince time.Time) error {
+	now := u.now()
+	return u.notificationRepo.CreateReminderJobs([]ReminderJob{{
+		Kind:      JobEscalation,
+		SourceID:  plantID,
+		UserID:    userID,
+		PlantID:   plantID,
+		DueAt:     overdueSince,
+		NotBefore: &now,
+		Status:    JobPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}})
+}
+
+// RetryDeliveries sends the deliveries whose next attempt is due and
+// returns how many it tried.
+func (u *notificationUseCase) RetryDeliveries() (int, error) {
+	now := u.now()
+	deliveries, err := u.notificationRepo.ClaimDeliveries(now, deliveryBatch, now.Add(deliveryLease), 0)
+	if err != nil {
+		return 0, err
+	}
+	for i := range deliveries {
+		err = u.attemptDelivery(&deliveries[i])
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// attemptDelivery sends a claimed delivery once and records the outcome. A
+// failed delivery waits longer after every attempt and is kept as a dead
+// letter when it fails permanently or runs out of attempts.
+func (u *notificationUseCase) attemptDelivery(delivery *NotificationDelivery) error {
+	var sendErr error
+	notifier := u.notifier(delivery.Channel)
+	if delivery.Notification.Id == 0 {
+		sendErr = errNotificationDeleted
+	} else if notifier == nil {
+		sendErr = Permanent(fmt.Errorf("channel %s is not configured", delivery.Channel))
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+		msg := Message{
+			NotificationID: delivery.NotificationID,
+			User:           delivery.User,
+			PlantID:        delivery.Notification.PlantId,
+			Category:       delivery.Notification.Category,
+			Title:          delivery.Notification.Title,
+			Body:           delivery.Notification.Body,
+		}
+		if IsActionable(&delivery.Notification) {
+			msg.Actions = ReminderActions
+		}
+		sendErr = notifier.Notify(ctx, msg)
+		cancel()
+	}
+
+	now := u.now()
+	delivery.UpdatedAt = now
+	switch {
+	case sendErr == nil:
+		delivery.Status = DeliverySent
+		delivery.SentAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.LastError = ""
+	case errors.Is(sendErr, ErrNoRecipient) || errors.Is(sendErr, errNotificationDeleted):
+		delivery.Status = DeliverySkipped
+		delivery.NextAttemptAt = nil
+		delivery.LastError = truncateError(sendErr)
+	case IsPermanent(sendErr) || delivery.Attempts >= maxDeliveryAttempts:
+		log.Printf("Giving up on %s delivery %d: %v", delivery.Channel, delivery.ID, sendErr)
+		delivery.Status = DeliveryDead
+		delivery.NextAttemptAt = nil
+		delivery.LastError = truncateError(sendErr)
+		return u.notificationRepo.DeadLetterDelivery(delivery, &DeadLetter{
+			DeliveryID:     delivery.ID,
+			NotificationID: delivery.NotificationID,
+			UserID:         delivery.UserID,
+			Channel:        delivery.Channel,
+			Title:          delivery.Notification.Title,
+			Body:           delivery.Notification.Body,
+			Attempts:       delivery.Attempts,
+			Error:          delivery.LastError,
+			CreatedAt:      now,
+		})
+	default:
+		next := now.Add(backoff(delivery.Attempts))
+		delivery.Status = DeliveryPending
+		delivery.NextAttemptAt = &next
+		delivery.LastError = truncateError(sendErr)
+	}
+	return u.notificationRepo.UpdateDelivery(delivery)
+}
+
+var errNotificationDeleted = errors.New("notification was deleted")
+
+func (u *notificationUseCase) notifier(channel string) Notifier {
+	for _, notifier := range u.notifiers {
+		if notifier.Channel() == channel {
+			return notifier
+		}
+	}
+	return nil
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	wait := deliveryBackoff
+	for i := 1; i < attempts && wait < maxDeliveryBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxDeliveryBackoff)
+}
+
+// GetChannels returns the channels notifications can be delivered over.
+func (u *notificationUseCase) GetChannels() []string {
+	channels := make([]string, 0, len(u.notifiers))
+	for _, notifier := range u.notifiers {
+		channels = append(channels, notifier.Channel())
+	}
+	return channels
+}
+
+func (u *notificationUseCase) GetNotificationSettings(userID uint) (*NotificationSettings, int, error) {
+	settings, err := u.notificationSettings(userID)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return settings, constants.CodeSuccess, nil
+}
+
+// UpdateNotificationSettings stores the preferences of the given categories
+// and replaces the quiet hours of a user.
+func (u *notificationUseCase) UpdateNotificationSettings(userID uint, settings *NotificationSettings) (*NotificationSettings, int, error) {
+	for _, q := range settings.QuietHours {
+		if q.Start == q.End {
+			return nil, constants.ErrCodeInvalidQuietHours, errors.New(constants.ErrInvalidQuietHours)
+		}
+	}
+	midnight := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if until, quiet := quietUntil(settings.QuietHours, midnight, time.UTC); quiet && until.Sub(midnight) >= 24*time.Hour {
+		return nil, constants.ErrCodeInvalidQuietHours, errors.New(constants.ErrInvalidQuietHours)
+	}
+
+	for i := range settings.Preferences {
+		settings.Preferences[i].UserID = int(userID)
+		settings.Preferences[i].UpdatedAt = u.now()
+	}
+	for i := range settings.QuietHours {
+		settings.QuietHours[i].UserID = int(userID)
+	}
+
+	err := u.notificationRepo.SaveNotificationSettings(userID, settings)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return u.GetNotificationSettings(userID)
+}
+
+// notificationSettings returns the settings of a user with a preference for
+// every category. Categories the user did not set are sent over every
+// channel.
+func (u *notificationUseCase) notificationSettings(userID uint) (*NotificationSettings, error) {
+	preferences, err := u.notificationRepo.GetNotificationPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	quietHours, err := u.notificationRepo.GetQuietHours(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &NotificationSettings{QuietHours: quietHours}
+	for _, category := range Categories {
+		preference := NotificationPreference{
+			UserID:   int(userID),
+			Category: category,
+			Enabled:  true,
+			Channels: strings.Join(u.GetChannels(), ","),
+		}
+		for _, p := range preferences {
+			if p.Category == category {
+				preference = p
+			}
+		}
+		settings.Preferences = append(settings.Preferences, preference)
+	}
+	return settings, nil
+}
+
+// TimezoneChanged moves the reminders of a user to a new time zone. They
+// keep their time of day, so a reminder at 07:00 in Jakarta is sent at 07:00
+// in Makassar after the user moved there.
+func (u *notificationUseCase) TimezoneChanged(userID uint, name string) error {
+	reminders, err := u.notificationRepo.GetCustomizeWateringReminders(userID)
+	if err != nil {
+		return err
+	}
+
+	to := timezone.Location(name)
+	for i := range reminders {
+		reminder := &reminders[i]
+		from := timezone.Location(reminder.Timezone)
+		reminder.StartAt = sameWallClock(reminder.StartAt, from, to)
+		if reminder.EndAt != nil {
+			endAt := sameWallClock(*reminder.EndAt, from, to)
+			reminder.EndAt = &endAt
+		}
+		reminder.Timezone = to.String()
+		if reminder.PausedAt == nil && reminder.NextRunAt != nil {
+			reminder.NextRunAt = u.nextRun(reminder, u.now())
+		}
+		reminder.UpdatedAt = u.now()
+
+		err = u.notificationRepo.UpdateCustomizeWateringReminder(reminder)
+		if err != nil {
+			return err
+		}
+		err = u.notificationRepo.DeletePendingReminderJobs(JobCustomReminder, reminder.Id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameWallClock returns the time in to that shows the clock t shows in from.
+func sameWallClock(t time.Time, from, to *time.Location) time.Time {
+	t = t.In(from)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), to)
+}
+
+// GetUserLocation returns the time zone the reminders of a user are set in.
+func (u *notificationUseCase) GetUserLocation(userID uint) (*time.Location, error) {
+	user, err := u.notificationRepo.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.Location(), nil
+}
+
+// checkReminder validates the plant and schedule of a reminder.
+func (u *notificationUseCase) checkReminder(reminder *CustomizeWateringReminder) (int, error) {
+	rule, err := rrule.Parse(reminder.RRule)
+	if err != nil {
+		return constants.ErrCodeInvalidRecurrence, errors.New(constants.ErrInvalidRecurrence + ": " + err.Error())
+	}
+	reminder.RRule = rule.String()
+
+	if reminder.EndAt != nil && reminder.EndAt.Before(reminder.StartAt) {
+		return constants.ErrCodeInvalidReminder, errors.New(constants.ErrInvalidReminder)
+	}
+
+	exists, err := u.notificationRepo.PlantExists(reminder.PlantId)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	if !exists {
+		return constants.ErrCodePlantNotFound, errors.New(constants.ErrPlantNotFound)
+	}
+	return constants.CodeSuccess, nil
+}
+
+// nextRun returns the first occurrence of the reminder after t, or nil when
+// the series is over. Occurrences follow the wall clock in the time zone of
+// the reminder.
+func (u *notificationUseCase) nextRun(reminder *CustomizeWateringReminder, after time.Time) *time.Time {
+	rule, err := rrule.Parse(reminder.RRule)
+	if err != nil {
+		return nil
+	}
+
+	next, ok := rule.Next(reminder.StartAt.In(timezone.Location(reminder.Timezone)), after)
+	if !ok || (reminder.EndAt != nil && next.After(*reminder.EndAt)) {
+		return nil
+	}
+	return &next
 }
@@ -0,0 +1,1098 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+	wateringhistory "github.com/OctavianoRyan25/be-agriculture/modules/watering_history"
+	"github.com/OctavianoRyan25/be-agriculture/modules/weather"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type MockRepository struct {
+	mock.Mock
+	Repository
+}
+
+func (m *MockRepository) CreateCustomizeWateringReminder(reminder *CustomizeWateringReminder) (*CustomizeWateringReminder, error) {
+	args := m.Called(reminder)
+	return reminder, args.Error(0)
+}
+
+func (m *MockRepository) GetCustomizeWateringReminders(userID uint) ([]CustomizeWateringReminder, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]CustomizeWateringReminder), args.Error(1)
+}
+
+func (m *MockRepository) GetCustomizeWateringReminder(id int) (*CustomizeWateringReminder, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*CustomizeWateringReminder), args.Error(1)
+}
+
+func (m *MockRepository) UpdateCustomizeWateringReminder(reminder *CustomizeWateringReminder) error {
+	args := m.Called(reminder)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteCustomizeWateringReminder(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetDueCustomizeWateringReminders(now time.Time) ([]CustomizeWateringReminder, error) {
+	args := m.Called(now)
+	return args.Get(0).([]CustomizeWateringReminder), args.Error(1)
+}
+
+func (m *MockRepository) AdvanceCustomizeWateringReminder(id int, from time.Time, next *time.Time) (bool, error) {
+	args := m.Called(id, from, next)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetPlantSchedules() ([]plant.Plant, error) {
+	args := m.Called()
+	return args.Get(0).([]plant.Plant), args.Error(1)
+}
+
+func (m *MockRepository) GetPlantOwners() ([]PlantOwner, error) {
+	args := m.Called()
+	return args.Get(0).([]PlantOwner), args.Error(1)
+}
+
+func (m *MockRepository) UserHasPlant(userID, plantID int) (bool, error) {
+	args := m.Called(userID, plantID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetReminderPlan(kind string) (*ReminderPlan, error) {
+	args := m.Called(kind)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ReminderPlan), args.Error(1)
+}
+
+func (m *MockRepository) SaveReminderPlan(plan *ReminderPlan) error {
+	args := m.Called(plan)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateReminderJobs(jobs []ReminderJob) error {
+	args := m.Called(jobs)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ClaimReminderJobs(now time.Time, limit int, lockedUntil time.Time) ([]ReminderJob, error) {
+	args := m.Called(now, limit, lockedUntil)
+	return args.Get(0).([]ReminderJob), args.Error(1)
+}
+
+func (m *MockRepository) UpdateReminderJob(job *ReminderJob) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeletePendingReminderJobs(kind string, sourceID int) error {
+	args := m.Called(kind, sourceID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) PlantExists(id int) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) StoreNotification(notification *Notification) (*Notification, error) {
+	args := m.Called(notification)
+	return args.Get(0).(*Notification), args.Error(1)
+}
+
+func (m *MockRepository) GetNotification(userID uint, id int) (*Notification, error) {
+	args := m.Called(userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Notification), args.Error(1)
+}
+
+func (m *MockRepository) GetNotifications(filter *NotificationFilter) ([]Notification, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]Notification), args.Error(1)
+}
+
+func (m *MockRepository) MarkNotificationsRead(userID uint, ids []int) (int64, error) {
+	args := m.Called(userID, ids)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) RestoreNotification(userID uint, id int, deletedAfter time.Time) (bool, error) {
+	args := m.Called(userID, id, deletedAfter)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) UpdateNotificationAction(notification *Notification) error {
+	args := m.Called(notification)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateWateringHistory(history *wateringhistory.WateringHistory) error {
+	args := m.Called(history)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetReminderSkip(userID, plantID int) (*ReminderSkip, error) {
+	args := m.Called(userID, plantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ReminderSkip), args.Error(1)
+}
+
+func (m *MockRepository) SaveReminderSkip(skip *ReminderSkip) error {
+	args := m.Called(skip)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateWateringSkip(skip *wateringhistory.WateringSkip) error {
+	args := m.Called(skip)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetUser(id uint) (*user.User, error) {
+	args := m.Called(id)
+	return args.Get(0).(*user.User), args.Error(1)
+}
+
+func (m *MockRepository) CreateDeliveries(deliveries []NotificationDelivery) error {
+	args := m.Called(deliveries)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ClaimDeliveries(now time.Time, limit int, lockedUntil time.Time, notificationID int) ([]NotificationDelivery, error) {
+	args := m.Called(now, limit, lockedUntil, notificationID)
+	return args.Get(0).([]NotificationDelivery), args.Error(1)
+}
+
+func (m *MockRepository) UpdateDelivery(delivery *NotificationDelivery) error {
+	args := m.Called(delivery)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetNotificationPreferences(userID uint) ([]NotificationPreference, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]NotificationPreference), args.Error(1)
+}
+
+func (m *MockRepository) GetQuietHours(userID uint) ([]QuietHours, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]QuietHours), args.Error(1)
+}
+
+func (m *MockRepository) SaveNotificationSettings(userID uint, settings *NotificationSettings) error {
+	args := m.Called(userID, settings)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeadLetterDelivery(delivery *NotificationDelivery, letter *DeadLetter) error {
+	args := m.Called(delivery, letter)
+	return args.Error(0)
+}
+
+func (m *MockRepository) IsOutdoorPlant(userID, plantID int) (bool, error) {
+	args := m.Called(userID, plantID)
+	return args.Bool(0), args.Error(1)
+}
+
+var jakarta, _ = time.LoadLocation("Asia/Jakarta")
+var makassar, _ = time.LoadLocation("Asia/Makassar")
+
+func newTestUseCase(repo *MockRepository, now time.Time) *notificationUseCase {
+	uc := NewUseCase(repo, nil)
+	uc.now = func() time.Time { return now }
+	return uc
+}
+
+func TestCreateCustomizeWateringReminder(t *testing.T) {
+	repo := new(MockRepository)
+	// 3 January 2024 is a Wednesday.
+	uc := newTestUseCase(repo, time.Date(2024, time.January, 3, 12, 0, 0, 0, jakarta))
+
+	repo.On("PlantExists", 5).Return(true, nil)
+	repo.On("CreateCustomizeWateringReminder", mock.Anything).Return(nil)
+
+	reminder, code, err := uc.CreateCustomizeWateringReminder(&CustomizeWateringReminder{
+		UserId:  1,
+		PlantId: 5,
+		RRule:   "freq=weekly;byday=th,mo",
+		StartAt: time.Date(2024, time.January, 3, 7, 0, 0, 0, jakarta),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, constants.Created, code)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,TH", reminder.RRule)
+	assert.True(t, reminder.NextRunAt.Equal(time.Date(2024, time.January, 4, 7, 0, 0, 0, jakarta)))
+}
+
+func TestCreateCustomizeWateringReminderInUserTimezone(t *testing.T) {
+	repo := new(MockRepository)
+	uc := newTestUseCase(repo, time.Date(2024, time.January, 3, 7, 30, 0, 0, jakarta))
+
+	repo.On("PlantExists", 5).Return(true, nil)
+	repo.On("CreateCustomizeWateringReminder", mock.Anything).Return(nil)
+
+	// 07:30 in Jakarta is 08:30 in Makassar, so 08:00 has passed there.
+	reminder, _, err := uc.CreateCustomizeWateringReminder(&CustomizeWateringReminder{
+		UserId:   1,
+		PlantId:  5,
+		RRule:    "FREQ=DAILY",
+		StartAt:  time.Date(2024, time.January, 3, 8, 0, 0, 0, makassar),
+		Timezone: "Asia/Makassar",
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, reminder.NextRunAt.Equal(time.Date(2024, time.January, 4, 8, 0, 0, 0, makassar)))
+	assert.Equal(t, "2024-01-04T08:00:00+08:00", MapReminderToResponse(reminder).NextRunAt.Format(time.RFC3339))
+}
+
+func TestCreateCustomizeWateringReminderInvalid(t *testing.T) {
+	repo := new(MockRepository)
+	uc := newTestUseCase(repo, time.Now())
+	start := time.Date(2024, time.January, 3, 7, 0, 0, 0, jakarta)
+	before := start.AddDate(0, 0, -1)
+
+	_, code, err := uc.CreateCustomizeWateringReminder(&CustomizeWateringReminder{PlantId: 5, RRule: "FREQ=HOURLY", StartAt: start})
+	assert.Error(t, err)
+	assert.Equal(t, constants.ErrCodeInvalidRecurrence, code)
+
+	_, code, err = uc.CreateCustomizeWateringReminder(&CustomizeWateringReminder{PlantId: 5, RRule: "FREQ=DAILY", StartAt: start, EndAt: &before})
+	assert.EqualError(t, err, constants.ErrInvalidReminder)
+	assert.Equal(t, constants.ErrCodeInvalidReminder, code)
+
+	repo.On("PlantExists", 9).Return(false, nil)
+	_, code, err = uc.CreateCustomizeWateringReminder(&CustomizeWateringReminder{PlantId: 9, RRule: "FREQ=DAILY", StartAt: start})
+	assert.EqualError(t, err, constants.ErrPlantNotFound)
+	assert.Equal(t, constants.ErrCodePlantNotFound, code)
+}
+
+func TestCustomizeWateringReminderOfOtherUser(t *testing.T) {
+	repo := new(MockRepository)
+	uc := newTestUseCase(repo, time.Now())
+
+	repo.On("GetCustomizeWateringReminder", 3).Return(&CustomizeWateringReminder{Id: 3, UserId: 2}, nil)
+	repo.On("GetCustomizeWateringReminder", 4).Return(nil, gorm.ErrRecordNotFound)
+
+	_, code, err := uc.GetCustomizeWateringReminder(1, 3)
+	assert.EqualError(t, err, constants.ErrReminderNotFound)
+	assert.Equal(t, constants.ErrCodeReminderNotFound, code)
+
+	code, err = uc.DeleteCustomizeWateringReminder(1, 4)
+	assert.Error(t, err)
+	assert.Equal(t, constants.ErrCodeReminderNotFound, code)
+	repo.AssertNotCalled(t, "DeleteCustomizeWateringReminder", mock.Anything)
+}
+
+func TestPauseAndResumeCustomizeWateringReminder(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 10, 12, 0, 0, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	next := time.Date(2024, time.January, 4, 7, 0, 0, 0, jakarta)
+	reminder := &CustomizeWateringReminder{
+		Id:        3,
+		UserId:    1,
+		RRule:     "FREQ=DAILY",
+		StartAt:   time.Date(2024, time.January, 1, 7, 0, 0, 0, jakarta),
+		NextRunAt: &next,
+	}
+
+	repo.On("GetCustomizeWateringReminder", 3).Return(reminder, nil)
+	repo.On("UpdateCustomizeWateringReminder", reminder).Return(nil)
+	repo.On("DeletePendingReminderJobs", JobCustomReminder, 3).Return(nil)
+
+	paused, _, err := uc.PauseCustomizeWateringReminder(1, 3)
+	assert.NoError(t, err)
+	assert.NotNil(t, paused.PausedAt)
+
+	resumed, _, err := uc.ResumeCustomizeWateringReminder(1, 3)
+	assert.NoError(t, err)
+	assert.Nil(t, resumed.PausedAt)
+	// Occurrences missed while paused are skipped.
+	assert.True(t, resumed.NextRunAt.Equal(time.Date(2024, time.January, 11, 7, 0, 0, 0, jakarta)))
+}
+
+func TestResumeEndedCustomizeWateringReminder(t *testing.T) {
+	repo := new(MockRepository)
+	uc := newTestUseCase(repo, time.Date(2024, time.January, 10, 12, 0, 0, 0, jakarta))
+	pausedAt := time.Date(2024, time.January, 2, 0, 0, 0, 0, jakarta)
+
+	repo.On("GetCustomizeWateringReminder", 3).Return(&CustomizeWateringReminder{
+		Id:       3,
+		UserId:   1,
+		RRule:    "FREQ=DAILY;COUNT=2",
+		StartAt:  time.Date(2024, time.January, 1, 7, 0, 0, 0, jakarta),
+		PausedAt: &pausedAt,
+	}, nil)
+
+	_, code, err := uc.ResumeCustomizeWateringReminder(1, 3)
+	assert.EqualError(t, err, constants.ErrReminderEnded)
+	assert.Equal(t, constants.ErrCodeReminderEnded, code)
+}
+
+func TestPlanCustomReminders(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 7, 0, 30, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	due := time.Date(2024, time.January, 5, 7, 0, 0, 0, jakarta)
+	start := time.Date(2024, time.January, 1, 7, 0, 0, 0, jakarta)
+	// Missed four days ago, too late to send now.
+	stale := start
+
+	repo.On("GetDueCustomizeWateringReminders", now.Add(planAhead)).Return([]CustomizeWateringReminder{
+		{Id: 1, RRule: "FREQ=DAILY;INTERVAL=2", StartAt: start, NextRunAt: &due},
+		{Id: 2, RRule: "FREQ=DAILY", StartAt: start, NextRunAt: &stale},
+		{Id: 3, RRule: "FREQ=DAILY", StartAt: start, NextRunAt: &due},
+	}, nil)
+	var planned []ReminderJob
+	repo.On("CreateReminderJobs", mock.Anything).Run(func(args mock.Arguments) {
+		planned = append(planned, args.Get(0).([]ReminderJob)...)
+	}).Return(nil)
+	repo.On("AdvanceCustomizeWateringReminder", 1, due, mock.MatchedBy(func(next *time.Time) bool {
+		return next.Equal(time.Date(2024, time.January, 7, 7, 0, 0, 0, jakarta))
+	})).Return(true, nil)
+	repo.On("AdvanceCustomizeWateringReminder", 2, stale, mock.MatchedBy(func(next *time.Time) bool {
+		return next.Equal(due)
+	})).Return(true, nil)
+	repo.On("AdvanceCustomizeWateringReminder", 2, due, mock.Anything).Return(true, nil)
+	// Reminder 3 is being planned by another instance.
+	repo.On("AdvanceCustomizeWateringReminder", 3, due, mock.Anything).Return(false, nil)
+	repo.On("GetReminderPlan", JobPlantSchedule).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetPlantSchedules").Return([]plant.Plant{}, nil)
+	repo.On("GetPlantOwners").Return([]PlantOwner{}, nil)
+	repo.On("SaveReminderPlan", mock.Anything).Return(nil)
+
+	err := uc.PlanReminderJobs()
+
+	assert.NoError(t, err)
+	assert.Len(t, planned, 3)
+	for i, id := range []int{1, 2, 3} {
+		assert.Equal(t, id, planned[i].SourceID)
+		assert.Equal(t, JobCustomReminder, planned[i].Kind)
+		assert.True(t, planned[i].DueAt.Equal(due))
+	}
+}
+
+func TestPlanPlantSchedulesCatchesUp(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 7, 0, 0, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	schedule := plant.PlantReminder{
+		ID:        8,
+		PlantID:   5,
+		Each:      plant.PeriodDay,
+		Interval:  1,
+		Times:     []plant.PlantReminderTime{{Time: "06:30"}, {Time: "07:10"}},
+		CreatedAt: time.Date(2024, time.January, 1, 0, 0, 0, 0, jakarta),
+	}
+
+	repo.On("GetDueCustomizeWateringReminders", mock.Anything).Return([]CustomizeWateringReminder{}, nil)
+	// The last plan ended an hour ago, before the app was restarted.
+	repo.On("GetReminderPlan", JobPlantSchedule).Return(&ReminderPlan{Kind: JobPlantSchedule, PlannedUntil: now.Add(-time.Hour)}, nil)
+	repo.On("GetPlantSchedules").Return([]plant.Plant{{ID: 5, WateringSchedule: schedule}}, nil)
+	repo.On("GetPlantOwners").Return([]PlantOwner{
+		{UserID: 1, PlantID: 5, Timezone: ""},
+		{UserID: 2, PlantID: 5, Timezone: "Asia/Makassar"},
+		{UserID: 3, PlantID: 6, Timezone: ""},
+	}, nil)
+	var planned []ReminderJob
+	repo.On("CreateReminderJobs", mock.Anything).Run(func(args mock.Arguments) {
+		planned = args.Get(0).([]ReminderJob)
+	}).Return(nil)
+	repo.On("SaveReminderPlan", &ReminderPlan{Kind: JobPlantSchedule, PlannedUntil: now.Add(planAhead)}).Return(nil)
+
+	err := uc.PlanReminderJobs()
+
+	assert.NoError(t, err)
+	assert.Len(t, planned, 3)
+	assert.Equal(t, 1, planned[0].UserID)
+	assert.True(t, planned[0].DueAt.Equal(time.Date(2024, time.January, 5, 6, 30, 0, 0, jakarta)))
+	assert.Equal(t, 1, planned[1].UserID)
+	assert.True(t, planned[1].DueAt.Equal(time.Date(2024, time.January, 5, 7, 10, 0, 0, jakarta)))
+	// 07:10 in Makassar is 06:10 in Jakarta; 06:30 there was before the gap.
+	assert.Equal(t, 2, planned[2].UserID)
+	assert.True(t, planned[2].DueAt.Equal(time.Date(2024, time.January, 5, 7, 10, 0, 0, makassar)))
+	assert.Equal(t, 8, planned[2].SourceID)
+	repo.AssertExpectations(t)
+}
+
+func TestClaimReminderJobsSkipsStaleJobs(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 7, 0, 0, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	pausedAt := now.Add(-time.Hour)
+	schedule := plant.PlantReminder{
+		ID:        8,
+		Each:      plant.PeriodDay,
+		Times:     []plant.PlantReminderTime{{Time: "07:00"}},
+		CreatedAt: time.Date(2024, time.January, 1, 0, 0, 0, 0, jakarta),
+	}
+
+	repo.On("ClaimReminderJobs", now, jobBatch, now.Add(jobLease)).Return([]ReminderJob{
+		{ID: 1, Kind: JobPlantSchedule, SourceID: 8, UserID: 1, PlantID: 5, DueAt: now, Plant: plant.Plant{ID: 5, WateringSchedule: schedule}},
+		{ID: 2, Kind: JobPlantSchedule, SourceID: 8, UserID: 1, PlantID: 5, DueAt: now.Add(-maxLateness - time.Minute), Plant: plant.Plant{ID: 5, WateringSchedule: schedule}},
+		{ID: 3, Kind: JobCustomReminder, SourceID: 4, UserID: 1, PlantID: 5, DueAt: now},
+		{ID: 4, Kind: JobCustomReminder, SourceID: 9, UserID: 1, PlantID: 5, DueAt: now},
+	}, nil)
+	repo.On("UserHasPlant", 1, 5).Return(true, nil)
+	repo.On("GetReminderSkip", mock.Anything, mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetNotificationPreferences", uint(1)).Return([]NotificationPreference(nil), nil)
+	repo.On("GetQuietHours", uint(1)).Return([]QuietHours(nil), nil)
+	repo.On("GetCustomizeWateringReminder", 4).Return(&CustomizeWateringReminder{Id: 4, PausedAt: &pausedAt}, nil)
+	repo.On("GetCustomizeWateringReminder", 9).Return(nil, gorm.ErrRecordNotFound)
+	var skipped []int
+	repo.On("UpdateReminderJob", mock.Anything).Run(func(args mock.Arguments) {
+		job := args.Get(0).(*ReminderJob)
+		assert.Equal(t, JobSkipped, job.Status)
+		skipped = append(skipped, job.ID)
+	}).Return(nil)
+
+	jobs, err := uc.ClaimReminderJobs()
+
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, 1, jobs[0].ID)
+	assert.Equal(t, []int{2, 3, 4}, skipped)
+}
+
+func TestClaimReminderJobsHonorsSettings(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 23, 0, 0, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	schedule := plant.PlantReminder{
+		ID:        8,
+		Each:      plant.PeriodDay,
+		Times:     []plant.PlantReminderTime{{Time: "23:00"}},
+		CreatedAt: time.Date(2024, time.January, 1, 0, 0, 0, 0, jakarta),
+	}
+	lateButHeld := now.Add(-maxLateness - time.Hour)
+
+	repo.On("ClaimReminderJobs", now, jobBatch, now.Add(jobLease)).Return([]ReminderJob{
+		{ID: 1, Kind: JobCustomReminder, SourceID: 4, UserID: 1, PlantID: 5, DueAt: now},
+		{ID: 2, Kind: JobPlantSchedule, SourceID: 8, UserID: 2, PlantID: 5, DueAt: now, Plant: plant.Plant{ID: 5, WateringSchedule: schedule}},
+		{ID: 3, Kind: JobPlantSchedule, SourceID: 8, UserID: 1, PlantID: 5, DueAt: now, Plant: plant.Plant{ID: 5, WateringSchedule: schedule}},
+		{ID: 4, Kind: JobCustomReminder, SourceID: 6, UserID: 1, PlantID: 5, DueAt: lateButHeld, NotBefore: &now},
+	}, nil)
+	repo.On("GetCustomizeWateringReminder", mock.Anything).Return(&CustomizeWateringReminder{Id: 4}, nil)
+	repo.On("UserHasPlant", mock.Anything, 5).Return(true, nil)
+	repo.On("GetReminderSkip", mock.Anything, mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetNotificationPreferences", uint(1)).Return([]NotificationPreference{
+		{UserID: 1, Category: CategoryCustomReminder, Enabled: false},
+	}, nil)
+	repo.On("GetQuietHours", uint(1)).Return([]QuietHours(nil), nil)
+	repo.On("GetNotificationPreferences", uint(2)).Return([]NotificationPreference(nil), nil)
+	repo.On("GetQuietHours", uint(2)).Return([]QuietHours{{UserID: 2, Start: "22:00", End: "06:00"}}, nil)
+	updated := map[int]ReminderJob{}
+	repo.On("UpdateReminderJob", mock.Anything).Run(func(args mock.Arguments) {
+		job := args.Get(0).(*ReminderJob)
+		updated[job.ID] = *job
+	}).Return(nil)
+
+	jobs, err := uc.ClaimReminderJobs()
+
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, 3, jobs[0].ID)
+	assert.Equal(t, JobSkipped, updated[1].Status)
+	assert.Equal(t, "turned off by the user", updated[1].LastError)
+	assert.Equal(t, JobPending, updated[2].Status)
+	assert.True(t, updated[2].NotBefore.Equal(time.Date(2024, time.January, 6, 6, 0, 0, 0, jakarta)))
+	// Held back by quiet hours, so it is not too late yet, but turned off.
+	assert.Equal(t, "turned off by the user", updated[4].LastError)
+	repo.AssertNumberOfCalls(t, "GetNotificationPreferences", 2)
+}
+
+func TestQuietUntil(t *testing.T) {
+	windows := []QuietHours{{Start: "22:00", End: "06:00"}, {Start: "06:00", End: "06:30"}, {Start: "13:00", End: "14:00"}}
+
+	until, quiet := quietUntil(windows, time.Date(2024, time.January, 5, 23, 15, 0, 0, jakarta), jakarta)
+	assert.True(t, quiet)
+	assert.True(t, until.Equal(time.Date(2024, time.January, 6, 6, 30, 0, 0, jakarta)))
+
+	until, quiet = quietUntil(windows, time.Date(2024, time.January, 5, 2, 0, 0, 0, jakarta), jakarta)
+	assert.True(t, quiet)
+	assert.True(t, until.Equal(time.Date(2024, time.January, 5, 6, 30, 0, 0, jakarta)))
+
+	until, quiet = quietUntil(windows, time.Date(2024, time.January, 5, 13, 30, 0, 0, jakarta), jakarta)
+	assert.True(t, quiet)
+	assert.True(t, until.Equal(time.Date(2024, time.January, 5, 14, 0, 0, 0, jakarta)))
+
+	_, quiet = quietUntil(windows, time.Date(2024, time.January, 5, 14, 0, 0, 0, jakarta), jakarta)
+	assert.False(t, quiet)
+}
+
+func TestUpdateNotificationSettingsInvalidQuietHours(t *testing.T) {
+	repo := new(MockRepository)
+	uc := newTestUseCase(repo, time.Now())
+
+	for _, quietHours := range [][]QuietHours{
+		{{Start: "22:00", End: "22:00"}},
+		{{Start: "22:00", End: "06:00"}, {Start: "06:00", End: "22:00"}},
+	} {
+		_, code, err := uc.UpdateNotificationSettings(1, &NotificationSettings{QuietHours: quietHours})
+		assert.EqualError(t, err, constants.ErrInvalidQuietHours)
+		assert.Equal(t, constants.ErrCodeInvalidQuietHours, code)
+	}
+	repo.AssertNotCalled(t, "SaveNotificationSettings", mock.Anything, mock.Anything)
+}
+
+func TestFinishReminderJob(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 7, 0, 0, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	repo.On("UpdateReminderJob", mock.Anything).Return(nil)
+	lockedUntil := now.Add(jobLease)
+
+	sent := &ReminderJob{ID: 1, Status: JobProcessing, LockedUntil: &lockedUntil}
+	assert.NoError(t, uc.FinishReminderJob(sent, nil))
+	assert.Equal(t, JobSent, sent.Status)
+	assert.Equal(t, now, *sent.SentAt)
+	assert.Nil(t, sent.LockedUntil)
+
+	failed := &ReminderJob{ID: 2, Status: JobProcessing, LockedUntil: &lockedUntil}
+	assert.NoError(t, uc.FinishReminderJob(failed, errors.New("fcm unavailable")))
+	assert.Equal(t, JobFailed, failed.Status)
+	assert.Equal(t, "fcm unavailable", failed.LastError)
+	assert.Nil(t, failed.SentAt)
+}
+
+func TestGetNotificationsPaginates(t *testing.T) {
+	repo := new(MockRepository)
+	uc := newTestUseCase(repo, time.Now())
+	unread := false
+
+	repo.On("GetNotifications", mock.Anything).Return([]Notification{{Id: 9}, {Id: 7}, {Id: 4}}, nil)
+
+	notifications, next, code, err := uc.GetNotifications(&NotificationFilter{UserID: 1, IsRead: &unread, Before: 12, Limit: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, constants.CodeSuccess, code)
+	assert.Len(t, notifications, 2)
+	assert.Equal(t, 7, next)
+	filter := repo.Calls[0].Arguments.Get(0).(*NotificationFilter)
+	assert.Equal(t, 3, filter.Limit)
+	assert.Equal(t, 12, filter.Before)
+	assert.False(t, *filter.IsRead)
+
+	repo = new(MockRepository)
+	uc = newTestUseCase(repo, time.Now())
+	repo.On("GetNotifications", mock.Anything).Return([]Notification{{Id: 3}}, nil)
+
+	notifications, next, _, err = uc.GetNotifications(&NotificationFilter{UserID: 1, Limit: 1000})
+
+	assert.NoError(t, err)
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, 0, next)
+	assert.Equal(t, maxPageSize+1, repo.Calls[0].Arguments.Get(0).(*NotificationFilter).Limit)
+}
+
+func TestMarkNotificationReadChecksOwnerFirst(t *testing.T) {
+	repo := new(MockRepository)
+	uc := newTestUseCase(repo, time.Now())
+	repo.On("GetNotification", uint(2), 5).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetNotification", uint(1), 5).Return(&Notification{Id: 5, UserId: 1}, nil)
+	repo.On("MarkNotificationsRead", uint(1), []int{5}).Return(int64(1), nil)
+
+	_, code, err := uc.MarkNotificationRead(2, 5)
+	assert.EqualError(t, err, constants.ErrNotificationNotFound)
+	assert.Equal(t, constants.ErrCodeNotificationNotFound, code)
+	repo.AssertNotCalled(t, "MarkNotificationsRead", uint(2), mock.Anything)
+
+	notification, _, err := uc.MarkNotificationRead(1, 5)
+	assert.NoError(t, err)
+	assert.True(t, notification.IsRead)
+	repo.AssertCalled(t, "MarkNotificationsRead", uint(1), []int{5})
+}
+
+func TestRestoreNotification(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 10, 12, 0, 0, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	repo.On("RestoreNotification", uint(1), 5, now.Add(-restoreWindow)).Return(true, nil)
+	repo.On("RestoreNotification", uint(1), 6, now.Add(-restoreWindow)).Return(false, nil)
+	repo.On("GetNotification", uint(1), 5).Return(&Notification{Id: 5, UserId: 1}, nil)
+
+	notification, _, err := uc.RestoreNotification(1, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, notification.Id)
+
+	_, code, err := uc.RestoreNotification(1, 6)
+	assert.EqualError(t, err, constants.ErrNotificationNotFound)
+	assert.Equal(t, constants.ErrCodeNotificationNotFound, code)
+}
+
+func TestActOnNotification(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 7, 0, 0, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	reminder := func() *Notification {
+		return &Notification{Id: 5, UserId: 1, PlantId: 2, Category: CategoryWatering}
+	}
+	repo.On("GetNotification", uint(1), 5).Return(reminder(), nil).Once()
+	repo.On("GetNotification", uint(1), 5).Return(reminder(), nil).Once()
+	repo.On("GetNotification", uint(1), 5).Return(reminder(), nil).Once()
+	repo.On("GetNotification", uint(1), 6).Return(&Notification{Id: 6, UserId: 1, Category: CategoryArticle}, nil)
+	repo.On("GetUser", uint(1)).Return(&user.User{ID: 1, Timezone: "Asia/Makassar"}, nil)
+	repo.On("CreateWateringHistory", mock.Anything).Return(nil)
+	repo.On("CreateReminderJobs", mock.Anything).Return(nil)
+	repo.On("SaveReminderSkip", mock.Anything).Return(nil)
+	repo.On("CreateWateringSkip", mock.Anything).Return(nil)
+	repo.On("UpdateNotificationAction", mock.Anything).Return(nil)
+
+	notification, _, err := uc.ActOnNotification(1, 5, ActionWatered)
+	assert.NoError(t, err)
+	assert.Equal(t, ActionWatered, notification.Action)
+	assert.True(t, notification.IsRead)
+	history := repo.Calls[1].Arguments.Get(0).(*wateringhistory.WateringHistory)
+	assert.Equal(t, 2, history.PlantID)
+	assert.Equal(t, 5, *history.NotificationID)
+
+	notification, _, err = uc.ActOnNotification(1, 5, ActionSnooze3h)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(3*time.Hour), *notification.SnoozedUntil)
+	jobs := repo.Calls[4].Arguments.Get(0).([]ReminderJob)
+	assert.Equal(t, JobSnooze, jobs[0].Kind)
+	assert.Equal(t, 5, jobs[0].SourceID)
+	assert.Equal(t, now.Add(3*time.Hour), jobs[0].DueAt)
+
+	notification, _, err = uc.ActOnNotification(1, 5, ActionSkipToday)
+	assert.NoError(t, err)
+	assert.Nil(t, notification.SnoozedUntil)
+	skip := repo.Calls[8].Arguments.Get(0).(*ReminderSkip)
+	assert.True(t, skip.Until.Equal(time.Date(2024, time.January, 6, 0, 0, 0, 0, makassar)))
+	wateringSkip := repo.Calls[9].Arguments.Get(0).(*wateringhistory.WateringSkip)
+	assert.Equal(t, wateringhistory.SkipReasonSkipToday, wateringSkip.Reason)
+	assert.Equal(t, 2, wateringSkip.PlantID)
+	assert.True(t, wateringSkip.StartsAt.Equal(time.Date(2024, time.January, 5, 0, 0, 0, 0, makassar)))
+	assert.True(t, wateringSkip.EndsAt.Equal(skip.Until))
+
+	_, code, err := uc.ActOnNotification(1, 6, ActionWatered)
+	assert.EqualError(t, err, constants.ErrNotActionable)
+	assert.Equal(t, constants.ErrCodeNotActionable, code)
+
+	_, code, err = uc.ActOnNotification(1, 6, "water_twice")
+	assert.EqualError(t, err, constants.ErrInvalidAction)
+	assert.Equal(t, constants.ErrCodeInvalidAction, code)
+}
+
+func TestClaimSnoozedAndSkippedJobs(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 10, 0, 0, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	earlier := now.Add(-2 * time.Hour)
+
+	repo.On("ClaimReminderJobs", now, jobBatch, now.Add(jobLease)).Return([]ReminderJob{
+		{ID: 1, Kind: JobSnooze, SourceID: 5, UserID: 1, PlantID: 2, DueAt: now},
+		{ID: 2, Kind: JobSnooze, SourceID: 6, UserID: 1, PlantID: 2, DueAt: earlier},
+		{ID: 3, Kind: JobSnooze, SourceID: 5, UserID: 1, PlantID: 3, DueAt: now},
+	}, nil)
+	repo.On("GetNotification", uint(1), 5).Return(&Notification{Id: 5, UserId: 1, PlantId: 2, Category: CategoryCustomReminder, SnoozedUntil: &now}, nil)
+	// Snoozed again later, the earlier snooze no longer counts.
+	later := now.Add(time.Hour)
+	repo.On("GetNotification", uint(1), 6).Return(&Notification{Id: 6, UserId: 1, PlantId: 2, SnoozedUntil: &later}, nil)
+	repo.On("GetReminderSkip", 1, 2).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetReminderSkip", 1, 3).Return(&ReminderSkip{UserID: 1, PlantID: 3, Until: now.Add(14 * time.Hour)}, nil)
+	repo.On("GetNotificationPreferences", uint(1)).Return([]NotificationPreference(nil), nil)
+	repo.On("GetQuietHours", uint(1)).Return([]QuietHours(nil), nil)
+	var skipped []int
+	repo.On("UpdateReminderJob", mock.Anything).Run(func(args mock.Arguments) {
+		skipped = append(skipped, args.Get(0).(*ReminderJob).ID)
+	}).Return(nil)
+
+	jobs, err := uc.ClaimReminderJobs()
+
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, 1, jobs[0].ID)
+	assert.Equal(t, []int{2, 3}, skipped)
+}
+
+func TestEscalateOverdueWatering(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 10, 0, 0, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	overdueSince := now.Add(-30 * time.Hour)
+
+	var created []ReminderJob
+	repo.On("CreateReminderJobs", mock.Anything).Run(func(args mock.Arguments) {
+		created = args.Get(0).([]ReminderJob)
+	}).Return(nil)
+
+	err := uc.EscalateOverdueWatering(1, 2, overdueSince)
+
+	assert.NoError(t, err)
+	assert.Len(t, created, 1)
+	job := created[0]
+	assert.Equal(t, JobEscalation, job.Kind)
+	assert.True(t, job.DueAt.Equal(overdueSince))
+	assert.True(t, job.NotBefore.Equal(now))
+
+	// Sent by its NotBefore, long after the watering time it is due at.
+	job.ID = 4
+	job.User = user.User{ID: 1, Name: "Budi", Timezone: "Asia/Jakarta"}
+	job.Plant = plant.Plant{ID: 2, Name: "Monstera"}
+	repo.On("ClaimReminderJobs", now, jobBatch, now.Add(jobLease)).Return([]ReminderJob{job}, nil)
+	repo.On("UserHasPlant", 1, 2).Return(true, nil)
+	repo.On("GetReminderSkip", 1, 2).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetNotificationPreferences", uint(1)).Return([]NotificationPreference(nil), nil)
+	repo.On("GetQuietHours", uint(1)).Return([]QuietHours(nil), nil)
+
+	jobs, err := uc.ClaimReminderJobs()
+
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	notification, err := uc.reminderNotification(&jobs[0])
+	assert.NoError(t, err)
+	assert.Equal(t, CategoryWatering, notification.Category)
+	assert.Equal(t, "Hiii Budi, your plant Monstera has not been watered since Thu 4 Jan 04:00. Water it soon to keep it healthy", notification.Body)
+}
+
+func TestDeliverReminderJob(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 7, 0, 0, 0, jakarta)
+	push := NewFakeNotifier(ChannelPush)
+	email := NewFakeNotifier(ChannelEmail, errors.New("smtp unavailable"))
+	uc := NewUseCase(repo, []Notifier{push, email})
+	uc.now = func() time.Time { return now }
+
+	owner := user.User{ID: 3, Name: "Budi", Email: "budi@example.com"}
+	job := &ReminderJob{ID: 7, Kind: JobCustomReminder, UserID: 3, User: owner, PlantID: 2, Plant: plant.Plant{ID: 2, Name: "Monstera"}}
+
+	repo.On("GetNotificationPreferences", uint(3)).Return([]NotificationPreference(nil), nil)
+	repo.On("GetQuietHours", uint(3)).Return([]QuietHours(nil), nil)
+	repo.On("StoreNotification", mock.Anything).Return(&Notification{Id: 10, UserId: 3, PlantId: 2}, nil)
+	var created []NotificationDelivery
+	repo.On("CreateDeliveries", mock.Anything).Run(func(args mock.Arguments) {
+		created = args.Get(0).([]NotificationDelivery)
+	}).Return(nil)
+	stored := Notification{Id: 10, UserId: 3, PlantId: 2, Title: "Customize Watering Reminder", Body: "Hiii Budi, It's time to water your plant: Monstera"}
+	repo.On("ClaimDeliveries", now, 2, now.Add(deliveryLease), 10).Return([]NotificationDelivery{
+		{ID: 1, NotificationID: 10, Notification: stored, UserID: 3, User: owner, Channel: ChannelPush, Status: DeliveryPending, Attempts: 1},
+		{ID: 2, NotificationID: 10, Notification: stored, UserID: 3, User: owner, Channel: ChannelEmail, Status: DeliveryPending, Attempts: 1},
+	}, nil)
+	updated := map[string]NotificationDelivery{}
+	repo.On("UpdateDelivery", mock.Anything).Run(func(args mock.Arguments) {
+		d := args.Get(0).(*NotificationDelivery)
+		updated[d.Channel] = *d
+	}).Return(nil)
+
+	err := uc.DeliverReminderJob(job)
+
+	assert.NoError(t, err)
+	saved := repo.Calls[2].Arguments.Get(0).(*Notification)
+	assert.Equal(t, "Customize Watering Reminder", saved.Title)
+	assert.Equal(t, CategoryCustomReminder, saved.Category)
+	assert.Equal(t, 7, *saved.JobID)
+	assert.Len(t, created, 2)
+	assert.Equal(t, 10, created[0].NotificationID)
+
+	assert.Len(t, push.Sent(), 1)
+	assert.Equal(t, "budi@example.com", push.Sent()[0].User.Email)
+	assert.Equal(t, DeliverySent, updated[ChannelPush].Status)
+	assert.Equal(t, now, *updated[ChannelPush].SentAt)
+
+	assert.Empty(t, email.Sent())
+	assert.Equal(t, DeliveryPending, updated[ChannelEmail].Status)
+	assert.Equal(t, now.Add(deliveryBackoff), *updated[ChannelEmail].NextAttemptAt)
+	assert.Equal(t, "smtp unavailable", updated[ChannelEmail].LastError)
+}
+
+func TestDeliverReminderJobUsesPreferredChannels(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 7, 0, 0, 0, jakarta)
+	uc := NewUseCase(repo, []Notifier{NewFakeNotifier(ChannelPush), NewFakeNotifier(ChannelEmail)})
+	uc.now = func() time.Time { return now }
+
+	repo.On("GetNotificationPreferences", uint(3)).Return([]NotificationPreference{
+		{UserID: 3, Category: CategoryWatering, Enabled: true, Channels: "email"},
+	}, nil)
+	repo.On("GetQuietHours", uint(3)).Return([]QuietHours(nil), nil)
+	repo.On("StoreNotification", mock.Anything).Return(&Notification{Id: 10, UserId: 3, Category: CategoryWatering}, nil)
+	var created []NotificationDelivery
+	repo.On("CreateDeliveries", mock.Anything).Run(func(args mock.Arguments) {
+		created = args.Get(0).([]NotificationDelivery)
+	}).Return(nil)
+	repo.On("ClaimDeliveries", now, 1, now.Add(deliveryLease), 10).Return([]NotificationDelivery{}, nil)
+
+	err := uc.DeliverReminderJob(&ReminderJob{ID: 7, Kind: JobPlantSchedule, UserID: 3, User: user.User{ID: 3}})
+
+	assert.NoError(t, err)
+	assert.Len(t, created, 1)
+	assert.Equal(t, ChannelEmail, created[0].Channel)
+}
+
+func TestDeliverReminderJobRecordsRainSkip(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 7, 0, 0, 0, jakarta)
+	uc := NewUseCase(repo, nil)
+	uc.now = func() time.Time { return now }
+
+	repo.On("GetNotificationPreferences", uint(3)).Return([]NotificationPreference(nil), nil)
+	repo.On("GetQuietHours", uint(3)).Return([]QuietHours(nil), nil)
+	repo.On("StoreNotification", mock.Anything).Return(&Notification{Id: 10, UserId: 3, Category: CategoryWatering}, nil)
+	var skip *wateringhistory.WateringSkip
+	repo.On("CreateWateringSkip", mock.Anything).Run(func(args mock.Arguments) {
+		skip = args.Get(0).(*wateringhistory.WateringSkip)
+	}).Return(nil)
+
+	rainUntil := now.Add(3 * time.Hour)
+	job := &ReminderJob{ID: 7, Kind: JobPlantSchedule, UserID: 3, User: user.User{ID: 3}, PlantID: 2, DueAt: now,
+		advice: &wateringAdvice{Skip: true, Rain: 6, RainUntil: rainUntil}}
+	err := uc.DeliverReminderJob(job)
+
+	assert.NoError(t, err)
+	assert.Equal(t, wateringhistory.SkipReasonRain, skip.Reason)
+	assert.Equal(t, 2, skip.PlantID)
+	assert.Equal(t, now, skip.StartsAt)
+	assert.Equal(t, rainUntil, skip.EndsAt)
+}
+
+func TestRetryDeliveries(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 7, 0, 0, 0, jakarta)
+	push := NewFakeNotifier(ChannelPush, ErrNoRecipient)
+	email := NewFakeNotifier(ChannelEmail, errors.New("smtp unavailable"))
+	webhook := NewFakeNotifier(ChannelWebhook, Permanent(errors.New("webhook responded 404 Not Found")))
+	uc := NewUseCase(repo, []Notifier{push, email, webhook})
+	uc.now = func() time.Time { return now }
+
+	notification := Notification{Id: 10, UserId: 3, PlantId: 2, Title: "Watering Reminder", Body: "Time to water"}
+	repo.On("ClaimDeliveries", now, deliveryBatch, now.Add(deliveryLease), 0).Return([]NotificationDelivery{
+		{ID: 1, NotificationID: 10, Notification: notification, UserID: 3, Channel: ChannelPush, Attempts: 1},
+		{ID: 2, NotificationID: 10, Notification: notification, UserID: 3, Channel: ChannelEmail, Attempts: maxDeliveryAttempts},
+		{ID: 3, NotificationID: 10, Notification: notification, UserID: 3, Channel: ChannelWebhook, Attempts: 1},
+	}, nil)
+	repo.On("UpdateDelivery", mock.Anything).Return(nil)
+	var letters []DeadLetter
+	repo.On("DeadLetterDelivery", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		assert.Equal(t, DeliveryDead, args.Get(0).(*NotificationDelivery).Status)
+		letters = append(letters, *args.Get(1).(*DeadLetter))
+	}).Return(nil)
+
+	n, err := uc.RetryDeliveries()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	skipped := repo.Calls[1].Arguments.Get(0).(*NotificationDelivery)
+	assert.Equal(t, DeliverySkipped, skipped.Status)
+	assert.Nil(t, skipped.NextAttemptAt)
+
+	assert.Len(t, letters, 2)
+	assert.Equal(t, ChannelEmail, letters[0].Channel)
+	assert.Equal(t, maxDeliveryAttempts, letters[0].Attempts)
+	assert.Equal(t, "smtp unavailable", letters[0].Error)
+	assert.Equal(t, ChannelWebhook, letters[1].Channel)
+	assert.Equal(t, 1, letters[1].Attempts)
+	assert.Equal(t, "Time to water", letters[1].Body)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, backoff(1))
+	assert.Equal(t, time.Minute, backoff(2))
+	assert.Equal(t, 4*time.Minute, backoff(4))
+	assert.Equal(t, maxDeliveryBackoff, backoff(20))
+}
+
+func TestTimezoneChangedKeepsTimeOfDay(t *testing.T) {
+	repo := new(MockRepository)
+	uc := newTestUseCase(repo, time.Date(2024, time.January, 10, 12, 0, 0, 0, jakarta))
+	next := time.Date(2024, time.January, 11, 7, 0, 0, 0, jakarta)
+	pausedNext := next
+	pausedAt := time.Date(2024, time.January, 9, 0, 0, 0, 0, jakarta)
+	endAt := time.Date(2024, time.January, 31, 23, 59, 59, 0, jakarta)
+
+	repo.On("GetCustomizeWateringReminders", uint(1)).Return([]CustomizeWateringReminder{
+		{Id: 1, UserId: 1, RRule: "FREQ=DAILY", StartAt: time.Date(2024, time.January, 1, 7, 0, 0, 0, jakarta), EndAt: &endAt, NextRunAt: &next},
+		{Id: 2, UserId: 1, RRule: "FREQ=DAILY", StartAt: time.Date(2024, time.January, 1, 7, 0, 0, 0, jakarta), NextRunAt: &pausedNext, PausedAt: &pausedAt},
+	}, nil)
+	var moved []CustomizeWateringReminder
+	repo.On("UpdateCustomizeWateringReminder", mock.Anything).Run(func(args mock.Arguments) {
+		moved = append(moved, *args.Get(0).(*CustomizeWateringReminder))
+	}).Return(nil)
+	repo.On("DeletePendingReminderJobs", JobCustomReminder, mock.Anything).Return(nil)
+
+	err := uc.TimezoneChanged(1, "Asia/Makassar")
+
+	assert.NoError(t, err)
+	assert.Len(t, moved, 2)
+	assert.Equal(t, "Asia/Makassar", moved[0].Timezone)
+	assert.True(t, moved[0].StartAt.Equal(time.Date(2024, time.January, 1, 7, 0, 0, 0, makassar)))
+	assert.True(t, moved[0].EndAt.Equal(time.Date(2024, time.January, 31, 23, 59, 59, 0, makassar)))
+	assert.True(t, moved[0].NextRunAt.Equal(time.Date(2024, time.January, 11, 7, 0, 0, 0, makassar)))
+	// Paused reminders get their next run when they are resumed.
+	assert.True(t, moved[1].NextRunAt.Equal(next))
+}
+
+// stubForecaster answers every location with the same forecast.
+type stubForecaster struct {
+	hourly []weather.HourlyWeather
+	err    error
+}
+
+func (f stubForecaster) GetHourlyWeatherByCoordinates(lat, lon float64) ([]weather.HourlyWeather, error) {
+	return f.hourly, f.err
+}
+
+// forecast returns hours of weather from start, a wall clock time marked as
+// UTC like the forecasts of the weather service.
+func forecast(start time.Time, hours ...weather.HourlyWeather) []weather.HourlyWeather {
+	for i := range hours {
+		hours[i].Timestamp = start.Add(time.Duration(i) * time.Hour)
+	}
+	return hours
+}
+
+func TestAdviseWatering(t *testing.T) {
+	now := time.Date(2024, time.January, 5, 7, 30, 0, 0, jakarta)
+	start := time.Date(2024, time.January, 5, 7, 0, 0, 0, time.UTC)
+	schedule := plant.PlantReminder{WateringAmount: 200, Unit: "ml", WeatherCondition: "rain", ConditionDescription: "Keep the pot out of the rain."}
+	dry := weather.HourlyWeather{Main: "Clouds", Temperature: 30}
+	shower := weather.HourlyWeather{Main: "Rain", Temperature: 27, Rain: 1.5, RainChance: 0.9}
+	storm := weather.HourlyWeather{Main: "Thunderstorm", Temperature: 26, Rain: 4, RainChance: 0.9}
+	hot := weather.HourlyWeather{Main: "Clear", Temperature: 36.4}
+
+	// Enough rain waters outdoor plants.
+	advice := adviseWatering(forecast(start, dry, storm, shower, dry), now, jakarta, true, true, schedule)
+	assert.True(t, advice.Skip)
+	assert.Equal(t, 5.5, advice.Rain)
+	assert.True(t, advice.RainUntil.Equal(time.Date(2024, time.January, 5, 10, 0, 0, 0, jakarta)))
+	// Rain beyond the window does not count.
+	hours := forecast(start.Add(-time.Hour), storm, dry, dry, dry, dry, dry, dry, storm)
+	assert.Nil(t, adviseWatering(hours, now, jakarta, true, true, schedule))
+
+	// Rain falling now holds the reminder back until it stops.
+	advice = adviseWatering(forecast(start, shower, shower, dry), now, jakarta, true, true, schedule)
+	assert.False(t, advice.Skip)
+	assert.True(t, advice.PostponeUntil.Equal(time.Date(2024, time.January, 5, 9, 0, 0, 0, jakarta)))
+	assert.Equal(t, "Keep the pot out of the rain.", advice.Note)
+	// Only once, and not for rain that lasts too long.
+	advice = adviseWatering(forecast(start, shower, shower, dry), now, jakarta, true, false, schedule)
+	assert.Nil(t, advice.PostponeUntil)
+	drizzle := weather.HourlyWeather{Main: "Drizzle", Temperature: 27, Rain: 0.6, RainChance: 0.9}
+	advice = adviseWatering(forecast(start, drizzle, drizzle, drizzle, drizzle, dry), now, jakarta, true, true, schedule)
+	assert.Nil(t, advice)
+
+	// Indoor plants do not get the rain.
+	assert.Nil(t, adviseWatering(forecast(start, storm, storm, dry), now, jakarta, false, true, schedule))
+
+	advice = adviseWatering(forecast(start, dry, hot, dry), now, jakarta, false, true, schedule)
+	assert.True(t, advice.Heat)
+	assert.Equal(t, 36.4, advice.MaxTemp)
+	assert.Equal(t, 250, advice.Amount)
+	assert.Empty(t, advice.Note)
+
+	assert.Nil(t, adviseWatering(forecast(start, dry, dry), now, jakarta, true, true, schedule))
+	assert.Nil(t, adviseWatering(nil, now, jakarta, true, true, schedule))
+}
+
+func TestClaimReminderJobsFollowsForecast(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 7, 0, 0, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	start := time.Date(2024, time.January, 5, 7, 0, 0, 0, time.UTC)
+	shower := weather.HourlyWeather{Main: "Rain", Temperature: 27, Rain: 1.5, RainChance: 0.9}
+	hot := weather.HourlyWeather{Main: "Clear", Temperature: 35.2}
+	uc.SetForecaster(stubForecaster{hourly: forecast(start, shower, shower, hot)})
+
+	lat, lon := -6.2088, 106.8456
+	owner := user.User{ID: 1, Name: "Budi", Timezone: "Asia/Jakarta", Latitude: &lat, Longitude: &lon}
+	schedule := plant.PlantReminder{
+		ID:             8,
+		Each:           plant.PeriodDay,
+		Times:          []plant.PlantReminderTime{{Time: "07:00"}},
+		WateringAmount: 200,
+		Unit:           "ml",
+		CreatedAt:      time.Date(2024, time.January, 1, 0, 0, 0, 0, jakarta),
+	}
+	monstera := plant.Plant{ID: 5, Name: "Monstera", WateringSchedule: schedule}
+	aloe := plant.Plant{ID: 6, Name: "Aloe", WateringSchedule: schedule}
+
+	repo.On("ClaimReminderJobs", now, jobBatch, now.Add(jobLease)).Return([]ReminderJob{
+		{ID: 1, Kind: JobPlantSchedule, SourceID: 8, UserID: 1, User: owner, PlantID: 5, Plant: monstera, DueAt: now},
+		{ID: 2, Kind: JobPlantSchedule, SourceID: 8, UserID: 1, User: owner, PlantID: 6, Plant: aloe, DueAt: now},
+		{ID: 3, Kind: JobPlantSchedule, SourceID: 8, UserID: 1, User: owner, PlantID: 5, Plant: monstera, DueAt: now, RainDelayed: true},
+	}, nil)
+	repo.On("UserHasPlant", 1, mock.Anything).Return(true, nil)
+	repo.On("GetReminderSkip", mock.Anything, mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetNotificationPreferences", uint(1)).Return([]NotificationPreference(nil), nil)
+	repo.On("GetQuietHours", uint(1)).Return([]QuietHours(nil), nil)
+	repo.On("IsOutdoorPlant", 1, 5).Return(true, nil)
+	repo.On("IsOutdoorPlant", 1, 6).Return(false, nil)
+	updated := map[int]ReminderJob{}
+	repo.On("UpdateReminderJob", mock.Anything).Run(func(args mock.Arguments) {
+		job := args.Get(0).(*ReminderJob)
+		updated[job.ID] = *job
+	}).Return(nil)
+
+	jobs, err := uc.ClaimReminderJobs()
+
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+	// The outdoor plant waits for the rain to stop.
+	assert.Equal(t, JobPending, updated[1].Status)
+	assert.True(t, updated[1].RainDelayed)
+	assert.True(t, updated[1].NotBefore.Equal(time.Date(2024, time.January, 5, 9, 0, 0, 0, jakarta)))
+
+	notification, err := uc.reminderNotification(&jobs[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "Hiii Budi, It's time to water your plant: Aloe. It will be hot, up to 35°C, so give it 250 ml instead of 200 ml", notification.Body)
+	notification, err = uc.reminderNotification(&jobs[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "Hiii Budi, It's time to water your plant: Monstera. It was held back until the rain stopped. It will be hot, up to 35°C, so give it 250 ml instead of 200 ml", notification.Body)
+}
+
+func TestClaimReminderJobsSkipsForRain(t *testing.T) {
+	repo := new(MockRepository)
+	now := time.Date(2024, time.January, 5, 7, 0, 0, 0, jakarta)
+	uc := newTestUseCase(repo, now)
+	start := time.Date(2024, time.January, 5, 7, 0, 0, 0, time.UTC)
+	uc.SetForecaster(stubForecaster{hourly: forecast(start,
+		weather.HourlyWeather{Main: "Clouds"},
+		weather.HourlyWeather{Main: "Rain", Rain: 3, RainChance: 0.8},
+		weather.HourlyWeather{Main: "Rain", Rain: 2.4, RainChance: 0.7},
+	)})
+
+	lat, lon := -6.2088, 106.8456
+	owner := user.User{ID: 1, Name: "Budi", Timezone: "Asia/Jakarta", Latitude: &lat, Longitude: &lon}
+	job := ReminderJob{ID: 1, Kind: JobCustomReminder, SourceID: 4, UserID: 1, User: owner, PlantID: 5, Plant: plant.Plant{ID: 5, Name: "Monstera"}, DueAt: now}
+	repo.On("ClaimReminderJobs", now, jobBatch, now.Add(jobLease)).Return([]ReminderJob{job}, nil)
+	repo.On("GetCustomizeWateringReminder", 4).Return(&CustomizeWateringReminder{Id: 4}, nil)
+	repo.On("GetReminderSkip", 1, 5).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetNotificationPreferences", uint(1)).Return([]NotificationPreference(nil), nil)
+	repo.On("GetQuietHours", uint(1)).Return([]QuietHours(nil), nil)
+	repo.On("IsOutdoorPlant", 1, 5).Return(true, nil)
+
+	jobs, err := uc.ClaimReminderJobs()
+
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	notification, err := uc.reminderNotification(&jobs[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "No Watering Needed", notification.Title)
+	assert.Equal(t, CategoryCustomReminder, notification.Category)
+	assert.Equal(t, "Hiii Budi, about 5 mm of rain is expected until 10:00, so your plant Monstera does not need watering now", notification.Body)
+
+	// Without a forecast the reminder is sent as planned.
+	uc.SetForecaster(stubForecaster{err: errors.New("provider down")})
+	jobs, err = uc.ClaimReminderJobs()
+	assert.NoError(t, err)
+	notification, err = uc.reminderNotification(&jobs[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "Customize Watering Reminder", notification.Title)
+}
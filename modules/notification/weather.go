@@ -0,0 +1,167 @@
+package notification
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+	"github.com/OctavianoRyan25/be-agriculture/modules/weather"
+)
+
+// Forecaster returns the hourly weather forecast at a location, like
+// weather.WeatherService.
+type Forecaster interface {
+	GetHourlyWeatherByCoordinates(lat, lon float64) ([]weather.HourlyWeather, error)
+}
+
+const (
+	// rainWindow is how far ahead the forecast is read when a watering
+	// reminder is sent.
+	rainWindow = 6 * time.Hour
+	// skipRain is the rain in mm over rainWindow that waters an outdoor
+	// plant instead of the user.
+	skipRain = 5.0
+	// An hour is rainy when at least rainyHour mm of rain are expected with
+	// a chance of at least rainyChance.
+	rainyHour   = 0.5
+	rainyChance = 0.5
+	// maxRainDelay is how long a reminder waits at most for the rain that
+	// falls when it is due to stop.
+	maxRainDelay = 3 * time.Hour
+	// From heatWave °C plants get heatExtra more water than usual.
+	heatWave  = 35.0
+	heatExtra = 0.25
+)
+
+// wateringAdvice is how the weather changes a watering reminder. Skip means
+// about Rain mm of rain are expected until RainUntil, enough for outdoor
+// plants. PostponeUntil is when the rain falling now stops. Heat means it
+// gets as hot as MaxTemp, and Amount is the amount of water to give instead
+// of the one of the schedule. Note is the ConditionDescription of the
+// schedule when the weather is its WeatherCondition.
+type wateringAdvice struct {
+	Skip          bool
+	Rain          float64
+	RainUntil     time.Time
+	PostponeUntil *time.Time
+	Heat          bool
+	MaxTemp       float64
+	Amount        int
+	Note          string
+}
+
+// SetForecaster makes watering reminders of users with a saved location
+// follow the weather forecast. Without it reminders are sent as planned.
+func (u *notificationUseCase) SetForecaster(forecaster Forecaster) {
+	u.forecaster = forecaster
+}
+
+// adviseJob returns how the weather changes a watering reminder sent now,
+// or nil when it does not. A reminder postponed for the rain once is not
+// postponed again. Reminders are sent as planned when the forecast fails.
+func (u *notificationUseCase) adviseJob(job *ReminderJob, now time.Time) (*wateringAdvice, error) {
+	if u.forecaster == nil || !job.User.HasLocation() {
+		return nil, nil
+	}
+	if job.Kind != JobPlantSchedule && job.Kind != JobCustomReminder {
+		return nil, nil
+	}
+
+	outdoor, err := u.notificationRepo.IsOutdoorPlant(job.UserID, job.PlantID)
+	if err != nil {
+		return nil, err
+	}
+	hourly, err := u.forecaster.GetHourlyWeatherByCoordinates(*job.User.Latitude, *job.User.Longitude)
+	if err != nil {
+		log.Printf("Failed to get the forecast for reminder job %d: %v", job.ID, err)
+		return nil, nil
+	}
+	return adviseWatering(hourly, now, job.User.Location(), outdoor, !job.RainDelayed, job.Plant.WateringSchedule), nil
+}
+
+// adviseWatering reads the forecast for the rainWindow from now. Forecast
+// times are the wall clock of the location marked as UTC, they are read in
+// loc. Rain only matters for outdoor plants.
+func adviseWatering(hourly []weather.HourlyWeather, now time.Time, loc *time.Location, outdoor, canPostpone bool, schedule plant.PlantReminder) *wateringAdvice {
+	from := sameWallClock(now, loc, time.UTC)
+	until := from.Add(rainWindow)
+	var hours []weather.HourlyWeather
+	for _, h := range hourly {
+		if h.Timestamp.Add(time.Hour).After(from) && h.Timestamp.Before(until) {
+			hours = append(hours, h)
+		}
+	}
+	if len(hours) == 0 {
+		return nil
+	}
+
+	advice := &wateringAdvice{MaxTemp: hours[0].Temperature}
+	for _, h := range hours {
+		advice.Rain += h.Rain
+		if h.Rain > 0 {
+			advice.RainUntil = sameWallClock(h.Timestamp.Add(time.Hour), time.UTC, loc)
+		}
+		advice.MaxTemp = math.Max(advice.MaxTemp, h.Temperature)
+	}
+	if schedule.WeatherCondition != "" && strings.EqualFold(hours[0].Main, schedule.WeatherCondition) {
+		advice.Note = schedule.ConditionDescription
+	}
+
+	if outdoor && advice.Rain >= skipRain {
+		advice.Skip = true
+		return advice
+	}
+	if outdoor && canPostpone {
+		rainy := 0
+		for rainy < len(hours) && hours[rainy].Rain >= rainyHour && hours[rainy].RainChance >= rainyChance {
+			rainy++
+		}
+		if rainy > 0 && rainy < len(hours) {
+			dry := sameWallClock(hours[rainy].Timestamp, time.UTC, loc)
+			if dry.Sub(now) <= maxRainDelay {
+				advice.PostponeUntil = &dry
+				return advice
+			}
+		}
+	}
+	if advice.MaxTemp >= heatWave {
+		advice.Heat = true
+		advice.Amount = int(math.Round(float64(schedule.WateringAmount) * (1 + heatExtra)))
+	}
+
+	if !advice.Heat && advice.Note == "" {
+		return nil
+	}
+	return advice
+}
+
+// adviseNotification tells the user in a reminder how the weather changed
+// it.
+func adviseNotification(notification *Notification, job *ReminderJob) {
+	if job.RainDelayed {
+		notification.Body += ". It was held back until the rain stopped"
+	}
+	advice := job.advice
+	if advice == nil {
+		return
+	}
+
+	schedule := job.Plant.WateringSchedule
+	switch {
+	case advice.Skip:
+		notification.Title = "No Watering Needed"
+		notification.Body = fmt.Sprintf("Hiii %s, about %.0f mm of rain is expected until %s, so your plant %s does not need watering now",
+			job.User.Name, advice.Rain, advice.RainUntil.Format("15:04"), job.Plant.Name)
+	case advice.Heat && advice.Amount > 0:
+		notification.Body += fmt.Sprintf(". It will be hot, up to %.0f°C, so give it %d %s instead of %d %s",
+			advice.MaxTemp, advice.Amount, schedule.Unit, schedule.WateringAmount, schedule.Unit)
+	case advice.Heat:
+		notification.Body += fmt.Sprintf(". It will be hot, up to %.0f°C, so give it a little more water than usual", advice.MaxTemp)
+	}
+	if advice.Note != "" {
+		notification.Body += ". " + strings.TrimSuffix(advice.Note, ".")
+	}
+}
@@ -0,0 +1,90 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier posts messages as JSON to a URL. With a secret, the body
+// is signed in the X-Signature-256 header as "sha256=" and the hex HMAC of
+// the body, so the receiver can check it came from this server.
+type WebhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type webhookPayload struct {
+	NotificationID int      `json:"notification_id"`
+	UserID         int      `json:"user_id"`
+	Email          string   `json:"email"`
+	PlantID        int      `json:"plant_id"`
+	Category       string   `json:"category"`
+	Title          string   `json:"title"`
+	Body           string   `json:"body"`
+	Actions        []string `json:"actions"`
+}
+
+func (n *WebhookNotifier) Channel() string {
+	return ChannelWebhook
+}
+
+// Notify posts the message. Client errors other than timeouts and rate
+// limits are permanent, the same request would be rejected again.
+func (n *WebhookNotifier) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(webhookPayload{
+		NotificationID: msg.NotificationID,
+		UserID:         msg.User.ID,
+		Email:          msg.User.Email,
+		PlantID:        msg.PlantID,
+		Category:       msg.Category,
+		Title:          msg.Title,
+		Body:           msg.Body,
+		Actions:        msg.Actions,
+	})
+	if err != nil {
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook responded %s", res.Status)
+	if res.StatusCode >= 400 && res.StatusCode < 500 &&
+		res.StatusCode != http.StatusRequestTimeout && res.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
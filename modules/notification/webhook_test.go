@@ -0,0 +1,68 @@
+package notification
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var payload webhookPayload
+	var signature string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		json.Unmarshal(body, &payload)
+		signature = r.Header.Get("X-Signature-256")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	n := NewWebhookNotifier(server.URL, "secret")
+	err := n.Notify(context.Background(), Message{
+		NotificationID: 10,
+		User:           user.User{ID: 3, Email: "budi@example.com"},
+		PlantID:        2,
+		Title:          "Watering Reminder",
+		Body:           "Time to water",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 10, payload.NotificationID)
+	assert.Equal(t, "budi@example.com", payload.Email)
+	assert.Equal(t, "Time to water", payload.Body)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signature)
+}
+
+func TestWebhookNotifierErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusGone, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusBadGateway, false},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+
+		err := NewWebhookNotifier(server.URL, "").Notify(context.Background(), Message{})
+		assert.Error(t, err, tt.status)
+		assert.Equal(t, tt.permanent, IsPermanent(err), tt.status)
+		server.Close()
+	}
+}
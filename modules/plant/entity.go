@@ -47,18 +47,33 @@ type PlantCharacteristic struct {
 	LeafColor  string `json:"leaf_color"`
 }
 
+// PlantReminder is the watering schedule of a plant: at each of Times, every
+// Interval days, weeks or months. Weekly schedules may be limited to some
+// Weekdays, stored as short names like "mon,thu". LegacyWateringTime keeps
+// the free-text times of a schedule from before Times that could not be
+// read, until an admin saves the schedule again.
 type PlantReminder struct {
-	ID                   int       `json:"id" gorm:"primaryKey"`
-	PlantID              int       `json:"plant_id"`
-	WateringFrequency    int       `json:"watering_frequency"`
-	Each                 string    `json:"each"`
-	WateringAmount       int       `json:"watering_amount"`
-	Unit                 string    `json:"unit"`
-	WateringTime         string    `json:"watering_time"`
-	WeatherCondition     string    `json:"weather_condition"`
-	ConditionDescription string    `json:"condition_description"`
-	CreatedAt            time.Time `json:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
+	ID                   int                 `json:"id" gorm:"primaryKey"`
+	PlantID              int                 `json:"plant_id"`
+	WateringFrequency    int                 `json:"watering_frequency"`
+	Each                 WateringPeriod      `json:"each"`
+	Interval             int                 `json:"interval" gorm:"default:1"`
+	Weekdays             string              `json:"weekdays" gorm:"size:27"`
+	WateringAmount       int                 `json:"watering_amount"`
+	Unit                 string              `json:"unit"`
+	Times                []PlantReminderTime `json:"times" gorm:"foreignKey:PlantReminderID;constraint:OnDelete:CASCADE"`
+	WeatherCondition     string              `json:"weather_condition"`
+	ConditionDescription string              `json:"condition_description"`
+	LegacyWateringTime   string              `json:"legacy_watering_time,omitempty" gorm:"type:text"`
+	CreatedAt            time.Time           `json:"created_at"`
+	UpdatedAt            time.Time           `json:"updated_at"`
+}
+
+// PlantReminderTime is one time of day of a watering schedule, as "15:04".
+type PlantReminderTime struct {
+	ID              int    `json:"id" gorm:"primaryKey"`
+	PlantReminderID int    `json:"plant_reminder_id" gorm:"index"`
+	Time            string `json:"time" gorm:"size:5;index"`
 }
 
 type PlantInstructionCategory struct {
@@ -109,6 +124,8 @@ type PlantCategory struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// UserPlant is a plant a user grows. Outdoor plants get rain, so their
+// watering reminders are skipped or postponed when enough rain is expected.
 type UserPlant struct {
 	ID                   int       `json:"id" gorm:"primaryKey"`
 	UserID               int       `json:"user_id"`
@@ -118,6 +135,7 @@ type UserPlant struct {
 	InstructionCategory2 int       `json:"instruction_category_2" gorm:"default:0"`
 	InstructionCategory3 int       `json:"instruction_category_3" gorm:"default:0"`
 	InstructionCategory4 int       `json:"instruction_category_4" gorm:"default:0"`
+	Outdoor              bool      `json:"outdoor" gorm:"not null;default:false"`
 	CreatedAt            time.Time `json:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at"`
 
@@ -18,6 +18,6 @@ func NewPlantEarliestWateringRepository(db *gorm.DB) PlantEarliestWateringReposi
 // GetEarliestWatering implements PlantEarliestWateringRepository.
 func (r *plantEarliestWateringRepository) GetEarliestWatering() ([]PlantReminder, error) {
 	var schedule []PlantReminder
-	err := r.db.Find(&schedule).Error
+	err := r.db.Preload("Times").Find(&schedule).Error
 	return schedule, err
 }
\ No newline at end of file
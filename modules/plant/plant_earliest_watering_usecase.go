@@ -29,27 +29,28 @@ func (s *plantEarliestWateringService) FindEarliestWateringTime() ([]PlantRemind
 		return nil, err
 	}
 
-    if len(schedules) == 0 {
-        return []PlantReminderResponse{}, fmt.Errorf("no schedules provided")
-    }
-
-    earliestSchedule := schedules[0]
-    earliestTime, err := ConvertToTime(earliestSchedule.WateringTime)
-    if err != nil {
-        return []PlantReminderResponse{}, err
-    }
+    var earliestSchedule *PlantReminder
+    var earliestTime time.Time
+    for i, schedule := range schedules {
+        times := schedule.WateringTimes()
+        if len(times) == 0 {
+            continue
+        }
 
-    for _, schedule := range schedules[1:] {
-        currentTime, err := ConvertToTime(schedule.WateringTime)
+        // Times are sorted, so the first one is the earliest of the schedule.
+        currentTime, err := ConvertToTime(times[0])
         if err != nil {
             return []PlantReminderResponse{}, err
         }
 
-        if currentTime.Before(earliestTime) {
+        if earliestSchedule == nil || currentTime.Before(earliestTime) {
             earliestTime = currentTime
-            earliestSchedule = schedule
+            earliestSchedule = &schedules[i]
         }
     }
-    response := NewPlantReminderResponse(earliestSchedule)
+    if earliestSchedule == nil {
+        return []PlantReminderResponse{}, fmt.Errorf("no schedules provided")
+    }
+    response := NewPlantReminderResponse(*earliestSchedule)
     return []PlantReminderResponse{response}, nil
 }
@@ -16,6 +16,7 @@ type PlantRepository interface {
 	ClearPlantInstructions(plantID int) error
 	ClearPlantFAQs(plantID int) error
 	ClearPlantImages(plantID int) error
+	ClearWateringSchedule(plantID int) error
 	FindRecommendations(userID int, plants *[]Plant) *gorm.DB
 	FindByCategoryID(categoryID int, plants *[]Plant) *gorm.DB
 	CategoryExists(categoryID int) (bool, error)
@@ -33,7 +34,7 @@ func (r *plantRepository) FindRecommendations(userID int, plants *[]Plant) *gorm
 	subQuery := r.db.Table("user_plants").Select("plant_id").Where("user_id = ?", userID)
 	return r.db.Preload("PlantCategory").
 			Preload("PlantCharacteristic").
-			Preload("WateringSchedule").
+			Preload("WateringSchedule.Times").
 			Preload("PlantInstructions").
 			Preload("PlantInstructions.InstructionCategory").
 			Preload("PlantFAQs").
@@ -45,7 +46,7 @@ func (r *plantRepository) FindRecommendations(userID int, plants *[]Plant) *gorm
 func (r *plantRepository) FindByCategoryID(categoryID int, plants *[]Plant) *gorm.DB {
 	return r.db.Preload("PlantCategory").
 			Preload("PlantCharacteristic").
-			Preload("WateringSchedule").
+			Preload("WateringSchedule.Times").
 			Preload("PlantInstructions").
 			Preload("PlantInstructions.InstructionCategory").
 			Preload("PlantFAQs").
@@ -68,11 +69,11 @@ func (r *plantRepository) FindAll(page, limit int) ([]Plant, error) {
 	var err error
 	if page > 0 && limit > 0 {
 			offset := (page - 1) * limit
-			err = r.db.Preload("PlantCategory").Preload("PlantCharacteristic").Preload("WateringSchedule").
+			err = r.db.Preload("PlantCategory").Preload("PlantCharacteristic").Preload("WateringSchedule.Times").
 					Preload("PlantInstructions").Preload("PlantInstructions.InstructionCategory").Preload("PlantFAQs").Preload("PlantImages").
 					Offset(offset).Limit(limit).Find(&plants).Error
 	} else {
-			err = r.db.Preload("PlantCategory").Preload("PlantCharacteristic").Preload("WateringSchedule").
+			err = r.db.Preload("PlantCategory").Preload("PlantCharacteristic").Preload("WateringSchedule.Times").
 					Preload("PlantInstructions").Preload("PlantInstructions.InstructionCategory").Preload("PlantFAQs").Preload("PlantImages").
 					Find(&plants).Error
 	}
@@ -81,7 +82,7 @@ func (r *plantRepository) FindAll(page, limit int) ([]Plant, error) {
 
 func (r *plantRepository) FindByID(id int) (Plant, error) {
 	var plant Plant
-	err := r.db.Preload("PlantCategory").Preload("PlantCharacteristic").Preload("WateringSchedule").
+	err := r.db.Preload("PlantCategory").Preload("PlantCharacteristic").Preload("WateringSchedule.Times").
 		Preload("PlantInstructions").Preload("PlantInstructions.InstructionCategory").Preload("PlantFAQs").Preload("PlantImages").First(&plant, id).Error
 	return plant, err
 }
@@ -100,7 +101,7 @@ func (r *plantRepository) FindByIDWithRelations(id int) (Plant, error) {
 	var plant Plant
 	err := r.db.Preload("PlantCategory").
 			Preload("PlantCharacteristic").
-			Preload("WateringSchedule").
+			Preload("WateringSchedule.Times").
 			Preload("PlantInstructions").
 			Preload("PlantInstructions.InstructionCategory").
 			Preload("PlantFAQs").
@@ -128,7 +129,7 @@ func (r *plantRepository) SearchPlantsByName(name string, limit, offset int) ([]
 	var plants []Plant
 	err := r.db.Preload("PlantCategory").
 		Preload("PlantCharacteristic").
-		Preload("WateringSchedule").
+		Preload("WateringSchedule.Times").
 		Preload("PlantInstructions").
 		Preload("PlantInstructions.InstructionCategory").
 		Preload("PlantFAQs").
@@ -144,7 +145,7 @@ func (r *plantRepository) FindEarliestWateringTime(name string, limit, offset in
 	var plants []Plant
 	err := r.db.Preload("PlantCategory").
 		Preload("PlantCharacteristic").
-		Preload("WateringSchedule").
+		Preload("WateringSchedule.Times").
 		Preload("PlantInstructions").
 		Preload("PlantInstructions.InstructionCategory").
 		Preload("PlantFAQs").
@@ -176,4 +177,14 @@ func (r *plantRepository) ClearPlantImages(plantID int) error {
 	return r.db.Where("plant_id = ?", plantID).Delete(&PlantImage{}).Error
 }
 
+func (r *plantRepository) ClearWateringSchedule(plantID int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		reminders := tx.Model(&PlantReminder{}).Select("id").Where("plant_id = ?", plantID)
+		if err := tx.Where("plant_reminder_id IN (?)", reminders).Delete(&PlantReminderTime{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("plant_id = ?", plantID).Delete(&PlantReminder{}).Error
+	})
+}
+
 
@@ -112,15 +112,7 @@ func (s *plantService) CreatePlant(input CreatePlantInput) (PlantResponse, error
 			WideUnit:   input.PlantCharacteristic.WideUnit,
 			LeafColor:  input.PlantCharacteristic.LeafColor,
 		},
-		WateringSchedule: PlantReminder{
-			WateringFrequency:   input.WateringSchedule.WateringFrequency,
-			Each:                input.WateringSchedule.Each,
-			WateringAmount:      input.WateringSchedule.WateringAmount,
-			Unit:                input.WateringSchedule.Unit,
-			WateringTime:        input.WateringSchedule.WateringTime,
-			WeatherCondition:    input.WateringSchedule.WeatherCondition,
-			ConditionDescription: input.WateringSchedule.ConditionDescription,
-		},
+		WateringSchedule: NewPlantReminder(input.WateringSchedule),
 	}
 
 	for i, instruction := range input.PlantInstructions {
@@ -195,17 +187,12 @@ func (s *plantService) UpdatePlant(id int, input UpdatePlantInput) (PlantRespons
 	}
 
 	// Update WateringSchedule
-	plant.WateringSchedule = PlantReminder{
-		WateringFrequency:    input.WateringSchedule.WateringFrequency,
-		Each:                 input.WateringSchedule.Each,
-		WateringAmount:       input.WateringSchedule.WateringAmount,
-		Unit:                 input.WateringSchedule.Unit,
-		WateringTime:         input.WateringSchedule.WateringTime,
-		WeatherCondition:     input.WateringSchedule.WeatherCondition,
-		ConditionDescription: input.WateringSchedule.ConditionDescription,
-	}
+	plant.WateringSchedule = NewPlantReminder(input.WateringSchedule)
 
-	// Clear existing instructions, FAQs, and images
+	// Clear existing watering schedule, instructions, FAQs, and images
+	if err := s.repository.ClearWateringSchedule(id); err != nil {
+		return PlantResponse{}, err
+	}
 	if err := s.repository.ClearPlantInstructions(id); err != nil {
 		return PlantResponse{}, err
 	}
@@ -18,6 +18,7 @@ type UserPlantRepository interface {
 	GetUserPlantHistoryByUserID(userID int) ([]UserPlantHistory, error)
 	CheckPlantExists(plantID int) (bool, error)
 	UpdateCustomizeName(userPlantID int, customizeName string) error
+	UpdateOutdoor(userPlantID int, outdoor bool) error
 	CheckUserPlantExists(userPlantID int) (bool, error)
 	CheckUserPlantExistsForAdd(userID, plantID int) (bool, error)
 	UpdateInstructionCategory(userPlantID int, fieldToUpdate string) error
@@ -37,7 +38,7 @@ func (r *userPlantRepository) GetUserPlantByUserIDAndPlantID(userID int, plantID
 	err := r.db.Preload("Plant").
 	Preload("Plant.PlantCategory").
 	Preload("Plant.PlantCharacteristic").
-	Preload("Plant.WateringSchedule").
+	Preload("Plant.WateringSchedule.Times").
 	Preload("Plant.PlantInstructions").
 	Preload("Plant.PlantInstructions.InstructionCategory").
 	Preload("Plant.PlantFAQs").
@@ -73,6 +74,10 @@ func (r *userPlantRepository) UpdateCustomizeName(userPlantID int, customizeName
 	return nil
 }
 
+func (r *userPlantRepository) UpdateOutdoor(userPlantID int, outdoor bool) error {
+	return r.db.Model(&UserPlant{}).Where("id = ?", userPlantID).Update("outdoor", outdoor).Error
+}
+
 func (r *userPlantRepository) CheckPlantExists(plantID int) (bool, error) {
 	var count int64
 	if err := r.db.Model(&Plant{}).Where("id = ?", plantID).Count(&count).Error; err != nil {
@@ -143,7 +148,7 @@ func (r *userPlantRepository) AddUserPlant(userPlant UserPlant) (UserPlant, erro
 	err = r.db.Preload("Plant").
 		Preload("Plant.PlantCategory").
 		Preload("Plant.PlantCharacteristic").
-		Preload("Plant.WateringSchedule").
+		Preload("Plant.WateringSchedule.Times").
 		Preload("Plant.PlantInstructions").
 		Preload("Plant.PlantFAQs").
 		Preload("Plant.PlantImages").
@@ -157,7 +162,7 @@ func (r *userPlantRepository) GetUserPlantsByUserID(userID int, limit int, offse
 	query := r.db.Preload("Plant").
 		Preload("Plant.PlantCategory").
 		Preload("Plant.PlantCharacteristic").
-		Preload("Plant.WateringSchedule").
+		Preload("Plant.WateringSchedule.Times").
 		Preload("Plant.PlantInstructions").
 		Preload("Plant.PlantInstructions.InstructionCategory").
 		Preload("Plant.PlantFAQs").
@@ -185,7 +190,7 @@ func (r *userPlantRepository) GetUserPlantByID(userPlantID int) (UserPlant, erro
 	err := r.db.Preload("Plant").
 			Preload("Plant.PlantCategory").
 			Preload("Plant.PlantCharacteristic").
-			Preload("Plant.WateringSchedule").
+			Preload("Plant.WateringSchedule.Times").
 			Preload("Plant.PlantInstructions").
 			Preload("Plant.PlantFAQs").
 			Preload("Plant.PlantImages").
@@ -17,6 +17,7 @@ type UserPlantService interface {
 	CheckPlantExists(plantID int) (bool, error)
 	CheckUserPlantExists(userPlantID int) (bool, error)
 	UpdateCustomizeName(userPlantID int, customizeName string) (UserPlantResponse, error)
+	UpdateOutdoor(userPlantID int, outdoor bool) (UserPlantResponse, error)
 	CheckUserPlantExistsForAdd(userID, plantID int) (bool, error)
 	UpdateInstructionCategory(input UpdateInstructionCategoryInput) error
 	GetUserPlantByUserIDAndPlantID(userID int, plantID int) (UserPlantResponse, error)
@@ -146,6 +147,7 @@ func (s *userPlantService) AddUserPlant(input AddUserPlantInput) (UserPlantRespo
 		UserID:        input.UserID,
 		PlantID:       input.PlantID,
 		CustomizeName: input.CustomizeName,
+		Outdoor:       input.Outdoor,
 	}
 
 	createdUserPlant, err := s.repository.AddUserPlant(userPlant)
@@ -185,6 +187,28 @@ func (s *userPlantService) UpdateCustomizeName(userPlantID int, customizeName st
 	return NewUserPlantResponse(userPlant), nil
 }
 
+// UpdateOutdoor marks a plant of the user as growing outdoors or indoors.
+func (s *userPlantService) UpdateOutdoor(userPlantID int, outdoor bool) (UserPlantResponse, error) {
+	exists, err := s.CheckUserPlantExists(userPlantID)
+	if err != nil {
+		return UserPlantResponse{}, err
+	}
+	if !exists {
+		return UserPlantResponse{}, errors.New("user plant ID not found")
+	}
+
+	err = s.repository.UpdateOutdoor(userPlantID, outdoor)
+	if err != nil {
+		return UserPlantResponse{}, err
+	}
+
+	userPlant, err := s.repository.GetUserPlantByID(userPlantID)
+	if err != nil {
+		return UserPlantResponse{}, err
+	}
+	return NewUserPlantResponse(userPlant), nil
+}
+
 func (s *userPlantService) CheckUserPlantExists(userPlantID int) (bool, error) {
 	return s.repository.CheckUserPlantExists(userPlantID)
 }
@@ -51,13 +51,14 @@ type UpdateInstructionCategoryInput struct {
 }
 
 type CreateWateringScheduleInput struct {
-	WateringFrequency    int    `form:"watering_frequency" validate:"required"`
-	Each                 string `form:"each" validate:"required"`
-	WateringAmount       int    `form:"watering_amount" validate:"required"`
-	Unit                 string `form:"unit" validate:"required"`
-	WateringTime         string `form:"watering_time" validate:"required"`
-	WeatherCondition     string `form:"weather_condition"`
-	ConditionDescription string `form:"condition_description"`
+	Each                 WateringPeriod `form:"each" validate:"required,oneof=Day Week Month"`
+	Interval             int            `form:"interval" validate:"omitempty,min=1,max=365"`
+	Weekdays             []string       `form:"weekdays" validate:"excluded_unless=Each Week,dive,oneof=sun mon tue wed thu fri sat"`
+	WateringAmount       int            `form:"watering_amount" validate:"required"`
+	Unit                 string         `form:"unit" validate:"required"`
+	WateringTimes        []string       `form:"watering_time" validate:"required,min=1,max=24,dive,datetime=15:04"`
+	WeatherCondition     string         `form:"weather_condition"`
+	ConditionDescription string         `form:"condition_description"`
 }
 
 type CreatePlantCharacteristicInput struct {
@@ -90,6 +91,7 @@ type AddUserPlantInput struct {
 	UserID        int    `json:"user_id" form:"user_id"`
 	PlantID       int    `json:"plant_id" form:"plant_id" validate:"required"`
 	CustomizeName string `json:"customize_name" form:"customize_name"`
+	Outdoor       bool   `json:"outdoor" form:"outdoor"`
 }
 
 type PlantProgressInput struct {
@@ -1,6 +1,9 @@
 package plant
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type PlantResponse struct {
 	ID                  int                          `json:"id"`
@@ -50,15 +53,20 @@ type PlantImageResponse struct {
 }
 
 type PlantReminderResponse struct {
-	ID                   int    `json:"id"`
-	PlantID              int    `json:"plant_id"`
-	WateringFrequency    int    `json:"watering_frequency"`
-	Each                 string `json:"each"`
-	WateringAmount       int    `json:"watering_amount"`
-	Unit                 string `json:"unit"`
+	ID                int      `json:"id"`
+	PlantID           int      `json:"plant_id"`
+	WateringFrequency int      `json:"watering_frequency"`
+	Each              string   `json:"each"`
+	Interval          int      `json:"interval"`
+	Weekdays          []string `json:"weekdays"`
+	WateringAmount    int      `json:"watering_amount"`
+	Unit              string   `json:"unit"`
+	WateringTimes     []string `json:"watering_times"`
+	// WateringTime joins WateringTimes with ", " for older clients.
 	WateringTime         string `json:"watering_time"`
 	WeatherCondition     string `json:"weather_condition"`
 	ConditionDescription string `json:"condition_description"`
+	LegacyWateringTime   string `json:"legacy_watering_time,omitempty"`
 }
 
 type PlantInstructionResponse struct {
@@ -181,16 +189,21 @@ func NewPlantImageResponse(image PlantImage) PlantImageResponse {
 }
 
 func NewPlantReminderResponse(reminder PlantReminder) PlantReminderResponse {
+	times := reminder.WateringTimes()
 	return PlantReminderResponse{
 		ID:                   reminder.ID,
 		PlantID:              reminder.PlantID,
 		WateringFrequency:    reminder.WateringFrequency,
-		Each:                 reminder.Each,
+		Each:                 string(reminder.Each),
+		Interval:             reminder.Interval,
+		Weekdays:             reminder.WeekdayList(),
 		WateringAmount:       reminder.WateringAmount,
 		Unit:                 reminder.Unit,
-		WateringTime:         reminder.WateringTime,
+		WateringTimes:        times,
+		WateringTime:         strings.Join(times, ", "),
 		WeatherCondition:     reminder.WeatherCondition,
 		ConditionDescription: reminder.ConditionDescription,
+		LegacyWateringTime:   reminder.LegacyWateringTime,
 	}
 }
 
@@ -244,6 +257,7 @@ type UserPlantResponse struct {
 	InstructionCategory2 int           `json:"instruction_category_2"`
 	InstructionCategory3 int           `json:"instruction_category_3"`
 	InstructionCategory4 int           `json:"instruction_category_4"`
+	Outdoor              bool          `json:"outdoor"`
 	CreatedAt            time.Time     `json:"created_at"`
 }
 
@@ -256,6 +270,7 @@ func NewUserPlantResponse(userPlant UserPlant) UserPlantResponse {
 		InstructionCategory2: userPlant.InstructionCategory2,
 		InstructionCategory3: userPlant.InstructionCategory3,
 		InstructionCategory4: userPlant.InstructionCategory4,
+		Outdoor:              userPlant.Outdoor,
 		CreatedAt:            userPlant.CreatedAt,
 	}
 }
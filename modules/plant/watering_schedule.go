@@ -0,0 +1,235 @@
+package plant
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// WateringPeriod is the unit of the interval of a watering schedule.
+type WateringPeriod string
+
+const (
+	PeriodDay   WateringPeriod = "Day"
+	PeriodWeek  WateringPeriod = "Week"
+	PeriodMonth WateringPeriod = "Month"
+)
+
+// weekdayNames holds the short names accepted in PlantReminder.Weekdays,
+// indexed by time.Weekday.
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseWateringPeriod accepts the spellings admins commonly type, like "day",
+// "daily" or "Weeks". Anything else is returned unchanged so validation can
+// reject it.
+func ParseWateringPeriod(value string) WateringPeriod {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "day", "days", "daily":
+		return PeriodDay
+	case "week", "weeks", "weekly":
+		return PeriodWeek
+	case "month", "months", "monthly":
+		return PeriodMonth
+	}
+	return WateringPeriod(value)
+}
+
+// ParseWateringTimes splits watering times like "07:00, 17:00", "7:00,17:00"
+// or "07.00 17.00" into sorted, de-duplicated "15:04" values. Every value may
+// hold several times. Entries that are not a time of day are returned in
+// invalid.
+func ParseWateringTimes(values []string) (times []string, invalid []string) {
+	seen := map[string]bool{}
+	for _, value := range values {
+		entries := strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == ';' || unicode.IsSpace(r)
+		})
+		for _, entry := range entries {
+			parsed, err := time.Parse("15:04", strings.Replace(entry, ".", ":", 1))
+			if err != nil {
+				invalid = append(invalid, entry)
+				continue
+			}
+			normalized := parsed.Format("15:04")
+			if !seen[normalized] {
+				seen[normalized] = true
+				times = append(times, normalized)
+			}
+		}
+	}
+	sort.Strings(times)
+	return times, invalid
+}
+
+// ParseWeekdays turns weekday names like "Monday, wed" into their short
+// lower case form, ordered from Sunday. Unknown names are returned unchanged
+// so validation can reject them.
+func ParseWeekdays(values []string) []string {
+	var known, unknown []string
+	seen := map[int]bool{}
+	for _, value := range values {
+		for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			day := weekdayIndex(entry)
+			if day < 0 {
+				unknown = append(unknown, entry)
+				continue
+			}
+			seen[day] = true
+		}
+	}
+	for day, name := range weekdayNames {
+		if seen[day] {
+			known = append(known, name)
+		}
+	}
+	return append(known, unknown...)
+}
+
+func weekdayIndex(name string) int {
+	name = strings.ToLower(name)
+	if len(name) < 3 {
+		return -1
+	}
+	for day, short := range weekdayNames {
+		if strings.HasPrefix(name, short) {
+			return day
+		}
+	}
+	return -1
+}
+
+// WateringTimes returns the times of day of the reminder, e.g. "07:00".
+func (r PlantReminder) WateringTimes() []string {
+	times := make([]string, 0, len(r.Times))
+	for _, t := range r.Times {
+		times = append(times, t.Time)
+	}
+	sort.Strings(times)
+	return times
+}
+
+// WeekdayList returns the weekdays of a weekly reminder.
+func (r PlantReminder) WeekdayList() []string {
+	if r.Weekdays == "" {
+		return []string{}
+	}
+	return strings.Split(r.Weekdays, ",")
+}
+
+// IsDue reports whether the reminder fires in the minute of t. The schedule
+// counts its interval from the day the reminder was created: a reminder
+// every 2 weeks created on a Monday fires on Mondays of every other week,
+// and a monthly reminder fires on the day of the month it was created, or
+// the last day of shorter months.
+func (r PlantReminder) IsDue(t time.Time) bool {
+	clock := t.Format("15:04")
+	found := false
+	for _, wateringTime := range r.Times {
+		if wateringTime.Time == clock {
+			found = true
+			break
+		}
+	}
+	return found && r.isDueOn(t)
+}
+
+func (r PlantReminder) isDueOn(t time.Time) bool {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	start := r.CreatedAt.In(t.Location())
+
+	switch r.Each {
+	case PeriodDay:
+		return mod(civilDay(t)-civilDay(start), interval) == 0
+	case PeriodWeek:
+		weekdays := r.WeekdayList()
+		if len(weekdays) == 0 {
+			weekdays = []string{weekdayNames[start.Weekday()]}
+		}
+		onDay := false
+		for _, day := range weekdays {
+			if day == weekdayNames[t.Weekday()] {
+				onDay = true
+				break
+			}
+		}
+		weeks := (civilDay(t) - int64(t.Weekday()) - (civilDay(start) - int64(start.Weekday()))) / 7
+		return onDay && mod(weeks, interval) == 0
+	case PeriodMonth:
+		day := start.Day()
+		if last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day(); day > last {
+			day = last
+		}
+		months := int64(t.Year()*12+int(t.Month())) - int64(start.Year()*12+int(start.Month()))
+		return t.Day() == day && mod(months, interval) == 0
+	}
+	return false
+}
+
+// Occurrences returns the watering times of the reminder after after and up
+// to until, with the times of day read in loc.
+func (r PlantReminder) Occurrences(after, until time.Time, loc *time.Location) []time.Time {
+	var res []time.Time
+	first := after.In(loc)
+	last := until.In(loc)
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); !day.After(last); day = day.AddDate(0, 0, 1) {
+		if !r.isDueOn(day) {
+			continue
+		}
+		for _, clock := range r.WateringTimes() {
+			parsed, err := time.Parse("15:04", clock)
+			if err != nil {
+				continue
+			}
+			t := time.Date(day.Year(), day.Month(), day.Day(), parsed.Hour(), parsed.Minute(), 0, 0, loc)
+			if t.After(after) && !t.After(until) {
+				res = append(res, t)
+			}
+		}
+	}
+	return res
+}
+
+// civilDay numbers the calendar day of t, ignoring its time zone offset.
+func civilDay(t time.Time) int64 {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+}
+
+func mod(a int64, b int) int64 {
+	m := a % int64(b)
+	if m < 0 {
+		m += int64(b)
+	}
+	return m
+}
+
+// NewPlantReminder builds the watering schedule described by validated
+// admin input.
+func NewPlantReminder(input CreateWateringScheduleInput) PlantReminder {
+	interval := input.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	times := make([]PlantReminderTime, 0, len(input.WateringTimes))
+	for _, wateringTime := range input.WateringTimes {
+		times = append(times, PlantReminderTime{Time: wateringTime})
+	}
+
+	return PlantReminder{
+		WateringFrequency:    len(times),
+		Each:                 input.Each,
+		Interval:             interval,
+		Weekdays:             strings.Join(input.Weekdays, ","),
+		WateringAmount:       input.WateringAmount,
+		Unit:                 input.Unit,
+		Times:                times,
+		WeatherCondition:     input.WeatherCondition,
+		ConditionDescription: input.ConditionDescription,
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+	}
+}
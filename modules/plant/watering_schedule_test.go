@@ -0,0 +1,128 @@
+package plant
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWateringTimes(t *testing.T) {
+	times, invalid := ParseWateringTimes([]string{"17:00,07:00", "7:00; 12.30", "noon"})
+
+	assert.Equal(t, []string{"07:00", "12:30", "17:00"}, times)
+	assert.Equal(t, []string{"noon"}, invalid)
+}
+
+func TestParseWeekdays(t *testing.T) {
+	assert.Equal(t, []string{"mon", "thu", "funday"}, ParseWeekdays([]string{"Thursday, mon", "funday", "MON"}))
+	assert.Equal(t, PeriodWeek, ParseWateringPeriod(" weekly"))
+	assert.Equal(t, WateringPeriod("Fortnight"), ParseWateringPeriod("Fortnight"))
+}
+
+func TestWateringScheduleValidation(t *testing.T) {
+	validate := validator.New()
+	input := CreateWateringScheduleInput{
+		Each:           PeriodDay,
+		WateringAmount: 100,
+		Unit:           "ml",
+		WateringTimes:  []string{"07:00"},
+	}
+	assert.NoError(t, validate.Struct(input))
+
+	weekdaysOnDaily := input
+	weekdaysOnDaily.Weekdays = []string{"mon"}
+	assert.Error(t, validate.Struct(weekdaysOnDaily))
+
+	weekly := weekdaysOnDaily
+	weekly.Each = PeriodWeek
+	assert.NoError(t, validate.Struct(weekly))
+
+	unknownPeriod := input
+	unknownPeriod.Each = "Fortnight"
+	assert.Error(t, validate.Struct(unknownPeriod))
+
+	noTimes := input
+	noTimes.WateringTimes = nil
+	assert.Error(t, validate.Struct(noTimes))
+}
+
+func TestPlantReminderIsDue(t *testing.T) {
+	jakarta, _ := time.LoadLocation("Asia/Jakarta")
+	at := func(day int, clock string) time.Time {
+		parsed, _ := time.Parse("15:04", clock)
+		return time.Date(2024, time.January, day, parsed.Hour(), parsed.Minute(), 0, 0, jakarta)
+	}
+	// 1 January 2024 is a Monday.
+	created := at(1, "10:00")
+	times := []PlantReminderTime{{Time: "07:00"}, {Time: "17:00"}}
+
+	daily := PlantReminder{Each: PeriodDay, Interval: 2, Times: times, CreatedAt: created}
+	assert.True(t, daily.IsDue(at(3, "07:00")))
+	assert.True(t, daily.IsDue(at(3, "17:00")))
+	assert.False(t, daily.IsDue(at(3, "07:01")))
+	assert.False(t, daily.IsDue(at(4, "07:00")))
+
+	weekly := PlantReminder{Each: PeriodWeek, Interval: 2, Weekdays: "mon,thu", Times: times, CreatedAt: created}
+	assert.True(t, weekly.IsDue(at(4, "07:00")))
+	assert.False(t, weekly.IsDue(at(8, "07:00")))
+	assert.True(t, weekly.IsDue(at(15, "07:00")))
+	assert.False(t, weekly.IsDue(at(16, "07:00")))
+
+	sameWeekday := PlantReminder{Each: PeriodWeek, Times: times, CreatedAt: created}
+	assert.True(t, sameWeekday.IsDue(at(8, "17:00")))
+	assert.False(t, sameWeekday.IsDue(at(9, "17:00")))
+
+	monthly := PlantReminder{Each: PeriodMonth, Interval: 1, Times: times, CreatedAt: time.Date(2024, time.January, 31, 9, 0, 0, 0, jakarta)}
+	assert.True(t, monthly.IsDue(time.Date(2024, time.February, 29, 7, 0, 0, 0, jakarta)))
+	assert.False(t, monthly.IsDue(time.Date(2024, time.February, 28, 7, 0, 0, 0, jakarta)))
+	assert.True(t, monthly.IsDue(time.Date(2024, time.March, 31, 7, 0, 0, 0, jakarta)))
+}
+
+func TestPlantReminderOccurrences(t *testing.T) {
+	jakarta, _ := time.LoadLocation("Asia/Jakarta")
+	makassar, _ := time.LoadLocation("Asia/Makassar")
+	// 1 January 2024 is a Monday.
+	reminder := PlantReminder{
+		Each:      PeriodDay,
+		Interval:  2,
+		Times:     []PlantReminderTime{{Time: "17:00"}, {Time: "07:00"}},
+		CreatedAt: time.Date(2024, time.January, 1, 10, 0, 0, 0, jakarta),
+	}
+
+	assert.Equal(t, []time.Time{
+		time.Date(2024, time.January, 1, 17, 0, 0, 0, jakarta),
+		time.Date(2024, time.January, 3, 7, 0, 0, 0, jakarta),
+	}, reminder.Occurrences(
+		time.Date(2024, time.January, 1, 7, 0, 0, 0, jakarta),
+		time.Date(2024, time.January, 3, 7, 0, 0, 0, jakarta),
+		jakarta,
+	))
+
+	// The times of day are local to the user.
+	assert.Equal(t, []time.Time{
+		time.Date(2024, time.January, 3, 7, 0, 0, 0, makassar),
+	}, reminder.Occurrences(
+		time.Date(2024, time.January, 3, 0, 0, 0, 0, jakarta),
+		time.Date(2024, time.January, 3, 12, 0, 0, 0, jakarta),
+		makassar,
+	))
+}
+
+func TestNewPlantReminder(t *testing.T) {
+	reminder := NewPlantReminder(CreateWateringScheduleInput{
+		Each:          PeriodWeek,
+		Weekdays:      []string{"mon", "fri"},
+		WateringTimes: []string{"07:00", "17:00"},
+	})
+
+	assert.Equal(t, 1, reminder.Interval)
+	assert.Equal(t, 2, reminder.WateringFrequency)
+	assert.Equal(t, "mon,fri", reminder.Weekdays)
+	assert.Equal(t, []string{"07:00", "17:00"}, reminder.WateringTimes())
+
+	res := NewPlantReminderResponse(reminder)
+	assert.Equal(t, "07:00, 17:00", res.WateringTime)
+	assert.Equal(t, []string{"mon", "fri"}, res.Weekdays)
+}
@@ -23,7 +23,7 @@ func NewRepository(db *gorm.DB) *searchRepo {
 
 func (r *searchRepo) Search(params PlantSearchParams) ([]plant.Plant, error) {
 	var plants []plant.Plant
-	query := r.db.Preload("PlantCategory").Preload("PlantCharacteristic").Preload("WateringSchedule").
+	query := r.db.Preload("PlantCategory").Preload("PlantCharacteristic").Preload("WateringSchedule.Times").
 		Preload("PlantInstructions").
 		Preload("PlantFAQs").
 		Preload("PlantImages")
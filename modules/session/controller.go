@@ -0,0 +1,102 @@
+package session
+
+import (
+	"net/http"
+
+	"github.com/OctavianoRyan25/be-agriculture/base"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type SessionController struct {
+	useCase UseCase
+}
+
+func NewSessionController(useCase UseCase) *SessionController {
+	return &SessionController{
+		useCase: useCase,
+	}
+}
+
+func (c *SessionController) Refresh(ctx echo.Context) error {
+	req := new(RefreshRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	pair, code, err := c.useCase.RefreshSession(req.RefreshToken)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Token refreshed",
+		Data:    MapTokenPairToResponse(pair),
+	}
+	return ctx.JSON(code, res)
+}
+
+func (c *SessionController) Logout(ctx echo.Context) error {
+	sessionID := ctx.Get("session_id").(string)
+
+	err := c.useCase.RevokeSession(sessionID)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return ctx.JSON(http.StatusInternalServerError, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Logout success",
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *SessionController) LogoutAll(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	role := ctx.Get("role").(string)
+
+	err := c.useCase.RevokeAllSessions(userID, role)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return ctx.JSON(http.StatusInternalServerError, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Logged out from all devices",
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
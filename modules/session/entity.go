@@ -0,0 +1,21 @@
+package session
+
+import "time"
+
+// Session backs one logged-in device. Access tokens carry the session ID in
+// the "sid" claim, so revoking the session invalidates them immediately.
+// Only SHA-256 hashes of refresh tokens are stored.
+type Session struct {
+	ID            string `gorm:"primaryKey;size:32"`
+	AccountID     uint   `gorm:"index:idx_session_account"`
+	Role          string `gorm:"size:20;index:idx_session_account"`
+	Email         string
+	RefreshToken  string `gorm:"size:64;uniqueIndex"`
+	PreviousToken string `gorm:"size:64;index"`
+	UserAgent     string
+	ExpiresAt     time.Time
+	RevokedAt     *time.Time
+	LastUsedAt    time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
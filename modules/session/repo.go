@@ -0,0 +1,105 @@
+package session
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	CreateSession(*Session) error
+	GetSession(string) (*Session, error)
+	GetSessionByRefreshToken(string) (*Session, error)
+	GetSessionByPreviousToken(string) (*Session, error)
+	RotateRefreshToken(*Session, string, time.Time) error
+	RevokeSession(string) error
+	RevokeAllSessions(uint, string) error
+	RevokeOtherSessions(uint, string, string) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *sessionRepository {
+	return &sessionRepository{
+		db: db,
+	}
+}
+
+func (r *sessionRepository) CreateSession(session *Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *sessionRepository) GetSession(id string) (*Session, error) {
+	var session Session
+	err := r.db.Where("id = ?", id).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) GetSessionByRefreshToken(token string) (*Session, error) {
+	var session Session
+	err := r.db.Where("refresh_token = ?", token).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) GetSessionByPreviousToken(token string) (*Session, error) {
+	var session Session
+	err := r.db.Where("previous_token = ?", token).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RotateRefreshToken swaps the refresh token of the session. The update only
+// matches the token that was presented, so two concurrent refreshes with the
+// same token cannot both succeed.
+func (r *sessionRepository) RotateRefreshToken(session *Session, token string, expiresAt time.Time) error {
+	now := time.Now()
+	result := r.db.Model(&Session{}).
+		Where("id = ? AND refresh_token = ? AND revoked_at IS NULL", session.ID, session.RefreshToken).
+		Updates(map[string]interface{}{
+			"previous_token": session.RefreshToken,
+			"refresh_token":  token,
+			"expires_at":     expiresAt,
+			"last_used_at":   now,
+			"updated_at":     now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	session.PreviousToken = session.RefreshToken
+	session.RefreshToken = token
+	session.ExpiresAt = expiresAt
+	session.LastUsedAt = now
+	return nil
+}
+
+func (r *sessionRepository) RevokeSession(id string) error {
+	return r.db.Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) RevokeAllSessions(accountID uint, role string) error {
+	return r.db.Model(&Session{}).
+		Where("account_id = ? AND role = ? AND revoked_at IS NULL", accountID, role).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) RevokeOtherSessions(accountID uint, role, keepID string) error {
+	return r.db.Model(&Session{}).
+		Where("account_id = ? AND role = ? AND id <> ? AND revoked_at IS NULL", accountID, role, keepID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,9 @@
+package session
+
+import "time"
+
+type TokenResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
@@ -0,0 +1,130 @@
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"gorm.io/gorm"
+)
+
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// TokenPair is what a client receives when a session is created or refreshed.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+type UseCase interface {
+	CreateSession(uint, string, string, string) (*TokenPair, int, error)
+	RefreshSession(string) (*TokenPair, int, error)
+	RevokeSession(string) error
+	RevokeAllSessions(uint, string) error
+	RevokeOtherSessions(uint, string, string) error
+	IsSessionActive(string) (bool, error)
+}
+
+type sessionUseCase struct {
+	repo Repository
+}
+
+func NewUseCase(repo Repository) *sessionUseCase {
+	return &sessionUseCase{
+		repo: repo,
+	}
+}
+
+func (uc *sessionUseCase) CreateSession(accountID uint, email, role, userAgent string) (*TokenPair, int, error) {
+	refreshToken := randomToken(32)
+	session := &Session{
+		ID:           randomToken(16),
+		AccountID:    accountID,
+		Role:         role,
+		Email:        email,
+		RefreshToken: hashToken(refreshToken),
+		UserAgent:    userAgent,
+		ExpiresAt:    time.Now().Add(refreshTokenTTL),
+		LastUsedAt:   time.Now(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	err := uc.repo.CreateSession(session)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	return uc.issue(session, refreshToken)
+}
+
+// RefreshSession exchanges a refresh token for a new access token and a new
+// refresh token. Presenting a refresh token that was already rotated means it
+// has leaked, so the whole session is revoked.
+func (uc *sessionUseCase) RefreshSession(refreshToken string) (*TokenPair, int, error) {
+	hashed := hashToken(refreshToken)
+	session, err := uc.repo.GetSessionByRefreshToken(hashed)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constants.ErrCodeBadRequest, err
+		}
+		reused, err := uc.repo.GetSessionByPreviousToken(hashed)
+		if err == nil {
+			uc.repo.RevokeSession(reused.ID)
+		}
+		return nil, constants.ErrCodeInvalidRefreshToken, errors.New(constants.ErrInvalidRefreshToken)
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, constants.ErrCodeInvalidRefreshToken, errors.New(constants.ErrInvalidRefreshToken)
+	}
+
+	newRefreshToken := randomToken(32)
+	err = uc.repo.RotateRefreshToken(session, hashToken(newRefreshToken), time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constants.ErrCodeInvalidRefreshToken, errors.New(constants.ErrInvalidRefreshToken)
+		}
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	return uc.issue(session, newRefreshToken)
+}
+
+func (uc *sessionUseCase) RevokeSession(id string) error {
+	return uc.repo.RevokeSession(id)
+}
+
+func (uc *sessionUseCase) RevokeAllSessions(accountID uint, role string) error {
+	return uc.repo.RevokeAllSessions(accountID, role)
+}
+
+// RevokeOtherSessions logs the account out everywhere except the session
+// keepID.
+func (uc *sessionUseCase) RevokeOtherSessions(accountID uint, role, keepID string) error {
+	return uc.repo.RevokeOtherSessions(accountID, role, keepID)
+}
+
+func (uc *sessionUseCase) IsSessionActive(id string) (bool, error) {
+	session, err := uc.repo.GetSession(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return session.RevokedAt == nil && time.Now().Before(session.ExpiresAt), nil
+}
+
+func (uc *sessionUseCase) issue(session *Session, refreshToken string) (*TokenPair, int, error) {
+	accessToken, err := helper.GenerateToken(session.AccountID, session.Email, session.Role, session.ID)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    time.Now().Add(helper.AccessTokenTTL),
+	}, constants.CodeSuccess, nil
+}
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateSession(session *Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetSession(id string) (*Session, error) {
+	args := m.Called(id)
+	return args.Get(0).(*Session), args.Error(1)
+}
+
+func (m *MockRepository) GetSessionByRefreshToken(token string) (*Session, error) {
+	args := m.Called(token)
+	return args.Get(0).(*Session), args.Error(1)
+}
+
+func (m *MockRepository) GetSessionByPreviousToken(token string) (*Session, error) {
+	args := m.Called(token)
+	return args.Get(0).(*Session), args.Error(1)
+}
+
+func (m *MockRepository) RotateRefreshToken(session *Session, token string, expiresAt time.Time) error {
+	args := m.Called(session, token, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RevokeSession(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RevokeAllSessions(accountID uint, role string) error {
+	args := m.Called(accountID, role)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RevokeOtherSessions(accountID uint, role, keepID string) error {
+	args := m.Called(accountID, role, keepID)
+	return args.Error(0)
+}
+
+func TestRefreshSessionRotatesToken(t *testing.T) {
+	helper.SetKeyRing(helper.NewHMACKeyRing("test", []byte("secret")))
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo)
+
+	existing := &Session{ID: "sid", AccountID: 1, Role: "user", ExpiresAt: time.Now().Add(time.Hour)}
+	mockRepo.On("GetSessionByRefreshToken", hashToken("old")).Return(existing, nil)
+	mockRepo.On("RotateRefreshToken", existing, mock.AnythingOfType("string"), mock.Anything).Return(nil)
+
+	pair, _, err := service.RefreshSession("old")
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, pair.AccessToken)
+	assert.NotEqual(t, "old", pair.RefreshToken)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRefreshSessionReusedTokenRevokesSession(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo)
+
+	mockRepo.On("GetSessionByRefreshToken", hashToken("stolen")).Return((*Session)(nil), gorm.ErrRecordNotFound)
+	mockRepo.On("GetSessionByPreviousToken", hashToken("stolen")).Return(&Session{ID: "sid"}, nil)
+	mockRepo.On("RevokeSession", "sid").Return(nil)
+
+	_, _, err := service.RefreshSession("stolen")
+
+	assert.Error(t, err)
+	mockRepo.AssertCalled(t, "RevokeSession", "sid")
+}
+
+func TestRefreshSessionRejectsExpiredSession(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo)
+
+	expired := &Session{ID: "sid", ExpiresAt: time.Now().Add(-time.Minute)}
+	mockRepo.On("GetSessionByRefreshToken", hashToken("old")).Return(expired, nil)
+
+	_, _, err := service.RefreshSession("old")
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "RotateRefreshToken", mock.Anything, mock.Anything, mock.Anything)
+}
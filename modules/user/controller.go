@@ -1,21 +1,46 @@
 package user
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/OctavianoRyan25/be-agriculture/base"
-	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/modules/device"
+	"github.com/OctavianoRyan25/be-agriculture/modules/lockout"
+	"github.com/OctavianoRyan25/be-agriculture/modules/session"
+	"github.com/OctavianoRyan25/be-agriculture/utils/timezone"
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
+const maxAvatarSize = 2 * 1024 * 1024
+
+var avatarTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
 type UserController struct {
-	userUseCase UserUseCase
+	userUseCase    UserUseCase
+	sessionUseCase session.UseCase
+	deviceUseCase  device.UseCase
+	cloudinary     *cloudinary.Cloudinary
 }
 
-func NewUserController(userUseCase UserUseCase) *UserController {
+func NewUserController(userUseCase UserUseCase, sessionUseCase session.UseCase, deviceUseCase device.UseCase, cloudinary *cloudinary.Cloudinary) *UserController {
 	return &UserController{
-		userUseCase: userUseCase,
+		userUseCase:    userUseCase,
+		sessionUseCase: sessionUseCase,
+		deviceUseCase:  deviceUseCase,
+		cloudinary:     cloudinary,
 	}
 }
 
@@ -163,8 +188,12 @@ func (c *UserController) Login(ctx echo.Context) error {
 	}
 
 	mapped := MapLoginRequestToUser(req)
-	user, code, err := c.userUseCase.Login(mapped)
+	user, code, err := c.userUseCase.Login(mapped, ctx.RealIP())
 	if err != nil {
+		var blocked *lockout.BlockedError
+		if errors.As(err, &blocked) {
+			ctx.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+		}
 		errRes := base.ErrorResponse{
 			Status:  "error",
 			Message: err.Error(),
@@ -173,17 +202,7 @@ func (c *UserController) Login(ctx echo.Context) error {
 		return ctx.JSON(code, errRes)
 	}
 
-	comparePass := ComparePass([]byte(user.Password), []byte(req.Password))
-	if !comparePass {
-		errRes := base.ErrorResponse{
-			Status:  "error",
-			Message: "Invalid email or password",
-			Code:    400,
-		}
-		return ctx.JSON(http.StatusBadRequest, errRes)
-	}
-
-	token, err := helper.GenerateToken(uint(user.ID), user.Email, "user")
+	pair, _, err := c.sessionUseCase.CreateSession(uint(user.ID), user.Email, "user", ctx.Request().UserAgent())
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
@@ -192,8 +211,28 @@ func (c *UserController) Login(ctx echo.Context) error {
 		}
 		return ctx.JSON(http.StatusInternalServerError, errRes)
 	}
+
+	if req.FCMToken != "" {
+		userDevice := &device.Device{
+			Token:      req.FCMToken,
+			Platform:   req.Platform,
+			AppVersion: req.AppVersion,
+		}
+		_, code, err = c.deviceUseCase.RegisterDevice(uint(user.ID), userDevice)
+		if err != nil {
+			errRes := base.ErrorResponse{
+				Status:  "error",
+				Message: err.Error(),
+				Code:    code,
+			}
+			return ctx.JSON(code, errRes)
+		}
+	}
+
 	resToken := LoginResponse{
-		Token: token,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    pair.ExpiresAt,
 	}
 	res := base.SuccessResponse{
 		Status:  "success",
@@ -214,17 +253,6 @@ func (c *UserController) GetUserProfile(ctx echo.Context) error {
 		}
 		return ctx.JSON(http.StatusBadRequest, errRes)
 	}
-	role := ctx.Get("role").(string)
-	if role != "user" {
-		errRes := base.ErrorResponse{
-			Status:  "error",
-			Message: "Forbidden access",
-			Code:    http.StatusForbidden,
-		}
-		return ctx.JSON(http.StatusForbidden, errRes)
-
-	}
-
 	user, code, err := c.userUseCase.GetUserProfile(userId)
 	if err != nil {
 		errRes := base.ErrorResponse{
@@ -257,16 +285,20 @@ func (c *UserController) ResendOTP(ctx echo.Context) error {
 		}
 		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
 	}
-	user, code, err := c.userUseCase.GetUser(req.Email)
+
+	validate := validator.New()
+
+	err = validate.Struct(req)
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
 			Message: err.Error(),
-			Code:    code,
+			Code:    http.StatusBadRequest,
 		}
-		return ctx.JSON(code, errRes)
+		return ctx.JSON(http.StatusBadRequest, errRes)
 	}
-	res, err := c.userUseCase.SendEmailVerification(user)
+
+	code, err := c.userUseCase.ResendOTP(req.Email, ctx.RealIP())
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
@@ -278,11 +310,88 @@ func (c *UserController) ResendOTP(ctx echo.Context) error {
 	resSuccess := base.SuccessResponse{
 		Status:  "success",
 		Message: "OTP sent",
-		Data:    res,
 	}
 	return ctx.JSON(code, resSuccess)
 }
 
+func (c *UserController) ForgotPassword(ctx echo.Context) error {
+	req := new(ForgotPasswordRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	code, err := c.userUseCase.RequestPasswordReset(req.Email)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "If the email is registered, a reset link has been sent",
+	}
+	return ctx.JSON(code, res)
+}
+
+func (c *UserController) VerifyResetToken(ctx echo.Context) error {
+	req := new(VerifyResetTokenRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	code, err := c.userUseCase.VerifyPasswordReset(req.Token)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Reset token is valid",
+	}
+	return ctx.JSON(code, res)
+}
+
 func (c *UserController) ResetPassword(ctx echo.Context) error {
 	req := new(ResetPasswordRequest)
 	err := ctx.Bind(&req)
@@ -306,7 +415,7 @@ func (c *UserController) ResetPassword(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, errRes)
 	}
 
-	user, code, err := c.userUseCase.GetUser(req.Email)
+	code, err := c.userUseCase.ResetPassword(req.Token, HashPass(req.NewPassword))
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
@@ -315,8 +424,290 @@ func (c *UserController) ResetPassword(ctx echo.Context) error {
 		}
 		return ctx.JSON(code, errRes)
 	}
-	user.Password = HashPass(req.NewPassword)
-	code, err = c.userUseCase.ResetPassword(user.Email, user.Password)
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Password change successfully",
+	}
+	return ctx.JSON(code, res)
+}
+
+func (c *UserController) UpdateProfile(ctx echo.Context) error {
+	req := new(UpdateProfileRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	userId := ctx.Get("user_id").(uint)
+	user, code, err := c.userUseCase.UpdateProfile(userId, req.Name)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Profile updated",
+		Data:    MapUserToResponse(user),
+	}
+	return ctx.JSON(code, res)
+}
+
+// UpdateTimezone sets the time zone reminders are sent in, by IANA name or
+// from the coordinates of the user.
+func (c *UserController) UpdateTimezone(ctx echo.Context) error {
+	req := new(UpdateTimezoneRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	name := req.Timezone
+	if name == "" {
+		name = timezone.FromCoordinates(*req.Latitude, *req.Longitude)
+		if name == "" {
+			errRes := base.ErrorResponse{
+				Status:  "error",
+				Message: constants.ErrUnknownLocation,
+				Code:    constants.ErrCodeUnknownLocation,
+			}
+			return ctx.JSON(constants.ErrCodeUnknownLocation, errRes)
+		}
+	}
+
+	userId := ctx.Get("user_id").(uint)
+	user, code, err := c.userUseCase.UpdateTimezone(userId, name)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Time zone updated",
+		Data:    MapUserToResponse(user),
+	}
+	return ctx.JSON(code, res)
+}
+
+// UpdateLocation saves the location the weather of the user's reminders is
+// checked at.
+func (c *UserController) UpdateLocation(ctx echo.Context) error {
+	req := new(UpdateLocationRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	userId := ctx.Get("user_id").(uint)
+	user, code, err := c.userUseCase.UpdateLocation(userId, *req.Latitude, *req.Longitude)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Location updated",
+		Data:    MapUserToResponse(user),
+	}
+	return ctx.JSON(code, res)
+}
+
+// DeleteLocation forgets the location of the user. Their reminders are no
+// longer adjusted to the weather.
+func (c *UserController) DeleteLocation(ctx echo.Context) error {
+	userId := ctx.Get("user_id").(uint)
+	user, code, err := c.userUseCase.DeleteLocation(userId)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Location removed",
+		Data:    MapUserToResponse(user),
+	}
+	return ctx.JSON(code, res)
+}
+
+// UpdateAvatar uploads the "image" form file to Cloudinary and uses it as the
+// profile picture. Each user has one avatar image that is overwritten.
+func (c *UserController) UpdateAvatar(ctx echo.Context) error {
+	userId := ctx.Get("user_id").(uint)
+
+	file, err := ctx.FormFile("image")
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: "Failed to get uploaded image",
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+	if file.Size > maxAvatarSize {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: constants.ErrInvalidImage,
+			Code:    constants.ErrCodeInvalidImage,
+		}
+		return ctx.JSON(constants.ErrCodeInvalidImage, errRes)
+	}
+
+	fileReader, err := file.Open()
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: "Failed to open uploaded file",
+			Code:    http.StatusInternalServerError,
+		}
+		return ctx.JSON(http.StatusInternalServerError, errRes)
+	}
+	defer fileReader.Close()
+
+	// Check the content instead of trusting the declared content type
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(fileReader, head)
+	if !avatarTypes[http.DetectContentType(head[:n])] {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: constants.ErrInvalidImage,
+			Code:    constants.ErrCodeInvalidImage,
+		}
+		return ctx.JSON(constants.ErrCodeInvalidImage, errRes)
+	}
+	_, err = fileReader.Seek(0, io.SeekStart)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: "Failed to read uploaded file",
+			Code:    http.StatusInternalServerError,
+		}
+		return ctx.JSON(http.StatusInternalServerError, errRes)
+	}
+
+	overwrite := true
+	params := uploader.UploadParams{
+		Folder:    "be-agriculture/avatars",
+		PublicID:  fmt.Sprintf("user-%d", userId),
+		Overwrite: &overwrite,
+	}
+	uploadResult, err := c.cloudinary.Upload.Upload(ctx.Request().Context(), fileReader, params)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: "Failed to upload image to Cloudinary",
+			Code:    http.StatusInternalServerError,
+		}
+		return ctx.JSON(http.StatusInternalServerError, errRes)
+	}
+
+	user, code, err := c.userUseCase.UpdateAvatar(userId, uploadResult.SecureURL)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Avatar updated",
+		Data:    MapUserToResponse(user),
+	}
+	return ctx.JSON(code, res)
+}
+
+// ChangePassword changes the password of the logged in user. Other sessions
+// are logged out, the current one stays valid.
+func (c *UserController) ChangePassword(ctx echo.Context) error {
+	req := new(ChangePasswordRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	userId := ctx.Get("user_id").(uint)
+	sessionId := ctx.Get("session_id").(string)
+	code, err := c.userUseCase.ChangePassword(userId, sessionId, req.CurrentPassword, HashPass(req.NewPassword))
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
@@ -331,3 +722,85 @@ func (c *UserController) ResetPassword(ctx echo.Context) error {
 	}
 	return ctx.JSON(code, res)
 }
+
+// ChangeEmail sends an OTP to the new email, see VerifyEmailChange.
+func (c *UserController) ChangeEmail(ctx echo.Context) error {
+	req := new(ChangeEmailRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	userId := ctx.Get("user_id").(uint)
+	code, err := c.userUseCase.RequestEmailChange(userId, req.Email)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "OTP sent to the new email",
+	}
+	return ctx.JSON(code, res)
+}
+
+func (c *UserController) VerifyEmailChange(ctx echo.Context) error {
+	req := new(VerifyEmailChangeRequest)
+	err := ctx.Bind(&req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+	}
+	validate := validator.New()
+
+	err = validate.Struct(req)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return ctx.JSON(http.StatusBadRequest, errRes)
+	}
+
+	userId := ctx.Get("user_id").(uint)
+	user, code, err := c.userUseCase.ConfirmEmailChange(userId, req.OTP)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Email changed",
+		Data:    MapUserToResponse(user),
+	}
+	return ctx.JSON(code, res)
+}
@@ -5,14 +5,65 @@ import (
 )
 
 type User struct {
+	ID             int `gorm:"primaryKey"`
+	Name           string
+	Email          string
+	Password       string
+	Is_Active      bool
+	OTP            string
+	OTP_issued_at  time.Time
+	OTP_expires_at time.Time
+	OTP_attempts   int
+	Url_Image      string
+	Pending_email  string
+	Timezone       string `gorm:"size:64;not null;default:''"`
+	Latitude       *float64
+	Longitude      *float64
+	Created_at     time.Time
+	Updated_at     time.Time
+}
+
+// PasswordReset is a single-use token sent by email to prove ownership of an
+// account before its password can be changed. Only the SHA-256 hash of the
+// token is stored.
+type PasswordReset struct {
 	ID         int `gorm:"primaryKey"`
-	Name       string
+	UserID     int
+	User       User   `gorm:"foreignKey:UserID;references:ID"`
+	Token      string `gorm:"size:64;uniqueIndex"`
+	Expires_at time.Time
+	Used_at    *time.Time
+	Created_at time.Time
+}
+
+// OTPSend records a resent verification code, used to throttle resends per
+// email and per IP address.
+type OTPSend struct {
+	ID         int       `gorm:"primaryKey"`
+	Email      string    `gorm:"size:255;index"`
+	IP         string    `gorm:"size:45;index"`
+	Created_at time.Time `gorm:"index"`
+}
+
+// Identity links a user to an account at an external login provider such as
+// Google. Subject is the provider's stable account ID.
+type Identity struct {
+	ID         int    `gorm:"primaryKey"`
+	UserID     int    `gorm:"index"`
+	User       User   `gorm:"foreignKey:UserID;references:ID"`
+	Provider   string `gorm:"size:32;uniqueIndex:idx_identity_subject"`
+	Subject    string `gorm:"size:255;uniqueIndex:idx_identity_subject"`
 	Email      string
-	Password   string
-	Is_Active  bool
-	OTP        string
-	FCMToken   string
-	Url_Image  string
 	Created_at time.Time
-	Updated_at time.Time
+}
+
+// OAuthState is the single-use state parameter of an OAuth flow, stored as a
+// SHA-256 hash. When UserID is set the flow links the provider account to
+// that user instead of logging in.
+type OAuthState struct {
+	ID         int    `gorm:"primaryKey"`
+	State      string `gorm:"size:64;uniqueIndex"`
+	UserID     *int
+	Expires_at time.Time
+	Created_at time.Time
 }
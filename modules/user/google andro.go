@@ -1,15 +1,13 @@
 package user
 
 import (
-	"context"
 	"net/http"
 	"os"
-	"strconv"
 
+	"github.com/OctavianoRyan25/be-agriculture/base"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
-	googleOAuth "google.golang.org/api/oauth2/v2"
 )
 
 var (
@@ -22,37 +20,41 @@ var (
 	}
 )
 
-func LoginGoogleforAndro(c echo.Context) error {
-	url := oauthConfigforAndro.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	return c.Redirect(http.StatusTemporaryRedirect, url)
+func (u *UserController) LoginGoogleforAndro(c echo.Context) error {
+	return u.redirectToGoogle(c, oauthConfigforAndro)
 }
 
-func CallbackGoogleforAndro(c echo.Context) error {
-	code := c.QueryParam("code")
-	token, err := oauthConfigforAndro.Exchange(context.Background(), code)
+func (u *UserController) CallbackGoogleforAndro(c echo.Context) error {
+	state, profile, code, err := u.googleCallback(c, oauthConfigforAndro)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Failed to exchange token: "+err.Error())
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return c.JSON(code, errRes)
 	}
-
-	client := oauthConfigforAndro.Client(context.Background(), token)
-	service, err := googleOAuth.New(client)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Failed to create oauth service: "+err.Error())
+	if state.UserID != nil {
+		return u.linkGoogle(c, uint(*state.UserID), profile)
 	}
 
-	userinfo, err := service.Userinfo.V2.Me.Get().Do()
+	user, code, err := u.userUseCase.LoginWithProvider(profile)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Failed to get user info: "+err.Error())
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return c.JSON(code, errRes)
 	}
 
-	parse, _ := strconv.Atoi(userinfo.Id)
-
-	jwtToken, err := GenerateJWTToken(uint(parse), userinfo.Email)
+	pair, _, err := u.sessionUseCase.CreateSession(uint(user.ID), user.Email, "user", c.Request().UserAgent())
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, "Failed to generate JWT token: "+err.Error())
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
-		"token": jwtToken,
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
 	})
 }
@@ -2,18 +2,25 @@ package user
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
-	"strconv"
 
 	"github.com/OctavianoRyan25/be-agriculture/base"
-	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"github.com/OctavianoRyan25/be-agriculture/constants"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	googleOAuth "google.golang.org/api/oauth2/v2"
 )
 
+const (
+	googleProvider   = "google"
+	oauthStateCookie = "oauth_state"
+)
+
 var (
 	oauthConfig = &oauth2.Config{
 		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
@@ -24,41 +31,189 @@ var (
 	}
 )
 
-func LoginGoogle(c echo.Context) error {
-	url := oauthConfig.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+func (u *UserController) LoginGoogle(c echo.Context) error {
+	return u.redirectToGoogle(c, oauthConfig)
+}
+
+func (u *UserController) CallbackGoogle(c echo.Context) error {
+	state, profile, code, err := u.googleCallback(c, oauthConfig)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return c.JSON(code, errRes)
+	}
+	if state.UserID != nil {
+		return u.linkGoogle(c, uint(*state.UserID), profile)
+	}
+
+	user, code, err := u.userUseCase.LoginWithProvider(profile)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return c.JSON(code, errRes)
+	}
+
+	pair, _, err := u.sessionUseCase.CreateSession(uint(user.ID), user.Email, "user", c.Request().UserAgent())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, "Failed to generate JWT token: "+err.Error())
+	}
+
+	// return c.JSON(http.StatusOK, map[string]string{
+	// 	"token": jwtToken,
+	// })
+
+	return c.Redirect(http.StatusTemporaryRedirect, "https://be-agriculture-awh2j5ffyq-uc.a.run.app/api/v1/auth?token="+pair.AccessToken+"&refresh_token="+pair.RefreshToken)
+}
+
+// LinkGoogle returns the Google consent URL that links a Google account to
+// the logged in user, even when the Google account uses another email. The
+// app opens it in the system browser, so the link is bound to the user by
+// its single-use state rather than by a cookie.
+func (u *UserController) LinkGoogle(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	state, code, err := u.userUseCase.CreateOAuthState(userID)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return c.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Google link url",
+		Data: map[string]string{
+			"url": oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline),
+		},
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (u *UserController) UnlinkGoogle(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	code, err := u.userUseCase.UnlinkProvider(userID, googleProvider)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return c.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Google account unlinked",
+		Data:    nil,
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (u *UserController) redirectToGoogle(c echo.Context, config *oauth2.Config) error {
+	state, code, err := u.userUseCase.CreateOAuthState(0)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return c.JSON(code, errRes)
+	}
+
+	setOAuthStateCookie(c, state)
+
+	url := config.AuthCodeURL(state, oauth2.AccessTypeOffline)
 	return c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
-func CallbackGoogle(c echo.Context) error {
-	code := c.QueryParam("code")
-	token, err := oauthConfig.Exchange(context.Background(), code)
+// setOAuthStateCookie ties the state of a login to this browser, so a login
+// callback started anywhere else is rejected.
+func setOAuthStateCookie(c echo.Context, state string) {
+	c.SetCookie(&http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/api/v1",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// googleCallback checks the state of a Google callback. A login must come
+// back to the browser that started it. A link is started by the app and
+// finished in the system browser, which has no cookie, so its state alone,
+// single-use and stored with the user it links to, is checked. Then it
+// exchanges the code and returns the Google account that signed in.
+func (u *UserController) googleCallback(c echo.Context, config *oauth2.Config) (*OAuthState, *OAuthProfile, int, error) {
+	param := c.QueryParam("state")
+	state, code, err := u.userUseCase.ConsumeOAuthState(param)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Failed to exchange token: "+err.Error())
+		return nil, nil, code, err
 	}
 
-	client := oauthConfig.Client(context.Background(), token)
+	if state.UserID == nil {
+		cookie, err := c.Cookie(oauthStateCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(param)) != 1 {
+			return nil, nil, constants.ErrCodeInvalidOAuthState, errors.New(constants.ErrInvalidOAuthState)
+		}
+		c.SetCookie(&http.Cookie{Name: oauthStateCookie, Path: "/api/v1", MaxAge: -1})
+	}
+
+	token, err := config.Exchange(context.Background(), c.QueryParam("code"))
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("failed to exchange token: %v", err)
+	}
+
+	client := config.Client(context.Background(), token)
 	service, err := googleOAuth.New(client)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Failed to create oauth service: "+err.Error())
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("failed to create oauth service: %v", err)
 	}
 
 	userinfo, err := service.Userinfo.V2.Me.Get().Do()
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Failed to get user info: "+err.Error())
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("failed to get user info: %v", err)
 	}
 
-	parse, _ := strconv.Atoi(userinfo.Id)
+	profile := &OAuthProfile{
+		Provider:      googleProvider,
+		Subject:       userinfo.Id,
+		Email:         userinfo.Email,
+		EmailVerified: userinfo.VerifiedEmail != nil && *userinfo.VerifiedEmail,
+		Name:          userinfo.Name,
+		Picture:       userinfo.Picture,
+	}
+	return state, profile, constants.CodeSuccess, nil
+}
 
-	jwtToken, err := helper.GenerateToken(uint(parse), userinfo.Email, "user")
+func (u *UserController) linkGoogle(c echo.Context, userID uint, profile *OAuthProfile) error {
+	code, err := u.userUseCase.LinkProvider(userID, profile)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Failed to generate JWT token: "+err.Error())
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return c.JSON(code, errRes)
 	}
 
-	// return c.JSON(http.StatusOK, map[string]string{
-	// 	"token": jwtToken,
-	// })
-
-	return c.Redirect(http.StatusTemporaryRedirect, "https://be-agriculture-awh2j5ffyq-uc.a.run.app/api/v1/auth?token="+jwtToken)
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Google account linked",
+		Data:    nil,
+	}
+	return c.JSON(http.StatusOK, res)
 }
 
 func GetToken(c echo.Context) error {
@@ -80,7 +235,3 @@ func GetToken(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, successRes)
 }
-
-func GenerateJWTToken(userID uint, email string) (string, error) {
-	return helper.GenerateToken(userID, email, "user") // Modify as necessary to use the GenerateToken function from your helper package
-}
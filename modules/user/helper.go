@@ -1,7 +1,10 @@
 package user
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/utils/timezone"
 )
 
 func MapUserRequestToUser(userRequest *UserRequest) *User {
@@ -10,28 +13,39 @@ func MapUserRequestToUser(userRequest *UserRequest) *User {
 		Email:     userRequest.Email,
 		Password:  userRequest.Password,
 		Url_Image: userRequest.Url_Image,
-		FCMToken:  userRequest.FCMToken,
 	}
 }
 
 func MapUserToResponse(user *User) *UserResponse {
 	return &UserResponse{
-		ID:         user.ID,
-		Name:       user.Name,
-		Email:      user.Email,
-		Is_Active:  user.Is_Active,
-		OTP:        user.OTP,
-		Url_Image:  user.Url_Image,
-		FCMToken:   user.FCMToken,
-		Created_at: user.Created_at,
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		Is_Active:     user.Is_Active,
+		Url_Image:     user.Url_Image,
+		Pending_email: user.Pending_email,
+		Timezone:      user.Location().String(),
+		Latitude:      user.Latitude,
+		Longitude:     user.Longitude,
+		Created_at:    user.Created_at,
 	}
 }
 
+// HasLocation reports whether the user saved a location, which the weather
+// of their reminders is checked at.
+func (u User) HasLocation() bool {
+	return u.Latitude != nil && u.Longitude != nil
+}
+
+// Location returns the time zone the user's schedules and timestamps are in.
+func (u User) Location() *time.Location {
+	return timezone.Location(u.Timezone)
+}
+
 func MapLoginRequestToUser(loginRequest *LoginRequest) *User {
 	return &User{
 		Email:    loginRequest.Email,
 		Password: loginRequest.Password,
-		FCMToken: loginRequest.FCMToken,
 	}
 }
 
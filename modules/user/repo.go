@@ -3,21 +3,41 @@ package user
 import (
 	"errors"
 	"fmt"
+	"time"
 
-	"github.com/OctavianoRyan25/be-agriculture/constants"
 	"gorm.io/gorm"
 )
 
 type Repository interface {
 	RegisterUser(*User) (*User, error)
 	IsDuplicateEmail(string) (bool, error)
-	VerifyEmail(string, string) error
+	UseOTPAttempt(int, int) (bool, error)
+	ActivateUser(int) error
+	UpdateOTP(*User) error
+	CreateOTPSend(*OTPSend) error
+	CountOTPSendsByEmail(string, time.Time) (int64, error)
+	CountOTPSendsByIP(string, time.Time) (int64, error)
 	IsValidated(string) (bool, error)
 	Login(*User) (*User, error)
 	GetUserProfile(uint) (*User, error)
 	GetUser(string) (*User, error)
-	ResetPassword(string, string) error
-	UpdateFCMToken(uint, string) error
+	UpdateName(int, string) error
+	UpdateAvatar(int, string) error
+	UpdateTimezone(int, string) error
+	UpdateLocation(id int, lat, lon *float64) error
+	UpdatePassword(int, string) error
+	SetPendingEmail(*User) error
+	ChangeEmail(int, string) error
+	CreatePasswordReset(*PasswordReset) error
+	GetPasswordReset(string) (*PasswordReset, error)
+	ResetPassword(*PasswordReset, string) error
+	CreateOAuthState(*OAuthState) error
+	ConsumeOAuthState(string) (*OAuthState, error)
+	GetIdentity(string, string) (*Identity, error)
+	GetUserIdentity(uint, string) (*Identity, error)
+	CreateIdentity(*Identity) error
+	SaveUserWithIdentity(*User, *Identity) error
+	DeleteIdentity(uint, string) (bool, error)
 }
 
 type userRepository struct {
@@ -51,24 +71,117 @@ func (r *userRepository) IsDuplicateEmail(email string) (bool, error) {
 	return true, nil
 }
 
-func (r *userRepository) VerifyEmail(email, otp string) error {
-	var user User
-	err := r.db.Where("email = ?", email).First(&user).Error
-	if err != nil {
-		return err
+// UseOTPAttempt counts a verification attempt. It reports false once the
+// user has used all of their attempts, so concurrent guesses cannot go over
+// the limit.
+func (r *userRepository) UseOTPAttempt(id, max int) (bool, error) {
+	res := r.db.Model(&User{}).
+		Where("id = ? AND otp_attempts < ?", id, max).
+		Update("otp_attempts", gorm.Expr("otp_attempts + 1"))
+	if res.Error != nil {
+		return false, res.Error
 	}
+	return res.RowsAffected > 0, nil
+}
 
-	if user.OTP != otp {
-		return errors.New(constants.ErrInvalidOTP)
-	}
+func (r *userRepository) ActivateUser(id int) error {
+	return r.db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"is_active":    true,
+		"otp":          "",
+		"otp_attempts": 0,
+		"updated_at":   time.Now(),
+	}).Error
+}
+
+func (r *userRepository) UpdateOTP(user *User) error {
+	return r.db.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"otp":            user.OTP,
+		"otp_issued_at":  user.OTP_issued_at,
+		"otp_expires_at": user.OTP_expires_at,
+		"otp_attempts":   user.OTP_attempts,
+		"updated_at":     time.Now(),
+	}).Error
+}
+
+func (r *userRepository) UpdateName(id int, name string) error {
+	return r.db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"name":       name,
+		"updated_at": time.Now(),
+	}).Error
+}
+
+func (r *userRepository) UpdateAvatar(id int, url string) error {
+	return r.db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"url_image":  url,
+		"updated_at": time.Now(),
+	}).Error
+}
+
+func (r *userRepository) UpdateTimezone(id int, timezone string) error {
+	return r.db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"timezone":   timezone,
+		"updated_at": time.Now(),
+	}).Error
+}
 
-	user.Is_Active = true
-	err = r.db.Save(&user).Error
+func (r *userRepository) UpdateLocation(id int, lat, lon *float64) error {
+	return r.db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"latitude":   lat,
+		"longitude":  lon,
+		"updated_at": time.Now(),
+	}).Error
+}
+
+func (r *userRepository) UpdatePassword(id int, password string) error {
+	return r.db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password":   password,
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// SetPendingEmail stores the requested new email together with the OTP sent
+// to it.
+func (r *userRepository) SetPendingEmail(user *User) error {
+	return r.db.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"pending_email":  user.Pending_email,
+		"otp":            user.OTP,
+		"otp_issued_at":  user.OTP_issued_at,
+		"otp_expires_at": user.OTP_expires_at,
+		"otp_attempts":   user.OTP_attempts,
+		"updated_at":     time.Now(),
+	}).Error
+}
+
+// ChangeEmail replaces the email of the user with the verified pending one.
+func (r *userRepository) ChangeEmail(id int, email string) error {
+	return r.db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"email":         email,
+		"pending_email": "",
+		"otp":           "",
+		"otp_attempts":  0,
+		"updated_at":    time.Now(),
+	}).Error
+}
+
+// CreateOTPSend records a resend and forgets the ones older than a day.
+func (r *userRepository) CreateOTPSend(send *OTPSend) error {
+	err := r.db.Where("created_at < ?", send.Created_at.Add(-24*time.Hour)).Delete(&OTPSend{}).Error
 	if err != nil {
 		return err
 	}
+	return r.db.Create(send).Error
+}
 
-	return nil
+func (r *userRepository) CountOTPSendsByEmail(email string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&OTPSend{}).Where("email = ? AND created_at >= ?", email, since).Count(&count).Error
+	return count, err
+}
+
+func (r *userRepository) CountOTPSendsByIP(ip string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&OTPSend{}).Where("ip = ? AND created_at >= ?", ip, since).Count(&count).Error
+	return count, err
 }
 
 func (r *userRepository) IsValidated(email string) (bool, error) {
@@ -110,34 +223,112 @@ func (r *userRepository) GetUser(email string) (*User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) ResetPassword(email, password string) error {
-	var user User
-	err := r.db.Where("email = ?", email).First(&user).Error
+func (r *userRepository) CreatePasswordReset(reset *PasswordReset) error {
+	return r.db.Create(reset).Error
+}
+
+func (r *userRepository) GetPasswordReset(token string) (*PasswordReset, error) {
+	var reset PasswordReset
+	err := r.db.Where("token = ?", token).First(&reset).Error
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return &reset, nil
+}
+
+// ResetPassword sets the new password and consumes every outstanding reset
+// token of the user. It returns gorm.ErrRecordNotFound when reset was used
+// in the meantime, so a token works for one request only.
+func (r *userRepository) ResetPassword(reset *PasswordReset, password string) error {
+	now := time.Now()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		err := tx.Model(&User{}).Where("id = ?", reset.UserID).Updates(map[string]interface{}{
+			"password":   password,
+			"updated_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
 
-	user.Password = password
-	err = r.db.Save(&user).Error
+		return tx.Model(&PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", reset.UserID).
+			Update("used_at", now).Error
+	})
+}
+
+func (r *userRepository) CreateOAuthState(state *OAuthState) error {
+	return r.db.Create(state).Error
+}
+
+// ConsumeOAuthState deletes the state and returns it, so a state can only be
+// used by one callback.
+func (r *userRepository) ConsumeOAuthState(state string) (*OAuthState, error) {
+	var s OAuthState
+	err := r.db.Where("state = ?", state).First(&s).Error
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	res := r.db.Where("id = ?", s.ID).Delete(&OAuthState{})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &s, nil
 }
 
-func (r *userRepository) UpdateFCMToken(id uint, fcmToken string) error {
-	var user User
-	err := r.db.Where("id = ?", id).First(&user).Error
+func (r *userRepository) GetIdentity(provider, subject string) (*Identity, error) {
+	var identity Identity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return &identity, nil
+}
 
-	user.FCMToken = fcmToken
-	err = r.db.Save(&user).Error
+func (r *userRepository) GetUserIdentity(userID uint, provider string) (*Identity, error) {
+	var identity Identity
+	err := r.db.Where("user_id = ? AND provider = ?", userID, provider).First(&identity).Error
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return &identity, nil
+}
 
-	return nil
+func (r *userRepository) CreateIdentity(identity *Identity) error {
+	return r.db.Create(identity).Error
+}
+
+// SaveUserWithIdentity creates or updates the user and links the identity to
+// it in a single transaction.
+func (r *userRepository) SaveUserWithIdentity(user *User, identity *Identity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Save(user).Error
+		if err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+func (r *userRepository) DeleteIdentity(userID uint, provider string) (bool, error) {
+	res := r.db.Where("user_id = ? AND provider = ?", userID, provider).Delete(&Identity{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
 }
@@ -10,7 +10,6 @@ type UserRequest struct {
 	Password   string    `json:"password" validate:"required"`
 	Is_Active  bool      `json:"is_active"`
 	Url_Image  string    `json:"url_image"`
-	FCMToken   string    `json:"fcm_token" validate:"required"`
 	Created_at time.Time `json:"created_at"`
 	Updated_at time.Time `json:"updated_at"`
 }
@@ -20,9 +19,11 @@ type CheckEmailRequest struct {
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
-	FCMToken string `json:"fcm_token" validate:"required"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required"`
+	FCMToken   string `json:"fcm_token"`
+	Platform   string `json:"platform" validate:"omitempty,oneof=android ios web"`
+	AppVersion string `json:"app_version"`
 }
 
 type OTPRequest struct {
@@ -30,7 +31,47 @@ type OTPRequest struct {
 	OTP   string `json:"otp" validate:"required"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type VerifyResetTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type UpdateProfileRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// UpdateTimezoneRequest sets the time zone by IANA name, or from the
+// coordinates of the user when no name is given.
+type UpdateTimezoneRequest struct {
+	Timezone  string   `json:"timezone" validate:"required_without_all=Latitude Longitude,max=64"`
+	Latitude  *float64 `json:"lat" validate:"required_without=Timezone,omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"lon" validate:"required_without=Timezone,omitempty,min=-180,max=180"`
+}
+
+// UpdateLocationRequest saves the location the weather of the reminders of
+// the user is checked at.
+type UpdateLocationRequest struct {
+	Latitude  *float64 `json:"lat" validate:"required,min=-90,max=90"`
+	Longitude *float64 `json:"lon" validate:"required,min=-180,max=180"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type ChangeEmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type VerifyEmailChangeRequest struct {
+	OTP string `json:"otp" validate:"required"`
+}
+
 type ResetPasswordRequest struct {
-	Email       string `json:"email" validate:"required,email"`
+	Token       string `json:"token" validate:"required"`
 	NewPassword string `json:"new_password" validate:"required"`
 }
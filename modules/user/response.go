@@ -5,16 +5,20 @@ import (
 )
 
 type UserResponse struct {
-	ID         int       `json:"id"`
-	Name       string    `json:"name"`
-	Email      string    `json:"email"`
-	Is_Active  bool      `json:"is_active"`
-	OTP        string    `json:"otp"`
-	Url_Image  string    `json:"url_image"`
-	FCMToken   string    `json:"fcm_token"`
-	Created_at time.Time `json:"created_at"`
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	Is_Active     bool      `json:"is_active"`
+	Url_Image     string    `json:"url_image"`
+	Pending_email string    `json:"pending_email,omitempty"`
+	Timezone      string    `json:"timezone"`
+	Latitude      *float64  `json:"lat"`
+	Longitude     *float64  `json:"lon"`
+	Created_at    time.Time `json:"created_at"`
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
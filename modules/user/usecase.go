@@ -1,35 +1,87 @@
 package user
 
 import (
-	"bytes"
+	"crypto/subtle"
 	"errors"
-	"fmt"
-	"html/template"
-	"path/filepath"
+	"log"
 	"time"
 
 	"github.com/OctavianoRyan25/be-agriculture/constants"
-	"gopkg.in/gomail.v2"
+	"github.com/OctavianoRyan25/be-agriculture/modules/lockout"
+	"github.com/OctavianoRyan25/be-agriculture/modules/session"
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"github.com/OctavianoRyan25/be-agriculture/utils/timezone"
+	"gorm.io/gorm"
 )
 
+const (
+	passwordResetTTL = 30 * time.Minute
+	oauthStateTTL    = 10 * time.Minute
+
+	otpTTL                = 10 * time.Minute
+	otpMaxAttempts        = 5
+	otpResendCooldown     = time.Minute
+	otpResendWindow       = time.Hour
+	otpMaxResendsPerEmail = 5
+	otpMaxResendsPerIP    = 20
+)
+
+// OAuthProfile is the account returned by an external login provider.
+type OAuthProfile struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
 type UserUseCase interface {
 	RegisterUser(*User) (*User, int, error)
 	CheckEmail(string) (int, error)
 	SendEmailVerification(*User) (int, error)
 	VerifyEmail(string, string) (int, error)
-	Login(*User) (*User, int, error)
+	ResendOTP(string, string) (int, error)
+	Login(*User, string) (*User, int, error)
 	GetUserProfile(uint) (*User, int, error)
 	GetUser(string) (*User, int, error)
+	UpdateProfile(uint, string) (*User, int, error)
+	UpdateAvatar(uint, string) (*User, int, error)
+	UpdateTimezone(uint, string) (*User, int, error)
+	DetectTimezone(uint, float64, float64) (int, error)
+	UpdateLocation(id uint, lat, lon float64) (*User, int, error)
+	DeleteLocation(id uint) (*User, int, error)
+	ChangePassword(uint, string, string, string) (int, error)
+	RequestEmailChange(uint, string) (int, error)
+	ConfirmEmailChange(uint, string) (*User, int, error)
+	RequestPasswordReset(string) (int, error)
+	VerifyPasswordReset(string) (int, error)
 	ResetPassword(string, string) (int, error)
+	CreateOAuthState(uint) (string, int, error)
+	ConsumeOAuthState(string) (*OAuthState, int, error)
+	LoginWithProvider(*OAuthProfile) (*User, int, error)
+	LinkProvider(uint, *OAuthProfile) (int, error)
+	UnlinkProvider(uint, string) (int, error)
+}
+
+// TimezoneListener is told when a user moves to another time zone, so the
+// schedules other modules keep for the user can follow.
+type TimezoneListener interface {
+	TimezoneChanged(userID uint, timezone string) error
 }
 
 type userUseCase struct {
-	repo Repository
+	repo      Repository
+	sessions  session.UseCase
+	lockout   lockout.UseCase
+	timezones TimezoneListener
 }
 
-func NewUseCase(repo Repository) *userUseCase {
+func NewUseCase(repo Repository, sessions session.UseCase, lockout lockout.UseCase) *userUseCase {
 	return &userUseCase{
-		repo: repo,
+		repo:     repo,
+		sessions: sessions,
+		lockout:  lockout,
 	}
 }
 
@@ -42,7 +94,7 @@ func (uc *userUseCase) RegisterUser(user *User) (*User, int, error) {
 		return nil, constants.ErrCodeEmailAlreadyExist, errors.New(constants.ErrEmailAlreadyExist)
 	}
 	user.Is_Active = false
-	user.OTP = RandomOTP()
+	issueOTP(user)
 	user.Created_at = time.Now()
 	user.Updated_at = time.Now()
 
@@ -62,73 +114,161 @@ func (uc *userUseCase) CheckEmail(email string) (int, error) {
 }
 
 func (uc *userUseCase) SendEmailVerification(user *User) (int, error) {
-	m := gomail.NewMessage()
-	m.SetHeader("From", EMAIL_FROM)
-	m.SetHeader("To", user.Email)
-	m.SetHeader("Subject", "Email Verification")
-
-	OTP := user.OTP
-	username := user.Name
-	//Production
-	path := filepath.Join("/app", "modules", "user", "template", "base.html")
-	//Development
-	// path := filepath.Join("modules", "user", "template", "base.html")
-	template, err := template.ParseFiles(path)
-	if err != nil {
-		return constants.ErrCodeBadRequest, err
-	}
-
-	var body bytes.Buffer
 	data := struct {
 		Username string
 		OTP      string
+		Minutes  int
 	}{
-		Username: username,
-		OTP:      OTP,
+		Username: user.Name,
+		OTP:      user.OTP,
+		Minutes:  int(otpTTL.Minutes()),
+	}
+
+	err := helper.SendTemplateEmail(templateDir, user.Email, "Email Verification", "base.html", data)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
 	}
 
-	err = template.Execute(&body, data)
+	return constants.CodeSuccess, nil
+}
+
+func (uc *userUseCase) VerifyEmail(email, otp string) (int, error) {
+	user, err := uc.repo.GetUser(email)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return constants.ErrCodeInvalidOTP, errors.New(constants.ErrInvalidOTP)
+		}
 		return constants.ErrCodeBadRequest, err
 	}
-	m.SetBody("text/html", body.String())
-	d := gomail.NewDialer(SMTP_HOST, 587, SMTP_USER, SMTP_PASSWORD)
+	if user.Is_Active {
+		return constants.ErrCodeEmailAlreadyVerified, errors.New(constants.ErrEmailAlreadyVerified)
+	}
+	if time.Now().After(user.OTP_expires_at) {
+		return constants.ErrCodeOTPExpired, errors.New(constants.ErrOTPExpired)
+	}
 
-	if err := d.DialAndSend(m); err != nil {
+	ok, err := uc.repo.UseOTPAttempt(user.ID, otpMaxAttempts)
+	if err != nil {
 		return constants.ErrCodeBadRequest, err
 	}
+	if !ok {
+		return constants.ErrCodeOTPLocked, errors.New(constants.ErrOTPLocked)
+	}
+	if subtle.ConstantTimeCompare([]byte(user.OTP), []byte(otp)) != 1 {
+		return constants.ErrCodeInvalidOTP, errors.New(constants.ErrInvalidOTP)
+	}
 
+	err = uc.repo.ActivateUser(user.ID)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
 	return constants.CodeSuccess, nil
 }
 
-func (uc *userUseCase) VerifyEmail(email, otp string) (int, error) {
-	err := uc.repo.VerifyEmail(email, otp)
+// ResendOTP issues a new verification code, which also unlocks verification
+// after too many wrong attempts. Resends are throttled per email and per IP.
+func (uc *userUseCase) ResendOTP(email, ip string) (int, error) {
+	now := time.Now()
+	since := now.Add(-otpResendWindow)
+
+	count, err := uc.repo.CountOTPSendsByIP(ip, since)
 	if err != nil {
 		return constants.ErrCodeBadRequest, err
 	}
-	return constants.CodeSuccess, nil
+	if count >= otpMaxResendsPerIP {
+		return constants.ErrCodeOTPResendLimit, errors.New(constants.ErrOTPResendLimit)
+	}
+
+	user, err := uc.repo.GetUser(email)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	if user.Is_Active {
+		return constants.ErrCodeEmailAlreadyVerified, errors.New(constants.ErrEmailAlreadyVerified)
+	}
+	if now.Sub(user.OTP_issued_at) < otpResendCooldown {
+		return constants.ErrCodeOTPResendCooldown, errors.New(constants.ErrOTPResendCooldown)
+	}
+
+	count, err = uc.repo.CountOTPSendsByEmail(email, since)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	if count >= otpMaxResendsPerEmail {
+		return constants.ErrCodeOTPResendLimit, errors.New(constants.ErrOTPResendLimit)
+	}
+
+	issueOTP(user)
+	err = uc.repo.UpdateOTP(user)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	err = uc.repo.CreateOTPSend(&OTPSend{Email: email, IP: ip, Created_at: now})
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	return uc.SendEmailVerification(user)
 }
 
-func (uc *userUseCase) Login(user *User) (*User, int, error) {
-	request_fcm := user.FCMToken
-	user, err := uc.repo.Login(user)
+// Login checks the email and password of user. Failed logins are counted per
+// account and per IP address; once they pile up the caller has to wait, and
+// the owner is emailed when the account gets locked.
+func (uc *userUseCase) Login(user *User, ip string) (*User, int, error) {
+	email, password := user.Email, user.Password
+
+	err := uc.lockout.Check("user", email, ip)
+	if err != nil {
+		var blocked *lockout.BlockedError
+		if errors.As(err, &blocked) {
+			return nil, blocked.Code(), blocked
+		}
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	user, err = uc.repo.Login(user)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			code, err := uc.loginFailed(nil, email, ip)
+			return nil, code, err
+		}
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if !ComparePass([]byte(user.Password), []byte(password)) {
+		code, err := uc.loginFailed(user, email, ip)
+		return nil, code, err
+	}
+
+	err = uc.lockout.Succeed("user", email)
 	if err != nil {
 		return nil, constants.ErrCodeBadRequest, err
 	}
 	if !user.Is_Active {
 		return nil, constants.ErrCodeEmailNotValidatedYet, errors.New(constants.ErrEmailNotValidatedYet)
 	}
-	response_fcm := user.FCMToken
-	fmt.Println("FCM token changed")
-	if request_fcm != response_fcm {
-		user.FCMToken = request_fcm
-		err = uc.repo.UpdateFCMToken(uint(user.ID), user.FCMToken)
+	return user, constants.CodeSuccess, nil
+}
+
+// loginFailed counts a failed login and tells the owner of user when it
+// locked the account. user is nil when no account has the email.
+func (uc *userUseCase) loginFailed(user *User, email, ip string) (int, error) {
+	locked, err := uc.lockout.Fail("user", email, ip)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	if locked && user != nil {
+		data := struct {
+			Username string
+		}{
+			Username: user.Name,
+		}
+		err = helper.SendTemplateEmail(templateDir, user.Email, "Account Locked", "account_locked.html", data)
 		if err != nil {
-			return nil, constants.ErrCodeBadRequest, err
+			log.Println("Failed to send lockout email:", err)
 		}
 	}
-	fmt.Println("FCM token updated")
-	return user, constants.CodeSuccess, nil
+	return constants.ErrCodeInvalidCredentials, errors.New(constants.ErrInvalidCredentials)
 }
 
 func (uc *userUseCase) GetUserProfile(id uint) (*User, int, error) {
@@ -147,10 +287,477 @@ func (uc *userUseCase) GetUser(email string) (*User, int, error) {
 	return user, constants.CodeSuccess, nil
 }
 
-func (uc *userUseCase) ResetPassword(email, password string) (int, error) {
-	err := uc.repo.ResetPassword(email, password)
+// UpdateProfile changes the display name of the user and returns the
+// updated profile.
+func (uc *userUseCase) UpdateProfile(id uint, name string) (*User, int, error) {
+	err := uc.repo.UpdateName(int(id), name)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return uc.GetUserProfile(id)
+}
+
+// UpdateAvatar sets the profile picture to url, an image already uploaded
+// to Cloudinary.
+func (uc *userUseCase) UpdateAvatar(id uint, url string) (*User, int, error) {
+	err := uc.repo.UpdateAvatar(int(id), url)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return uc.GetUserProfile(id)
+}
+
+// SetTimezoneListener registers the listener told about time zone changes.
+// The modules keeping schedules depend on this one, so they are wired in
+// from main.
+func (uc *userUseCase) SetTimezoneListener(listener TimezoneListener) {
+	uc.timezones = listener
+}
+
+// UpdateTimezone sets the IANA time zone the schedules of the user are
+// evaluated in.
+func (uc *userUseCase) UpdateTimezone(id uint, name string) (*User, int, error) {
+	loc, err := timezone.Load(name)
+	if err != nil {
+		return nil, constants.ErrCodeInvalidTimezone, errors.New(constants.ErrInvalidTimezone)
+	}
+
+	user, code, err := uc.GetUserProfile(id)
+	if err != nil {
+		return nil, code, err
+	}
+	if user.Timezone == loc.String() {
+		return user, constants.CodeSuccess, nil
+	}
+
+	err = uc.repo.UpdateTimezone(int(id), loc.String())
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if uc.timezones != nil {
+		err = uc.timezones.TimezoneChanged(id, loc.String())
+		if err != nil {
+			log.Println("Failed to move schedules to the new time zone:", err)
+		}
+	}
+	return uc.GetUserProfile(id)
+}
+
+// DetectTimezone sets the time zone from the coordinates of the user, for
+// users who have not set one.
+func (uc *userUseCase) DetectTimezone(id uint, lat, lon float64) (int, error) {
+	user, code, err := uc.GetUserProfile(id)
+	if err != nil {
+		return code, err
+	}
+	if user.Timezone != "" {
+		return constants.CodeSuccess, nil
+	}
+
+	name := timezone.FromCoordinates(lat, lon)
+	if name == "" {
+		return constants.ErrCodeUnknownLocation, errors.New(constants.ErrUnknownLocation)
+	}
+	_, code, err = uc.UpdateTimezone(id, name)
+	return code, err
+}
+
+// UpdateLocation saves the location of the user. Users who have not set a
+// time zone get the zone of the location, like DetectTimezone.
+func (uc *userUseCase) UpdateLocation(id uint, lat, lon float64) (*User, int, error) {
+	user, code, err := uc.GetUserProfile(id)
+	if err != nil {
+		return nil, code, err
+	}
+
+	err = uc.repo.UpdateLocation(int(id), &lat, &lon)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if user.Timezone == "" {
+		if name := timezone.FromCoordinates(lat, lon); name != "" {
+			_, code, err = uc.UpdateTimezone(id, name)
+			if err != nil {
+				return nil, code, err
+			}
+		}
+	}
+	return uc.GetUserProfile(id)
+}
+
+// DeleteLocation forgets the location of the user. The time zone stays.
+func (uc *userUseCase) DeleteLocation(id uint) (*User, int, error) {
+	_, code, err := uc.GetUserProfile(id)
+	if err != nil {
+		return nil, code, err
+	}
+
+	err = uc.repo.UpdateLocation(int(id), nil, nil)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return uc.GetUserProfile(id)
+}
+
+// ChangePassword replaces the password with the hashed password after
+// checking the current one, then logs out every other session of the user.
+func (uc *userUseCase) ChangePassword(id uint, sessionID, current, password string) (int, error) {
+	user, err := uc.repo.GetUserProfile(id)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	if !ComparePass([]byte(user.Password), []byte(current)) {
+		return constants.ErrCodeInvalidPassword, errors.New(constants.ErrInvalidPassword)
+	}
+
+	err = uc.repo.UpdatePassword(user.ID, password)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	err = uc.sessions.RevokeOtherSessions(id, "user", sessionID)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	return constants.CodeSuccess, nil
+}
+
+// RequestEmailChange sends an OTP to the new email. The email of the account
+// only changes once ConfirmEmailChange gets that OTP.
+func (uc *userUseCase) RequestEmailChange(id uint, email string) (int, error) {
+	user, err := uc.repo.GetUserProfile(id)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	if user.Pending_email != "" && time.Since(user.OTP_issued_at) < otpResendCooldown {
+		return constants.ErrCodeOTPResendCooldown, errors.New(constants.ErrOTPResendCooldown)
+	}
+
+	duplicate, err := uc.repo.IsDuplicateEmail(email)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	if duplicate {
+		return constants.ErrCodeEmailAlreadyExist, errors.New(constants.ErrEmailAlreadyExist)
+	}
+
+	user.Pending_email = email
+	issueOTP(user)
+	err = uc.repo.SetPendingEmail(user)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	data := struct {
+		Username string
+		OTP      string
+		Minutes  int
+	}{
+		Username: user.Name,
+		OTP:      user.OTP,
+		Minutes:  int(otpTTL.Minutes()),
+	}
+	err = helper.SendTemplateEmail(templateDir, email, "Verify Your New Email", "change_email.html", data)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	return constants.CodeSuccess, nil
+}
+
+// ConfirmEmailChange switches the account to the pending email when otp
+// matches, and tells the old email about the change.
+func (uc *userUseCase) ConfirmEmailChange(id uint, otp string) (*User, int, error) {
+	user, err := uc.repo.GetUserProfile(id)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if user.Pending_email == "" {
+		return nil, constants.ErrCodeNoPendingEmail, errors.New(constants.ErrNoPendingEmail)
+	}
+	if time.Now().After(user.OTP_expires_at) {
+		return nil, constants.ErrCodeOTPExpired, errors.New(constants.ErrOTPExpired)
+	}
+
+	ok, err := uc.repo.UseOTPAttempt(user.ID, otpMaxAttempts)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if !ok {
+		return nil, constants.ErrCodeOTPLocked, errors.New(constants.ErrOTPLocked)
+	}
+	if subtle.ConstantTimeCompare([]byte(user.OTP), []byte(otp)) != 1 {
+		return nil, constants.ErrCodeInvalidOTP, errors.New(constants.ErrInvalidOTP)
+	}
+
+	// The email may have been registered since the OTP was sent
+	duplicate, err := uc.repo.IsDuplicateEmail(user.Pending_email)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if duplicate {
+		return nil, constants.ErrCodeEmailAlreadyExist, errors.New(constants.ErrEmailAlreadyExist)
+	}
+
+	err = uc.repo.ChangeEmail(user.ID, user.Pending_email)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	data := struct {
+		Username string
+		Email    string
+	}{
+		Username: user.Name,
+		Email:    user.Pending_email,
+	}
+	err = helper.SendTemplateEmail(templateDir, user.Email, "Your Email Was Changed", "email_changed.html", data)
+	if err != nil {
+		log.Println("Failed to send email change notice:", err)
+	}
+
+	return uc.GetUserProfile(id)
+}
+
+// RequestPasswordReset emails a single-use reset link to the owner of the
+// address and logs out every device of the account. Unknown addresses and
+// failed emails are not reported so the endpoint cannot be used to discover
+// registered emails.
+func (uc *userUseCase) RequestPasswordReset(email string) (int, error) {
+	user, err := uc.repo.GetUser(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return constants.CodeSuccess, nil
+		}
+		return constants.ErrCodeBadRequest, err
+	}
+
+	token := helper.RandomToken()
+	reset := &PasswordReset{
+		UserID:     user.ID,
+		Token:      helper.HashToken(token),
+		Expires_at: time.Now().Add(passwordResetTTL),
+		Created_at: time.Now(),
+	}
+	err = uc.repo.CreatePasswordReset(reset)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	err = uc.sessions.RevokeAllSessions(uint(user.ID), "user")
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	data := struct {
+		Username string
+		Link     string
+		Minutes  int
+	}{
+		Username: user.Name,
+		Link:     RESET_PASSWORD_URL + "?token=" + token,
+		Minutes:  int(passwordResetTTL.Minutes()),
+	}
+	err = helper.SendTemplateEmail(templateDir, user.Email, "Reset Password", "reset_password.html", data)
+	if err != nil {
+		log.Println("Failed to send password reset email:", err)
+	}
+
+	return constants.CodeSuccess, nil
+}
+
+func (uc *userUseCase) VerifyPasswordReset(token string) (int, error) {
+	_, err := uc.getValidPasswordReset(token)
+	if err != nil {
+		return constants.ErrCodeInvalidResetToken, err
+	}
+	return constants.CodeSuccess, nil
+}
+
+func (uc *userUseCase) ResetPassword(token, password string) (int, error) {
+	reset, err := uc.getValidPasswordReset(token)
+	if err != nil {
+		return constants.ErrCodeInvalidResetToken, err
+	}
+
+	err = uc.repo.ResetPassword(reset, password)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return constants.ErrCodeInvalidResetToken, errors.New(constants.ErrInvalidResetToken)
+	}
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	// Log out every device that signed in since the reset was requested
+	err = uc.sessions.RevokeAllSessions(uint(reset.UserID), "user")
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	return constants.CodeSuccess, nil
+}
+
+func (uc *userUseCase) getValidPasswordReset(token string) (*PasswordReset, error) {
+	reset, err := uc.repo.GetPasswordReset(helper.HashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(constants.ErrInvalidResetToken)
+		}
+		return nil, err
+	}
+	if reset.Used_at != nil || time.Now().After(reset.Expires_at) {
+		return nil, errors.New(constants.ErrInvalidResetToken)
+	}
+	return reset, nil
+}
+
+// CreateOAuthState returns the state parameter of a new OAuth flow. A non
+// zero linkUserID makes the flow link the provider account to that user.
+func (uc *userUseCase) CreateOAuthState(linkUserID uint) (string, int, error) {
+	state := helper.RandomToken()
+	oauthState := &OAuthState{
+		State:      helper.HashToken(state),
+		Expires_at: time.Now().Add(oauthStateTTL),
+		Created_at: time.Now(),
+	}
+	if linkUserID != 0 {
+		id := int(linkUserID)
+		oauthState.UserID = &id
+	}
+
+	err := uc.repo.CreateOAuthState(oauthState)
+	if err != nil {
+		return "", constants.ErrCodeBadRequest, err
+	}
+	return state, constants.CodeSuccess, nil
+}
+
+func (uc *userUseCase) ConsumeOAuthState(state string) (*OAuthState, int, error) {
+	oauthState, err := uc.repo.ConsumeOAuthState(helper.HashToken(state))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constants.ErrCodeInvalidOAuthState, errors.New(constants.ErrInvalidOAuthState)
+		}
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if time.Now().After(oauthState.Expires_at) {
+		return nil, constants.ErrCodeInvalidOAuthState, errors.New(constants.ErrInvalidOAuthState)
+	}
+	return oauthState, constants.CodeSuccess, nil
+}
+
+// LoginWithProvider returns the user linked to the provider account. Unknown
+// provider accounts are linked to the user with the same verified email, or
+// to a new user when there is none.
+func (uc *userUseCase) LoginWithProvider(profile *OAuthProfile) (*User, int, error) {
+	identity, err := uc.repo.GetIdentity(profile.Provider, profile.Subject)
+	if err == nil {
+		user, err := uc.repo.GetUserProfile(uint(identity.UserID))
+		if err != nil {
+			return nil, constants.ErrCodeBadRequest, err
+		}
+		return user, constants.CodeSuccess, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+
+	if !profile.EmailVerified {
+		return nil, constants.ErrCodeEmailNotVerified, errors.New(constants.ErrEmailNotVerified)
+	}
+
+	user, err := uc.repo.GetUser(profile.Email)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		user = &User{
+			Name:       profile.Name,
+			Email:      profile.Email,
+			Url_Image:  profile.Picture,
+			Created_at: time.Now(),
+		}
+	case err != nil:
+		return nil, constants.ErrCodeBadRequest, err
+	case !user.Is_Active:
+		// Whoever registered this address never proved they own it, the
+		// provider did. Drop the unverified password so only the owner can
+		// sign in.
+		user.Password = ""
+		user.OTP = ""
+	}
+	user.Is_Active = true
+	user.Updated_at = time.Now()
+
+	identity = &Identity{
+		Provider:   profile.Provider,
+		Subject:    profile.Subject,
+		Email:      profile.Email,
+		Created_at: time.Now(),
+	}
+	err = uc.repo.SaveUserWithIdentity(user, identity)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return user, constants.CodeSuccess, nil
+}
+
+// LinkProvider links the provider account to an existing user, whatever
+// email the provider account uses.
+func (uc *userUseCase) LinkProvider(userID uint, profile *OAuthProfile) (int, error) {
+	identity, err := uc.repo.GetIdentity(profile.Provider, profile.Subject)
+	if err == nil {
+		if uint(identity.UserID) == userID {
+			return constants.CodeSuccess, nil
+		}
+		return constants.ErrCodeIdentityLinked, errors.New(constants.ErrIdentityLinked)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	_, err = uc.repo.GetUserIdentity(userID, profile.Provider)
+	if err == nil {
+		return constants.ErrCodeIdentityLinked, errors.New(constants.ErrIdentityLinked)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	err = uc.repo.CreateIdentity(&Identity{
+		UserID:     int(userID),
+		Provider:   profile.Provider,
+		Subject:    profile.Subject,
+		Email:      profile.Email,
+		Created_at: time.Now(),
+	})
 	if err != nil {
 		return constants.ErrCodeBadRequest, err
 	}
 	return constants.CodeSuccess, nil
 }
+
+func (uc *userUseCase) UnlinkProvider(userID uint, provider string) (int, error) {
+	user, err := uc.repo.GetUserProfile(userID)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	// Users created from a provider login have no password to fall back on
+	if user.Password == "" {
+		return constants.ErrCodeLastLoginMethod, errors.New(constants.ErrLastLoginMethod)
+	}
+
+	deleted, err := uc.repo.DeleteIdentity(userID, provider)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	if !deleted {
+		return constants.ErrCodeIdentityNotFound, errors.New(constants.ErrIdentityNotFound)
+	}
+	return constants.CodeSuccess, nil
+}
+
+// issueOTP gives the user a fresh verification code with a full set of
+// attempts.
+func issueOTP(user *User) {
+	user.OTP = RandomOTP()
+	user.OTP_issued_at = time.Now()
+	user.OTP_expires_at = user.OTP_issued_at.Add(otpTTL)
+	user.OTP_attempts = 0
+}
@@ -0,0 +1,520 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/modules/lockout"
+	"github.com/OctavianoRyan25/be-agriculture/modules/session"
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// MockRepository implements the methods used by the tests, the embedded
+// Repository panics on anything else.
+type MockRepository struct {
+	mock.Mock
+	Repository
+}
+
+func (m *MockRepository) GetUser(email string) (*User, error) {
+	args := m.Called(email)
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockRepository) Login(user *User) (*User, error) {
+	args := m.Called(user.Email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockRepository) GetUserProfile(id uint) (*User, error) {
+	args := m.Called(id)
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockRepository) GetIdentity(provider, subject string) (*Identity, error) {
+	args := m.Called(provider, subject)
+	return args.Get(0).(*Identity), args.Error(1)
+}
+
+func (m *MockRepository) GetUserIdentity(userID uint, provider string) (*Identity, error) {
+	args := m.Called(userID, provider)
+	return args.Get(0).(*Identity), args.Error(1)
+}
+
+func (m *MockRepository) CreateIdentity(identity *Identity) error {
+	args := m.Called(identity)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SaveUserWithIdentity(user *User, identity *Identity) error {
+	args := m.Called(user, identity)
+	return args.Error(0)
+}
+
+func (m *MockRepository) UseOTPAttempt(id, max int) (bool, error) {
+	args := m.Called(id, max)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ActivateUser(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CountOTPSendsByIP(ip string, since time.Time) (int64, error) {
+	args := m.Called(ip, since)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) UpdatePassword(id int, password string) error {
+	args := m.Called(id, password)
+	return args.Error(0)
+}
+
+func (m *MockRepository) IsDuplicateEmail(email string) (bool, error) {
+	args := m.Called(email)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ChangeEmail(id int, email string) error {
+	args := m.Called(id, email)
+	return args.Error(0)
+}
+
+func (m *MockRepository) UpdateTimezone(id int, timezone string) error {
+	args := m.Called(id, timezone)
+	return args.Error(0)
+}
+
+func (m *MockRepository) UpdateLocation(id int, lat, lon *float64) error {
+	args := m.Called(id, lat, lon)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetPasswordReset(token string) (*PasswordReset, error) {
+	args := m.Called(token)
+	return args.Get(0).(*PasswordReset), args.Error(1)
+}
+
+func (m *MockRepository) CreatePasswordReset(reset *PasswordReset) error {
+	args := m.Called(reset)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ResetPassword(reset *PasswordReset, password string) error {
+	args := m.Called(reset, password)
+	return args.Error(0)
+}
+
+// MockSessions implements the session methods used by the tests.
+type MockSessions struct {
+	mock.Mock
+	session.UseCase
+}
+
+func (m *MockSessions) RevokeAllSessions(accountID uint, role string) error {
+	args := m.Called(accountID, role)
+	return args.Error(0)
+}
+
+func (m *MockSessions) RevokeOtherSessions(accountID uint, role, keepID string) error {
+	args := m.Called(accountID, role, keepID)
+	return args.Error(0)
+}
+
+type MockLockout struct {
+	mock.Mock
+}
+
+func (m *MockLockout) Check(scope, email, ip string) error {
+	args := m.Called(scope, email, ip)
+	return args.Error(0)
+}
+
+func (m *MockLockout) Fail(scope, email, ip string) (bool, error) {
+	args := m.Called(scope, email, ip)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockLockout) Succeed(scope, email string) error {
+	args := m.Called(scope, email)
+	return args.Error(0)
+}
+
+type MockTimezoneListener struct {
+	mock.Mock
+}
+
+func (m *MockTimezoneListener) TimezoneChanged(userID uint, timezone string) error {
+	args := m.Called(userID, timezone)
+	return args.Error(0)
+}
+
+var googleProfile = &OAuthProfile{
+	Provider:      "google",
+	Subject:       "1234567890",
+	Email:         "farmer@example.com",
+	EmailVerified: true,
+	Name:          "Farmer",
+}
+
+func TestLoginWithProviderReturnsLinkedUser(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	mockRepo.On("GetIdentity", "google", "1234567890").Return(&Identity{UserID: 7}, nil)
+	mockRepo.On("GetUserProfile", uint(7)).Return(&User{ID: 7}, nil)
+
+	user, _, err := service.LoginWithProvider(googleProfile)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 7, user.ID)
+	mockRepo.AssertNotCalled(t, "SaveUserWithIdentity", mock.Anything, mock.Anything)
+}
+
+func TestLoginWithProviderLinksExistingEmail(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	existing := &User{ID: 3, Email: "farmer@example.com", Password: "hash", Is_Active: true}
+	mockRepo.On("GetIdentity", "google", "1234567890").Return((*Identity)(nil), gorm.ErrRecordNotFound)
+	mockRepo.On("GetUser", "farmer@example.com").Return(existing, nil)
+	mockRepo.On("SaveUserWithIdentity", existing, mock.AnythingOfType("*user.Identity")).Return(nil)
+
+	user, _, err := service.LoginWithProvider(googleProfile)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, user.ID)
+	assert.Equal(t, "hash", user.Password)
+}
+
+func TestLoginWithProviderClaimsUnverifiedAccount(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	existing := &User{ID: 3, Email: "farmer@example.com", Password: "hash", OTP: "123456"}
+	mockRepo.On("GetIdentity", "google", "1234567890").Return((*Identity)(nil), gorm.ErrRecordNotFound)
+	mockRepo.On("GetUser", "farmer@example.com").Return(existing, nil)
+	mockRepo.On("SaveUserWithIdentity", existing, mock.AnythingOfType("*user.Identity")).Return(nil)
+
+	user, _, err := service.LoginWithProvider(googleProfile)
+
+	assert.NoError(t, err)
+	assert.True(t, user.Is_Active)
+	assert.Empty(t, user.Password)
+}
+
+func TestLoginWithProviderRequiresVerifiedEmail(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	profile := *googleProfile
+	profile.EmailVerified = false
+	mockRepo.On("GetIdentity", "google", "1234567890").Return((*Identity)(nil), gorm.ErrRecordNotFound)
+
+	_, _, err := service.LoginWithProvider(&profile)
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "GetUser", mock.Anything)
+}
+
+func TestLinkProviderRejectsAccountOfAnotherUser(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	mockRepo.On("GetIdentity", "google", "1234567890").Return(&Identity{UserID: 9}, nil)
+
+	code, err := service.LinkProvider(3, googleProfile)
+
+	assert.Error(t, err)
+	assert.Equal(t, 409, code)
+	mockRepo.AssertNotCalled(t, "CreateIdentity", mock.Anything)
+}
+
+func TestVerifyEmailActivatesUser(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	user := &User{ID: 4, OTP: "1234", OTP_expires_at: time.Now().Add(time.Minute)}
+	mockRepo.On("GetUser", "farmer@example.com").Return(user, nil)
+	mockRepo.On("UseOTPAttempt", 4, otpMaxAttempts).Return(true, nil)
+	mockRepo.On("ActivateUser", 4).Return(nil)
+
+	_, err := service.VerifyEmail("farmer@example.com", "1234")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyEmailRejectsExpiredOTP(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	user := &User{ID: 4, OTP: "1234", OTP_expires_at: time.Now().Add(-time.Minute)}
+	mockRepo.On("GetUser", "farmer@example.com").Return(user, nil)
+
+	code, err := service.VerifyEmail("farmer@example.com", "1234")
+
+	assert.Error(t, err)
+	assert.Equal(t, 410, code)
+	mockRepo.AssertNotCalled(t, "ActivateUser", mock.Anything)
+}
+
+func TestVerifyEmailLocksAfterMaxAttempts(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	user := &User{ID: 4, OTP: "1234", OTP_expires_at: time.Now().Add(time.Minute)}
+	mockRepo.On("GetUser", "farmer@example.com").Return(user, nil)
+	mockRepo.On("UseOTPAttempt", 4, otpMaxAttempts).Return(false, nil)
+
+	code, err := service.VerifyEmail("farmer@example.com", "1234")
+
+	assert.Error(t, err)
+	assert.Equal(t, 423, code)
+	mockRepo.AssertNotCalled(t, "ActivateUser", mock.Anything)
+}
+
+func TestResendOTPThrottlesIP(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	mockRepo.On("CountOTPSendsByIP", "10.0.0.1", mock.Anything).Return(int64(otpMaxResendsPerIP), nil)
+
+	code, err := service.ResendOTP("farmer@example.com", "10.0.0.1")
+
+	assert.Error(t, err)
+	assert.Equal(t, 429, code)
+	mockRepo.AssertNotCalled(t, "GetUser", mock.Anything)
+}
+
+func TestLoginCountsWrongPassword(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockLockout := new(MockLockout)
+	mockRepo.On("Login", "farmer@example.com").Return(&User{ID: 1, Email: "farmer@example.com", Password: HashPass("secret"), Is_Active: true}, nil)
+	mockLockout.On("Check", "user", "farmer@example.com", "10.0.0.1").Return(nil)
+	mockLockout.On("Fail", "user", "farmer@example.com", "10.0.0.1").Return(false, nil)
+	service := NewUseCase(mockRepo, nil, mockLockout)
+
+	_, code, err := service.Login(&User{Email: "farmer@example.com", Password: "wrong"}, "10.0.0.1")
+
+	assert.EqualError(t, err, constants.ErrInvalidCredentials)
+	assert.Equal(t, constants.ErrCodeInvalidCredentials, code)
+	mockLockout.AssertExpectations(t)
+}
+
+func TestLoginCountsUnknownEmail(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockLockout := new(MockLockout)
+	mockRepo.On("Login", "nobody@example.com").Return(nil, gorm.ErrRecordNotFound)
+	mockLockout.On("Check", "user", "nobody@example.com", "10.0.0.1").Return(nil)
+	mockLockout.On("Fail", "user", "nobody@example.com", "10.0.0.1").Return(true, nil)
+	service := NewUseCase(mockRepo, nil, mockLockout)
+
+	_, _, err := service.Login(&User{Email: "nobody@example.com", Password: "secret"}, "10.0.0.1")
+
+	assert.EqualError(t, err, constants.ErrInvalidCredentials)
+	mockLockout.AssertExpectations(t)
+}
+
+func TestLoginResetsFailures(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockLockout := new(MockLockout)
+	mockRepo.On("Login", "farmer@example.com").Return(&User{ID: 1, Email: "farmer@example.com", Password: HashPass("secret"), Is_Active: true}, nil)
+	mockLockout.On("Check", "user", "farmer@example.com", "10.0.0.1").Return(nil)
+	mockLockout.On("Succeed", "user", "farmer@example.com").Return(nil)
+	service := NewUseCase(mockRepo, nil, mockLockout)
+
+	user, _, err := service.Login(&User{Email: "farmer@example.com", Password: "secret"}, "10.0.0.1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, user.ID)
+	mockLockout.AssertExpectations(t)
+}
+
+func TestLoginRejectsBlockedAccount(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockLockout := new(MockLockout)
+	mockLockout.On("Check", "user", "farmer@example.com", "10.0.0.1").Return(&lockout.BlockedError{RetryAfter: time.Minute, Locked: true})
+	service := NewUseCase(mockRepo, nil, mockLockout)
+
+	_, code, err := service.Login(&User{Email: "farmer@example.com", Password: "secret"}, "10.0.0.1")
+
+	assert.EqualError(t, err, constants.ErrAccountLocked)
+	assert.Equal(t, constants.ErrCodeAccountLocked, code)
+	mockRepo.AssertNotCalled(t, "Login", "farmer@example.com")
+}
+
+func TestChangePasswordRequiresCurrentPassword(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetUserProfile", uint(1)).Return(&User{ID: 1, Password: HashPass("secret")}, nil)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	code, err := service.ChangePassword(1, "session", "wrong", "new")
+
+	assert.EqualError(t, err, constants.ErrInvalidPassword)
+	assert.Equal(t, constants.ErrCodeInvalidPassword, code)
+	mockRepo.AssertNotCalled(t, "UpdatePassword", 1, "new")
+}
+
+func TestChangePasswordRevokesOtherSessions(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockSessions := new(MockSessions)
+	mockRepo.On("GetUserProfile", uint(1)).Return(&User{ID: 1, Password: HashPass("secret")}, nil)
+	mockRepo.On("UpdatePassword", 1, "new").Return(nil)
+	mockSessions.On("RevokeOtherSessions", uint(1), "user", "session").Return(nil)
+	service := NewUseCase(mockRepo, mockSessions, nil)
+
+	_, err := service.ChangePassword(1, "session", "secret", "new")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockSessions.AssertExpectations(t)
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetUser", "budi@example.com").Return(&User{ID: 1, Email: "budi@example.com"}, nil)
+	mockRepo.On("GetUser", "nobody@example.com").Return((*User)(nil), gorm.ErrRecordNotFound)
+	mockRepo.On("CreatePasswordReset", mock.AnythingOfType("*user.PasswordReset")).Return(nil)
+	mockSessions := new(MockSessions)
+	mockSessions.On("RevokeAllSessions", uint(1), "user").Return(nil)
+	service := NewUseCase(mockRepo, mockSessions, nil)
+
+	// The email cannot be sent in tests, which is not reported either.
+	code, err := service.RequestPasswordReset("budi@example.com")
+
+	assert.NoError(t, err)
+	assert.Equal(t, constants.CodeSuccess, code)
+	mockSessions.AssertCalled(t, "RevokeAllSessions", uint(1), "user")
+
+	code, err = service.RequestPasswordReset("nobody@example.com")
+
+	assert.NoError(t, err)
+	assert.Equal(t, constants.CodeSuccess, code)
+	mockSessions.AssertNumberOfCalls(t, "RevokeAllSessions", 1)
+}
+
+func TestResetPasswordRejectsTokenUsedMeanwhile(t *testing.T) {
+	mockRepo := new(MockRepository)
+	reset := &PasswordReset{ID: 4, UserID: 1, Expires_at: time.Now().Add(time.Hour)}
+	mockRepo.On("GetPasswordReset", helper.HashToken("token")).Return(reset, nil)
+	// Another request used the token after it was looked up.
+	mockRepo.On("ResetPassword", reset, "new").Return(gorm.ErrRecordNotFound)
+	mockSessions := new(MockSessions)
+	service := NewUseCase(mockRepo, mockSessions, nil)
+
+	code, err := service.ResetPassword("token", "new")
+
+	assert.EqualError(t, err, constants.ErrInvalidResetToken)
+	assert.Equal(t, constants.ErrCodeInvalidResetToken, code)
+	mockSessions.AssertNotCalled(t, "RevokeAllSessions", mock.Anything, mock.Anything)
+}
+
+func TestRequestEmailChangeRejectsTakenEmail(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetUserProfile", uint(1)).Return(&User{ID: 1, Email: "farmer@example.com"}, nil)
+	mockRepo.On("IsDuplicateEmail", "taken@example.com").Return(true, nil)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	code, err := service.RequestEmailChange(1, "taken@example.com")
+
+	assert.EqualError(t, err, constants.ErrEmailAlreadyExist)
+	assert.Equal(t, constants.ErrCodeEmailAlreadyExist, code)
+}
+
+func TestConfirmEmailChangeSwitchesEmail(t *testing.T) {
+	mockRepo := new(MockRepository)
+	user := &User{ID: 1, Email: "farmer@example.com", Pending_email: "new@example.com", OTP: "123456", OTP_expires_at: time.Now().Add(time.Minute)}
+	mockRepo.On("GetUserProfile", uint(1)).Return(user, nil)
+	mockRepo.On("UseOTPAttempt", 1, otpMaxAttempts).Return(true, nil)
+	mockRepo.On("IsDuplicateEmail", "new@example.com").Return(false, nil)
+	mockRepo.On("ChangeEmail", 1, "new@example.com").Return(nil)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	_, _, err := service.ConfirmEmailChange(1, "123456")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfirmEmailChangeRequiresPendingEmail(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetUserProfile", uint(1)).Return(&User{ID: 1, Email: "farmer@example.com"}, nil)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	_, code, err := service.ConfirmEmailChange(1, "123456")
+
+	assert.EqualError(t, err, constants.ErrNoPendingEmail)
+	assert.Equal(t, constants.ErrCodeNoPendingEmail, code)
+}
+
+func TestUpdateTimezoneMovesSchedules(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockListener := new(MockTimezoneListener)
+	mockRepo.On("GetUserProfile", uint(1)).Return(&User{ID: 1}, nil)
+	mockRepo.On("UpdateTimezone", 1, "Asia/Makassar").Return(nil)
+	mockListener.On("TimezoneChanged", uint(1), "Asia/Makassar").Return(nil)
+	service := NewUseCase(mockRepo, nil, nil)
+	service.SetTimezoneListener(mockListener)
+
+	_, _, err := service.UpdateTimezone(1, "Asia/Makassar")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockListener.AssertExpectations(t)
+}
+
+func TestUpdateTimezoneRejectsUnknownZone(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	_, code, err := service.UpdateTimezone(1, "Local")
+
+	assert.EqualError(t, err, constants.ErrInvalidTimezone)
+	assert.Equal(t, constants.ErrCodeInvalidTimezone, code)
+	mockRepo.AssertNotCalled(t, "UpdateTimezone", mock.Anything, mock.Anything)
+}
+
+func TestDetectTimezoneKeepsChosenZone(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetUserProfile", uint(1)).Return(&User{ID: 1, Timezone: "Asia/Jakarta"}, nil)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	_, err := service.DetectTimezone(1, -2.5, 140.7)
+
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "UpdateTimezone", mock.Anything, mock.Anything)
+}
+
+func TestUpdateLocationDetectsTimezone(t *testing.T) {
+	mockRepo := new(MockRepository)
+	lat, lon := -5.15, 119.4
+	mockRepo.On("GetUserProfile", uint(1)).Return(&User{ID: 1}, nil)
+	mockRepo.On("UpdateLocation", 1, &lat, &lon).Return(nil)
+	mockRepo.On("UpdateTimezone", 1, "Asia/Makassar").Return(nil)
+	service := NewUseCase(mockRepo, nil, nil)
+
+	_, _, err := service.UpdateLocation(1, lat, lon)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+
+	mockRepo = new(MockRepository)
+	mockRepo.On("GetUserProfile", uint(1)).Return(&User{ID: 1, Timezone: "Asia/Jakarta", Latitude: &lat, Longitude: &lon}, nil)
+	mockRepo.On("UpdateLocation", 1, (*float64)(nil), (*float64)(nil)).Return(nil)
+	service = NewUseCase(mockRepo, nil, nil)
+
+	_, _, err = service.DeleteLocation(1)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpdateTimezone", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,105 @@
+package wateringhistory
+
+import (
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+)
+
+const (
+	// complianceWindow is how far back waterings are compared with the
+	// schedule.
+	complianceWindow = 30 * 24 * time.Hour
+	// lateAfter is how long after a watering time a plant without a
+	// watering counts as late.
+	lateAfter = time.Hour
+	// earlyWatering is how long before a watering time a watering still
+	// counts for it.
+	earlyWatering = 2 * time.Hour
+)
+
+// checkCompliance compares the watering times of schedule after since with
+// the waterings, sorted from oldest. A watering counts for the watering time
+// it is closest ahead of: it must come at most earlyWatering before it and
+// before the next watering time takes over. Watering times that are less
+// than lateAfter ago are not counted yet, skipped ones are not counted at
+// all.
+func checkCompliance(schedule plant.PlantReminder, since, now time.Time, loc *time.Location, waterings []time.Time, skips []WateringSkip) Compliance {
+	c := Compliance{Status: ComplianceNoSchedule, Since: since.In(loc)}
+	if len(waterings) > 0 {
+		last := waterings[len(waterings)-1].In(loc)
+		c.LastWateredAt = &last
+	}
+	if len(schedule.Times) == 0 {
+		return c
+	}
+	c.Status = ComplianceOnTrack
+	c.NextDueAt = nextWatering(schedule, now, loc)
+
+	// Watering times up to the early window of now decide where the
+	// waterings of the last due watering time end.
+	occurrences := schedule.Occurrences(since, now.Add(earlyWatering), loc)
+	due := now.Add(-lateAfter)
+	next := 0
+	for i, at := range occurrences {
+		if at.After(due) {
+			break
+		}
+		end := now
+		if i+1 < len(occurrences) {
+			end = occurrences[i+1].Add(-earlyWatering)
+		}
+
+		met := false
+		for next < len(waterings) && waterings[next].Before(end) {
+			if !waterings[next].Before(at.Add(-earlyWatering)) {
+				met = true
+			}
+			next++
+		}
+		if skipped(skips, at) {
+			continue
+		}
+
+		c.Expected++
+		if met {
+			c.Met++
+			c.Missed = 0
+			c.OverdueSince = nil
+			continue
+		}
+		c.Missed++
+		if c.OverdueSince == nil {
+			overdue := at
+			c.OverdueSince = &overdue
+		}
+	}
+
+	if c.OverdueSince != nil {
+		c.Status = ComplianceOverdue
+		c.LateBy = now.Sub(*c.OverdueSince)
+	}
+	return c
+}
+
+// skipped reports whether one of skips excuses the watering time at.
+func skipped(skips []WateringSkip, at time.Time) bool {
+	for _, skip := range skips {
+		if !at.Before(skip.StartsAt) && at.Before(skip.EndsAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWatering returns the first watering time of schedule after now. It
+// gives up when there is none in more than a year.
+func nextWatering(schedule plant.PlantReminder, now time.Time, loc *time.Location) *time.Time {
+	for ahead := 7 * 24 * time.Hour; ahead <= 2*366*24*time.Hour; ahead *= 2 {
+		occurrences := schedule.Occurrences(now, now.Add(ahead), loc)
+		if len(occurrences) > 0 {
+			return &occurrences[0]
+		}
+	}
+	return nil
+}
@@ -1,15 +1,27 @@
 package wateringhistory
 
 import (
-	"fmt"
+	"errors"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/OctavianoRyan25/be-agriculture/base"
-	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+	"github.com/OctavianoRyan25/be-agriculture/constants"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
+const maxPhotoSize = 2 * 1024 * 1024
+
+var photoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
 type WateringHistoryController struct {
 	useCase WateringHistoryUseCase
 }
@@ -22,20 +34,42 @@ func NeWateringHistoryController(useCase WateringHistoryUseCase) *WateringHistor
 
 func (c *WateringHistoryController) StoreWateringHistory(ctx echo.Context) error {
 	userID := ctx.Get("user_id").(uint)
-	req := new(WateringHistoryRequest)
-	err := ctx.Bind(&req)
+	mapped, photo, _, errRes := c.bindWatering(ctx, userID)
+	if errRes != nil {
+		return ctx.JSON(errRes.Code, errRes)
+	}
+	if photo != nil {
+		defer photo.Close()
+	}
+
+	wh, code, err := c.useCase.StoreWateringHistory(mapped, readerOf(photo))
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
 			Message: err.Error(),
-			Code:    http.StatusUnprocessableEntity,
+			Code:    code,
 		}
-		return ctx.JSON(http.StatusUnprocessableEntity, errRes)
+		return ctx.JSON(code, errRes)
 	}
 
-	validate := validator.New()
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Watering history created",
+		Data:    MapWateringHistoryToResponse(wh),
+	}
+
+	return ctx.JSON(http.StatusCreated, res)
+}
 
-	err = validate.Struct(req)
+// GetAllWateringHistories lists the waterings of the user, newest first,
+// optionally of one plant and between two days.
+func (c *WateringHistoryController) GetAllWateringHistories(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	query := new(WateringHistoryQuery)
+	err := (&echo.DefaultBinder{}).BindQueryParams(ctx, query)
+	if err == nil {
+		err = validator.New().Struct(query)
+	}
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
@@ -45,12 +79,7 @@ func (c *WateringHistoryController) StoreWateringHistory(ctx echo.Context) error
 		return ctx.JSON(http.StatusBadRequest, errRes)
 	}
 
-	mapped := &WateringHistory{
-		PlantID: req.PlantID,
-		UserID:  int(userID),
-	}
-
-	wh, err := c.useCase.StoreWateringHistory(mapped)
+	location, err := c.useCase.GetUserLocation(userID)
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
@@ -59,58 +88,227 @@ func (c *WateringHistoryController) StoreWateringHistory(ctx echo.Context) error
 		}
 		return ctx.JSON(http.StatusInternalServerError, errRes)
 	}
+	filter := &WateringHistoryFilter{
+		UserID:      userID,
+		UserPlantID: query.UserPlantID,
+		PlantID:     query.PlantID,
+	}
+	if query.From != "" {
+		from, _ := time.ParseInLocation("2006-01-02", query.From, location)
+		filter.From = &from
+	}
+	if query.To != "" {
+		to, _ := time.ParseInLocation("2006-01-02", query.To, location)
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
 
-	mappedUser := user.MapUserToResponse(&wh.User)
-	mappedPlant := MapPlantToPlantResponse(&wh.Plant)
+	wh, code, err := c.useCase.GetAllWateringHistories(filter)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
 
-	mappedres := &WateringHistoryResponse{
-		Id:        wh.ID,
-		Plant:     *mappedPlant,
-		User:      *mappedUser,
-		CreatedAt: wh.CreatedAt,
+	mappedRes := []WateringHistoryResponse{}
+	for i := range wh {
+		mappedRes = append(mappedRes, MapWateringHistoryToResponse(&wh[i]))
 	}
 
 	res := base.SuccessResponse{
 		Status:  "success",
-		Message: "Watering history created",
-		Data:    mappedres,
+		Message: "Watering histories fetched",
+		Data:    mappedRes,
 	}
 
-	return ctx.JSON(http.StatusCreated, res)
+	return ctx.JSON(http.StatusOK, res)
 }
 
-func (c *WateringHistoryController) GetAllWateringHistories(ctx echo.Context) error {
+func (c *WateringHistoryController) GetWateringHistory(ctx echo.Context) error {
 	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	wh, code, err := c.useCase.GetWateringHistory(userID, id)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
+
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Watering history fetched",
+		Data:    MapWateringHistoryToResponse(wh),
+	}
 
-	wh, err := c.useCase.GetAllWateringHistories(userID)
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// UpdateWateringHistory replaces a logged watering with the request. The
+// photo is kept unless a new one is uploaded or remove_photo is set.
+func (c *WateringHistoryController) UpdateWateringHistory(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	changes, photo, removePhoto, errRes := c.bindWatering(ctx, userID)
+	if errRes != nil {
+		return ctx.JSON(errRes.Code, errRes)
+	}
+	if photo != nil {
+		defer photo.Close()
+	}
+
+	wh, code, err := c.useCase.UpdateWateringHistory(userID, id, changes, readerOf(photo), removePhoto)
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
 			Message: err.Error(),
-			Code:    http.StatusInternalServerError,
+			Code:    code,
 		}
-		return ctx.JSON(http.StatusInternalServerError, errRes)
+		return ctx.JSON(code, errRes)
 	}
 
-	var mappedRes []WateringHistoryResponse
-	for _, v := range wh {
-		mappedRes = append(mappedRes, WateringHistoryResponse{
-			Id:        v.ID,
-			Plant:     *MapPlantToPlantResponse(&v.Plant),
-			User:      *user.MapUserToResponse(&v.User),
-			CreatedAt: v.CreatedAt,
-		})
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Watering history updated",
+		Data:    MapWateringHistoryToResponse(wh),
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *WateringHistoryController) DeleteWateringHistory(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	id, _ := strconv.Atoi(ctx.Param("id"))
+	code, err := c.useCase.DeleteWateringHistory(userID, id)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
 	}
 
 	res := base.SuccessResponse{
 		Status:  "success",
-		Message: "Watering histories fetched",
-		Data:    mappedRes,
+		Message: "Watering history deleted",
 	}
 
 	return ctx.JSON(http.StatusOK, res)
 }
 
+// bindWatering reads a watering from a JSON or multipart request, and opens
+// its photo after checking it. The photo is nil when the request has none,
+// and WateredAt is zero.
+func (c *WateringHistoryController) bindWatering(ctx echo.Context, userID uint) (*WateringHistory, multipart.File, bool, *base.ErrorResponse) {
+	req := new(WateringHistoryRequest)
+	if err := ctx.Bind(req); err != nil {
+		return nil, nil, false, &base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return nil, nil, false, &base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	wh := &WateringHistory{
+		PlantID: req.PlantID,
+		UserID:  int(userID),
+		Amount:  req.Amount,
+		Unit:    req.Unit,
+		Method:  req.Method,
+		Note:    req.Note,
+	}
+	if req.UserPlantID != 0 {
+		wh.UserPlantID = &req.UserPlantID
+	}
+	if req.WateredAt != "" {
+		wh.WateredAt, _ = time.Parse(time.RFC3339, req.WateredAt)
+	}
+
+	photo, errRes := openPhoto(ctx)
+	if errRes != nil {
+		return nil, nil, false, errRes
+	}
+	return wh, photo, req.RemovePhoto, nil
+}
+
+// openPhoto opens the "photo" form file once it is known to be an image
+// that is not too large. It returns nil when the request has no photo.
+func openPhoto(ctx echo.Context) (multipart.File, *base.ErrorResponse) {
+	file, err := ctx.FormFile("photo")
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &base.ErrorResponse{
+			Status:  "error",
+			Message: "Failed to get uploaded photo",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	if file.Size > maxPhotoSize {
+		return nil, &base.ErrorResponse{
+			Status:  "error",
+			Message: constants.ErrInvalidImage,
+			Code:    constants.ErrCodeInvalidImage,
+		}
+	}
+
+	fileReader, err := file.Open()
+	if err != nil {
+		return nil, &base.ErrorResponse{
+			Status:  "error",
+			Message: "Failed to open uploaded file",
+			Code:    http.StatusInternalServerError,
+		}
+	}
+
+	// Check the content instead of trusting the declared content type
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(fileReader, head)
+	if !photoTypes[http.DetectContentType(head[:n])] {
+		fileReader.Close()
+		return nil, &base.ErrorResponse{
+			Status:  "error",
+			Message: constants.ErrInvalidImage,
+			Code:    constants.ErrCodeInvalidImage,
+		}
+	}
+	_, err = fileReader.Seek(0, io.SeekStart)
+	if err != nil {
+		fileReader.Close()
+		return nil, &base.ErrorResponse{
+			Status:  "error",
+			Message: "Failed to read uploaded file",
+			Code:    http.StatusInternalServerError,
+		}
+	}
+	return fileReader, nil
+}
+
+// readerOf returns photo as an io.Reader, keeping a missing photo nil
+// rather than a nil multipart.File in a non-nil interface.
+func readerOf(photo multipart.File) io.Reader {
+	if photo == nil {
+		return nil
+	}
+	return photo
+}
+
+// GetLateWateringHistories lists the plants of the user that missed their
+// last watering times, most late first.
 func (c *WateringHistoryController) GetLateWateringHistories(ctx echo.Context) error {
 	userID := ctx.Get("user_id").(uint)
 	if userID == 0 {
@@ -121,40 +319,64 @@ func (c *WateringHistoryController) GetLateWateringHistories(ctx echo.Context) e
 		}
 		return ctx.JSON(http.StatusBadRequest, errRes)
 	}
-	role := ctx.Get("role").(string)
-	if role != "user" {
+	summary, code, err := c.useCase.GetComplianceSummary(userID)
+	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
-			Message: "Forbidden access",
-			Code:    http.StatusForbidden,
+			Message: err.Error(),
+			Code:    code,
 		}
-		return ctx.JSON(http.StatusForbidden, errRes)
+		return ctx.JSON(code, errRes)
+	}
 
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Late watering history fetched",
+		Data:    MapComplianceSummaryToResponse(summary).OverduePlants,
 	}
 
-	notification, err := c.useCase.GetLateWateringHistories(userID)
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *WateringHistoryController) GetComplianceSummary(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	summary, code, err := c.useCase.GetComplianceSummary(userID)
 	if err != nil {
 		errRes := base.ErrorResponse{
 			Status:  "error",
 			Message: err.Error(),
-			Code:    http.StatusInternalServerError,
+			Code:    code,
 		}
-		return ctx.JSON(http.StatusInternalServerError, errRes)
+		return ctx.JSON(code, errRes)
 	}
 
-	mappedPlant := MapPlantToPlantResponse(&notification.Plant)
+	res := base.SuccessResponse{
+		Status:  "success",
+		Message: "Watering compliance fetched",
+		Data:    MapComplianceSummaryToResponse(summary),
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (c *WateringHistoryController) GetPlantCompliance(ctx echo.Context) error {
+	userID := ctx.Get("user_id").(uint)
+	plantID, _ := strconv.Atoi(ctx.Param("plant_id"))
+	userPlantID, _ := strconv.Atoi(ctx.QueryParam("user_plant_id"))
+	compliance, code, err := c.useCase.GetPlantCompliance(userID, plantID, userPlantID)
+	if err != nil {
+		errRes := base.ErrorResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Code:    code,
+		}
+		return ctx.JSON(code, errRes)
+	}
 
 	res := base.SuccessResponse{
 		Status:  "success",
-		Message: "Late watering history fetched",
-		Data: NotificationResponse{
-			Id:        notification.Id,
-			Title:     fmt.Sprintf("Hi there! You Forgot to Water your %s plant", notification.Plant.Name),
-			Body:      "Remember, your plants need water to thrive. Let's water them",
-			Plant:     *mappedPlant,
-			UserID:    notification.UserId,
-			CreatedAt: notification.CreatedAt,
-		},
+		Message: "Watering compliance fetched",
+		Data:    MapComplianceToResponse(compliance),
 	}
 
 	return ctx.JSON(http.StatusOK, res)
@@ -7,24 +7,92 @@ import (
 	"github.com/OctavianoRyan25/be-agriculture/modules/user"
 )
 
+// Methods a plant is watered with.
+const (
+	MethodCan       = "can"
+	MethodDrip      = "drip"
+	MethodSprinkler = "sprinkler"
+)
+
+// WateringHistory is a watering the user logged. WateredAt is when the plant
+// was watered, which may be before the entry was created. Amount is in Unit,
+// the unit of the plant's watering schedule unless the user gave another.
 type WateringHistory struct {
+	ID      int `gorm:"primaryKey"`
+	PlantID int
+	Plant   plant.Plant `gorm:"foreignKey:PlantID;references:ID"`
+	UserID  int
+	User    user.User `gorm:"foreignKey:UserID;references:ID"`
+	// UserPlantID is the plant of the user that was watered. It is nil for
+	// entries of a plant the user removed since.
+	UserPlantID *int            `gorm:"index"`
+	UserPlant   plant.UserPlant `gorm:"foreignKey:UserPlantID;references:ID;constraint:OnDelete:SET NULL"`
+	Amount      int
+	Unit        string    `gorm:"size:20"`
+	Method      string    `gorm:"size:16"`
+	PhotoURL    string    `gorm:"size:255"`
+	Note        string    `gorm:"size:500"`
+	WateredAt   time.Time `gorm:"index"`
+	// NotificationID is the reminder the plant was marked as watered from.
+	NotificationID *int `gorm:"uniqueIndex"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Reasons the watering times of a plant were skipped.
+const (
+	SkipReasonSkipToday = "skip_today"
+	SkipReasonRain      = "rain"
+)
+
+// WateringSkip excuses the watering times of a plant of the user from
+// StartsAt until EndsAt, because the user skipped the plant for the day or
+// the rain watered it. Skipped watering times are not expected, so they
+// neither count as met nor make the plant overdue.
+type WateringSkip struct {
 	ID        int `gorm:"primaryKey"`
+	UserID    int `gorm:"index"`
 	PlantID   int
-	Plant     plant.Plant `gorm:"foreignKey:PlantID;references:ID"`
-	UserID    int
-	User      user.User `gorm:"foreignKey:UserID;references:ID"`
+	Reason    string `gorm:"size:16"`
+	StartsAt  time.Time
+	EndsAt    time.Time
 	CreatedAt time.Time
-	UpdatedAt time.Time
 }
 
-type Notification struct {
-	Id        int `gorm:"primaryKey"`
-	Title     string
-	Body      string
-	UserId    int         `gorm:"foreignKey:UserID;references:ID"`
-	PlantId   int         `gorm:"foreignKey:PlantId;references:ID"`
-	Plant     plant.Plant `gorm:"foreignKey:PlantId;references:ID"`
-	IsRead    bool
-	CreatedAt time.Time
-	UpdatedAt time.Time
+// Compliance status of a plant of a user.
+const (
+	ComplianceOnTrack    = "on_track"
+	ComplianceOverdue    = "overdue"
+	ComplianceNoSchedule = "no_schedule"
+)
+
+// Compliance compares the watering schedule of a plant of the user with the
+// waterings logged for it since Since. Expected counts the watering times
+// that are due and were not skipped, Met those with a logged watering. A plant is overdue when
+// its last watering times passed without a watering: OverdueSince is the
+// first of them and Missed how many there are.
+type Compliance struct {
+	UserPlantID   int
+	Plant         plant.Plant
+	Status        string
+	Since         time.Time
+	Expected      int
+	Met           int
+	Missed        int
+	LastWateredAt *time.Time
+	OverdueSince  *time.Time
+	LateBy        time.Duration
+	NextDueAt     *time.Time
+}
+
+// ComplianceSummary counts the plants of a user by status, with the
+// overdue plants most late first.
+type ComplianceSummary struct {
+	Plants     int
+	OnTrack    int
+	Overdue    int
+	NoSchedule int
+	Expected   int
+	Met        int
+	Late       []Compliance
 }
@@ -1,6 +1,66 @@
 package wateringhistory
 
-import "github.com/OctavianoRyan25/be-agriculture/modules/plant"
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+	"github.com/robfig/cron/v3"
+)
+
+// defaultEscalateAfter is how long a plant may be overdue before the user
+// gets an escalation, when WATERING_ESCALATION_AFTER is not set.
+const defaultEscalateAfter = 24 * time.Hour
+
+// EscalateAfterFromEnv reads how long a plant may be overdue before it is
+// escalated from WATERING_ESCALATION_AFTER, like "24h". Zero turns
+// escalations off.
+func EscalateAfterFromEnv() time.Duration {
+	value := os.Getenv("WATERING_ESCALATION_AFTER")
+	if value == "" {
+		return defaultEscalateAfter
+	}
+	after, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid WATERING_ESCALATION_AFTER %q, using %s", value, defaultEscalateAfter)
+		return defaultEscalateAfter
+	}
+	return after
+}
+
+// StartComplianceWorker escalates overdue plants every hour.
+func StartComplianceWorker(useCase WateringHistoryUseCase) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+	c.AddFunc("@every 1h", func() {
+		_, err := useCase.EscalateOverduePlants()
+		if err != nil {
+			fmt.Printf("Failed to escalate overdue plants: %v\n", err)
+		}
+	})
+	c.Start()
+}
+
+func MapWateringHistoryToResponse(wh *WateringHistory) WateringHistoryResponse {
+	loc := wh.User.Location()
+	return WateringHistoryResponse{
+		Id:             wh.ID,
+		UserPlantID:    wh.UserPlantID,
+		CustomizeName:  wh.UserPlant.CustomizeName,
+		Plant:          *MapPlantToPlantResponse(&wh.Plant),
+		User:           *user.MapUserToResponse(&wh.User),
+		Amount:         wh.Amount,
+		Unit:           wh.Unit,
+		Method:         wh.Method,
+		PhotoURL:       wh.PhotoURL,
+		Note:           wh.Note,
+		WateredAt:      wh.WateredAt.In(loc),
+		NotificationID: wh.NotificationID,
+		CreatedAt:      wh.CreatedAt.In(loc),
+	}
+}
 
 func MapPlantToPlantResponse(plant *plant.Plant) *PlantResponse {
 	return &PlantResponse{
@@ -31,3 +91,45 @@ func MapPlantImagesToPlantImageResponses(images []plant.PlantImage) []PlantImage
 
 	return plantImageResponses
 }
+
+func MapComplianceToResponse(c *Compliance) ComplianceResponse {
+	rate := 1.0
+	if c.Expected > 0 {
+		rate = float64(c.Met) / float64(c.Expected)
+	}
+	return ComplianceResponse{
+		UserPlantID:    c.UserPlantID,
+		Plant:          *MapPlantToPlantResponse(&c.Plant),
+		Status:         c.Status,
+		Since:          c.Since,
+		Expected:       c.Expected,
+		Met:            c.Met,
+		Missed:         c.Missed,
+		ComplianceRate: rate,
+		LastWateredAt:  c.LastWateredAt,
+		OverdueSince:   c.OverdueSince,
+		LateByMinutes:  int(c.LateBy / time.Minute),
+		NextDueAt:      c.NextDueAt,
+	}
+}
+
+func MapComplianceSummaryToResponse(s *ComplianceSummary) ComplianceSummaryResponse {
+	rate := 1.0
+	if s.Expected > 0 {
+		rate = float64(s.Met) / float64(s.Expected)
+	}
+	late := make([]ComplianceResponse, 0, len(s.Late))
+	for i := range s.Late {
+		late = append(late, MapComplianceToResponse(&s.Late[i]))
+	}
+	return ComplianceSummaryResponse{
+		Plants:         s.Plants,
+		OnTrack:        s.OnTrack,
+		Overdue:        s.Overdue,
+		NoSchedule:     s.NoSchedule,
+		Expected:       s.Expected,
+		Met:            s.Met,
+		ComplianceRate: rate,
+		OverduePlants:  late,
+	}
+}
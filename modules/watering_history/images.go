@@ -0,0 +1,48 @@
+package wateringhistory
+
+import (
+	"context"
+	"io"
+
+	"github.com/OctavianoRyan25/be-agriculture/utils/helper"
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+)
+
+// ImageStore keeps the photos of logged waterings.
+type ImageStore interface {
+	UploadImage(photo io.Reader) (string, error)
+	RemoveImage(string) error
+}
+
+type cloudinaryImages struct {
+	cloudinary *cloudinary.Cloudinary
+}
+
+func NewCloudinaryImages(cloudinary *cloudinary.Cloudinary) *cloudinaryImages {
+	return &cloudinaryImages{
+		cloudinary: cloudinary,
+	}
+}
+
+// UploadImage uploads a photo to Cloudinary and returns its URL.
+func (s *cloudinaryImages) UploadImage(photo io.Reader) (string, error) {
+	result, err := s.cloudinary.Upload.Upload(context.Background(), photo, uploader.UploadParams{Folder: "be-agriculture/watering"})
+	if err != nil {
+		return "", err
+	}
+	return result.SecureURL, nil
+}
+
+// RemoveImage deletes the image at imageURL from Cloudinary. Images hosted
+// elsewhere are ignored.
+func (s *cloudinaryImages) RemoveImage(imageURL string) error {
+	publicID, ok := helper.CloudinaryPublicID(imageURL)
+	if !ok {
+		return nil
+	}
+	_, err := s.cloudinary.Upload.Destroy(context.Background(), uploader.DestroyParams{
+		PublicID: publicID,
+	})
+	return err
+}
@@ -1,13 +1,27 @@
 package wateringhistory
 
 import (
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repository interface {
 	StoreWateringHistory(*WateringHistory) (*WateringHistory, error)
-	GetAllWateringHistories(uint) ([]WateringHistory, error)
-	GetLateWateringHistories(uint) (Notification, error)
+	GetWateringHistory(userID uint, id int) (*WateringHistory, error)
+	GetAllWateringHistories(*WateringHistoryFilter) ([]WateringHistory, error)
+	UpdateWateringHistory(*WateringHistory) (*WateringHistory, error)
+	DeleteWateringHistory(*WateringHistory) error
+	GetUser(uint) (*user.User, error)
+	GetUserPlant(userID uint, id int) (*plant.UserPlant, error)
+	GetUserPlantByPlant(userID uint, plantID int) (*plant.UserPlant, error)
+	GetUserPlants(userID uint) ([]plant.UserPlant, error)
+	GetWateringsSince(userID uint, since time.Time) ([]WateringHistory, error)
+	GetSkipsSince(userID uint, since time.Time) ([]WateringSkip, error)
+	GetPlantOwnerIDs() ([]uint, error)
 }
 
 type wateringHistoryRepository struct {
@@ -21,32 +35,126 @@ func NewRepository(db *gorm.DB) *wateringHistoryRepository {
 }
 
 func (r *wateringHistoryRepository) StoreWateringHistory(wh *WateringHistory) (*WateringHistory, error) {
-	err := r.db.Create(wh).Error
+	err := r.db.Omit(clause.Associations).Create(wh).Error
 	if err != nil {
 		return nil, err
 	}
+	return r.GetWateringHistory(uint(wh.UserID), wh.ID)
+}
 
-	err = r.db.Preload("User").Preload("Plant").First(wh, wh.ID).Error
+func (r *wateringHistoryRepository) GetWateringHistory(userID uint, id int) (*WateringHistory, error) {
+	var wh WateringHistory
+	err := r.db.Preload("User").Preload("UserPlant").Preload("Plant.PlantImages").
+		Where("id = ? AND user_id = ?", id, userID).First(&wh).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wh, nil
+}
+
+func (r *wateringHistoryRepository) GetAllWateringHistories(filter *WateringHistoryFilter) ([]WateringHistory, error) {
+	query := r.db.Preload("User").Preload("UserPlant").Preload("Plant.PlantImages").Where("user_id = ?", filter.UserID)
+	if filter.UserPlantID != 0 {
+		query = query.Where("user_plant_id = ?", filter.UserPlantID)
+	}
+	if filter.PlantID != 0 {
+		query = query.Where("plant_id = ?", filter.PlantID)
+	}
+	if filter.From != nil {
+		query = query.Where("watered_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("watered_at < ?", *filter.To)
+	}
+
+	var wh []WateringHistory
+	err := query.Order("watered_at desc, id desc").Find(&wh).Error
 	if err != nil {
 		return nil, err
 	}
 	return wh, nil
 }
 
-func (r *wateringHistoryRepository) GetAllWateringHistories(userID uint) ([]WateringHistory, error) {
+func (r *wateringHistoryRepository) UpdateWateringHistory(wh *WateringHistory) (*WateringHistory, error) {
+	err := r.db.Omit(clause.Associations).Save(wh).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetWateringHistory(uint(wh.UserID), wh.ID)
+}
+
+func (r *wateringHistoryRepository) DeleteWateringHistory(wh *WateringHistory) error {
+	return r.db.Where("id = ? AND user_id = ?", wh.ID, wh.UserID).Delete(&WateringHistory{}).Error
+}
+
+func (r *wateringHistoryRepository) GetUser(id uint) (*user.User, error) {
+	var u user.User
+	err := r.db.First(&u, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUserPlant returns a plant of the user with its watering schedule.
+func (r *wateringHistoryRepository) GetUserPlant(userID uint, id int) (*plant.UserPlant, error) {
+	var userPlant plant.UserPlant
+	err := r.db.Preload("Plant.WateringSchedule").Where("id = ? AND user_id = ?", id, userID).First(&userPlant).Error
+	if err != nil {
+		return nil, err
+	}
+	return &userPlant, nil
+}
+
+// GetUserPlantByPlant returns the first plant the user added of a kind, for
+// clients that log waterings by plant.
+func (r *wateringHistoryRepository) GetUserPlantByPlant(userID uint, plantID int) (*plant.UserPlant, error) {
+	var userPlant plant.UserPlant
+	err := r.db.Preload("Plant.WateringSchedule").Where("user_id = ? AND plant_id = ?", userID, plantID).Order("id").First(&userPlant).Error
+	if err != nil {
+		return nil, err
+	}
+	return &userPlant, nil
+}
+
+// GetUserPlants returns the plants of a user with their watering schedule
+// and the user, whose time zone the watering times are read in.
+func (r *wateringHistoryRepository) GetUserPlants(userID uint) ([]plant.UserPlant, error) {
+	var userPlants []plant.UserPlant
+	err := r.db.Preload("User").Preload("Plant.PlantImages").Preload("Plant.WateringSchedule.Times").
+		Where("user_id = ?", userID).Order("created_at").Find(&userPlants).Error
+	if err != nil {
+		return nil, err
+	}
+	return userPlants, nil
+}
+
+// GetWateringsSince returns the waterings of a user after since, oldest
+// first.
+func (r *wateringHistoryRepository) GetWateringsSince(userID uint, since time.Time) ([]WateringHistory, error) {
 	var wh []WateringHistory
-	err := r.db.Preload("User").Preload("Plant").Preload("Plant.PlantImages").Order("created_at desc").Where("user_id = ?", userID).Find(&wh).Error
+	err := r.db.Where("user_id = ? AND watered_at > ?", userID, since).Order("watered_at").Find(&wh).Error
 	if err != nil {
 		return nil, err
 	}
 	return wh, nil
 }
 
-func (r *wateringHistoryRepository) GetLateWateringHistories(userID uint) (Notification, error) {
-	var notification Notification
-	err := r.db.Preload("Plant").Preload("Plant.PlantImages").Where("user_id = ? AND is_read = ?", userID, false).First(&notification).Error
+// GetSkipsSince returns the skips of a user that end after since.
+func (r *wateringHistoryRepository) GetSkipsSince(userID uint, since time.Time) ([]WateringSkip, error) {
+	var skips []WateringSkip
+	err := r.db.Where("user_id = ? AND ends_at > ?", userID, since).Order("starts_at").Find(&skips).Error
 	if err != nil {
-		return notification, err
+		return nil, err
+	}
+	return skips, nil
+}
+
+func (r *wateringHistoryRepository) GetPlantOwnerIDs() ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&plant.UserPlant{}).Distinct().Pluck("user_id", &ids).Error
+	if err != nil {
+		return nil, err
 	}
-	return notification, nil
+	return ids, nil
 }
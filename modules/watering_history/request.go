@@ -1,5 +1,27 @@
 package wateringhistory
 
+// WateringHistoryRequest logs a watering of a plant of the user, given by
+// UserPlantID or, for older clients, by PlantID. WateredAt backdates the
+// watering, it is now when left out. A photo can be uploaded as the
+// multipart file "photo".
 type WateringHistoryRequest struct {
-	PlantID int `json:"plant_id" validate:"required"`
+	UserPlantID int    `json:"user_plant_id" form:"user_plant_id" validate:"required_without=PlantID"`
+	PlantID     int    `json:"plant_id" form:"plant_id" validate:"required_without=UserPlantID"`
+	Amount      int    `json:"amount" form:"amount" validate:"min=0"`
+	Unit        string `json:"unit" form:"unit" validate:"max=20"`
+	Method      string `json:"method" form:"method" validate:"omitempty,oneof=can drip sprinkler"`
+	Note        string `json:"note" form:"note" validate:"max=500"`
+	WateredAt   string `json:"watered_at" form:"watered_at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	// RemovePhoto removes the photo of an entry that is updated without a
+	// new one.
+	RemovePhoto bool `json:"remove_photo" form:"remove_photo"`
+}
+
+// WateringHistoryQuery filters the watering history. From and To are days
+// in the time zone of the user, both included.
+type WateringHistoryQuery struct {
+	UserPlantID int    `query:"user_plant_id"`
+	PlantID     int    `query:"plant_id"`
+	From        string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To          string `query:"to" validate:"omitempty,datetime=2006-01-02"`
 }
@@ -7,10 +7,19 @@ import (
 )
 
 type WateringHistoryResponse struct {
-	Id        int               `json:"id"`
-	Plant     PlantResponse     `json:"plant"`
-	User      user.UserResponse `json:"user"`
-	CreatedAt time.Time         `json:"created_at"`
+	Id             int               `json:"id"`
+	UserPlantID    *int              `json:"user_plant_id"`
+	CustomizeName  string            `json:"customize_name"`
+	Plant          PlantResponse     `json:"plant"`
+	User           user.UserResponse `json:"user"`
+	Amount         int               `json:"amount"`
+	Unit           string            `json:"unit"`
+	Method         string            `json:"method"`
+	PhotoURL       string            `json:"photo_url"`
+	Note           string            `json:"note"`
+	WateredAt      time.Time         `json:"watered_at"`
+	NotificationID *int              `json:"notification_id"`
+	CreatedAt      time.Time         `json:"created_at"`
 }
 
 type PlantResponse struct {
@@ -31,11 +40,31 @@ type PlantImageResponse struct {
 	FileName string `json:"file_name"`
 }
 
-type NotificationResponse struct {
-	Id        int           `json:"id"`
-	Title     string        `json:"title"`
-	Body      string        `json:"body"`
-	UserID    int           `json:"user_id"`
-	Plant     PlantResponse `json:"plant"`
-	CreatedAt time.Time     `json:"created_at"`
+// ComplianceResponse shows how well the waterings of a plant kept to its
+// schedule since Since. ComplianceRate is Met out of Expected, 1 when no
+// watering was due yet.
+type ComplianceResponse struct {
+	UserPlantID    int           `json:"user_plant_id"`
+	Plant          PlantResponse `json:"plant"`
+	Status         string        `json:"status"`
+	Since          time.Time     `json:"since"`
+	Expected       int           `json:"expected_waterings"`
+	Met            int           `json:"met_waterings"`
+	Missed         int           `json:"missed_in_a_row"`
+	ComplianceRate float64       `json:"compliance_rate"`
+	LastWateredAt  *time.Time    `json:"last_watered_at"`
+	OverdueSince   *time.Time    `json:"overdue_since"`
+	LateByMinutes  int           `json:"late_by_minutes"`
+	NextDueAt      *time.Time    `json:"next_due_at"`
+}
+
+type ComplianceSummaryResponse struct {
+	Plants         int                  `json:"plants"`
+	OnTrack        int                  `json:"on_track"`
+	Overdue        int                  `json:"overdue"`
+	NoSchedule     int                  `json:"no_schedule"`
+	Expected       int                  `json:"expected_waterings"`
+	Met            int                  `json:"met_waterings"`
+	ComplianceRate float64              `json:"compliance_rate"`
+	OverduePlants  []ComplianceResponse `json:"overdue_plants"`
 }
@@ -1,46 +1,369 @@
 package wateringhistory
 
-import "time"
+import (
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+	"gorm.io/gorm"
+)
 
 type WateringHistoryUseCase interface {
-	StoreWateringHistory(*WateringHistory) (*WateringHistory, error)
-	GetAllWateringHistories(uint) ([]WateringHistory, error)
-	GetLateWateringHistories(uint) (Notification, error)
+	StoreWateringHistory(wh *WateringHistory, photo io.Reader) (*WateringHistory, int, error)
+	GetWateringHistory(userID uint, id int) (*WateringHistory, int, error)
+	GetAllWateringHistories(*WateringHistoryFilter) ([]WateringHistory, int, error)
+	UpdateWateringHistory(userID uint, id int, changes *WateringHistory, photo io.Reader, removePhoto bool) (*WateringHistory, int, error)
+	DeleteWateringHistory(userID uint, id int) (int, error)
+	GetUserLocation(uint) (*time.Location, error)
+	GetWateringCompliance(userID uint) ([]Compliance, int, error)
+	GetPlantCompliance(userID uint, plantID, userPlantID int) (*Compliance, int, error)
+	GetComplianceSummary(userID uint) (*ComplianceSummary, int, error)
+	EscalateOverduePlants() (int, error)
+}
+
+// Escalator tells a user that a plant has been overdue for watering since
+// overdueSince. It is called again for as long as the plant stays overdue,
+// so it must send one message per overdueSince at most.
+type Escalator interface {
+	EscalateOverdueWatering(userID, plantID int, overdueSince time.Time) error
+}
+
+// clockSkew is how far in the future a watering may be logged, for
+// devices whose clock is a little ahead.
+const clockSkew = 5 * time.Minute
+
+// WateringHistoryFilter selects the waterings of a user, newest first. Zero
+// fields match anything. Waterings from From are included, from To on they
+// are not.
+type WateringHistoryFilter struct {
+	UserID      uint
+	UserPlantID int
+	PlantID     int
+	From        *time.Time
+	To          *time.Time
 }
 
 type wateringHistoryUseCase struct {
-	repo Repository
+	repo          Repository
+	images        ImageStore
+	escalator     Escalator
+	escalateAfter time.Duration
+	now           func() time.Time
 }
 
-func NewUseCase(repo Repository) *wateringHistoryUseCase {
+func NewUseCase(repo Repository, images ImageStore) *wateringHistoryUseCase {
 	return &wateringHistoryUseCase{
-		repo: repo,
+		repo:   repo,
+		images: images,
+		now:    time.Now,
 	}
 }
 
-func (uc *wateringHistoryUseCase) StoreWateringHistory(wh *WateringHistory) (*WateringHistory, error) {
-	location, _ := time.LoadLocation("Asia/Jakarta")
-	wh.CreatedAt = time.Now().In(location)
-	wh.UpdatedAt = time.Now().In(location)
-	wh, err := uc.repo.StoreWateringHistory(wh)
+// SetEscalator has plants that are overdue by after or more escalated to
+// the user. The notification module implements it, it cannot be passed to
+// NewUseCase as the notification module needs this one.
+func (uc *wateringHistoryUseCase) SetEscalator(escalator Escalator, after time.Duration) {
+	uc.escalator = escalator
+	uc.escalateAfter = after
+}
+
+// StoreWateringHistory logs a watering of a plant of the user, with a
+// photo unless photo is nil. Without WateredAt the plant was watered now.
+// The photo is only uploaded once the watering is valid.
+func (uc *wateringHistoryUseCase) StoreWateringHistory(wh *WateringHistory, photo io.Reader) (*WateringHistory, int, error) {
+	now := uc.now()
+	if wh.WateredAt.IsZero() {
+		wh.WateredAt = now
+	}
+	code, err := uc.checkWatering(wh)
 	if err != nil {
-		return nil, err
+		return nil, code, err
+	}
+	if photo != nil {
+		wh.PhotoURL, code, err = uc.uploadPhoto(photo)
+		if err != nil {
+			return nil, code, err
+		}
+	}
+
+	wh.CreatedAt = now
+	wh.UpdatedAt = now
+	stored, err := uc.repo.StoreWateringHistory(wh)
+	if err != nil {
+		uc.removePhoto(wh.PhotoURL)
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return stored, constants.CodeSuccess, nil
+}
+
+func (uc *wateringHistoryUseCase) GetWateringHistory(userID uint, id int) (*WateringHistory, int, error) {
+	wh, err := uc.repo.GetWateringHistory(userID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, constants.ErrCodeWateringNotFound, errors.New(constants.ErrWateringNotFound)
+	}
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return wh, constants.CodeSuccess, nil
+}
+
+func (uc *wateringHistoryUseCase) GetAllWateringHistories(filter *WateringHistoryFilter) ([]WateringHistory, int, error) {
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return nil, constants.ErrCodeInvalidDateRange, errors.New(constants.ErrInvalidDateRange)
+	}
+	wh, err := uc.repo.GetAllWateringHistories(filter)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	return wh, constants.CodeSuccess, nil
+}
+
+// UpdateWateringHistory corrects a logged watering. Without WateredAt the
+// entry keeps its time, and without a new photo its photo unless
+// removePhoto. A replaced or removed photo is deleted.
+func (uc *wateringHistoryUseCase) UpdateWateringHistory(userID uint, id int, changes *WateringHistory, photo io.Reader, removePhoto bool) (*WateringHistory, int, error) {
+	wh, code, err := uc.GetWateringHistory(userID, id)
+	if err != nil {
+		return nil, code, err
+	}
+
+	wh.UserPlantID = changes.UserPlantID
+	wh.PlantID = changes.PlantID
+	wh.Amount = changes.Amount
+	wh.Unit = changes.Unit
+	wh.Method = changes.Method
+	wh.Note = changes.Note
+	if !changes.WateredAt.IsZero() {
+		wh.WateredAt = changes.WateredAt
+	}
+	code, err = uc.checkWatering(wh)
+	if err != nil {
+		return nil, code, err
+	}
+
+	oldPhotoURL := wh.PhotoURL
+	if photo != nil {
+		wh.PhotoURL, code, err = uc.uploadPhoto(photo)
+		if err != nil {
+			return nil, code, err
+		}
+	} else if removePhoto {
+		wh.PhotoURL = ""
+	}
+
+	wh.UpdatedAt = uc.now()
+	updated, err := uc.repo.UpdateWateringHistory(wh)
+	if err != nil {
+		if wh.PhotoURL != oldPhotoURL {
+			uc.removePhoto(wh.PhotoURL)
+		}
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if wh.PhotoURL != oldPhotoURL {
+		uc.removePhoto(oldPhotoURL)
+	}
+	return updated, constants.CodeSuccess, nil
+}
+
+func (uc *wateringHistoryUseCase) DeleteWateringHistory(userID uint, id int) (int, error) {
+	wh, code, err := uc.GetWateringHistory(userID, id)
+	if err != nil {
+		return code, err
+	}
+	err = uc.repo.DeleteWateringHistory(wh)
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+	uc.removePhoto(wh.PhotoURL)
+	return constants.CodeSuccess, nil
+}
+
+func (uc *wateringHistoryUseCase) uploadPhoto(photo io.Reader) (string, int, error) {
+	photoURL, err := uc.images.UploadImage(photo)
+	if err != nil {
+		log.Println("Failed to upload watering photo:", err)
+		return "", constants.ErrCodePhotoUploadFailed, errors.New(constants.ErrPhotoUploadFailed)
+	}
+	return photoURL, constants.CodeSuccess, nil
+}
+
+// removePhoto deletes a photo that is no longer used. A failure only
+// leaves an unused image behind, so it is logged.
+func (uc *wateringHistoryUseCase) removePhoto(photoURL string) {
+	if photoURL == "" {
+		return
+	}
+	err := uc.images.RemoveImage(photoURL)
+	if err != nil {
+		log.Printf("Failed to remove watering photo %s: %v", photoURL, err)
 	}
-	return wh, nil
 }
 
-func (uc *wateringHistoryUseCase) GetAllWateringHistories(userID uint) ([]WateringHistory, error) {
-	wh, err := uc.repo.GetAllWateringHistories(userID)
+func (uc *wateringHistoryUseCase) GetUserLocation(userID uint) (*time.Location, error) {
+	u, err := uc.repo.GetUser(userID)
 	if err != nil {
 		return nil, err
 	}
-	return wh, nil
+	return u.Location(), nil
+}
+
+// checkWatering finds the plant of the user a watering is for, by
+// UserPlantID or else by the first plant of the user with PlantID. An
+// amount without a unit is in the unit of the plant's watering schedule.
+func (uc *wateringHistoryUseCase) checkWatering(wh *WateringHistory) (int, error) {
+	if wh.WateredAt.After(uc.now().Add(clockSkew)) {
+		return constants.ErrCodeWateredInFuture, errors.New(constants.ErrWateredInFuture)
+	}
+
+	var userPlant *plant.UserPlant
+	var err error
+	if wh.UserPlantID != nil {
+		userPlant, err = uc.repo.GetUserPlant(uint(wh.UserID), *wh.UserPlantID)
+	} else {
+		userPlant, err = uc.repo.GetUserPlantByPlant(uint(wh.UserID), wh.PlantID)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return constants.ErrCodePlantNotFound, errors.New(constants.ErrPlantNotFound)
+	}
+	if err != nil {
+		return constants.ErrCodeBadRequest, err
+	}
+
+	wh.UserPlantID = &userPlant.ID
+	wh.PlantID = userPlant.PlantID
+	if wh.Amount > 0 && wh.Unit == "" {
+		wh.Unit = userPlant.Plant.WateringSchedule.Unit
+	}
+	return constants.CodeSuccess, nil
+}
+
+// GetWateringCompliance compares the watering schedule of every plant of
+// the user with the waterings they logged in the last 30 days, or since
+// they added the plant or it got its schedule.
+func (uc *wateringHistoryUseCase) GetWateringCompliance(userID uint) ([]Compliance, int, error) {
+	now := uc.now()
+	userPlants, err := uc.repo.GetUserPlants(userID)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	if len(userPlants) == 0 {
+		return []Compliance{}, constants.CodeSuccess, nil
+	}
+
+	windowStart := now.Add(-complianceWindow)
+	waterings, err := uc.repo.GetWateringsSince(userID, windowStart.Add(-earlyWatering))
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	skips, err := uc.repo.GetSkipsSince(userID, windowStart)
+	if err != nil {
+		return nil, constants.ErrCodeBadRequest, err
+	}
+	skipsByPlant := map[int][]WateringSkip{}
+	for _, skip := range skips {
+		skipsByPlant[skip.PlantID] = append(skipsByPlant[skip.PlantID], skip)
+	}
+	byUserPlant := map[int][]time.Time{}
+	for _, wh := range waterings {
+		if wh.UserPlantID != nil {
+			byUserPlant[*wh.UserPlantID] = append(byUserPlant[*wh.UserPlantID], wh.WateredAt)
+		}
+	}
+
+	var compliance []Compliance
+	for _, up := range userPlants {
+		schedule := up.Plant.WateringSchedule
+		since := windowStart
+		for _, start := range []time.Time{up.CreatedAt, schedule.CreatedAt} {
+			if start.After(since) {
+				since = start
+			}
+		}
+		c := checkCompliance(schedule, since, now, up.User.Location(), byUserPlant[up.ID], skipsByPlant[up.PlantID])
+		c.UserPlantID = up.ID
+		c.Plant = up.Plant
+		compliance = append(compliance, c)
+	}
+	return compliance, constants.CodeSuccess, nil
 }
 
-func (uc *wateringHistoryUseCase) GetLateWateringHistories(userID uint) (Notification, error) {
-	notification, err := uc.repo.GetLateWateringHistories(userID)
+// GetPlantCompliance returns the compliance of the plant of the user with
+// userPlantID or, without it, of the first plant of the user with plantID.
+func (uc *wateringHistoryUseCase) GetPlantCompliance(userID uint, plantID, userPlantID int) (*Compliance, int, error) {
+	compliance, code, err := uc.GetWateringCompliance(userID)
 	if err != nil {
-		return notification, err
+		return nil, code, err
+	}
+	for i := range compliance {
+		if userPlantID != 0 && compliance[i].UserPlantID != userPlantID {
+			continue
+		}
+		if compliance[i].Plant.ID == plantID {
+			return &compliance[i], constants.CodeSuccess, nil
+		}
+	}
+	return nil, constants.ErrCodePlantNotFound, errors.New(constants.ErrPlantNotFound)
+}
+
+func (uc *wateringHistoryUseCase) GetComplianceSummary(userID uint) (*ComplianceSummary, int, error) {
+	compliance, code, err := uc.GetWateringCompliance(userID)
+	if err != nil {
+		return nil, code, err
+	}
+
+	summary := &ComplianceSummary{Plants: len(compliance), Late: []Compliance{}}
+	for _, c := range compliance {
+		summary.Expected += c.Expected
+		summary.Met += c.Met
+		switch c.Status {
+		case ComplianceOnTrack:
+			summary.OnTrack++
+		case ComplianceOverdue:
+			summary.Overdue++
+			summary.Late = append(summary.Late, c)
+		default:
+			summary.NoSchedule++
+		}
+	}
+	sort.SliceStable(summary.Late, func(i, j int) bool {
+		return summary.Late[i].LateBy > summary.Late[j].LateBy
+	})
+	return summary, constants.CodeSuccess, nil
+}
+
+// EscalateOverduePlants escalates the plants of every user that are
+// overdue by escalateAfter or more, and returns how many it escalated. It
+// does nothing without an escalator.
+func (uc *wateringHistoryUseCase) EscalateOverduePlants() (int, error) {
+	if uc.escalator == nil || uc.escalateAfter <= 0 {
+		return 0, nil
+	}
+
+	userIDs, err := uc.repo.GetPlantOwnerIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	escalated := 0
+	for _, userID := range userIDs {
+		compliance, _, err := uc.GetWateringCompliance(userID)
+		if err != nil {
+			return escalated, err
+		}
+		for _, c := range compliance {
+			if c.Status != ComplianceOverdue || c.LateBy < uc.escalateAfter {
+				continue
+			}
+			err = uc.escalator.EscalateOverdueWatering(int(userID), c.Plant.ID, *c.OverdueSince)
+			if err != nil {
+				return escalated, err
+			}
+			escalated++
+		}
 	}
-	return notification, nil
+	return escalated, nil
 }
@@ -0,0 +1,410 @@
+package wateringhistory
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/constants"
+	"github.com/OctavianoRyan25/be-agriculture/modules/plant"
+	"github.com/OctavianoRyan25/be-agriculture/modules/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type MockRepository struct {
+	mock.Mock
+	Repository
+}
+
+func (m *MockRepository) GetUserPlants(userID uint) ([]plant.UserPlant, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]plant.UserPlant), args.Error(1)
+}
+
+func (m *MockRepository) GetWateringsSince(userID uint, since time.Time) ([]WateringHistory, error) {
+	args := m.Called(userID, since)
+	return args.Get(0).([]WateringHistory), args.Error(1)
+}
+
+func (m *MockRepository) GetSkipsSince(userID uint, since time.Time) ([]WateringSkip, error) {
+	args := m.Called(userID, since)
+	return args.Get(0).([]WateringSkip), args.Error(1)
+}
+
+func (m *MockRepository) GetPlantOwnerIDs() ([]uint, error) {
+	args := m.Called()
+	return args.Get(0).([]uint), args.Error(1)
+}
+
+func (m *MockRepository) StoreWateringHistory(wh *WateringHistory) (*WateringHistory, error) {
+	args := m.Called(wh)
+	return wh, args.Error(0)
+}
+
+func (m *MockRepository) GetWateringHistory(userID uint, id int) (*WateringHistory, error) {
+	args := m.Called(userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*WateringHistory), args.Error(1)
+}
+
+func (m *MockRepository) GetAllWateringHistories(filter *WateringHistoryFilter) ([]WateringHistory, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]WateringHistory), args.Error(1)
+}
+
+func (m *MockRepository) UpdateWateringHistory(wh *WateringHistory) (*WateringHistory, error) {
+	args := m.Called(wh)
+	return wh, args.Error(0)
+}
+
+func (m *MockRepository) DeleteWateringHistory(wh *WateringHistory) error {
+	args := m.Called(wh)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetUserPlant(userID uint, id int) (*plant.UserPlant, error) {
+	args := m.Called(userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*plant.UserPlant), args.Error(1)
+}
+
+func (m *MockRepository) GetUserPlantByPlant(userID uint, plantID int) (*plant.UserPlant, error) {
+	args := m.Called(userID, plantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*plant.UserPlant), args.Error(1)
+}
+
+type MockImages struct {
+	mock.Mock
+}
+
+func (m *MockImages) UploadImage(photo io.Reader) (string, error) {
+	args := m.Called(photo)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockImages) RemoveImage(url string) error {
+	args := m.Called(url)
+	return args.Error(0)
+}
+
+type escalation struct {
+	userID, plantID int
+	overdueSince    time.Time
+}
+
+type fakeEscalator struct {
+	escalations []escalation
+}
+
+func (e *fakeEscalator) EscalateOverdueWatering(userID, plantID int, overdueSince time.Time) error {
+	e.escalations = append(e.escalations, escalation{userID, plantID, overdueSince})
+	return nil
+}
+
+func newTestUseCase(repo *MockRepository, now time.Time) *wateringHistoryUseCase {
+	uc := NewUseCase(repo, new(MockImages))
+	uc.now = func() time.Time { return now }
+	return uc
+}
+
+var jakarta, _ = time.LoadLocation("Asia/Jakarta")
+
+// at returns a time in January 2024 in Jakarta.
+func at(day int, clock string) time.Time {
+	parsed, _ := time.Parse("15:04", clock)
+	return time.Date(2024, time.January, day, parsed.Hour(), parsed.Minute(), 0, 0, jakarta)
+}
+
+func twiceDaily(id int) plant.PlantReminder {
+	return plant.PlantReminder{
+		ID:        id,
+		Each:      plant.PeriodDay,
+		Interval:  1,
+		Times:     []plant.PlantReminderTime{{Time: "07:00"}, {Time: "17:00"}},
+		CreatedAt: at(1, "00:00"),
+	}
+}
+
+func TestStoreWateringHistory(t *testing.T) {
+	repo := new(MockRepository)
+	now := at(4, "10:00")
+	uc := newTestUseCase(repo, now)
+	basil := &plant.UserPlant{ID: 8, UserID: 5, PlantID: 2, Plant: plant.Plant{ID: 2, WateringSchedule: plant.PlantReminder{Unit: "ml"}}}
+	repo.On("GetUserPlantByPlant", uint(5), 2).Return(basil, nil)
+	repo.On("GetUserPlant", uint(5), 8).Return(basil, nil)
+	repo.On("GetUserPlant", uint(5), 9).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("StoreWateringHistory", mock.Anything).Return(nil)
+
+	// Older clients log by plant, the watering is for their first one.
+	wh, code, err := uc.StoreWateringHistory(&WateringHistory{UserID: 5, PlantID: 2, Amount: 250, Method: MethodCan}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.CodeSuccess, code)
+	assert.Equal(t, 8, *wh.UserPlantID)
+	assert.Equal(t, "ml", wh.Unit)
+	assert.True(t, now.Equal(wh.WateredAt))
+
+	userPlantID := 8
+	wh, _, err = uc.StoreWateringHistory(&WateringHistory{UserID: 5, UserPlantID: &userPlantID, Amount: 1, Unit: "l", WateredAt: at(3, "18:00")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, wh.PlantID)
+	assert.Equal(t, "l", wh.Unit)
+	assert.True(t, at(3, "18:00").Equal(wh.WateredAt))
+
+	_, code, err = uc.StoreWateringHistory(&WateringHistory{UserID: 5, UserPlantID: &userPlantID, WateredAt: now.Add(time.Hour)}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, constants.ErrCodeWateredInFuture, code)
+
+	// Someone else's plant
+	other := 9
+	_, code, err = uc.StoreWateringHistory(&WateringHistory{UserID: 5, UserPlantID: &other}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, constants.ErrCodePlantNotFound, code)
+}
+
+func TestUpdateAndDeleteWateringHistory(t *testing.T) {
+	repo := new(MockRepository)
+	now := at(4, "10:00")
+	uc := newTestUseCase(repo, now)
+	userPlantID := 8
+	logged := func() *WateringHistory {
+		return &WateringHistory{ID: 3, UserID: 5, PlantID: 2, UserPlantID: &userPlantID, Amount: 250, Unit: "ml", PhotoURL: "https://example.com/basil.jpg", WateredAt: at(4, "07:00")}
+	}
+	repo.On("GetWateringHistory", uint(5), 3).Return(logged(), nil).Once()
+	repo.On("GetWateringHistory", uint(5), 4).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetUserPlant", uint(5), 8).Return(&plant.UserPlant{ID: 8, UserID: 5, PlantID: 2}, nil)
+	repo.On("UpdateWateringHistory", mock.Anything).Return(nil)
+	repo.On("DeleteWateringHistory", mock.Anything).Return(nil)
+	images := new(MockImages)
+	uc.images = images
+	images.On("RemoveImage", "https://example.com/basil.jpg").Return(nil)
+
+	// Without a new time or photo the entry keeps them.
+	wh, code, err := uc.UpdateWateringHistory(5, 3, &WateringHistory{UserID: 5, UserPlantID: &userPlantID, Amount: 300, Unit: "ml", Note: "a little more"}, nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.CodeSuccess, code)
+	assert.Equal(t, 300, wh.Amount)
+	assert.Equal(t, "a little more", wh.Note)
+	assert.True(t, at(4, "07:00").Equal(wh.WateredAt))
+	assert.Equal(t, "https://example.com/basil.jpg", wh.PhotoURL)
+	images.AssertNotCalled(t, "RemoveImage", mock.Anything)
+
+	repo.On("GetWateringHistory", uint(5), 3).Return(logged(), nil).Once()
+	wh, _, err = uc.UpdateWateringHistory(5, 3, &WateringHistory{UserID: 5, UserPlantID: &userPlantID, WateredAt: at(4, "06:00")}, nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "", wh.PhotoURL)
+	assert.True(t, at(4, "06:00").Equal(wh.WateredAt))
+	images.AssertNumberOfCalls(t, "RemoveImage", 1)
+
+	_, code, err = uc.UpdateWateringHistory(5, 4, &WateringHistory{UserID: 5, UserPlantID: &userPlantID}, nil, false)
+	assert.Error(t, err)
+	assert.Equal(t, constants.ErrCodeWateringNotFound, code)
+
+	repo.On("GetWateringHistory", uint(5), 3).Return(logged(), nil).Once()
+	code, err = uc.DeleteWateringHistory(5, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.CodeSuccess, code)
+	repo.AssertCalled(t, "DeleteWateringHistory", mock.Anything)
+	images.AssertNumberOfCalls(t, "RemoveImage", 2)
+
+	code, err = uc.DeleteWateringHistory(5, 4)
+	assert.Error(t, err)
+	assert.Equal(t, constants.ErrCodeWateringNotFound, code)
+}
+
+func TestWateringPhotos(t *testing.T) {
+	repo := new(MockRepository)
+	now := at(4, "10:00")
+	uc := newTestUseCase(repo, now)
+	images := new(MockImages)
+	uc.images = images
+	userPlantID, other := 8, 9
+	repo.On("GetUserPlant", uint(5), 8).Return(&plant.UserPlant{ID: 8, UserID: 5, PlantID: 2}, nil)
+	repo.On("GetUserPlant", uint(5), 9).Return(nil, gorm.ErrRecordNotFound)
+	images.On("UploadImage", mock.Anything).Return("https://example.com/new.jpg", nil)
+	images.On("RemoveImage", mock.Anything).Return(nil)
+
+	// Invalid waterings do not upload their photo.
+	_, code, err := uc.StoreWateringHistory(&WateringHistory{UserID: 5, UserPlantID: &other}, strings.NewReader("photo"))
+	assert.Equal(t, constants.ErrCodePlantNotFound, code)
+	assert.Error(t, err)
+	_, code, _ = uc.StoreWateringHistory(&WateringHistory{UserID: 5, UserPlantID: &userPlantID, WateredAt: now.Add(time.Hour)}, strings.NewReader("photo"))
+	assert.Equal(t, constants.ErrCodeWateredInFuture, code)
+	images.AssertNotCalled(t, "UploadImage", mock.Anything)
+
+	repo.On("StoreWateringHistory", mock.Anything).Return(nil).Once()
+	wh, _, err := uc.StoreWateringHistory(&WateringHistory{UserID: 5, UserPlantID: &userPlantID}, strings.NewReader("photo"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/new.jpg", wh.PhotoURL)
+
+	// The upload is removed again when the watering cannot be saved.
+	repo.On("StoreWateringHistory", mock.Anything).Return(errors.New("db down")).Once()
+	_, _, err = uc.StoreWateringHistory(&WateringHistory{UserID: 5, UserPlantID: &userPlantID}, strings.NewReader("photo"))
+	assert.Error(t, err)
+	images.AssertCalled(t, "RemoveImage", "https://example.com/new.jpg")
+
+	// A replaced photo is removed.
+	repo.On("GetWateringHistory", uint(5), 3).Return(&WateringHistory{ID: 3, UserID: 5, PlantID: 2, UserPlantID: &userPlantID, PhotoURL: "https://example.com/old.jpg", WateredAt: at(4, "07:00")}, nil)
+	repo.On("UpdateWateringHistory", mock.Anything).Return(nil)
+	wh, _, err = uc.UpdateWateringHistory(5, 3, &WateringHistory{UserID: 5, UserPlantID: &userPlantID}, strings.NewReader("photo"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/new.jpg", wh.PhotoURL)
+	images.AssertCalled(t, "RemoveImage", "https://example.com/old.jpg")
+
+	images.ExpectedCalls = nil
+	images.On("UploadImage", mock.Anything).Return("", errors.New("unavailable"))
+	_, code, err = uc.StoreWateringHistory(&WateringHistory{UserID: 5, UserPlantID: &userPlantID}, strings.NewReader("photo"))
+	assert.Error(t, err)
+	assert.Equal(t, constants.ErrCodePhotoUploadFailed, code)
+}
+
+func TestGetAllWateringHistoriesRejectsReversedRange(t *testing.T) {
+	repo := new(MockRepository)
+	uc := newTestUseCase(repo, at(4, "10:00"))
+	from, to := at(3, "00:00"), at(2, "00:00")
+
+	_, code, err := uc.GetAllWateringHistories(&WateringHistoryFilter{UserID: 5, From: &from, To: &to})
+
+	assert.Error(t, err)
+	assert.Equal(t, constants.ErrCodeInvalidDateRange, code)
+	repo.AssertNotCalled(t, "GetAllWateringHistories", mock.Anything)
+}
+
+func TestCheckCompliance(t *testing.T) {
+	schedule := twiceDaily(1)
+	now := at(4, "10:00")
+	waterings := []time.Time{
+		at(1, "04:00"), // too early for 07:00
+		at(1, "17:30"),
+		at(2, "08:00"),
+		at(2, "16:00"), // early for 17:00
+		at(3, "07:10"),
+	}
+
+	c := checkCompliance(schedule, at(1, "00:00"), now, jakarta, waterings, nil)
+
+	assert.Equal(t, ComplianceOverdue, c.Status)
+	// 1 January 07:00 to 4 January 07:00
+	assert.Equal(t, 7, c.Expected)
+	assert.Equal(t, 4, c.Met)
+	assert.Equal(t, 2, c.Missed)
+	assert.True(t, at(3, "17:00").Equal(*c.OverdueSince))
+	assert.Equal(t, 17*time.Hour, c.LateBy)
+	assert.True(t, at(3, "07:10").Equal(*c.LastWateredAt))
+	assert.True(t, at(4, "17:00").Equal(*c.NextDueAt))
+
+	t.Run("a watering time is not late within the first hour", func(t *testing.T) {
+		c := checkCompliance(schedule, at(3, "12:00"), at(3, "17:30"), jakarta, nil, nil)
+
+		assert.Equal(t, ComplianceOnTrack, c.Status)
+		assert.Equal(t, 0, c.Expected)
+	})
+
+	t.Run("a watering after the last watering time catches up", func(t *testing.T) {
+		c := checkCompliance(schedule, at(3, "12:00"), now, jakarta, []time.Time{at(4, "09:30")}, nil)
+
+		assert.Equal(t, ComplianceOnTrack, c.Status)
+		assert.Equal(t, 2, c.Expected)
+		assert.Equal(t, 1, c.Met)
+		assert.Nil(t, c.OverdueSince)
+	})
+
+	t.Run("skipped watering times are not expected", func(t *testing.T) {
+		skips := []WateringSkip{
+			{Reason: SkipReasonRain, StartsAt: at(3, "17:00"), EndsAt: at(3, "20:00")},
+			{Reason: SkipReasonSkipToday, StartsAt: at(4, "00:00"), EndsAt: at(5, "00:00")},
+		}
+		c := checkCompliance(schedule, at(3, "12:00"), now, jakarta, nil, skips)
+
+		assert.Equal(t, ComplianceOnTrack, c.Status)
+		assert.Equal(t, 0, c.Expected)
+		assert.Equal(t, 0, c.Missed)
+		assert.Nil(t, c.OverdueSince)
+	})
+
+	t.Run("a plant without watering times has no schedule", func(t *testing.T) {
+		c := checkCompliance(plant.PlantReminder{}, at(1, "00:00"), now, jakarta, waterings, nil)
+
+		assert.Equal(t, ComplianceNoSchedule, c.Status)
+		assert.Nil(t, c.NextDueAt)
+	})
+}
+
+func TestComplianceSummaryAndEscalation(t *testing.T) {
+	repo := new(MockRepository)
+	now := at(4, "10:00")
+	owner := user.User{ID: 5, Timezone: "Asia/Jakarta"}
+	basil := plant.Plant{ID: 2, Name: "Basil", WateringSchedule: twiceDaily(2)}
+	userPlants := []plant.UserPlant{
+		{ID: 10, UserID: 5, PlantID: 1, CreatedAt: at(3, "12:00"), User: owner, Plant: plant.Plant{ID: 1, Name: "Aloe", WateringSchedule: twiceDaily(1)}},
+		{ID: 11, UserID: 5, PlantID: 2, CreatedAt: at(1, "00:00"), User: owner, Plant: basil},
+		{ID: 12, UserID: 5, PlantID: 3, CreatedAt: at(1, "00:00"), User: owner, Plant: plant.Plant{ID: 3, Name: "Cactus"}},
+		// A second basil is checked on its own.
+		{ID: 14, UserID: 5, PlantID: 2, CreatedAt: at(3, "00:00"), User: owner, Plant: basil},
+	}
+	watered := 11
+	repo.On("GetUserPlants", uint(5)).Return(userPlants, nil)
+	repo.On("GetWateringsSince", uint(5), now.Add(-complianceWindow-earlyWatering)).Return([]WateringHistory{
+		{PlantID: 2, UserPlantID: &watered, UserID: 5, WateredAt: at(4, "06:30")},
+	}, nil)
+	repo.On("GetSkipsSince", uint(5), now.Add(-complianceWindow)).Return([]WateringSkip{
+		// Cactus has no schedule, skipping it changes nothing.
+		{UserID: 5, PlantID: 3, Reason: SkipReasonSkipToday, StartsAt: at(4, "00:00"), EndsAt: at(5, "00:00")},
+	}, nil)
+	repo.On("GetPlantOwnerIDs").Return([]uint{5}, nil)
+
+	uc := newTestUseCase(repo, now)
+	summary, code, err := uc.GetComplianceSummary(5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, constants.CodeSuccess, code)
+	assert.Equal(t, 4, summary.Plants)
+	assert.Equal(t, 1, summary.OnTrack)
+	assert.Equal(t, 2, summary.Overdue)
+	assert.Equal(t, 1, summary.NoSchedule)
+	assert.Len(t, summary.Late, 2)
+	assert.Equal(t, 14, summary.Late[0].UserPlantID)
+	assert.True(t, at(3, "07:00").Equal(*summary.Late[0].OverdueSince))
+	assert.Equal(t, 10, summary.Late[1].UserPlantID)
+	assert.True(t, at(3, "17:00").Equal(*summary.Late[1].OverdueSince))
+	// The first basil was due 7 times and watered once, the second one 3
+	// times, Aloe was due twice since it was added.
+	assert.Equal(t, 12, summary.Expected)
+	assert.Equal(t, 1, summary.Met)
+
+	c, _, err := uc.GetPlantCompliance(5, 2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 11, c.UserPlantID)
+	c, _, err = uc.GetPlantCompliance(5, 2, 14)
+	assert.NoError(t, err)
+	assert.Equal(t, ComplianceOverdue, c.Status)
+	_, code, err = uc.GetPlantCompliance(5, 4, 0)
+	assert.Error(t, err)
+	assert.Equal(t, constants.ErrCodePlantNotFound, code)
+
+	escalator := &fakeEscalator{}
+	uc.SetEscalator(escalator, 24*time.Hour)
+	escalated, err := uc.EscalateOverduePlants()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, escalated)
+	assert.Equal(t, 2, escalator.escalations[0].plantID)
+
+	escalator = &fakeEscalator{}
+	uc.SetEscalator(escalator, 12*time.Hour)
+	escalated, err = uc.EscalateOverduePlants()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, escalated)
+	assert.Equal(t, 5, escalator.escalations[0].userID)
+	assert.Equal(t, 1, escalator.escalations[0].plantID)
+	assert.True(t, at(3, "17:00").Equal(escalator.escalations[0].overdueSince))
+}
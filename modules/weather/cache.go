@@ -0,0 +1,250 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/OctavianoRyan25/be-agriculture/utils/geohash"
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache stores weather responses for a while. Get reports whether key was
+// found, errors are failures of the backend.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+const (
+	// cellPrecision is the geohash length locations are rounded to, a cell
+	// of about 5 by 5 km, smaller than the grid of the weather models.
+	cellPrecision = 5
+	// staleFor is how long an expired response is kept to answer with
+	// when the provider fails.
+	staleFor = 6 * time.Hour
+	// cacheTimeout bounds one request to the cache backend.
+	cacheTimeout = time.Second
+)
+
+// Time to live of the responses of each endpoint. Current weather changes
+// fastest, the daily forecast is updated a few times a day.
+const (
+	currentTTL = 10 * time.Minute
+	hourlyTTL  = 30 * time.Minute
+	dailyTTL   = 3 * time.Hour
+)
+
+// cacheEntry is a response stored in the cache.
+type cacheEntry struct {
+	FetchedAt time.Time       `json:"fetched_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// cachedWeatherService answers from the cache for the geohash cell of a
+// location, and asks the service it wraps for the weather at the center of
+// the cell when the response is missing or expired. Concurrent requests for
+// the same cell share one provider request. When the provider fails, an
+// expired response is answered for up to staleFor.
+type cachedWeatherService struct {
+	next      WeatherService
+	cache     Cache
+	namespace string
+	group     singleflight.Group
+	now       func() time.Time
+}
+
+// NewCachedWeatherService caches the responses of service in cache.
+// Namespace separates the responses of different providers, usually the
+// provider name.
+func NewCachedWeatherService(service WeatherService, cache Cache, namespace string) WeatherService {
+	return &cachedWeatherService{
+		next:      service,
+		cache:     cache,
+		namespace: namespace,
+		now:       time.Now,
+	}
+}
+
+func (s *cachedWeatherService) GetCurrentWeatherByCoordinates(lat, lon float64) (*Weather, error) {
+	return cached(s, "current", currentTTL, lat, lon, s.next.GetCurrentWeatherByCoordinates)
+}
+
+func (s *cachedWeatherService) GetHourlyWeatherByCoordinates(lat, lon float64) ([]HourlyWeather, error) {
+	return cached(s, "hourly", hourlyTTL, lat, lon, s.next.GetHourlyWeatherByCoordinates)
+}
+
+func (s *cachedWeatherService) GetDailyWeatherByCoordinates(lat, lon float64) ([]DailyWeather, error) {
+	return cached(s, "daily", dailyTTL, lat, lon, s.next.GetDailyWeatherByCoordinates)
+}
+
+// cached answers the response of endpoint for the cell of a location from
+// the cache, or from fetch when it is older than ttl. Every caller decodes
+// its own copy, so callers can change what they get.
+func cached[T any](s *cachedWeatherService, endpoint string, ttl time.Duration, lat, lon float64, fetch func(lat, lon float64) (T, error)) (T, error) {
+	var result T
+	if err := checkCoordinates(lat, lon); err != nil {
+		return result, err
+	}
+
+	cell := geohash.Encode(lat, lon, cellPrecision)
+	key := fmt.Sprintf("weather:%s:%s:%s", s.namespace, endpoint, cell)
+
+	entry, found := s.load(key)
+	if found && s.now().Sub(entry.FetchedAt) < ttl {
+		err := json.Unmarshal(entry.Data, &result)
+		if err == nil {
+			return result, nil
+		}
+		log.Printf("Failed to decode cached weather %s: %v", key, err)
+		found = false
+	}
+
+	data, err, _ := s.group.Do(key, func() (interface{}, error) {
+		value, err := fetch(geohash.Center(cell))
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		s.store(key, cacheEntry{FetchedAt: s.now(), Data: data}, ttl)
+		return data, nil
+	})
+	if err != nil {
+		if !found {
+			return result, err
+		}
+		log.Printf("Serving weather %s from %s: %v", key, entry.FetchedAt.Format(time.RFC3339), err)
+		data = []byte(entry.Data)
+	}
+
+	err = json.Unmarshal(data.([]byte), &result)
+	return result, err
+}
+
+// load returns the entry of key. Failures of the cache are logged and
+// treated as a miss, the weather can still be fetched.
+func (s *cachedWeatherService) load(key string) (cacheEntry, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), cacheTimeout)
+	defer cancel()
+
+	var entry cacheEntry
+	value, found, err := s.cache.Get(ctx, key)
+	if err != nil {
+		log.Printf("Failed to read weather cache %s: %v", key, err)
+		return entry, false
+	}
+	if !found {
+		return entry, false
+	}
+	if err := json.Unmarshal(value, &entry); err != nil {
+		log.Printf("Failed to decode weather cache %s: %v", key, err)
+		return entry, false
+	}
+	return entry, true
+}
+
+// store keeps entry for ttl and then staleFor longer, in case the provider
+// fails.
+func (s *cachedWeatherService) store(key string, entry cacheEntry, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), cacheTimeout)
+	defer cancel()
+
+	value, err := json.Marshal(entry)
+	if err == nil {
+		err = s.cache.Set(ctx, key, value, ttl+staleFor)
+	}
+	if err != nil {
+		log.Printf("Failed to write weather cache %s: %v", key, err)
+	}
+}
+
+// MemoryCache is a Cache in the memory of the server, for a single
+// instance. Expired values are removed as new ones are stored.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	sets    int
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// sweepEvery is how many values are stored between removing expired ones.
+const sweepEvery = 256
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !c.now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.entries[key] = memoryEntry{value: value, expiresAt: now.Add(ttl)}
+
+	c.sets++
+	if c.sets%sweepEvery == 0 {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	return nil
+}
+
+// Cache backends WEATHER_CACHE can name.
+const (
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+	CacheOff    = "off"
+)
+
+// CacheFromEnv creates the cache WEATHER_CACHE names: "memory" (the
+// default), "redis" for the server at WEATHER_CACHE_REDIS_URL, or "off" for
+// no cache, which returns nil. A Redis URL that cannot be used falls back to
+// the memory cache.
+func CacheFromEnv() Cache {
+	switch name := os.Getenv("WEATHER_CACHE"); name {
+	case "", CacheMemory:
+		return NewMemoryCache()
+	case CacheOff:
+		return nil
+	case CacheRedis:
+		cache, err := NewRedisCache(os.Getenv("WEATHER_CACHE_REDIS_URL"))
+		if err != nil {
+			log.Printf("Invalid WEATHER_CACHE_REDIS_URL, using the memory cache: %v", err)
+			return NewMemoryCache()
+		}
+		return cache
+	default:
+		log.Printf("Unknown weather cache %q, using the memory cache", name)
+		return NewMemoryCache()
+	}
+}
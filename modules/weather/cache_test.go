@@ -0,0 +1,313 @@
+package weather
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubService counts the requests it answers and remembers the location
+// of the last one. When gate is set, requests wait for it to close.
+type stubService struct {
+	mu       sync.Mutex
+	calls    int
+	lat, lon float64
+	err      error
+	gate     chan struct{}
+}
+
+func (s *stubService) record(lat, lon float64) error {
+	if s.gate != nil {
+		<-s.gate
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	s.lat, s.lon = lat, lon
+	return s.err
+}
+
+func (s *stubService) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls
+}
+
+func (s *stubService) GetCurrentWeatherByCoordinates(lat, lon float64) (*Weather, error) {
+	if err := s.record(lat, lon); err != nil {
+		return nil, err
+	}
+	return &Weather{City: "Jakarta", Temperature: float64(s.Calls())}, nil
+}
+
+func (s *stubService) GetHourlyWeatherByCoordinates(lat, lon float64) ([]HourlyWeather, error) {
+	if err := s.record(lat, lon); err != nil {
+		return nil, err
+	}
+	return []HourlyWeather{{City: "Jakarta"}}, nil
+}
+
+func (s *stubService) GetDailyWeatherByCoordinates(lat, lon float64) ([]DailyWeather, error) {
+	if err := s.record(lat, lon); err != nil {
+		return nil, err
+	}
+	return []DailyWeather{{City: "Jakarta"}}, nil
+}
+
+func newTestCachedService(next WeatherService, now *time.Time) *cachedWeatherService {
+	cache := NewMemoryCache()
+	cache.now = func() time.Time { return *now }
+	s := NewCachedWeatherService(next, cache, "test").(*cachedWeatherService)
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestCachedWeatherService(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	next := &stubService{}
+	service := newTestCachedService(next, &now)
+
+	current, err := service.GetCurrentWeatherByCoordinates(-6.2088, 106.8456)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, current.Temperature)
+	// The weather is fetched for the center of the cell.
+	assert.InDelta(t, -6.2088, next.lat, 0.03)
+	assert.NotEqual(t, -6.2088, next.lat)
+
+	// A neighbour in the same cell gets the same response, a copy of it.
+	current.City = "changed"
+	current, err = service.GetCurrentWeatherByCoordinates(-6.2150, 106.8400)
+	require.NoError(t, err)
+	assert.Equal(t, "Jakarta", current.City)
+	assert.Equal(t, 1, next.Calls())
+
+	// Each endpoint is cached on its own.
+	_, err = service.GetDailyWeatherByCoordinates(-6.2088, 106.8456)
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.Calls())
+
+	// Bogor is another cell.
+	_, err = service.GetCurrentWeatherByCoordinates(-6.5950, 106.8166)
+	require.NoError(t, err)
+	assert.Equal(t, 3, next.Calls())
+
+	// The current weather expires first.
+	now = now.Add(currentTTL)
+	current, err = service.GetCurrentWeatherByCoordinates(-6.2088, 106.8456)
+	require.NoError(t, err)
+	assert.Equal(t, 4.0, current.Temperature)
+	_, err = service.GetDailyWeatherByCoordinates(-6.2088, 106.8456)
+	require.NoError(t, err)
+	assert.Equal(t, 4, next.Calls())
+
+	_, err = service.GetCurrentWeatherByCoordinates(91, 0)
+	assert.ErrorIs(t, err, ErrInvalidCoordinates)
+	assert.Equal(t, 4, next.Calls())
+}
+
+func TestCachedWeatherServiceServesStale(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	next := &stubService{}
+	service := newTestCachedService(next, &now)
+
+	_, err := service.GetHourlyWeatherByCoordinates(-6.2088, 106.8456)
+	require.NoError(t, err)
+
+	next.err = &ProviderError{Provider: "test", StatusCode: 503, Err: ErrProviderUnavailable}
+	now = now.Add(hourlyTTL + time.Hour)
+	hourly, err := service.GetHourlyWeatherByCoordinates(-6.2088, 106.8456)
+	assert.NoError(t, err)
+	assert.Len(t, hourly, 1)
+	assert.Equal(t, 2, next.Calls(), "an expired response is fetched again")
+
+	// Without a response to fall back to, the error is returned.
+	_, err = service.GetCurrentWeatherByCoordinates(-6.2088, 106.8456)
+	assert.ErrorIs(t, err, ErrProviderUnavailable)
+
+	now = now.Add(staleFor)
+	_, err = service.GetHourlyWeatherByCoordinates(-6.2088, 106.8456)
+	assert.ErrorIs(t, err, ErrProviderUnavailable)
+}
+
+func TestCachedWeatherServiceMergesRequests(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	next := &stubService{gate: make(chan struct{})}
+	service := newTestCachedService(next, &now)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.GetDailyWeatherByCoordinates(-6.2088, 106.8456)
+			errs <- err
+		}()
+	}
+	// Give the requests time to wait for the first one.
+	time.Sleep(50 * time.Millisecond)
+	close(next.gate)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, next.Calls())
+}
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewMemoryCache()
+	cache.now = func() time.Time { return now }
+
+	require.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Minute))
+	value, found, err := cache.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("1"), value)
+
+	now = now.Add(time.Minute)
+	_, found, _ = cache.Get(ctx, "a")
+	assert.False(t, found)
+
+	// Expired values are removed even when nobody asks for them.
+	require.NoError(t, cache.Set(ctx, "b", []byte("2"), time.Minute))
+	now = now.Add(time.Minute)
+	for i := 0; i < sweepEvery; i++ {
+		require.NoError(t, cache.Set(ctx, "c", []byte("3"), time.Minute))
+	}
+	assert.Len(t, cache.entries, 1)
+}
+
+// serveRedis runs a server answering GET, SET, AUTH and SELECT like Redis,
+// from a map.
+func serveRedis(t *testing.T, password string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	values := make(map[string]string)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				rd := bufio.NewReader(conn)
+				authed := password == ""
+				for {
+					args, err := readCommand(rd)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					switch {
+					case args[0] == "AUTH" && args[len(args)-1] == password:
+						authed = true
+						io.WriteString(conn, "+OK\r\n")
+					case args[0] == "AUTH":
+						io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+					case !authed:
+						io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+					case args[0] == "SELECT":
+						io.WriteString(conn, "+OK\r\n")
+					case args[0] == "SET" && len(args) == 5 && args[3] == "PX":
+						values[args[1]] = args[2]
+						io.WriteString(conn, "+OK\r\n")
+					case args[0] == "GET":
+						value, ok := values[args[1]]
+						if ok {
+							io.WriteString(conn, "$"+strconv.Itoa(len(value))+"\r\n"+value+"\r\n")
+						} else {
+							io.WriteString(conn, "$-1\r\n")
+						}
+					default:
+						io.WriteString(conn, "-ERR unknown command\r\n")
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedisCache(t *testing.T) {
+	ctx := context.Background()
+	addr := serveRedis(t, "secret")
+
+	cache, err := NewRedisCache("redis://:secret@" + addr + "/2")
+	require.NoError(t, err)
+
+	_, found, err := cache.Get(ctx, "weather:test")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	value := []byte("{\"city\":\"Jakarta\"}\r\nwith a line break")
+	require.NoError(t, cache.Set(ctx, "weather:test", value, time.Minute))
+	got, found, err := cache.Get(ctx, "weather:test")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, got)
+
+	cache, err = NewRedisCache("redis://:wrong@" + addr)
+	require.NoError(t, err)
+	_, _, err = cache.Get(ctx, "weather:test")
+	var replyErr redisError
+	assert.True(t, errors.As(err, &replyErr))
+
+	for _, invalid := range []string{"http://localhost", "redis://", "redis://localhost/db"} {
+		_, err := NewRedisCache(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	cache, err = NewRedisCache("redis://localhost")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:6379", cache.addr)
+}
@@ -19,6 +19,8 @@ type Weather struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// HourlyWeather is the forecast of an hour from Timestamp. Rain is the
+// expected rain in mm and RainChance its probability, from 0 to 1.
 type HourlyWeather struct {
 	ID          uint      `gorm:"primary_key" json:"id"`
 	City        string    `json:"city"`
@@ -30,9 +32,12 @@ type HourlyWeather struct {
 	Main        string    `json:"main"`
 	Description string    `json:"description"`
 	Icon        string    `json:"icon"`
+	Rain        float64   `json:"rain"`
+	RainChance  float64   `json:"rain_chance"`
 	Timestamp   time.Time `json:"timestamp"`
 }
 
+// DailyWeather is the forecast of a day, with the rain of the whole day.
 type DailyWeather struct {
 	ID          uint      `gorm:"primary_key" json:"id"`
 	City        string    `json:"city"`
@@ -45,5 +50,7 @@ type DailyWeather struct {
 	Description string    `json:"description"`
 	Sunrise     int64 `json:"sunrise"`
 	Icon        string    `json:"icon"`
+	Rain        float64   `json:"rain"`
+	RainChance  float64   `json:"rain_chance"`
 	Date        time.Time `json:"date"`
 }
@@ -0,0 +1,94 @@
+package weather
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"sync"
+)
+
+const ProviderFake = "fake"
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// FakeProvider answers every location with the weather in Jakarta from the
+// files in fixtures, for tests and for running the server without a
+// weather API. After SetErr, it fails every request with the error instead.
+type FakeProvider struct {
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+// SetErr makes the following requests fail with err, or succeed again when
+// err is nil.
+func (p *FakeProvider) SetErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.err = err
+}
+
+// Calls returns how many requests the provider answered or failed.
+func (p *FakeProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.calls
+}
+
+// load counts the request and decodes the fixture file into out.
+func (p *FakeProvider) load(ctx context.Context, file string, out interface{}) error {
+	p.mu.Lock()
+	p.calls++
+	err := p.err
+	p.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return &ProviderError{Provider: ProviderFake, Message: err.Error(), Err: ErrProviderUnavailable}
+	}
+	data, err := fixtures.ReadFile("fixtures/" + file)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func (p *FakeProvider) Current(ctx context.Context, lat, lon float64) (*Weather, error) {
+	var weather Weather
+	err := p.load(ctx, "current.json", &weather)
+	if err != nil {
+		return nil, err
+	}
+	return &weather, nil
+}
+
+func (p *FakeProvider) Hourly(ctx context.Context, lat, lon float64) ([]HourlyWeather, error) {
+	var hourly []HourlyWeather
+	err := p.load(ctx, "hourly.json", &hourly)
+	if err != nil {
+		return nil, err
+	}
+	return hourly, nil
+}
+
+func (p *FakeProvider) Daily(ctx context.Context, lat, lon float64) ([]DailyWeather, error) {
+	var daily []DailyWeather
+	err := p.load(ctx, "daily.json", &daily)
+	if err != nil {
+		return nil, err
+	}
+	return daily, nil
+}
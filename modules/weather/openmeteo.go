@@ -0,0 +1,242 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const ProviderOpenMeteo = "openmeteo"
+
+// OpenMeteoProvider fetches the weather from Open-Meteo, which needs no API
+// key. It does not name the city, and its daily forecast has no pressure or
+// humidity. Weather codes are described the way OpenWeather describes
+// them, with OpenWeather icons, so the app shows both the same.
+type OpenMeteoProvider struct {
+	baseURL string
+	client  *resty.Client
+}
+
+func NewOpenMeteoProvider() *OpenMeteoProvider {
+	return &OpenMeteoProvider{
+		baseURL: "https://api.open-meteo.com/v1",
+		client:  resty.New().SetTimeout(providerTimeout),
+	}
+}
+
+const (
+	omCurrentFields = "temperature_2m,apparent_temperature,pressure_msl,relative_humidity_2m,wind_speed_10m,weather_code,is_day"
+	omHourlyFields  = omCurrentFields + ",precipitation,precipitation_probability"
+	omDailyFields   = "weather_code,temperature_2m_max,apparent_temperature_max,wind_speed_10m_max,sunrise,precipitation_sum,precipitation_probability_max"
+	// omForecastHours matches the 4 days of the OpenWeather hourly
+	// forecast.
+	omForecastHours = 96
+)
+
+type omResponse struct {
+	UTCOffsetSeconds int `json:"utc_offset_seconds"`
+	Current          struct {
+		Time                int64   `json:"time"`
+		Temperature2m       float64 `json:"temperature_2m"`
+		ApparentTemperature float64 `json:"apparent_temperature"`
+		PressureMSL         float64 `json:"pressure_msl"`
+		RelativeHumidity2m  float64 `json:"relative_humidity_2m"`
+		WindSpeed10m        float64 `json:"wind_speed_10m"`
+		WeatherCode         int     `json:"weather_code"`
+		IsDay               int     `json:"is_day"`
+	} `json:"current"`
+	Hourly struct {
+		Time                []int64   `json:"time"`
+		Temperature2m       []float64 `json:"temperature_2m"`
+		ApparentTemperature []float64 `json:"apparent_temperature"`
+		PressureMSL         []float64 `json:"pressure_msl"`
+		RelativeHumidity2m  []float64 `json:"relative_humidity_2m"`
+		WindSpeed10m        []float64 `json:"wind_speed_10m"`
+		WeatherCode         []int     `json:"weather_code"`
+		IsDay               []int     `json:"is_day"`
+		// Precipitation is in mm, its probability in percent.
+		Precipitation            []float64 `json:"precipitation"`
+		PrecipitationProbability []float64 `json:"precipitation_probability"`
+	} `json:"hourly"`
+	Daily struct {
+		Time                        []int64   `json:"time"`
+		WeatherCode                 []int     `json:"weather_code"`
+		Temperature2mMax            []float64 `json:"temperature_2m_max"`
+		ApparentTemperatureMax      []float64 `json:"apparent_temperature_max"`
+		WindSpeed10mMax             []float64 `json:"wind_speed_10m_max"`
+		Sunrise                     []int64   `json:"sunrise"`
+		PrecipitationSum            []float64 `json:"precipitation_sum"`
+		PrecipitationProbabilityMax []float64 `json:"precipitation_probability_max"`
+	} `json:"daily"`
+}
+
+// at returns the value at i, or the zero value when the provider sent a
+// shorter list.
+func at[T any](values []T, i int) T {
+	var zero T
+	if i >= len(values) {
+		return zero
+	}
+	return values[i]
+}
+
+// wmoCondition describes a WMO weather code as main, description and the
+// OpenWeather icon without its day or night suffix.
+func wmoCondition(code int) (string, string, string) {
+	switch code {
+	case 0:
+		return "Clear", "clear sky", "01"
+	case 1:
+		return "Clouds", "mainly clear", "02"
+	case 2:
+		return "Clouds", "partly cloudy", "03"
+	case 3:
+		return "Clouds", "overcast clouds", "04"
+	case 45, 48:
+		return "Fog", "fog", "50"
+	case 51, 53, 55:
+		return "Drizzle", "drizzle", "09"
+	case 56, 57:
+		return "Drizzle", "freezing drizzle", "09"
+	case 61:
+		return "Rain", "light rain", "10"
+	case 63:
+		return "Rain", "moderate rain", "10"
+	case 65:
+		return "Rain", "heavy intensity rain", "10"
+	case 66, 67:
+		return "Rain", "freezing rain", "13"
+	case 71, 73, 75, 77:
+		return "Snow", "snow", "13"
+	case 80, 81:
+		return "Rain", "shower rain", "09"
+	case 82:
+		return "Rain", "heavy intensity shower rain", "09"
+	case 85, 86:
+		return "Snow", "shower snow", "13"
+	case 95:
+		return "Thunderstorm", "thunderstorm", "11"
+	case 96, 99:
+		return "Thunderstorm", "thunderstorm with hail", "11"
+	}
+	return "", "", ""
+}
+
+func omIcon(icon string, isDay int) string {
+	if icon == "" {
+		return ""
+	}
+	if isDay == 0 {
+		return icon + "n"
+	}
+	return icon + "d"
+}
+
+func (p *OpenMeteoProvider) Name() string {
+	return ProviderOpenMeteo
+}
+
+func (p *OpenMeteoProvider) forecast(ctx context.Context, lat, lon float64, params map[string]string) (*omResponse, error) {
+	params["latitude"] = fmt.Sprintf("%f", lat)
+	params["longitude"] = fmt.Sprintf("%f", lon)
+	params["timezone"] = "auto"
+	params["timeformat"] = "unixtime"
+	params["wind_speed_unit"] = "ms"
+
+	var res omResponse
+	err := getJSON(ctx, p.client, ProviderOpenMeteo, p.baseURL+"/forecast", params, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p *OpenMeteoProvider) Current(ctx context.Context, lat, lon float64) (*Weather, error) {
+	res, err := p.forecast(ctx, lat, lon, map[string]string{
+		"current":       omCurrentFields,
+		"daily":         "sunrise",
+		"forecast_days": "1",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	current := res.Current
+	main, description, icon := wmoCondition(current.WeatherCode)
+	return &Weather{
+		ID:          1,
+		Temperature: current.Temperature2m,
+		RealFeel:    current.ApparentTemperature,
+		Pressure:    int(current.PressureMSL + 0.5),
+		Humidity:    int(current.RelativeHumidity2m + 0.5),
+		WindSpeed:   current.WindSpeed10m,
+		Main:        main,
+		Description: description,
+		Icon:        omIcon(icon, current.IsDay),
+		Sunrise:     localTime(at(res.Daily.Sunrise, 0), res.UTCOffsetSeconds).Unix(),
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+func (p *OpenMeteoProvider) Hourly(ctx context.Context, lat, lon float64) ([]HourlyWeather, error) {
+	res, err := p.forecast(ctx, lat, lon, map[string]string{
+		"hourly":         omHourlyFields,
+		"forecast_hours": fmt.Sprint(omForecastHours),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h := res.Hourly
+	hourly := make([]HourlyWeather, 0, len(h.Time))
+	for i, t := range h.Time {
+		main, description, icon := wmoCondition(at(h.WeatherCode, i))
+		hourly = append(hourly, HourlyWeather{
+			ID:          uint(i + 1),
+			Timestamp:   localTime(t, res.UTCOffsetSeconds),
+			Temperature: at(h.Temperature2m, i),
+			RealFeel:    at(h.ApparentTemperature, i),
+			Pressure:    int(at(h.PressureMSL, i) + 0.5),
+			Humidity:    int(at(h.RelativeHumidity2m, i) + 0.5),
+			WindSpeed:   at(h.WindSpeed10m, i),
+			Main:        main,
+			Description: description,
+			Icon:        omIcon(icon, at(h.IsDay, i)),
+			Rain:        at(h.Precipitation, i),
+			RainChance:  at(h.PrecipitationProbability, i) / 100,
+		})
+	}
+	return hourly, nil
+}
+
+func (p *OpenMeteoProvider) Daily(ctx context.Context, lat, lon float64) ([]DailyWeather, error) {
+	res, err := p.forecast(ctx, lat, lon, map[string]string{
+		"daily":         omDailyFields,
+		"forecast_days": "7",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d := res.Daily
+	daily := make([]DailyWeather, 0, len(d.Time))
+	for i, t := range d.Time {
+		main, description, icon := wmoCondition(at(d.WeatherCode, i))
+		daily = append(daily, DailyWeather{
+			ID:          uint(i + 1),
+			Date:        localTime(t, res.UTCOffsetSeconds),
+			Temperature: at(d.Temperature2mMax, i),
+			RealFeel:    at(d.ApparentTemperatureMax, i),
+			WindSpeed:   at(d.WindSpeed10mMax, i),
+			Main:        main,
+			Description: description,
+			Sunrise:     localTime(at(d.Sunrise, i), res.UTCOffsetSeconds).Unix(),
+			Icon:        omIcon(icon, 1),
+			Rain:        at(d.PrecipitationSum, i),
+			RainChance:  at(d.PrecipitationProbabilityMax, i) / 100,
+		})
+	}
+	return daily, nil
+}
@@ -0,0 +1,203 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const ProviderOpenWeather = "openweather"
+
+// OpenWeatherProvider fetches the weather from OpenWeather. The hourly
+// forecast needs a paid plan and is served from the pro host.
+type OpenWeatherProvider struct {
+	apiKey     string
+	baseURL    string
+	proBaseURL string
+	client     *resty.Client
+}
+
+func NewOpenWeatherProvider(apiKey string) *OpenWeatherProvider {
+	return &OpenWeatherProvider{
+		apiKey:     apiKey,
+		baseURL:    "https://api.openweathermap.org/data/2.5",
+		proBaseURL: "https://pro.openweathermap.org/data/2.5",
+		client:     resty.New().SetTimeout(providerTimeout),
+	}
+}
+
+type owCondition struct {
+	Main        string `json:"main"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+}
+
+type owMain struct {
+	Temp      float64 `json:"temp"`
+	FeelsLike float64 `json:"feels_like"`
+	Pressure  int     `json:"pressure"`
+	Humidity  int     `json:"humidity"`
+}
+
+type owWind struct {
+	Speed float64 `json:"speed"`
+}
+
+type owCity struct {
+	Name     string `json:"name"`
+	Timezone int    `json:"timezone"`
+}
+
+type owCurrentResponse struct {
+	Name     string        `json:"name"`
+	Timezone int           `json:"timezone"`
+	Main     owMain        `json:"main"`
+	Wind     owWind        `json:"wind"`
+	Weather  []owCondition `json:"weather"`
+	Sys      struct {
+		Sunrise int64 `json:"sunrise"`
+	} `json:"sys"`
+}
+
+type owHourlyResponse struct {
+	City owCity `json:"city"`
+	List []struct {
+		Dt      int64         `json:"dt"`
+		Main    owMain        `json:"main"`
+		Wind    owWind        `json:"wind"`
+		Weather []owCondition `json:"weather"`
+		Pop     float64       `json:"pop"`
+		Rain    struct {
+			OneHour float64 `json:"1h"`
+		} `json:"rain"`
+	} `json:"list"`
+}
+
+type owDailyResponse struct {
+	City owCity `json:"city"`
+	List []struct {
+		Dt      int64 `json:"dt"`
+		Sunrise int64 `json:"sunrise"`
+		Temp    struct {
+			Day float64 `json:"day"`
+		} `json:"temp"`
+		FeelsLike struct {
+			Day float64 `json:"day"`
+		} `json:"feels_like"`
+		Pressure int           `json:"pressure"`
+		Humidity int           `json:"humidity"`
+		Speed    float64       `json:"speed"`
+		Weather  []owCondition `json:"weather"`
+		Pop      float64       `json:"pop"`
+		Rain     float64       `json:"rain"`
+	} `json:"list"`
+}
+
+// condition returns the first condition, OpenWeather lists the primary one
+// first.
+func condition(conditions []owCondition) owCondition {
+	if len(conditions) == 0 {
+		return owCondition{}
+	}
+	return conditions[0]
+}
+
+func (p *OpenWeatherProvider) Name() string {
+	return ProviderOpenWeather
+}
+
+func (p *OpenWeatherProvider) params(lat, lon float64) map[string]string {
+	return map[string]string{
+		"lat":   fmt.Sprintf("%f", lat),
+		"lon":   fmt.Sprintf("%f", lon),
+		"appid": p.apiKey,
+		"units": "metric",
+	}
+}
+
+func (p *OpenWeatherProvider) Current(ctx context.Context, lat, lon float64) (*Weather, error) {
+	var res owCurrentResponse
+	err := getJSON(ctx, p.client, ProviderOpenWeather, p.baseURL+"/weather", p.params(lat, lon), &res)
+	if err != nil {
+		return nil, err
+	}
+
+	c := condition(res.Weather)
+	return &Weather{
+		ID:          1,
+		City:        res.Name,
+		Temperature: res.Main.Temp,
+		RealFeel:    res.Main.FeelsLike,
+		Pressure:    res.Main.Pressure,
+		Humidity:    res.Main.Humidity,
+		WindSpeed:   res.Wind.Speed,
+		Main:        c.Main,
+		Description: c.Description,
+		Icon:        c.Icon,
+		Sunrise:     localTime(res.Sys.Sunrise, res.Timezone).Unix(),
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+func (p *OpenWeatherProvider) Hourly(ctx context.Context, lat, lon float64) ([]HourlyWeather, error) {
+	var res owHourlyResponse
+	err := getJSON(ctx, p.client, ProviderOpenWeather, p.proBaseURL+"/forecast/hourly", p.params(lat, lon), &res)
+	if err != nil {
+		return nil, err
+	}
+
+	hourly := make([]HourlyWeather, 0, len(res.List))
+	for i, item := range res.List {
+		c := condition(item.Weather)
+		hourly = append(hourly, HourlyWeather{
+			ID:          uint(i + 1),
+			City:        res.City.Name,
+			Timestamp:   localTime(item.Dt, res.City.Timezone),
+			Temperature: item.Main.Temp,
+			RealFeel:    item.Main.FeelsLike,
+			Pressure:    item.Main.Pressure,
+			Humidity:    item.Main.Humidity,
+			WindSpeed:   item.Wind.Speed,
+			Main:        c.Main,
+			Description: c.Description,
+			Icon:        c.Icon,
+			Rain:        item.Rain.OneHour,
+			RainChance:  item.Pop,
+		})
+	}
+	return hourly, nil
+}
+
+func (p *OpenWeatherProvider) Daily(ctx context.Context, lat, lon float64) ([]DailyWeather, error) {
+	params := p.params(lat, lon)
+	params["cnt"] = "7"
+	var res owDailyResponse
+	err := getJSON(ctx, p.client, ProviderOpenWeather, p.baseURL+"/forecast/daily", params, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	daily := make([]DailyWeather, 0, len(res.List))
+	for i, item := range res.List {
+		c := condition(item.Weather)
+		daily = append(daily, DailyWeather{
+			ID:          uint(i + 1),
+			City:        res.City.Name,
+			Date:        localTime(item.Dt, res.City.Timezone),
+			Temperature: item.Temp.Day,
+			RealFeel:    item.FeelsLike.Day,
+			Pressure:    item.Pressure,
+			Humidity:    item.Humidity,
+			WindSpeed:   item.Speed,
+			Main:        c.Main,
+			Description: c.Description,
+			Sunrise:     localTime(item.Sunrise, res.City.Timezone).Unix(),
+			Icon:        c.Icon,
+			Rain:        item.Rain,
+			RainChance:  item.Pop,
+		})
+	}
+	return daily, nil
+}
@@ -0,0 +1,142 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// WeatherProvider fetches the weather at a location from a weather API.
+// Errors wrap one of the errors below, so callers can tell a bad request
+// from an API that is down.
+type WeatherProvider interface {
+	Name() string
+	Current(ctx context.Context, lat, lon float64) (*Weather, error)
+	Hourly(ctx context.Context, lat, lon float64) ([]HourlyWeather, error)
+	Daily(ctx context.Context, lat, lon float64) ([]DailyWeather, error)
+}
+
+var (
+	ErrInvalidCoordinates = errors.New("invalid coordinates")
+	// ErrRateLimited means the provider refuses more requests for now.
+	ErrRateLimited = errors.New("weather provider rate limit reached")
+	// ErrProviderRejected means the provider refused the request itself,
+	// like an invalid API key or a plan without the endpoint.
+	ErrProviderRejected = errors.New("weather provider rejected the request")
+	// ErrProviderUnavailable means the provider could not be reached or
+	// failed, trying again later may help.
+	ErrProviderUnavailable = errors.New("weather provider unavailable")
+	// ErrBadResponse means the provider answered with something that is
+	// not the weather.
+	ErrBadResponse = errors.New("unexpected response from weather provider")
+)
+
+// ProviderError is an error of a provider request, wrapping one of the
+// errors above.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s: %v: %s", e.Provider, e.Err, e.Message)
+	}
+	return fmt.Sprintf("%s: %v: %d %s", e.Provider, e.Err, e.StatusCode, e.Message)
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// ErrorCode returns the HTTP status a weather endpoint answers an error of
+// the service with.
+func ErrorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidCoordinates):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrProviderUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrProviderRejected), errors.Is(err, ErrBadResponse):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// providerTimeout bounds one request to a weather API.
+const providerTimeout = 10 * time.Second
+
+// getJSON requests url and decodes the JSON response into out. Failed
+// requests are mapped to the errors above by their status code.
+func getJSON(ctx context.Context, client *resty.Client, provider, url string, params map[string]string, out interface{}) error {
+	resp, err := client.R().SetContext(ctx).SetQueryParams(params).Get(url)
+	if err != nil {
+		return &ProviderError{Provider: provider, Message: err.Error(), Err: ErrProviderUnavailable}
+	}
+
+	if resp.IsError() {
+		// OpenWeather explains errors in message, Open-Meteo in reason.
+		var body struct {
+			Message string `json:"message"`
+			Reason  string `json:"reason"`
+		}
+		_ = json.Unmarshal(resp.Body(), &body)
+		message := body.Message
+		if message == "" {
+			message = body.Reason
+		}
+
+		status := resp.StatusCode()
+		kind := ErrProviderRejected
+		switch {
+		case status == http.StatusTooManyRequests:
+			kind = ErrRateLimited
+		case status == http.StatusRequestTimeout || status >= 500:
+			kind = ErrProviderUnavailable
+		case status == http.StatusBadRequest && strings.Contains(strings.ToLower(message), "lat"):
+			kind = ErrInvalidCoordinates
+		}
+		return &ProviderError{Provider: provider, StatusCode: status, Message: message, Err: kind}
+	}
+
+	err = json.Unmarshal(resp.Body(), out)
+	if err != nil {
+		return &ProviderError{Provider: provider, StatusCode: resp.StatusCode(), Message: err.Error(), Err: ErrBadResponse}
+	}
+	return nil
+}
+
+// localTime returns the wall clock time of unix at a UTC offset, marked as
+// UTC. The app shows weather times as they are, in the time of the
+// location.
+func localTime(unix int64, offset int) time.Time {
+	return time.Unix(unix, 0).UTC().Add(time.Duration(offset) * time.Second)
+}
+
+// ProviderFromEnv creates the provider WEATHER_PROVIDER names: "openweather"
+// (the default), "openmeteo", or "fake" for the fixtures of FakeProvider.
+func ProviderFromEnv() WeatherProvider {
+	switch name := os.Getenv("WEATHER_PROVIDER"); name {
+	case "", ProviderOpenWeather:
+		apiKey := os.Getenv("OPENWEATHER_API_KEY")
+		if apiKey == "" {
+			log.Printf("OPENWEATHER_API_KEY is not set, weather requests will fail")
+		}
+		return NewOpenWeatherProvider(apiKey)
+	case ProviderOpenMeteo:
+		return NewOpenMeteoProvider()
+	case ProviderFake:
+		return NewFakeProvider()
+	default:
+		log.Printf("Unknown weather provider %q, using %s", name, ProviderOpenWeather)
+		return NewOpenWeatherProvider(os.Getenv("OPENWEATHER_API_KEY"))
+	}
+}
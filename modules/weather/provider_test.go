@@ -0,0 +1,196 @@
+package weather
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveFile answers every request with the file in testdata, and records
+// the requested path and query.
+func serveFile(t *testing.T, file string, requests *[]*http.Request) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + file)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			*requests = append(*requests, r)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serveStatus(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testOpenWeather(url string) *OpenWeatherProvider {
+	p := NewOpenWeatherProvider("key")
+	p.baseURL = url
+	p.proBaseURL = url
+	return p
+}
+
+func testOpenMeteo(url string) *OpenMeteoProvider {
+	p := NewOpenMeteoProvider()
+	p.baseURL = url
+	return p
+}
+
+func TestOpenWeatherProvider(t *testing.T) {
+	ctx := context.Background()
+	noon := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sunrise := time.Date(2024, 1, 1, 5, 42, 0, 0, time.UTC).Unix()
+
+	var requests []*http.Request
+	server := serveFile(t, "openweather_current.json", &requests)
+	current, err := testOpenWeather(server.URL).Current(ctx, -6.2088, 106.8456)
+	require.NoError(t, err)
+	assert.Equal(t, "Jakarta", current.City)
+	assert.Equal(t, 31.2, current.Temperature)
+	assert.Equal(t, 36.4, current.RealFeel)
+	assert.Equal(t, 1009, current.Pressure)
+	assert.Equal(t, 66, current.Humidity)
+	assert.Equal(t, "Clouds", current.Main)
+	assert.Equal(t, "03d", current.Icon)
+	assert.Equal(t, sunrise, current.Sunrise)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/weather", requests[0].URL.Path)
+	assert.Equal(t, "key", requests[0].URL.Query().Get("appid"))
+	assert.Equal(t, "metric", requests[0].URL.Query().Get("units"))
+
+	server = serveFile(t, "openweather_hourly.json", nil)
+	hourly, err := testOpenWeather(server.URL).Hourly(ctx, -6.2088, 106.8456)
+	require.NoError(t, err)
+	require.Len(t, hourly, 2)
+	assert.Equal(t, time.Date(2024, 1, 1, 19, 0, 0, 0, time.UTC), hourly[0].Timestamp)
+	assert.Equal(t, "light rain", hourly[0].Description)
+	assert.Equal(t, 1.2, hourly[0].Rain)
+	assert.Equal(t, 0.8, hourly[0].RainChance)
+	// The second hour has no weather, which must not fail the forecast.
+	assert.Equal(t, 27.0, hourly[1].Temperature)
+	assert.Empty(t, hourly[1].Main)
+	assert.Zero(t, hourly[1].Rain)
+
+	server = serveFile(t, "openweather_daily.json", nil)
+	daily, err := testOpenWeather(server.URL).Daily(ctx, -6.2088, 106.8456)
+	require.NoError(t, err)
+	require.Len(t, daily, 1)
+	assert.Equal(t, noon, daily[0].Date)
+	assert.Equal(t, 31.5, daily[0].Temperature)
+	assert.Equal(t, 3.4, daily[0].WindSpeed)
+	assert.Equal(t, sunrise, daily[0].Sunrise)
+	assert.Equal(t, "Rain", daily[0].Main)
+	assert.Equal(t, 7.5, daily[0].Rain)
+	assert.Equal(t, 0.9, daily[0].RainChance)
+}
+
+func TestOpenMeteoProvider(t *testing.T) {
+	ctx := context.Background()
+
+	var requests []*http.Request
+	server := serveFile(t, "openmeteo_forecast.json", &requests)
+	p := testOpenMeteo(server.URL)
+
+	current, err := p.Current(ctx, -6.2088, 106.8456)
+	require.NoError(t, err)
+	assert.Equal(t, 31.2, current.Temperature)
+	assert.Equal(t, 1009, current.Pressure)
+	assert.Equal(t, 66, current.Humidity)
+	assert.Equal(t, "Rain", current.Main)
+	assert.Equal(t, "moderate rain", current.Description)
+	assert.Equal(t, "10d", current.Icon)
+	assert.Equal(t, time.Date(2024, 1, 1, 5, 42, 0, 0, time.UTC).Unix(), current.Sunrise)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/forecast", requests[0].URL.Path)
+	assert.Equal(t, "unixtime", requests[0].URL.Query().Get("timeformat"))
+	assert.Equal(t, "ms", requests[0].URL.Query().Get("wind_speed_unit"))
+
+	hourly, err := p.Hourly(ctx, -6.2088, 106.8456)
+	require.NoError(t, err)
+	require.Len(t, hourly, 2)
+	assert.Equal(t, time.Date(2024, 1, 1, 19, 0, 0, 0, time.UTC), hourly[0].Timestamp)
+	assert.Equal(t, "Thunderstorm", hourly[0].Main)
+	assert.Equal(t, "11n", hourly[0].Icon)
+	assert.Equal(t, 4.2, hourly[0].Rain)
+	assert.Equal(t, 0.85, hourly[0].RainChance)
+	// is_day is missing for the second hour.
+	assert.Equal(t, "partly cloudy", hourly[1].Description)
+	assert.Equal(t, "03n", hourly[1].Icon)
+
+	daily, err := p.Daily(ctx, -6.2088, 106.8456)
+	require.NoError(t, err)
+	require.Len(t, daily, 2)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), daily[0].Date)
+	assert.Equal(t, 32.1, daily[0].Temperature)
+	assert.Equal(t, 7.5, daily[0].Rain)
+	assert.Equal(t, 0.9, daily[0].RainChance)
+	assert.Equal(t, "Clear", daily[1].Main)
+	// The second day has no probability of rain.
+	assert.Zero(t, daily[1].RainChance)
+	assert.Equal(t, "01d", daily[1].Icon)
+}
+
+func TestProviderErrors(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+		code   int
+	}{
+		{"invalid key", http.StatusUnauthorized, `{"cod":401,"message":"Invalid API key"}`, ErrProviderRejected, http.StatusBadGateway},
+		{"rate limited", http.StatusTooManyRequests, `{"cod":429,"message":"too many requests"}`, ErrRateLimited, http.StatusServiceUnavailable},
+		{"down", http.StatusBadGateway, `bad gateway`, ErrProviderUnavailable, http.StatusServiceUnavailable},
+		{"wrong latitude", http.StatusBadRequest, `{"cod":"400","message":"wrong latitude"}`, ErrInvalidCoordinates, http.StatusBadRequest},
+		{"open-meteo latitude", http.StatusBadRequest, `{"error":true,"reason":"Latitude must be in range of -90 to 90°."}`, ErrInvalidCoordinates, http.StatusBadRequest},
+		{"not json", http.StatusOK, `<html></html>`, ErrBadResponse, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveStatus(t, tt.status, tt.body)
+
+			_, err := testOpenWeather(server.URL).Current(ctx, 0, 0)
+			assert.ErrorIs(t, err, tt.want)
+			assert.Equal(t, tt.code, ErrorCode(err))
+
+			_, err = testOpenMeteo(server.URL).Daily(ctx, 0, 0)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+
+	server := serveStatus(t, http.StatusOK, `{}`)
+	server.Close()
+	_, err := testOpenWeather(server.URL).Hourly(ctx, 0, 0)
+	assert.ErrorIs(t, err, ErrProviderUnavailable)
+}
+
+func TestWMOCondition(t *testing.T) {
+	main, description, icon := wmoCondition(81)
+	assert.Equal(t, "Rain", main)
+	assert.Equal(t, "shower rain", description)
+	assert.Equal(t, "09d", omIcon(icon, 1))
+
+	main, _, icon = wmoCondition(-1)
+	assert.Empty(t, main)
+	assert.Empty(t, omIcon(icon, 0))
+}
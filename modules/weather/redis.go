@@ -0,0 +1,196 @@
+package weather
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedisCache is a Cache on a Redis server, or any server speaking its
+// protocol like Valkey or KeyDB, shared by all instances. It only needs GET
+// and SET, so it talks to the server itself over one connection.
+type RedisCache struct {
+	addr     string
+	username string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// NewRedisCache returns a cache on the server at a URL like
+// redis://:password@localhost:6379/0. The server is connected to on the
+// first request.
+func NewRedisCache(rawURL string) (*RedisCache, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("missing host")
+	}
+
+	cache := &RedisCache{addr: u.Host}
+	if u.Port() == "" {
+		cache.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		cache.username = u.User.Username()
+		cache.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		cache.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database %q", db)
+		}
+	}
+	return cache, nil
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected reply to GET: %v", reply)
+	}
+	return value, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := c.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// redisError is an error the server answered a command with. Unlike other
+// errors, it leaves the connection usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// do sends a command and reads its reply. The connection is closed after
+// any failure other than a redisError, and opened again by the next command.
+func (c *RedisCache) do(ctx context.Context, args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	} else {
+		c.conn.SetDeadline(time.Time{})
+	}
+
+	reply, err := c.command(args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+func (c *RedisCache) connect(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c.conn = conn
+	c.rd = bufio.NewReader(conn)
+
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := c.command(args...); err != nil {
+			conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	if c.db != 0 {
+		if _, err := c.command("SELECT", strconv.Itoa(c.db)); err != nil {
+			conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// command writes args as an array of bulk strings and reads the reply.
+func (c *RedisCache) command(args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply reads a simple string, error, integer or bulk string reply. A
+// missing bulk string is returned as nil.
+func (c *RedisCache) readReply() (interface{}, error) {
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.rd, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+	return nil, fmt.Errorf("redis: unsupported reply %q", line)
+}
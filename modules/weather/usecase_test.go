@@ -1,88 +1,73 @@
 package weather
 
 import (
+	"errors"
+	"math"
 	"testing"
 	"time"
 
-	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
 )
 
-// MockRestyClient implements a mock of the Resty client for testing purposes.
-type MockRestyClient struct{}
-
-func (m *MockRestyClient) R() *resty.Request {
-	return nil // Implement as needed for testing, or mock further.
-}
-
 func TestGetCurrentWeatherByCoordinates(t *testing.T) {
-	service := &weatherService{}
+	service := NewWeatherService(NewFakeProvider())
 
-	lat := 37.7749
-	lon := -122.4194
+	lat := -6.2088
+	lon := 106.8456
 
 	weather, err := service.GetCurrentWeatherByCoordinates(lat, lon)
 
-	if err != nil {
-		t.Errorf("Error fetching current weather: %v", err)
-	}
-
-	if weather == nil {
-		t.Error("Expected weather object, got nil")
-	}
-
-	if weather.City == "" {
-		t.Error("Expected non-empty city name")
-	}
-
-	if weather.CreatedAt.After(time.Now()) {
-		t.Error("CreatedAt should not be in the future")
+	assert.NoError(t, err)
+	if assert.NotNil(t, weather) {
+		assert.Equal(t, "Jakarta", weather.City)
+		assert.NotEmpty(t, weather.Main)
+		assert.False(t, weather.CreatedAt.After(time.Now()))
 	}
 }
 
 func TestGetHourlyWeatherByCoordinates(t *testing.T) {
-	service := &weatherService{}
-
-	lat := 37.7749
-	lon := -122.4194
-
-	hourlyWeather, err := service.GetHourlyWeatherByCoordinates(lat, lon)
+	service := NewWeatherService(NewFakeProvider())
 
-	if err != nil {
-		t.Errorf("Error fetching hourly weather: %v", err)
-	}
-
-	if hourlyWeather == nil || len(hourlyWeather) == 0 {
-		t.Error("Expected hourly weather data, got nil or empty slice")
-	}
+	hourlyWeather, err := service.GetHourlyWeatherByCoordinates(-6.2088, 106.8456)
 
+	assert.NoError(t, err)
+	assert.Len(t, hourlyWeather, 24)
 	for _, hw := range hourlyWeather {
-		if hw.Timestamp.IsZero() {
-			t.Error("Expected non-zero timestamp for hourly weather")
-		}
+		assert.False(t, hw.Timestamp.IsZero())
 	}
 }
 
 func TestGetDailyWeatherByCoordinates(t *testing.T) {
-	service := &weatherService{}
+	service := NewWeatherService(NewFakeProvider())
 
-	lat := 37.7749
-	lon := -122.4194
+	dailyWeather, err := service.GetDailyWeatherByCoordinates(-6.2088, 106.8456)
 
-	dailyWeather, err := service.GetDailyWeatherByCoordinates(lat, lon)
-
-	if err != nil {
-		t.Errorf("Error fetching daily weather: %v", err)
+	assert.NoError(t, err)
+	assert.Len(t, dailyWeather, 7)
+	for _, dw := range dailyWeather {
+		assert.False(t, dw.Date.IsZero())
 	}
+}
 
-	if dailyWeather == nil || len(dailyWeather) == 0 {
-		t.Error("Expected daily weather data, got nil or empty slice")
-	}
+func TestWeatherServiceErrors(t *testing.T) {
+	provider := NewFakeProvider()
+	service := NewWeatherService(provider)
 
-	for _, dw := range dailyWeather {
-		if dw.Date.IsZero() {
-			t.Error("Expected non-zero date for daily weather")
-		}
+	for _, coords := range [][2]float64{{91, 0}, {-91, 0}, {0, 181}, {0, -181}, {math.NaN(), 0}} {
+		_, err := service.GetCurrentWeatherByCoordinates(coords[0], coords[1])
+		assert.ErrorIs(t, err, ErrInvalidCoordinates)
+		assert.Equal(t, 400, ErrorCode(err))
 	}
-}
+	assert.Equal(t, 0, provider.Calls(), "invalid coordinates should not reach the provider")
 
+	provider.SetErr(&ProviderError{Provider: ProviderFake, StatusCode: 429, Err: ErrRateLimited})
+	_, err := service.GetDailyWeatherByCoordinates(-6.2088, 106.8456)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, 503, ErrorCode(err))
 
+	provider.SetErr(nil)
+	_, err = service.GetHourlyWeatherByCoordinates(-6.2088, 106.8456)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 500, ErrorCode(errors.New("boom")))
+}
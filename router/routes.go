@@ -5,45 +5,70 @@ import (
 
 	"github.com/OctavianoRyan25/be-agriculture/handler"
 	"github.com/OctavianoRyan25/be-agriculture/middlewares"
+	"github.com/OctavianoRyan25/be-agriculture/modules/account"
 	"github.com/OctavianoRyan25/be-agriculture/modules/admin"
 	"github.com/OctavianoRyan25/be-agriculture/modules/article"
+	"github.com/OctavianoRyan25/be-agriculture/modules/audit"
 	bot "github.com/OctavianoRyan25/be-agriculture/modules/chatbot"
+	"github.com/OctavianoRyan25/be-agriculture/modules/device"
 	"github.com/OctavianoRyan25/be-agriculture/modules/notification"
 	"github.com/OctavianoRyan25/be-agriculture/modules/search"
+	"github.com/OctavianoRyan25/be-agriculture/modules/session"
 	"github.com/OctavianoRyan25/be-agriculture/modules/user"
 	wateringhistory "github.com/OctavianoRyan25/be-agriculture/modules/watering_history"
 	"github.com/labstack/echo/v4"
 )
 
-func InitRoutes(e *echo.Echo, userController *user.UserController, adminController *admin.AdminController, plantCategoryHandler *handler.PlantCategoryHandler, plantHandler *handler.PlantHandler, plantUserHandler *handler.UserPlantHandler, weatherHandler *handler.WeatherHandler, plantInstructionCategoryHandler *handler.PlantInstructionCategoryHandler, plantProgressHandler *handler.PlantProgressHandler, search *search.SearchController, notification *notification.NotificationController, wateringhistory *wateringhistory.WateringHistoryController, fertilizer *handler.FertilizerHandler, aiFertilizer *handler.AIFertilizerRecommendationHandler, plantEarliestWateringHandler *handler.PlantEarliestWateringHandler, article *article.ArticleController) {
+func InitRoutes(e *echo.Echo, userController *user.UserController, adminController *admin.AdminController, sessionController *session.SessionController, deviceController *device.DeviceController, accountController *account.AccountController, plantCategoryHandler *handler.PlantCategoryHandler, plantHandler *handler.PlantHandler, plantUserHandler *handler.UserPlantHandler, weatherHandler *handler.WeatherHandler, plantInstructionCategoryHandler *handler.PlantInstructionCategoryHandler, plantProgressHandler *handler.PlantProgressHandler, search *search.SearchController, notification *notification.NotificationController, wateringhistory *wateringhistory.WateringHistoryController, fertilizer *handler.FertilizerHandler, aiFertilizer *handler.AIFertilizerRecommendationHandler, plantEarliestWateringHandler *handler.PlantEarliestWateringHandler, article *article.ArticleController, auditController *audit.AuditController, jwks *handler.JWKSHandler) {
+	e.GET("/.well-known/jwks.json", jwks.GetJWKS)
+
 	group := e.Group("/api/v1")
 	group.POST("/register", userController.RegisterUser)
 	group.POST("/check-email", userController.CheckEmail)
 	group.POST("/verify", userController.VerifyEmail)
 	group.POST("/login", userController.Login)
-	group.GET("/profile", userController.GetUserProfile, middlewares.Authentication())
+	group.GET("/profile", userController.GetUserProfile, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.PATCH("/profile", userController.UpdateProfile, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.PUT("/profile/avatar", userController.UpdateAvatar, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.PUT("/profile/timezone", userController.UpdateTimezone, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.PUT("/profile/location", userController.UpdateLocation, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.DELETE("/profile/location", userController.DeleteLocation, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.PUT("/profile/password", userController.ChangePassword, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/profile/email", userController.ChangeEmail, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/profile/email/verify", userController.VerifyEmailChange, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
 	group.POST("/resendotp", userController.ResendOTP)
-	group.POST("/forgot-password", userController.ResetPassword)
+	group.POST("/forgot-password", userController.ForgotPassword)
+	group.POST("/forgot-password/verify", userController.VerifyResetToken)
+	group.POST("/reset-password", userController.ResetPassword)
+	group.POST("/refresh", sessionController.Refresh)
+	group.POST("/logout", sessionController.Logout, middlewares.Authentication())
+	group.POST("/logout-all", sessionController.LogoutAll, middlewares.Authentication())
 
 	groupAdmin := e.Group("/api/v1/admin")
-	groupAdmin.POST("/register", adminController.RegisterUser)
 	groupAdmin.POST("/login", adminController.Login)
-	groupAdmin.GET("/profile", adminController.GetUserProfile, middlewares.Authentication())
+	groupAdmin.GET("/profile", adminController.GetUserProfile, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleAdmin))
+	groupAdmin.POST("/invitations", adminController.InviteAdmin, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageAdmins))
+	groupAdmin.POST("/invitations/verify", adminController.VerifyInvitation)
+	groupAdmin.POST("/invitations/accept", adminController.AcceptInvitation)
+	groupAdmin.GET("/admins", adminController.GetAdmins, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageAdmins))
+	groupAdmin.PUT("/admins/:id/deactivate", adminController.DeactivateAdmin, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageAdmins))
+	groupAdmin.PUT("/admins/:id/activate", adminController.ActivateAdmin, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageAdmins))
+	groupAdmin.GET("/audit-logs", auditController.GetLogs, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermViewAuditLog))
 
 	group.GET("/plants/categories", plantCategoryHandler.GetAll)
 	group.GET("/plants/categories/:id", plantCategoryHandler.GetByID)
-	groupAdmin.POST("/plants/categories", plantCategoryHandler.Create, middlewares.Authentication())
-	groupAdmin.PUT("/plants/categories/:id", plantCategoryHandler.Update, middlewares.Authentication())
-	groupAdmin.DELETE("/plants/categories/:id", plantCategoryHandler.Delete, middlewares.Authentication())
+	groupAdmin.POST("/plants/categories", plantCategoryHandler.Create, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
+	groupAdmin.PUT("/plants/categories/:id", plantCategoryHandler.Update, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
+	groupAdmin.DELETE("/plants/categories/:id", plantCategoryHandler.Delete, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
 
 	group.GET("/plants/progress/:plant_id", plantProgressHandler.GetAllByUserIDAndPlantID, middlewares.Authentication())
 	group.POST("/plants/progress", plantProgressHandler.UploadProgress, middlewares.Authentication())
 
 	group.GET("/plants/instructions/categories", plantInstructionCategoryHandler.GetAll)
 	group.GET("/plants/instructions/categories/:id", plantInstructionCategoryHandler.GetByID)
-	groupAdmin.POST("/plants/instructions/categories", plantInstructionCategoryHandler.Create, middlewares.Authentication())
-	groupAdmin.PUT("/plants/instructions/categories/:id", plantInstructionCategoryHandler.Update, middlewares.Authentication())
-	groupAdmin.DELETE("/plants/instructions/categories/:id", plantInstructionCategoryHandler.Delete, middlewares.Authentication())
+	groupAdmin.POST("/plants/instructions/categories", plantInstructionCategoryHandler.Create, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
+	groupAdmin.PUT("/plants/instructions/categories/:id", plantInstructionCategoryHandler.Update, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
+	groupAdmin.DELETE("/plants/instructions/categories/:id", plantInstructionCategoryHandler.Delete, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
 
 	group.GET("/plants", plantHandler.GetAll)
 	group.GET("/plants/:id", plantHandler.GetByID)
@@ -51,13 +76,14 @@ func InitRoutes(e *echo.Echo, userController *user.UserController, adminControll
 	group.GET("/plants/category/:category_id", plantHandler.GetPlantsByCategoryID)
 	group.GET("/plants/recommendations", plantHandler.GetRecommendations, middlewares.Authentication())
 	group.GET("/plants/instructions/:plant_id/:instruction_category_id", plantInstructionCategoryHandler.GetInstructionByCategoryID)
-	groupAdmin.POST("/plants", plantHandler.Create, middlewares.Authentication())
-	groupAdmin.PUT("/plants/:id", plantHandler.Update, middlewares.Authentication())
-	groupAdmin.DELETE("/plants/:id", plantHandler.Delete, middlewares.Authentication())
+	groupAdmin.POST("/plants", plantHandler.Create, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
+	groupAdmin.PUT("/plants/:id", plantHandler.Update, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
+	groupAdmin.DELETE("/plants/:id", plantHandler.Delete, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
 
 	group.GET("/my/plants/:user_id", plantUserHandler.GetUserPlants, middlewares.Authentication())
 	group.POST("/my/plants/add", plantUserHandler.AddUserPlant, middlewares.Authentication())
 	group.PUT("/my/plants/:userPlantID/customize-name", plantUserHandler.UpdateCustomizeName, middlewares.Authentication())
+	group.PUT("/my/plants/:userPlantID/outdoor", plantUserHandler.UpdateOutdoor, middlewares.Authentication())
 	group.DELETE("/my/plants/:user_plant_id", plantUserHandler.DeleteUserPlantByID, middlewares.Authentication())
 	group.POST("/my/plants/history", plantUserHandler.AddUserPlantHistory, middlewares.Authentication())
 	group.GET("/my/plants/history", plantUserHandler.GetUserPlantHistoryByUserID, middlewares.Authentication())
@@ -68,21 +94,52 @@ func InitRoutes(e *echo.Echo, userController *user.UserController, adminControll
 	group.GET("/weather/hourly", weatherHandler.GetHourlyWeather, middlewares.Authentication())
 	group.GET("/weather/daily", weatherHandler.GetDailyWeather, middlewares.Authentication())
 
-	group.GET("/notifications/:id", notification.ReadNotification, middlewares.Authentication())
-	group.GET("/notifications", notification.GetAllNotifications, middlewares.Authentication())
-	group.DELETE("/notifications", notification.DeleteAllNotifications, middlewares.Authentication())
+	group.GET("/notifications", notification.GetNotifications, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/notifications/unread-count", notification.CountUnreadNotifications, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/notifications/read-all", notification.MarkAllNotificationsRead, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/notifications/:id", notification.GetNotification, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/notifications/:id/read", notification.MarkNotificationRead, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.DELETE("/notifications/:id", notification.DeleteNotification, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/notifications/:id/restore", notification.RestoreNotification, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/notifications/:id/actions/:action", notification.ActOnNotification, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.DELETE("/notifications", notification.DeleteAllNotifications, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/notification-settings", notification.GetNotificationSettings, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.PUT("/notification-settings", notification.UpdateNotificationSettings, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+
+	group.GET("/devices", deviceController.GetDevices, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/devices", deviceController.RegisterDevice, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.DELETE("/devices", deviceController.UnregisterDevice, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+
+	group.POST("/account/exports", accountController.RequestExport, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/account/exports", accountController.GetExports, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/account/exports/:id/download", accountController.DownloadExport, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/account/deletion", accountController.RequestDeletion, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/account/deletion", accountController.GetDeletion, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.DELETE("/account/deletion", accountController.CancelDeletion, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
 
 	groupFertilizer := e.Group("/api/v1")
 	groupFertilizer.GET("/fertilizer", fertilizer.GetFertilizer)
 	groupFertilizer.GET("/fertilizer/:Id", fertilizer.GetFertilizerById)
-	groupFertilizer.POST("/fertilizer", fertilizer.CreateFertilizer, middlewares.Authentication())
-	groupFertilizer.PUT("/fertilizer/:Id", fertilizer.UpdateFertilizer, middlewares.Authentication())
-	groupFertilizer.DELETE("/fertilizer/:Id", fertilizer.DeleteFertilizer, middlewares.Authentication())
-
-	group.POST("/create-customize-watering-reminder", notification.CreateCustomizeWateringReminder, middlewares.Authentication())
-	group.POST("/watering-history", wateringhistory.StoreWateringHistory, middlewares.Authentication())
-	group.GET("/watering-history", wateringhistory.GetAllWateringHistories, middlewares.Authentication())
-	group.GET("/check-watering", wateringhistory.GetLateWateringHistories, middlewares.Authentication())
+	groupFertilizer.POST("/fertilizer", fertilizer.CreateFertilizer, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
+	groupFertilizer.PUT("/fertilizer/:Id", fertilizer.UpdateFertilizer, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
+	groupFertilizer.DELETE("/fertilizer/:Id", fertilizer.DeleteFertilizer, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageCatalog))
+
+	group.POST("/create-customize-watering-reminder", notification.CreateCustomizeWateringReminder, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/watering-reminders", notification.CreateCustomizeWateringReminder, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/watering-reminders", notification.GetCustomizeWateringReminders, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/watering-reminders/:id", notification.GetCustomizeWateringReminder, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.PUT("/watering-reminders/:id", notification.UpdateCustomizeWateringReminder, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.DELETE("/watering-reminders/:id", notification.DeleteCustomizeWateringReminder, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/watering-reminders/:id/pause", notification.PauseCustomizeWateringReminder, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/watering-reminders/:id/resume", notification.ResumeCustomizeWateringReminder, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.POST("/watering-history", wateringhistory.StoreWateringHistory, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/watering-history", wateringhistory.GetAllWateringHistories, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/watering-history/:id", wateringhistory.GetWateringHistory, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.PUT("/watering-history/:id", wateringhistory.UpdateWateringHistory, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.DELETE("/watering-history/:id", wateringhistory.DeleteWateringHistory, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/check-watering", wateringhistory.GetLateWateringHistories, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/watering-compliance", wateringhistory.GetComplianceSummary, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.GET("/watering-compliance/:plant_id", wateringhistory.GetPlantCompliance, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
 	group.GET("/watering-earliest", plantEarliestWateringHandler.GetEarliestWateringTime)
 
 	group.POST("/chatbot", bot.ClassifyEnvironmentalIssue)
@@ -91,21 +148,24 @@ func InitRoutes(e *echo.Echo, userController *user.UserController, adminControll
 
 	group.GET("/recommend-plants", aiFertilizer.GetPlantingRecommendation)
 
-	group.GET("/login-google", user.LoginGoogle)
+	group.GET("/login-google", userController.LoginGoogle)
+
+	group.GET("/auth/google/callback", userController.CallbackGoogle)
 
-	group.GET("/auth/google/callback", user.CallbackGoogle)
+	group.GET("/login-google-andro", userController.LoginGoogleforAndro)
 
-	group.GET("/login-google-andro", user.LoginGoogleforAndro)
+	group.GET("/auth-andro/google/callback", userController.CallbackGoogleforAndro)
 
-	group.GET("/auth-andro/google/callback", user.CallbackGoogleforAndro)
+	group.POST("/auth/google/link", userController.LinkGoogle, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
+	group.DELETE("/auth/google/link", userController.UnlinkGoogle, middlewares.Authentication(), middlewares.RequireRole(middlewares.RoleUser))
 
 	group.GET("/auth", user.GetToken)
 
-	group.POST("/article", article.StoreArticle, middlewares.Authentication())
+	group.POST("/article", article.StoreArticle, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageArticles))
 	group.GET("/article", article.GetAllArticles)
 	group.GET("/article/:id", article.GetArticle)
-	group.PUT("/article/:id", article.UpdateArticle, middlewares.Authentication())
-	group.DELETE("/article/:id", article.DeleteArticle, middlewares.Authentication())
+	group.PUT("/article/:id", article.UpdateArticle, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageArticles))
+	group.DELETE("/article/:id", article.DeleteArticle, middlewares.Authentication(), middlewares.RequirePermission(middlewares.PermManageArticles))
 
 	group.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "Hello, World!")
@@ -0,0 +1,89 @@
+// Package geohash rounds coordinates to geohash cells, so nearby locations
+// can share data fetched for their cell.
+package geohash
+
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Encode returns the geohash of a location with precision characters. Each
+// character narrows the cell, 5 characters are a cell of about 5 km.
+func Encode(lat, lon float64, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+
+	hash := make([]byte, 0, precision)
+	even := true
+	bit, ch := 0, 0
+	for len(hash) < precision {
+		// Bits alternate between longitude and latitude, longitude first.
+		if even {
+			mid := (minLon + maxLon) / 2
+			if lon >= mid {
+				ch = ch<<1 | 1
+				minLon = mid
+			} else {
+				ch <<= 1
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch <<= 1
+				maxLat = mid
+			}
+		}
+		even = !even
+
+		bit++
+		if bit == 5 {
+			hash = append(hash, base32[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// Center returns the location at the center of the cell of hash. Characters
+// that are not geohash characters are ignored.
+func Center(hash string) (float64, float64) {
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+
+	even := true
+	for i := 0; i < len(hash); i++ {
+		ch := indexOf(hash[i])
+		if ch < 0 {
+			continue
+		}
+		for mask := 16; mask > 0; mask >>= 1 {
+			if even {
+				mid := (minLon + maxLon) / 2
+				if ch&mask != 0 {
+					minLon = mid
+				} else {
+					maxLon = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if ch&mask != 0 {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return (minLat + maxLat) / 2, (minLon + maxLon) / 2
+}
+
+func indexOf(c byte) int {
+	for i := 0; i < len(base32); i++ {
+		if base32[i] == c {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,44 @@
+package geohash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		lat, lon  float64
+		precision int
+		hash      string
+	}{
+		{57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{-6.2088, 106.8456, 5, "qqgux"},
+		{-90, -180, 3, "000"},
+		{90, 180, 3, "zzz"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.hash, Encode(tt.lat, tt.lon, tt.precision))
+	}
+
+	// Two places in central Jakarta share a cell, Bogor does not.
+	assert.Equal(t, Encode(-6.2088, 106.8456, 5), Encode(-6.2150, 106.8400, 5))
+	assert.NotEqual(t, Encode(-6.2088, 106.8456, 5), Encode(-6.5950, 106.8166, 5))
+}
+
+func TestCenter(t *testing.T) {
+	lat, lon := Center("u4pruydqqvj")
+	assert.InDelta(t, 57.64911, lat, 0.0001)
+	assert.InDelta(t, 10.40744, lon, 0.0001)
+
+	// The center of a cell is in the cell.
+	hash := Encode(-6.2088, 106.8456, 5)
+	lat, lon = Center(hash)
+	assert.Equal(t, hash, Encode(lat, lon, 5))
+	assert.InDelta(t, -6.2088, lat, 0.03)
+	assert.InDelta(t, 106.8456, lon, 0.03)
+
+	lat, lon = Center("")
+	assert.Equal(t, 0.0, lat)
+	assert.Equal(t, 0.0, lon)
+}
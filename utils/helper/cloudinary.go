@@ -0,0 +1,42 @@
+package helper
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// CloudinaryPublicID returns the public ID of a Cloudinary delivery URL like
+// https://res.cloudinary.com/<cloud>/image/upload/v1716634951/be-agriculture/abc.jpg,
+// which is needed to delete the image. Images hosted elsewhere, such as
+// Google profile pictures, have none.
+func CloudinaryPublicID(imageURL string) (string, bool) {
+	u, err := url.Parse(imageURL)
+	if err != nil || u.Host != "res.cloudinary.com" {
+		return "", false
+	}
+	_, rest, ok := strings.Cut(u.Path, "/upload/")
+	if !ok {
+		return "", false
+	}
+
+	parts := strings.Split(rest, "/")
+	if len(parts) > 1 && isVersion(parts[0]) {
+		parts = parts[1:]
+	}
+	id := strings.Join(parts, "/")
+	id = strings.TrimSuffix(id, path.Ext(id))
+	return id, id != ""
+}
+
+func isVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
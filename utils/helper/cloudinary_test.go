@@ -0,0 +1,20 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudinaryPublicID(t *testing.T) {
+	id, ok := CloudinaryPublicID("https://res.cloudinary.com/demo/image/upload/v1716634951/be-agriculture/avatars/user-1.png")
+	assert.True(t, ok)
+	assert.Equal(t, "be-agriculture/avatars/user-1", id)
+
+	id, ok = CloudinaryPublicID("https://res.cloudinary.com/demo/image/upload/be-agriculture/watering/abc.webp")
+	assert.True(t, ok)
+	assert.Equal(t, "be-agriculture/watering/abc", id)
+
+	_, ok = CloudinaryPublicID("https://lh3.googleusercontent.com/a/photo.jpg")
+	assert.False(t, ok)
+}
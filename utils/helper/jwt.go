@@ -3,23 +3,35 @@ package helper
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/OctavianoRyan25/lapor-lingkungan-hidup/constants"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
-var secretKey = []byte("Rahasia")
+// AccessTokenTTL is how long an access token is accepted. Clients use their
+// refresh token to get a new one.
+const AccessTokenTTL = 15 * time.Minute
 
-func GenerateToken(id uint, email string, role string) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+func GenerateToken(id uint, email string, role string, sessionID string) (string, error) {
+	if keyRing == nil {
+		return "", errors.New("JWT keys are not loaded")
+	}
+
+	now := time.Now()
+	token := jwt.New(keyRing.signing.Method)
+	token.Header["kid"] = keyRing.signing.ID
 	claims := token.Claims.(jwt.MapClaims)
 	claims["id"] = id
 	claims["email"] = email
 	claims["role"] = role
+	claims["sid"] = sessionID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(AccessTokenTTL).Unix()
 
 	// Membuat token JWT dengan klaim yang diberikan
-	signedToken, err := token.SignedString(secretKey)
+	signedToken, err := token.SignedString(keyRing.signing.PrivateKey)
 	if err != nil {
 		return "", err
 	}
@@ -37,13 +49,18 @@ func VerifyToken(c echo.Context) (jwt.MapClaims, error) {
 		return nil, errResponse
 	}
 
+	if keyRing == nil {
+		return nil, errResponse
+	}
+
 	stringToken := strings.Split(headerToken, " ")[1]
 	token, err := jwt.Parse(stringToken, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		key, err := keyRing.lookup(t)
+		if err != nil {
 			return nil, errResponse
 		}
-		return secretKey, nil
-	})
+		return key.PublicKey, nil
+	}, jwt.WithExpirationRequired())
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,243 @@
+package helper
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is one entry of the key ring. Keys without a private part are
+// only used to verify tokens signed before a rotation.
+type signingKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey interface{}
+	PublicKey  interface{}
+}
+
+// KeyRing holds the key used to sign new tokens and every key that is still
+// accepted when verifying them, indexed by the "kid" header.
+type KeyRing struct {
+	signing *signingKey
+	keys    map[string]*signingKey
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+var keyRing *KeyRing
+
+// SetKeyRing replaces the keys used by GenerateToken and VerifyToken.
+func SetKeyRing(ring *KeyRing) {
+	keyRing = ring
+}
+
+// NewHMACKeyRing returns a key ring signing with a single HS256 secret.
+func NewHMACKeyRing(kid string, secret []byte) *KeyRing {
+	key := &signingKey{ID: kid, Method: jwt.SigningMethodHS256, PrivateKey: secret, PublicKey: secret}
+	return &KeyRing{signing: key, keys: map[string]*signingKey{kid: key}}
+}
+
+// LoadKeyRing reads the JWT keys from the environment:
+//
+//	JWT_KEYS_DIR    directory of PEM files named <kid>.pem (RSA or Ed25519
+//	                private key) or <kid>.pub.pem (public key of a retired key)
+//	JWT_KEYS        comma separated kid:secret pairs of HS256 secrets
+//	JWT_SECRET      single HS256 secret, used with kid "default"
+//	JWT_SIGNING_KID kid used to sign new tokens, required with several keys
+//
+// Every loaded key is accepted when verifying, so a new key can be introduced
+// with JWT_SIGNING_KID while tokens signed by the previous key stay valid
+// until they expire.
+func LoadKeyRing() (*KeyRing, error) {
+	ring := &KeyRing{keys: map[string]*signingKey{}}
+
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		err := ring.loadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pairs := os.Getenv("JWT_KEYS"); pairs != "" {
+		for _, pair := range strings.Split(pairs, ",") {
+			kid, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || kid == "" || secret == "" {
+				return nil, fmt.Errorf("invalid JWT_KEYS entry %q", pair)
+			}
+			ring.add(&signingKey{ID: kid, Method: jwt.SigningMethodHS256, PrivateKey: []byte(secret), PublicKey: []byte(secret)})
+		}
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		ring.add(&signingKey{ID: "default", Method: jwt.SigningMethodHS256, PrivateKey: []byte(secret), PublicKey: []byte(secret)})
+	}
+
+	if len(ring.keys) == 0 {
+		return nil, errors.New("no JWT keys configured, set JWT_SECRET, JWT_KEYS or JWT_KEYS_DIR")
+	}
+
+	kid := os.Getenv("JWT_SIGNING_KID")
+	if kid == "" {
+		if len(ring.keys) > 1 {
+			return nil, errors.New("JWT_SIGNING_KID is required when several JWT keys are configured")
+		}
+		for id := range ring.keys {
+			kid = id
+		}
+	}
+	key, ok := ring.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("JWT signing key %q not found", kid)
+	}
+	if key.PrivateKey == nil {
+		return nil, fmt.Errorf("JWT signing key %q has no private key", kid)
+	}
+	ring.signing = key
+
+	return ring, nil
+}
+
+// JWKS returns the public keys partner services can use to verify our
+// tokens. HMAC secrets are never published.
+func (r *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	ids := make([]string, 0, len(r.keys))
+	for id := range r.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		key := r.keys[id]
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+
+	return set
+}
+
+func (r *KeyRing) add(key *signingKey) {
+	r.keys[key.ID] = key
+}
+
+func (r *KeyRing) lookup(t *jwt.Token) (*signingKey, error) {
+	key := r.signing
+	if kid, ok := t.Header["kid"].(string); ok {
+		key, ok = r.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+	return key, nil
+}
+
+func (r *KeyRing) loadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return fmt.Errorf("%s: no PEM block found", file)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(file), ".pem")
+		if kid, ok := strings.CutSuffix(name, ".pub"); ok {
+			pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+			key, err := newAsymmetricKey(kid, nil, pub)
+			if err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+			r.add(key)
+			continue
+		}
+
+		priv, err := parsePrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		key, err := newAsymmetricKey(name, priv, priv.(crypto.Signer).Public())
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		r.add(key)
+	}
+
+	return nil
+}
+
+func parsePrivateKey(der []byte) (interface{}, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	return x509.ParsePKCS1PrivateKey(der)
+}
+
+func newAsymmetricKey(kid string, priv, pub interface{}) (*signingKey, error) {
+	key := &signingKey{ID: kid, PrivateKey: priv, PublicKey: pub}
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", pub)
+	}
+	return key, nil
+}
@@ -0,0 +1,56 @@
+package helper
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func verify(token string) (jwt.MapClaims, error) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return VerifyToken(echo.New().NewContext(req, httptest.NewRecorder()))
+}
+
+func TestVerifyTokenAfterRotation(t *testing.T) {
+	old := NewHMACKeyRing("old", []byte("old-secret"))
+	SetKeyRing(old)
+	token, err := GenerateToken(1, "user@example.com", "user", "sid")
+	assert.NoError(t, err)
+
+	rotated := NewHMACKeyRing("new", []byte("new-secret"))
+	rotated.add(old.keys["old"])
+	SetKeyRing(rotated)
+
+	claims, err := verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "sid", claims["sid"])
+
+	SetKeyRing(NewHMACKeyRing("new", []byte("new-secret")))
+	_, err = verify(token)
+	assert.Error(t, err)
+}
+
+func TestEdDSAKeyIsPublished(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	key, err := newAsymmetricKey("ed", priv, pub)
+	assert.NoError(t, err)
+	SetKeyRing(&KeyRing{signing: key, keys: map[string]*signingKey{"ed": key}})
+
+	token, err := GenerateToken(1, "user@example.com", "user", "sid")
+	assert.NoError(t, err)
+	_, err = verify(token)
+	assert.NoError(t, err)
+
+	set := keyRing.JWKS()
+	assert.Len(t, set.Keys, 1)
+	assert.Equal(t, "EdDSA", set.Keys[0].Alg)
+	assert.Equal(t, "ed", set.Keys[0].Kid)
+}
@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/gomail.v2"
+)
+
+var (
+	EMAIL_FROM    = os.Getenv("EMAIL_FROM")
+	SMTP_HOST     = os.Getenv("SMTP_HOST")
+	SMTP_USER     = os.Getenv("SMTP_USER")
+	SMTP_PASSWORD = os.Getenv("SMTP_PASS")
+)
+
+// TemplateDir returns the directory with the html email templates of a
+// module. It is modules/<module>/template below the working directory when
+// that exists, like when running from the repository, and the copy in /app
+// of the release image otherwise.
+func TemplateDir(module string) string {
+	dir := filepath.Join("modules", module, "template")
+	if _, err := os.Stat(dir); err == nil {
+		return dir
+	}
+	return filepath.Join("/app", dir)
+}
+
+// SendTemplateEmail renders the html template templateName of dir with data
+// and sends it to the given address.
+func SendTemplateEmail(dir, to, subject, templateName string, data interface{}) error {
+	tmpl, err := template.ParseFiles(filepath.Join(dir, templateName))
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	err = tmpl.Execute(&body, data)
+	if err != nil {
+		return err
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", EMAIL_FROM)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/html", body.String())
+	d := gomail.NewDialer(SMTP_HOST, 587, SMTP_USER, SMTP_PASSWORD)
+
+	return d.DialAndSend(m)
+}
@@ -0,0 +1,25 @@
+package helper
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// RandomToken returns a 64 character hex token suitable for links sent by
+// email.
+func RandomToken() string {
+	var b [32]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// HashToken returns the value stored in the database for a token, so a leaked
+// table cannot be used to reset passwords or accept invitations.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,332 @@
+// Package rrule implements the part of iCalendar recurrence rules (RFC 5545)
+// that reminders use: FREQ=DAILY, WEEKLY or MONTHLY with INTERVAL, BYDAY,
+// BYMONTHDAY, COUNT and UNTIL. Occurrences happen at the time of day of the
+// start, in the location of the start.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// maxPeriods bounds the search for the next occurrence of rules that never
+// match, like FREQ=DAILY;INTERVAL=7;BYDAY=MO starting on a Tuesday.
+const maxPeriods = 10000
+
+var dayNames = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Count      int
+	// Until is the last moment an occurrence may happen, or zero. A date or
+	// a local time without "Z" is read as a wall clock time in the location
+	// of the start.
+	Until         time.Time
+	untilFloating bool
+}
+
+// Parse reads a rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH". A leading
+// "RRULE:" is allowed.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+	if s == "" {
+		return nil, errors.New("rrule: empty rule")
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("rrule: invalid part %q", part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("rrule: %s given twice", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			rule.Freq = Frequency(value)
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly {
+				err = fmt.Errorf("rrule: unsupported FREQ %s", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = parseInt(key, value, 1, 999)
+		case "COUNT":
+			rule.Count, err = parseInt(key, value, 1, 10000)
+		case "UNTIL":
+			err = rule.parseUntil(value)
+		case "BYDAY":
+			err = rule.parseByDay(value)
+		case "BYMONTHDAY":
+			err = rule.parseByMonthDay(value)
+		case "WKST":
+			if value != "MO" {
+				err = errors.New("rrule: only WKST=MO is supported")
+			}
+		default:
+			err = fmt.Errorf("rrule: unsupported part %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("rrule: FREQ is required")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, errors.New("rrule: COUNT and UNTIL cannot be combined")
+	}
+	if rule.Freq == Weekly && len(rule.ByMonthDay) > 0 {
+		return nil, errors.New("rrule: BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	return rule, nil
+}
+
+func parseInt(key, value string, lo, hi int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("rrule: %s must be between %d and %d", key, lo, hi)
+	}
+	return n, nil
+}
+
+func (r *Rule) parseUntil(value string) error {
+	layouts := []struct {
+		layout   string
+		floating bool
+	}{
+		{"20060102T150405Z", false},
+		{"20060102T150405", true},
+		{"20060102", true},
+	}
+	for _, l := range layouts {
+		until, err := time.Parse(l.layout, value)
+		if err != nil {
+			continue
+		}
+		if len(value) == len("20060102") {
+			until = until.Add(24*time.Hour - time.Second)
+		}
+		r.Until, r.untilFloating = until, l.floating
+		return nil
+	}
+	return fmt.Errorf("rrule: invalid UNTIL %s", value)
+}
+
+func (r *Rule) parseByDay(value string) error {
+	seen := map[time.Weekday]bool{}
+	for _, name := range strings.Split(value, ",") {
+		day, ok := dayNames[name]
+		if !ok {
+			return fmt.Errorf("rrule: invalid BYDAY %s", name)
+		}
+		if !seen[day] {
+			seen[day] = true
+			r.ByDay = append(r.ByDay, day)
+		}
+	}
+	sort.Slice(r.ByDay, func(i, j int) bool { return weekOffset(r.ByDay[i]) < weekOffset(r.ByDay[j]) })
+	return nil
+}
+
+func (r *Rule) parseByMonthDay(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(v)
+		if err != nil || day == 0 || day < -31 || day > 31 {
+			return fmt.Errorf("rrule: invalid BYMONTHDAY %s", v)
+		}
+		r.ByMonthDay = append(r.ByMonthDay, day)
+	}
+	return nil
+}
+
+// String returns the rule in canonical form.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		names := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			names = append(names, strings.ToUpper(day.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(names, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, day := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(day))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if r.untilFloating {
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405"))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence of the rule after the given time, for
+// a series that starts at start. It reports false when the series has
+// ended.
+func (r *Rule) Next(start, after time.Time) (time.Time, bool) {
+	loc := start.Location()
+	until := r.Until
+	if r.untilFloating {
+		until = time.Date(until.Year(), until.Month(), until.Day(), until.Hour(), until.Minute(), until.Second(), 0, loc)
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	// Without COUNT earlier periods don't matter, so skip right to the one
+	// before after.
+	period := 0
+	if r.Count == 0 && after.After(start) {
+		period = max(0, r.periodsBetween(start, after.In(loc))/interval-1)
+	}
+
+	seen := 0
+	for end := period + maxPeriods; period < end; period++ {
+		for _, day := range r.days(start, period*interval) {
+			t := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), 0, loc)
+			if t.Before(start) {
+				continue
+			}
+			seen++
+			if r.Count > 0 && seen > r.Count {
+				return time.Time{}, false
+			}
+			if !until.IsZero() && t.After(until) {
+				return time.Time{}, false
+			}
+			if t.After(after) {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// periodsBetween counts the whole days, weeks or months from start to t.
+func (r *Rule) periodsBetween(start, t time.Time) int {
+	switch r.Freq {
+	case Weekly:
+		return int((civilDay(t) - int64(weekOffset(t.Weekday())) - civilDay(start) + int64(weekOffset(start.Weekday()))) / 7)
+	case Monthly:
+		return (t.Year()-start.Year())*12 + int(t.Month()) - int(start.Month())
+	}
+	return int(civilDay(t) - civilDay(start))
+}
+
+// days returns the candidate days, in order, of the period that starts
+// offset days, weeks or months after the period of start.
+func (r *Rule) days(start time.Time, offset int) []time.Time {
+	loc := start.Location()
+	switch r.Freq {
+	case Weekly:
+		monday := time.Date(start.Year(), start.Month(), start.Day()-weekOffset(start.Weekday())+7*offset, 0, 0, 0, 0, loc)
+		weekdays := r.ByDay
+		if len(weekdays) == 0 {
+			weekdays = []time.Weekday{start.Weekday()}
+		}
+		days := make([]time.Time, 0, len(weekdays))
+		for _, day := range weekdays {
+			days = append(days, monday.AddDate(0, 0, weekOffset(day)))
+		}
+		return days
+	case Monthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(offset), 1, 0, 0, 0, 0, loc)
+		last := first.AddDate(0, 1, -1).Day()
+		var days []time.Time
+		for d := 1; d <= last; d++ {
+			day := first.AddDate(0, 0, d-1)
+			if r.matchesMonthDay(d, last, start.Day()) && r.matchesWeekday(day.Weekday()) {
+				days = append(days, day)
+			}
+		}
+		return days
+	}
+	day := time.Date(start.Year(), start.Month(), start.Day()+offset, 0, 0, 0, 0, loc)
+	if !r.matchesWeekday(day.Weekday()) {
+		return nil
+	}
+	return []time.Time{day}
+}
+
+// matchesMonthDay reports whether day d of a month with last days is part of
+// a monthly rule. Without BYMONTHDAY and BYDAY the rule repeats on the day of
+// the month of the start and skips months that don't have it.
+func (r *Rule) matchesMonthDay(d, last, startDay int) bool {
+	if len(r.ByMonthDay) == 0 {
+		return len(r.ByDay) > 0 || d == startDay
+	}
+	for _, day := range r.ByMonthDay {
+		if day == d || (day < 0 && last+day+1 == d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(day time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// weekOffset numbers the days of a week that starts on Monday.
+func weekOffset(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+// civilDay numbers the calendar day of t, ignoring its time zone offset.
+func civilDay(t time.Time) int64 {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+}
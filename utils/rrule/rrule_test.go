@@ -0,0 +1,114 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var jakarta, _ = time.LoadLocation("Asia/Jakarta")
+
+func at(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, jakarta)
+}
+
+// occurrences lists the first n occurrences of rule after start.
+func occurrences(t *testing.T, rule string, start time.Time, n int) []time.Time {
+	r, err := Parse(rule)
+	assert.NoError(t, err)
+
+	var res []time.Time
+	after := start.Add(-time.Second)
+	for i := 0; i < n; i++ {
+		next, ok := r.Next(start, after)
+		if !ok {
+			break
+		}
+		res = append(res, next)
+		after = next
+	}
+	return res
+}
+
+func TestParse(t *testing.T) {
+	r, err := Parse("rrule:freq=weekly;byday=TH,MO;interval=2")
+	assert.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", r.String())
+
+	r, err = Parse("FREQ=MONTHLY;BYMONTHDAY=1,-1;UNTIL=20240630")
+	assert.NoError(t, err)
+	assert.Equal(t, "FREQ=MONTHLY;BYMONTHDAY=1,-1;UNTIL=20240630T235959", r.String())
+
+	for _, invalid := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;BYDAY=1MO",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=WEEKLY;BYMONTHDAY=3",
+		"FREQ=DAILY;BYHOUR=7",
+		"FREQ=DAILY;FREQ=WEEKLY",
+	} {
+		_, err := Parse(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestNextDaily(t *testing.T) {
+	start := at(2024, time.January, 30, 7, 0)
+
+	assert.Equal(t, []time.Time{
+		at(2024, time.January, 30, 7, 0),
+		at(2024, time.February, 2, 7, 0),
+		at(2024, time.February, 5, 7, 0),
+	}, occurrences(t, "FREQ=DAILY;INTERVAL=3", start, 3))
+
+	r, _ := Parse("FREQ=DAILY;INTERVAL=3")
+	next, ok := r.Next(start, at(2025, time.January, 1, 12, 0))
+	assert.True(t, ok)
+	assert.Equal(t, at(2025, time.January, 3, 7, 0), next)
+}
+
+func TestNextWeekly(t *testing.T) {
+	// 3 January 2024 is a Wednesday.
+	start := at(2024, time.January, 3, 18, 30)
+
+	assert.Equal(t, []time.Time{
+		at(2024, time.January, 4, 18, 30),
+		at(2024, time.January, 15, 18, 30),
+		at(2024, time.January, 18, 18, 30),
+		at(2024, time.January, 29, 18, 30),
+	}, occurrences(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", start, 4))
+
+	assert.Equal(t, []time.Time{
+		at(2024, time.January, 3, 18, 30),
+		at(2024, time.January, 10, 18, 30),
+	}, occurrences(t, "FREQ=WEEKLY", start, 2))
+}
+
+func TestNextMonthly(t *testing.T) {
+	start := at(2024, time.January, 31, 8, 0)
+
+	assert.Equal(t, []time.Time{
+		at(2024, time.January, 31, 8, 0),
+		at(2024, time.March, 31, 8, 0),
+		at(2024, time.May, 31, 8, 0),
+	}, occurrences(t, "FREQ=MONTHLY", start, 3))
+
+	assert.Equal(t, []time.Time{
+		at(2024, time.January, 31, 8, 0),
+		at(2024, time.February, 1, 8, 0),
+		at(2024, time.February, 29, 8, 0),
+	}, occurrences(t, "FREQ=MONTHLY;BYMONTHDAY=1,-1", start, 3))
+}
+
+func TestNextEnds(t *testing.T) {
+	start := at(2024, time.January, 1, 7, 0)
+
+	assert.Len(t, occurrences(t, "FREQ=DAILY;COUNT=3", start, 10), 3)
+	assert.Len(t, occurrences(t, "FREQ=DAILY;UNTIL=20240105", start, 10), 5)
+	assert.Len(t, occurrences(t, "FREQ=DAILY;UNTIL=20240104T235959Z", start, 10), 4)
+	assert.Empty(t, occurrences(t, "FREQ=DAILY;INTERVAL=7;BYDAY=TU", start, 1))
+}
@@ -0,0 +1,62 @@
+// Package timezone resolves the IANA time zones users are reminded in.
+package timezone
+
+import (
+	"errors"
+	"time"
+)
+
+// Default is the zone of users who have not set one.
+const Default = "Asia/Jakarta"
+
+// Load returns the location of an IANA zone name like "Asia/Makassar". Unlike
+// time.LoadLocation it rejects "" and "Local", which depend on the server.
+func Load(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, errors.New("timezone: " + name + " is not an IANA time zone")
+	}
+	return time.LoadLocation(name)
+}
+
+// Location returns the location of name, or of Default when name is empty or
+// unknown.
+func Location(name string) *time.Location {
+	if loc, err := Load(name); err == nil {
+		return loc
+	}
+	loc, err := time.LoadLocation(Default)
+	if err != nil {
+		return time.FixedZone("WIB", 7*60*60)
+	}
+	return loc
+}
+
+// box is an area of one time zone, in degrees.
+type box struct {
+	zone           string
+	minLat, maxLat float64
+	minLon, maxLon float64
+}
+
+// areas roughly outline the time zones of Indonesia and are checked in order.
+// The borders follow the provinces only approximately, so places close to
+// them may get the zone of their neighbour.
+var areas = []box{
+	// Sangihe and Talaud are part of North Sulawesi.
+	{"Asia/Makassar", 2, 5, 124, 127.2},
+	{"Asia/Dili", -9.5, -8.1, 124.9, 127.4},
+	{"Asia/Jayapura", -11, 6.5, 126, 141.1},
+	{"Asia/Makassar", -11, 6.5, 114.5, 126},
+	{"Asia/Jakarta", -11, 6.5, 94.5, 114.5},
+}
+
+// FromCoordinates guesses the zone of a place in Indonesia. It returns ""
+// for places outside Indonesia, where the zone cannot be guessed this way.
+func FromCoordinates(lat, lon float64) string {
+	for _, a := range areas {
+		if lat >= a.minLat && lat <= a.maxLat && lon >= a.minLon && lon < a.maxLon {
+			return a.zone
+		}
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package timezone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	loc, err := Load("Asia/Makassar")
+	assert.NoError(t, err)
+	assert.Equal(t, "Asia/Makassar", loc.String())
+
+	for _, invalid := range []string{"", "Local", "Asia/Bandung", "+07:00"} {
+		_, err := Load(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	assert.Equal(t, Default, Location("").String())
+	assert.Equal(t, Default, Location("Asia/Bandung").String())
+}
+
+func TestFromCoordinates(t *testing.T) {
+	tests := []struct {
+		place    string
+		lat, lon float64
+		zone     string
+	}{
+		{"Jakarta", -6.2, 106.8, "Asia/Jakarta"},
+		{"Medan", 3.6, 98.7, "Asia/Jakarta"},
+		{"Pontianak", -0.03, 109.3, "Asia/Jakarta"},
+		{"Denpasar", -8.65, 115.2, "Asia/Makassar"},
+		{"Makassar", -5.15, 119.4, "Asia/Makassar"},
+		{"Balikpapan", -1.27, 116.8, "Asia/Makassar"},
+		{"Manado", 1.47, 124.8, "Asia/Makassar"},
+		{"Melonguane", 4.0, 126.7, "Asia/Makassar"},
+		{"Ambon", -3.7, 128.2, "Asia/Jayapura"},
+		{"Jayapura", -2.5, 140.7, "Asia/Jayapura"},
+		{"Dili", -8.55, 125.6, "Asia/Dili"},
+		{"Tokyo", 35.7, 139.7, ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.zone, FromCoordinates(tt.lat, tt.lon), tt.place)
+	}
+}